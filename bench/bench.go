@@ -0,0 +1,377 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package bench measures the speed of a sim, by running a fixed number of
+trials with each of several different allocations of layers to threads
+(layouts), and reporting the time per cycle, trials per second and memory
+allocations per trial, along with the time taken by each layer.
+
+The layouts are:
+  - Sim: the thread allocation configured by the sim (e.g., hip puts DG, CA3
+    and CA1 on separate threads).
+  - Single: all layers on one thread.
+  - Balanced: for each of the Threads numbers, the layers are divided among
+    that many threads to balance their estimated computational cost.
+  - PerLayer: each layer on its own thread, which provides the time taken
+    by each layer, in the Layers table.
+
+The results are added to the Results table, which is also appended to File
+(if set) so that performance can be tracked over time.
+
+In a sim, RunSim runs the benchmark as its Benchmark method, with the
+network view off, and AddAction and MethodProps add the Benchmark action
+to its toolbar and SimProps.
+*/
+package bench
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emer/emergent/timer"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+)
+
+// Bench has the parameters and results of the benchmark
+type Bench struct {
+	Sim     string        `desc:"name of the sim, recorded in the results"`
+	NTrials int           `min:"1" desc:"number of trials to run for each thread layout"`
+	Threads []int         `desc:"numbers of threads for the Balanced layouts"`
+	File    string        `desc:"file that the results are appended to -- none if empty"`
+	Results *etable.Table `view:"no-inline" desc:"results for each thread layout"`
+	Layers  *etable.Table `view:"no-inline" desc:"time taken by each layer, from the PerLayer layout"`
+}
+
+// Defaults sets default parameters for given sim name
+func (bm *Bench) Defaults(sim string) {
+	bm.Sim = sim
+	bm.NTrials = 100
+	bm.Threads = []int{2, 4}
+	bm.File = sim + "_bench.tsv"
+}
+
+// AddFlags adds the command-line flags for the benchmark:
+// -bench N runs the benchmark with N trials (defaulting to the current
+// NTrials), and -benchthreads and -benchfile set the Threads and File.
+// Use Flagged after parsing to check if -bench was given.
+func (bm *Bench) AddFlags() {
+	flag.IntVar(&bm.NTrials, "bench", bm.NTrials, "run a benchmark with this many trials for each thread layout, instead of training")
+	flag.Func("benchthreads", "comma-separated numbers of threads for the balanced thread layouts in the benchmark (default 2,4)", func(s string) error {
+		var thr []int
+		for _, ts := range strings.Split(s, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(ts))
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of threads: %s", ts)
+			}
+			thr = append(thr, n)
+		}
+		bm.Threads = thr
+		return nil
+	})
+	flag.StringVar(&bm.File, "benchfile", bm.File, "file to append benchmark results to")
+}
+
+// Flagged returns true if the -bench flag was given on the command line,
+// after the flags have been parsed (e.g., with flag.Parse).
+func Flagged() bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "bench" {
+			set = true
+		}
+	})
+	return set
+}
+
+// HasFlag returns true if the given command-line args, before they are
+// parsed, include the -bench flag, or any of the other given flags (names
+// without the dash) -- for the sims that only run without the gui for the
+// benchmark and a few other flags, so that any other args (e.g., added by
+// the OS) still open the gui.
+func HasFlag(args []string, others ...string) bool {
+	names := append([]string{"bench"}, others...)
+	for _, a := range args {
+		if a == "--" {
+			break
+		}
+		if !strings.HasPrefix(a, "-") {
+			continue
+		}
+		a = strings.TrimPrefix(strings.TrimPrefix(a, "-"), "-")
+		for _, nm := range names {
+			if a == nm || strings.HasPrefix(a, nm+"=") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ConfigResults configures the Results table
+func (bm *Bench) ConfigResults() {
+	dt := &etable.Table{}
+	dt.SetMetaData("name", "BenchResults")
+	dt.SetMetaData("desc", "benchmark results for each thread layout")
+	dt.SetMetaData("precision", "4")
+	dt.SetFromSchema(etable.Schema{
+		{"Date", etensor.STRING, nil, nil},
+		{"Sim", etensor.STRING, nil, nil},
+		{"Layout", etensor.STRING, nil, nil},
+		{"NThreads", etensor.INT64, nil, nil},
+		{"Trials", etensor.INT64, nil, nil},
+		{"Cycles", etensor.INT64, nil, nil},
+		{"Secs", etensor.FLOAT64, nil, nil},
+		{"NsPerCycle", etensor.FLOAT64, nil, nil},
+		{"TrialsPerSec", etensor.FLOAT64, nil, nil},
+		{"AllocsPerTrial", etensor.FLOAT64, nil, nil},
+		{"KBPerTrial", etensor.FLOAT64, nil, nil},
+	}, 0)
+	bm.Results = dt
+}
+
+// ConfigLayers configures the Layers table
+func (bm *Bench) ConfigLayers() {
+	dt := &etable.Table{}
+	dt.SetMetaData("name", "BenchLayers")
+	dt.SetMetaData("desc", "time taken by each layer")
+	dt.SetMetaData("precision", "4")
+	dt.SetFromSchema(etable.Schema{
+		{"Layer", etensor.STRING, nil, nil},
+		{"Neurons", etensor.INT64, nil, nil},
+		{"Syns", etensor.INT64, nil, nil},
+		{"Secs", etensor.FLOAT64, nil, nil},
+		{"Pct", etensor.FLOAT64, nil, nil},
+	}, 0)
+	bm.Layers = dt
+}
+
+// Threads returns the current thread for each layer in the network
+func Threads(net *leabra.Network) []int {
+	thr := make([]int, len(net.Layers))
+	for li, lyi := range net.Layers {
+		thr[li] = lyi.(leabra.LeabraLayer).AsLeabra().Thr
+	}
+	return thr
+}
+
+// SetThreads sets the thread for each layer in the network,
+// and restarts the threads
+func SetThreads(net *leabra.Network, thr []int) {
+	for li, lyi := range net.Layers {
+		lyi.(leabra.LeabraLayer).AsLeabra().SetThread(thr[li])
+	}
+	net.StopThreads()
+	net.BuildThreads()
+	net.StartThreads()
+}
+
+// Balanced returns a thread for each layer that divides the layers among
+// nThreads threads, balancing their estimated computational cost:
+// the most costly remaining layer goes to the least loaded thread.
+// Layers that are off are put on thread 0.
+func Balanced(net *leabra.Network, nThreads int) []int {
+	type lc struct {
+		li   int
+		cost int
+	}
+	var lcs []lc
+	for li, lyi := range net.Layers {
+		if lyi.IsOff() {
+			continue
+		}
+		_, _, tot := lyi.(leabra.LeabraLayer).AsLeabra().CostEst()
+		lcs = append(lcs, lc{li, tot})
+	}
+	sort.SliceStable(lcs, func(i, j int) bool { return lcs[i].cost > lcs[j].cost })
+	thr := make([]int, len(net.Layers))
+	load := make([]int, nThreads)
+	for _, l := range lcs {
+		mt := 0
+		for t := range load {
+			if load[t] < load[mt] {
+				mt = t
+			}
+		}
+		thr[l.li] = mt
+		load[mt] += l.cost
+	}
+	return thr
+}
+
+// PerLayer returns a thread for each layer that puts each layer that is
+// not off on its own thread
+func PerLayer(net *leabra.Network) []int {
+	thr := make([]int, len(net.Layers))
+	nt := 0
+	for li, lyi := range net.Layers {
+		if lyi.IsOff() {
+			continue
+		}
+		thr[li] = nt
+		nt++
+	}
+	return thr
+}
+
+// Run runs the benchmark on given network, calling the trial function
+// NTrials times for each thread layout, and using the CycleTot in given
+// time to count cycles (allowing for it to be reset by a new run).  The original thread layout is restored at the end.
+// The results are added to the Results and Layers tables, printed,
+// and appended to File if set.
+func (bm *Bench) Run(net *leabra.Network, ltime *leabra.Time, trial func()) error {
+	if bm.NTrials <= 0 {
+		return fmt.Errorf("bench: NTrials must be > 0")
+	}
+	if bm.Results == nil {
+		bm.ConfigResults()
+	}
+	bm.ConfigLayers()
+	date := time.Now().Format("2006-01-02 15:04:05")
+	st := bm.Results.Rows
+
+	orig := Threads(net)
+	bm.runLayout(net, ltime, trial, "Sim", orig, date)
+	single := make([]int, len(orig))
+	bm.runLayout(net, ltime, trial, "Single", single, date)
+	for _, nt := range bm.Threads {
+		if nt < 2 {
+			continue
+		}
+		bm.runLayout(net, ltime, trial, fmt.Sprintf("Balanced%d", nt), Balanced(net, nt), date)
+	}
+	bm.runLayout(net, ltime, trial, "PerLayer", PerLayer(net), date)
+	bm.layerTimes(net)
+	SetThreads(net, orig)
+
+	fmt.Print(bm.Report(st))
+	if bm.File == "" {
+		return nil
+	}
+	return bm.AppendFile(st)
+}
+
+// runLayout runs the trials with given thread layout, adding a row to Results
+func (bm *Bench) runLayout(net *leabra.Network, ltime *leabra.Time, trial func(), layout string, thr []int, date string) {
+	SetThreads(net, thr)
+	trial() // warm up
+	net.ThrTimerReset()
+
+	var ms0, ms1 runtime.MemStats
+	var tmr timer.Time
+	runtime.GC()
+	runtime.ReadMemStats(&ms0)
+	ncyc := 0
+	tmr.Start()
+	for i := 0; i < bm.NTrials; i++ {
+		cyc0 := ltime.CycleTot
+		trial()
+		if ltime.CycleTot >= cyc0 {
+			ncyc += ltime.CycleTot - cyc0
+		} else { // time was reset for a new run during the trial
+			ncyc += ltime.CycleTot
+		}
+	}
+	tmr.Stop()
+	runtime.ReadMemStats(&ms1)
+
+	secs := tmr.TotalSecs()
+	ntr := float64(bm.NTrials)
+	dt := bm.Results
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellString("Date", row, date)
+	dt.SetCellString("Sim", row, bm.Sim)
+	dt.SetCellString("Layout", row, layout)
+	dt.SetCellFloat("NThreads", row, float64(net.NThreads))
+	dt.SetCellFloat("Trials", row, ntr)
+	dt.SetCellFloat("Cycles", row, float64(ncyc))
+	dt.SetCellFloat("Secs", row, secs)
+	if ncyc > 0 {
+		dt.SetCellFloat("NsPerCycle", row, 1.0e9*secs/float64(ncyc))
+	}
+	if secs > 0 {
+		dt.SetCellFloat("TrialsPerSec", row, ntr/secs)
+	}
+	dt.SetCellFloat("AllocsPerTrial", row, float64(ms1.Mallocs-ms0.Mallocs)/ntr)
+	dt.SetCellFloat("KBPerTrial", row, float64(ms1.TotalAlloc-ms0.TotalAlloc)/(1024*ntr))
+}
+
+// layerTimes records the time per layer from the thread timers of
+// the PerLayer layout
+func (bm *Bench) layerTimes(net *leabra.Network) {
+	dt := bm.Layers
+	tot := 0.0
+	for th := 0; th < net.NThreads && net.NThreads > 1; th++ {
+		tot += net.ThrTimes[th].TotalSecs()
+	}
+	for th, lays := range net.ThrLay {
+		for _, lyi := range lays {
+			ly := lyi.(leabra.LeabraLayer).AsLeabra()
+			neur, syn, _ := ly.CostEst()
+			secs := 0.0
+			if net.NThreads > 1 {
+				secs = net.ThrTimes[th].TotalSecs()
+			}
+			row := dt.Rows
+			dt.SetNumRows(row + 1)
+			dt.SetCellString("Layer", row, ly.Nm)
+			dt.SetCellFloat("Neurons", row, float64(neur))
+			dt.SetCellFloat("Syns", row, float64(syn))
+			dt.SetCellFloat("Secs", row, secs)
+			if tot > 0 {
+				dt.SetCellFloat("Pct", row, 100*secs/tot)
+			}
+		}
+	}
+}
+
+// Report returns a text report of the results starting at given row
+// of the Results table, and of the Layers table
+func (bm *Bench) Report(st int) string {
+	var b strings.Builder
+	dt := bm.Results
+	fmt.Fprintf(&b, "Benchmark: %s, %d trials per layout\n", bm.Sim, bm.NTrials)
+	fmt.Fprintf(&b, "\t%-10s\t%4s\t%8s\t%8s\t%10s\t%10s\t%10s\t%10s\n", "Layout", "Thr", "Cycles", "Secs", "ns/Cycle", "Trials/s", "Allocs/Trl", "KB/Trl")
+	for row := st; row < dt.Rows; row++ {
+		fmt.Fprintf(&b, "\t%-10s\t%4d\t%8d\t%8.3f\t%10.0f\t%10.2f\t%10.0f\t%10.1f\n", dt.CellString("Layout", row), int(dt.CellFloat("NThreads", row)), int(dt.CellFloat("Cycles", row)), dt.CellFloat("Secs", row), dt.CellFloat("NsPerCycle", row), dt.CellFloat("TrialsPerSec", row), dt.CellFloat("AllocsPerTrial", row), dt.CellFloat("KBPerTrial", row))
+	}
+	lt := bm.Layers
+	fmt.Fprintf(&b, "\n\t%-14s\t%8s\t%10s\t%8s\t%6s\n", "Layer", "Neurons", "Syns", "Secs", "Pct")
+	for row := 0; row < lt.Rows; row++ {
+		fmt.Fprintf(&b, "\t%-14s\t%8d\t%10d\t%8.3f\t%6.1f\n", lt.CellString("Layer", row), int(lt.CellFloat("Neurons", row)), int(lt.CellFloat("Syns", row)), lt.CellFloat("Secs", row), lt.CellFloat("Pct", row))
+	}
+	return b.String()
+}
+
+// AppendFile appends the Results starting at given row to File,
+// writing the headers first if the file is new
+func (bm *Bench) AppendFile(st int) error {
+	_, err := os.Stat(bm.File)
+	isNew := os.IsNotExist(err)
+	f, err := os.OpenFile(bm.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dt := bm.Results
+	if isNew {
+		dt.WriteCSVHeaders(f, etable.Tab)
+	}
+	for row := st; row < dt.Rows; row++ {
+		if err := dt.WriteCSVRow(f, row, etable.Tab); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Benchmark results appended to: %s\n", bm.File)
+	return nil
+}
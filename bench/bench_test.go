@@ -0,0 +1,189 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bench
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emer/emergent/emer"
+	"github.com/emer/emergent/prjn"
+	"github.com/emer/leabra/leabra"
+)
+
+func testNet(t *testing.T) *leabra.Network {
+	net := &leabra.Network{}
+	net.InitName(net, "Bench")
+	inp := net.AddLayer2D("Input", 5, 5, emer.Input)
+	hid := net.AddLayer2D("Hidden", 6, 6, emer.Hidden)
+	out := net.AddLayer2D("Output", 5, 5, emer.Target)
+	full := prjn.NewFull()
+	net.ConnectLayers(inp, hid, full, emer.Forward)
+	net.BidirConnectLayers(hid, out, full)
+	net.Defaults()
+	if err := net.Build(); err != nil {
+		t.Fatal(err)
+	}
+	net.InitWts()
+	return net
+}
+
+// captureStdout returns what fun prints to os.Stdout
+func captureStdout(t *testing.T, fun func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		done <- string(b)
+	}()
+	fun()
+	w.Close()
+	os.Stdout = stdout
+	return <-done
+}
+
+func TestHasFlag(t *testing.T) {
+	tests := []struct {
+		args   []string
+		others []string
+		want   bool
+	}{
+		{nil, nil, false},
+		{[]string{"-bench", "50"}, nil, true},
+		{[]string{"--bench=50"}, nil, true},
+		{[]string{"-threads", "-bench=10"}, nil, true},
+		{[]string{"-psn_0_12345"}, nil, false},
+		{[]string{"-benh", "50"}, nil, false},
+		{[]string{"-benchthreads", "2,4"}, nil, false},
+		{[]string{"-benchfile=x.tsv"}, nil, false},
+		{[]string{"bench"}, nil, false},
+		{[]string{"--", "-bench"}, nil, false},
+		{[]string{"-script", "run.txt"}, []string{"script"}, true},
+		{[]string{"-script=run.txt"}, []string{"script"}, true},
+		{[]string{"-scripts"}, []string{"script"}, false},
+	}
+	for _, tt := range tests {
+		if got := HasFlag(tt.args, tt.others...); got != tt.want {
+			t.Errorf("HasFlag(%q, %q) = %v, want %v", tt.args, tt.others, got, tt.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	net := testNet(t)
+	var ltime leabra.Time
+	ncyc := 10
+	ntrl := 0
+	trial := func() {
+		ntrl++
+		if ntrl%3 == 0 {
+			ltime.Reset() // new run during the trial
+		}
+		for cyc := 0; cyc < ncyc; cyc++ {
+			net.Cycle(&ltime)
+			ltime.CycleInc()
+		}
+	}
+	bm := &Bench{}
+	bm.Defaults("test")
+	bm.NTrials = 5
+	bm.Threads = []int{1, 2}
+	bm.File = filepath.Join(t.TempDir(), "bench.tsv")
+	orig := Threads(net)
+
+	var err error
+	out := captureStdout(t, func() { err = bm.Run(net, &ltime, trial) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	layouts := []string{"Sim", "Single", "Balanced2", "PerLayer"} // Threads 1 is skipped
+	dt := bm.Results
+	if dt.Rows != len(layouts) {
+		t.Fatalf("%d Results rows, want %d", dt.Rows, len(layouts))
+	}
+	for row, lay := range layouts {
+		if nm := dt.CellString("Layout", row); nm != lay {
+			t.Errorf("row %d: layout %s, want %s", row, nm, lay)
+		}
+		if sim := dt.CellString("Sim", row); sim != "test" {
+			t.Errorf("%s: sim %s", lay, sim)
+		}
+		if tr := dt.CellFloat("Trials", row); tr != 5 {
+			t.Errorf("%s: %g trials, want 5", lay, tr)
+		}
+		if cyc := dt.CellFloat("Cycles", row); cyc != float64(5*ncyc) {
+			t.Errorf("%s: %g cycles, want %d", lay, cyc, 5*ncyc)
+		}
+		if dt.CellFloat("Secs", row) <= 0 || dt.CellFloat("NsPerCycle", row) <= 0 || dt.CellFloat("TrialsPerSec", row) <= 0 {
+			t.Errorf("%s: no timing: secs %g ns/cycle %g trials/sec %g", lay, dt.CellFloat("Secs", row), dt.CellFloat("NsPerCycle", row), dt.CellFloat("TrialsPerSec", row))
+		}
+	}
+	if n := dt.CellFloat("NThreads", 3); n != 3 {
+		t.Errorf("PerLayer: %g threads, want 3", n)
+	}
+	if n := dt.CellFloat("NThreads", 1); n != 1 {
+		t.Errorf("Single: %g threads, want 1", n)
+	}
+	for li, thr := range Threads(net) {
+		if thr != orig[li] {
+			t.Errorf("layer %d thread %d not restored to %d", li, thr, orig[li])
+		}
+	}
+
+	lt := bm.Layers
+	if lt.Rows != 3 {
+		t.Fatalf("%d Layers rows, want 3", lt.Rows)
+	}
+	pct := 0.0
+	for row := 0; row < lt.Rows; row++ {
+		pct += lt.CellFloat("Pct", row)
+	}
+	if pct < 99.9 || pct > 100.1 {
+		t.Errorf("layer Pct sums to %g, want 100", pct)
+	}
+
+	// the output also has the NThreads lines printed by the network
+	if !strings.Contains(out, bm.Report(0)+"Benchmark results appended to: "+bm.File+"\n") {
+		t.Errorf("output:\n%s\nwant report:\n%s", out, bm.Report(0))
+	}
+	for _, s := range append(layouts, "Benchmark: test, 5 trials per layout", "Input", "Hidden", "Output") {
+		if !strings.Contains(out, s) {
+			t.Errorf("report does not have %q:\n%s", s, out)
+		}
+	}
+
+	// a second run is appended to the Results and the File, without headers
+	captureStdout(t, func() { err = bm.Run(net, &ltime, trial) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dt.Rows != 2*len(layouts) {
+		t.Errorf("%d Results rows after second run, want %d", dt.Rows, 2*len(layouts))
+	}
+	b, err := os.ReadFile(bm.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 1+2*len(layouts) {
+		t.Fatalf("%d lines in file, want %d", len(lines), 1+2*len(layouts))
+	}
+	if !strings.Contains(lines[0], "NsPerCycle") || strings.Contains(lines[1], "NsPerCycle") {
+		t.Errorf("file headers: %q, %q", lines[0], lines[1])
+	}
+
+	bm.NTrials = 0
+	if err := bm.Run(net, &ltime, trial); err == nil {
+		t.Error("expected error for 0 NTrials")
+	}
+}
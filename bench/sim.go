@@ -0,0 +1,69 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bench
+
+import (
+	"flag"
+	"log"
+
+	"github.com/emer/emergent/netview"
+	"github.com/emer/leabra/leabra"
+	"github.com/goki/gi/gi"
+	"github.com/goki/ki/ki"
+)
+
+// RunSim runs the benchmark for a sim, as its Benchmark method, using given
+// trial function (e.g., the sim's TrainTrial or TestTrial).  The network
+// views of the sim (pointers to its NetView fields) are off while it runs,
+// init (the sim's Init) is called before and after to start over, and then
+// stopped (the sim's Stopped) to update the gui -- init must not replace
+// the network.  Errors are logged, and the results are in Results and Layers.
+func (bm *Bench) RunSim(net *leabra.Network, ltime *leabra.Time, trial, init, stopped func(), views ...**netview.NetView) {
+	nvs := make([]*netview.NetView, len(views))
+	for i, nv := range views {
+		nvs[i] = *nv
+		*nv = nil
+	}
+	init()
+	if err := bm.Run(net, ltime, trial); err != nil {
+		log.Println(err)
+	}
+	for i, nv := range views {
+		*nv = nvs[i]
+	}
+	init()
+	stopped()
+}
+
+// RunArgs runs the benchmark without the gui, using the -bench,
+// -benchthreads and -benchfile args (see AddFlags), with given function
+// (the sim's Benchmark method) -- for sims that otherwise only run with the gui
+func (bm *Bench) RunArgs(run func()) {
+	bm.AddFlags()
+	flag.Parse()
+	run()
+}
+
+// AddAction adds the Benchmark action to the toolbar of a sim, which runs
+// given function (the sim's Benchmark method) in a separate goroutine if
+// the sim is not already running, per its running flag (e.g., &ss.IsRunning)
+func AddAction(tbar *gi.ToolBar, win *gi.Window, running *bool, run func()) {
+	tbar.AddAction(gi.ActOpts{Label: "Benchmark", Icon: "fast-fwd", Tooltip: "Runs Bench.NTrials trials for each of several thread layouts, and reports the speed of each, including the time per layer -- see Bench for the results.  Does Init at the end.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!*running)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if !*running {
+			*running = true
+			tbar.UpdateActions()
+			go run()
+		}
+	})
+}
+
+// MethodProps are the props for the Benchmark method in the SimProps
+// CallMethods of a sim
+var MethodProps = ki.Props{
+	"desc": "runs Bench.NTrials trials for each of several thread layouts, and reports the speed of each -- does Init at the end",
+	"icon": "fast-fwd",
+}
@@ -0,0 +1,239 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package bp is a simple feedforward backpropagation network (multilayer
+perceptron), as a baseline for comparison with the leabra learning
+mechanisms in the sims.
+
+A bp.Network is configured from the layers of a leabra.Network (see
+ConfigFrom), with the same number of units in each layer, fully connected
+from each layer to the next, and sigmoid units.  It is trained online
+(a weight update after each pattern), by gradient descent on the sum
+squared error, with momentum.  After each pattern, SetLeabra copies its
+activations into the corresponding leabra layers, so that the statistics,
+logs and network view of the sim work the same as for the leabra network.
+*/
+package bp
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+)
+
+// Layer is one layer of units in a Network, with its weights from the
+// previous layer
+type Layer struct {
+	Name  string    `desc:"name of the layer -- same as the leabra layer"`
+	Acts  []float32 `desc:"activations (sigmoid of net input, or the input values for the first layer)"`
+	Errs  []float32 `desc:"error derivatives with respect to the net input of each unit"`
+	Bias  []float32 `desc:"bias weights"`
+	Wts   []float32 `desc:"weights from the previous layer, indexed [recv * nsend + send]"`
+	DBias []float32 `view:"-" desc:"previous bias weight changes, for momentum"`
+	DWts  []float32 `view:"-" desc:"previous weight changes, for momentum"`
+}
+
+// Network is a feedforward backpropagation network
+type Network struct {
+	Lrate    float32  `def:"0.5" min:"0" desc:"learning rate"`
+	Momentum float32  `def:"0.9" min:"0" max:"1" desc:"momentum: proportion of the previous weight change added to the current one"`
+	WtRange  float32  `def:"0.5" min:"0" desc:"initial weights (and biases) are uniformly random in the range +/- this value"`
+	Layers   []*Layer `desc:"the layers, from input to output"`
+
+	inp, targ etensor.Float32 // input and target patterns for LeabraTrial
+}
+
+func (nt *Network) Defaults() {
+	nt.Lrate = 0.5
+	nt.Momentum = 0.9
+	nt.WtRange = 0.5
+}
+
+// ConfigFrom configures the network with a layer of the same size as each
+// of the given layers of the leabra network, in order from input to output,
+// and initializes the weights
+func (nt *Network) ConfigFrom(lnet *leabra.Network, lays ...string) error {
+	nt.Layers = nil
+	for li, lnm := range lays {
+		lly, err := lnet.LayerByNameTry(lnm)
+		if err != nil {
+			return err
+		}
+		n := lly.Shape().Len()
+		ly := &Layer{Name: lnm, Acts: make([]float32, n), Errs: make([]float32, n)}
+		if li > 0 {
+			ns := len(nt.Layers[li-1].Acts)
+			ly.Bias = make([]float32, n)
+			ly.DBias = make([]float32, n)
+			ly.Wts = make([]float32, n*ns)
+			ly.DWts = make([]float32, n*ns)
+		}
+		nt.Layers = append(nt.Layers, ly)
+	}
+	nt.InitWts()
+	return nil
+}
+
+// InitWts initializes the weights and biases to uniform random values
+// in the range +/- WtRange
+func (nt *Network) InitWts() {
+	for _, ly := range nt.Layers {
+		for i := range ly.Wts {
+			ly.Wts[i] = nt.WtRange * (2*rand.Float32() - 1)
+			ly.DWts[i] = 0
+		}
+		for i := range ly.Bias {
+			ly.Bias[i] = nt.WtRange * (2*rand.Float32() - 1)
+			ly.DBias[i] = 0
+		}
+	}
+}
+
+// LayerByName returns the layer of given name, or nil if not found
+func (nt *Network) LayerByName(name string) *Layer {
+	for _, ly := range nt.Layers {
+		if ly.Name == name {
+			return ly
+		}
+	}
+	return nil
+}
+
+// Output returns the output (last) layer
+func (nt *Network) Output() *Layer {
+	return nt.Layers[len(nt.Layers)-1]
+}
+
+// Forward computes the activations of all the layers, given the input
+// pattern, which must have the same number of values as the input layer
+func (nt *Network) Forward(inp etensor.Tensor) error {
+	in := nt.Layers[0]
+	if inp.Len() != len(in.Acts) {
+		return fmt.Errorf("bp.Forward: input has %d values but layer %s has %d units", inp.Len(), in.Name, len(in.Acts))
+	}
+	for i := range in.Acts {
+		in.Acts[i] = float32(inp.FloatVal1D(i))
+	}
+	for li := 1; li < len(nt.Layers); li++ {
+		snd, ly := nt.Layers[li-1].Acts, nt.Layers[li]
+		ns := len(snd)
+		for ri := range ly.Acts {
+			net := ly.Bias[ri]
+			wts := ly.Wts[ri*ns : (ri+1)*ns]
+			for si, a := range snd {
+				net += wts[si] * a
+			}
+			ly.Acts[ri] = float32(1 / (1 + math.Exp(-float64(net))))
+		}
+	}
+	return nil
+}
+
+// Backward computes the error derivatives of all the layers for given
+// target pattern for the output layer, and updates the weights -- must
+// be called after Forward for the same pattern
+func (nt *Network) Backward(targ etensor.Tensor) error {
+	out := nt.Output()
+	if targ.Len() != len(out.Acts) {
+		return fmt.Errorf("bp.Backward: target has %d values but layer %s has %d units", targ.Len(), out.Name, len(out.Acts))
+	}
+	for i, a := range out.Acts {
+		out.Errs[i] = (float32(targ.FloatVal1D(i)) - a) * a * (1 - a)
+	}
+	for li := len(nt.Layers) - 1; li > 1; li-- {
+		ly, snd := nt.Layers[li], nt.Layers[li-1]
+		ns := len(snd.Acts)
+		for si, a := range snd.Acts {
+			err := float32(0)
+			for ri, re := range ly.Errs {
+				err += re * ly.Wts[ri*ns+si]
+			}
+			snd.Errs[si] = err * a * (1 - a)
+		}
+	}
+	for li := 1; li < len(nt.Layers); li++ {
+		ly, snd := nt.Layers[li], nt.Layers[li-1].Acts
+		ns := len(snd)
+		for ri, re := range ly.Errs {
+			for si, a := range snd {
+				wi := ri*ns + si
+				ly.DWts[wi] = nt.Lrate*re*a + nt.Momentum*ly.DWts[wi]
+				ly.Wts[wi] += ly.DWts[wi]
+			}
+			ly.DBias[ri] = nt.Lrate*re + nt.Momentum*ly.DBias[ri]
+			ly.Bias[ri] += ly.DBias[ri]
+		}
+	}
+	return nil
+}
+
+// Trial runs one trial with given input and target patterns, updating
+// the weights if train is true
+func (nt *Network) Trial(inp, targ etensor.Tensor, train bool) error {
+	if err := nt.Forward(inp); err != nil {
+		return err
+	}
+	if train {
+		return nt.Backward(targ)
+	}
+	return nil
+}
+
+// LeabraTrial runs one trial in place of the leabra network, on the
+// external inputs (Ext) of its input layer and the targets (Targ) of its
+// output layer, as applied by ApplyExt, updating the weights if train is
+// true, and then sets its activations from this network (see SetLeabra).
+func (nt *Network) LeabraTrial(lnet *leabra.Network, train bool) error {
+	inp, err := lnet.LayerByNameTry(nt.Layers[0].Name)
+	if err != nil {
+		return err
+	}
+	out, err := lnet.LayerByNameTry(nt.Output().Name)
+	if err != nil {
+		return err
+	}
+	inp.(leabra.LeabraLayer).AsLeabra().UnitValsTensor(&nt.inp, "Ext")
+	out.(leabra.LeabraLayer).AsLeabra().UnitValsTensor(&nt.targ, "Targ")
+	if err := nt.Trial(&nt.inp, &nt.targ, train); err != nil {
+		return err
+	}
+	nt.SetLeabra(lnet)
+	return nil
+}
+
+// SetLeabra sets the activations of the corresponding layers of the
+// leabra network to those of this network, as the minus phase (Act, ActM),
+// with the output layer's plus phase (ActP) set to its target, so that
+// the leabra layer stats (e.g., MSE, CosDiff) and views reflect this network
+func (nt *Network) SetLeabra(lnet *leabra.Network) {
+	for li, ly := range nt.Layers {
+		lly, err := lnet.LayerByNameTry(ly.Name)
+		if err != nil {
+			continue
+		}
+		lay := lly.(leabra.LeabraLayer).AsLeabra()
+		out := li == len(nt.Layers)-1
+		var sumM, sumP float32
+		for ni := range lay.Neurons {
+			nrn := &lay.Neurons[ni]
+			nrn.Act = ly.Acts[ni]
+			nrn.ActM = ly.Acts[ni]
+			if out {
+				nrn.ActP = nrn.Targ
+			} else {
+				nrn.ActP = ly.Acts[ni]
+			}
+			sumM += nrn.ActM
+			sumP += nrn.ActP
+		}
+		n := float32(len(lay.Neurons))
+		lay.Pools[0].ActM.Avg = sumM / n
+		lay.Pools[0].ActP.Avg = sumP / n
+		lay.CosDiffFmActs()
+	}
+}
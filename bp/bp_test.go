@@ -0,0 +1,186 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bp
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/emer/emergent/emer"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+)
+
+// testNet returns a leabra network with layers of given sizes, from
+// input to output, and a bp network configured from it
+func testNet(t *testing.T, sizes ...int) (*Network, *leabra.Network) {
+	lnet := &leabra.Network{}
+	lnet.InitName(lnet, "Test")
+	var lays []string
+	for li, n := range sizes {
+		typ := emer.Hidden
+		switch li {
+		case 0:
+			typ = emer.Input
+		case len(sizes) - 1:
+			typ = emer.Target
+		}
+		nm := fmt.Sprintf("Layer%d", li)
+		lnet.AddLayer2D(nm, 1, n, typ)
+		lays = append(lays, nm)
+	}
+	if err := lnet.Build(); err != nil {
+		t.Fatal(err)
+	}
+	nt := &Network{}
+	nt.Defaults()
+	if err := nt.ConfigFrom(lnet, lays...); err != nil {
+		t.Fatal(err)
+	}
+	return nt, lnet
+}
+
+// pattern returns a 1D tensor with given values
+func pattern(vals ...float32) *etensor.Float32 {
+	tsr := etensor.NewFloat32([]int{len(vals)}, nil, nil)
+	copy(tsr.Values, vals)
+	return tsr
+}
+
+// sse returns the sum squared error of the output, times 0.5, for given
+// input and target -- the error that Backward descends
+func sse(t *testing.T, nt *Network, inp, targ *etensor.Float32) float64 {
+	if err := nt.Forward(inp); err != nil {
+		t.Fatal(err)
+	}
+	e := 0.0
+	for i, a := range nt.Output().Acts {
+		d := float64(targ.Values[i] - a)
+		e += 0.5 * d * d
+	}
+	return e
+}
+
+func TestConfigFrom(t *testing.T) {
+	nt, lnet := testNet(t, 2, 3, 1)
+	sizes := []struct {
+		nact, nwt int
+	}{{2, 0}, {3, 6}, {1, 3}}
+	for li, ly := range nt.Layers {
+		if len(ly.Acts) != sizes[li].nact || len(ly.Wts) != sizes[li].nwt {
+			t.Errorf("%s: %d units and %d weights, want %d and %d", ly.Name, len(ly.Acts), len(ly.Wts), sizes[li].nact, sizes[li].nwt)
+		}
+		for _, w := range ly.Wts {
+			if w < -nt.WtRange || w > nt.WtRange {
+				t.Errorf("%s: weight %g out of range +/- %g", ly.Name, w, nt.WtRange)
+			}
+		}
+	}
+	if err := nt.ConfigFrom(lnet, "Layer0", "NoSuchLayer"); err == nil {
+		t.Errorf("ConfigFrom did not return an error for a missing layer")
+	}
+	if err := nt.Forward(pattern(1, 0, 1)); err == nil {
+		t.Errorf("Forward did not return an error for the wrong input size")
+	}
+}
+
+func TestGradient(t *testing.T) {
+	rand.Seed(1)
+	nt, _ := testNet(t, 3, 4, 2)
+	// with no momentum and a learning rate of 1, the weight changes are
+	// minus the gradient of the error
+	nt.Lrate = 1
+	nt.Momentum = 0
+	inp := pattern(1, 0, 0.5)
+	targ := pattern(0.9, 0.1)
+	const eps = 1e-3
+	// numgrad returns the finite-difference gradient for the weight
+	numgrad := func(w *float32) float64 {
+		sv := *w
+		*w = sv + eps
+		ep := sse(t, nt, inp, targ)
+		*w = sv - eps
+		em := sse(t, nt, inp, targ)
+		*w = sv
+		return (ep - em) / (2 * eps)
+	}
+	type grad struct {
+		name string
+		num  float64
+	}
+	var grads [][]grad
+	for _, ly := range nt.Layers[1:] {
+		var gs []grad
+		for i := range ly.Wts {
+			gs = append(gs, grad{fmt.Sprintf("%s Wts[%d]", ly.Name, i), numgrad(&ly.Wts[i])})
+		}
+		for i := range ly.Bias {
+			gs = append(gs, grad{fmt.Sprintf("%s Bias[%d]", ly.Name, i), numgrad(&ly.Bias[i])})
+		}
+		grads = append(grads, gs)
+	}
+	if err := nt.Trial(inp, targ, true); err != nil {
+		t.Fatal(err)
+	}
+	for li, ly := range nt.Layers[1:] {
+		dws := append(append([]float32{}, ly.DWts...), ly.DBias...)
+		for i, g := range grads[li] {
+			if d := float64(-dws[i]); math.Abs(d-g.num) > 1e-4 {
+				t.Errorf("%s: gradient %g, finite difference %g", g.name, d, g.num)
+			}
+		}
+	}
+	if err := nt.Backward(pattern(1)); err == nil {
+		t.Errorf("Backward did not return an error for the wrong target size")
+	}
+}
+
+func TestXOR(t *testing.T) {
+	rand.Seed(1)
+	nt, lnet := testNet(t, 2, 4, 1)
+	inps := []*etensor.Float32{pattern(0, 0), pattern(0, 1), pattern(1, 0), pattern(1, 1)}
+	targs := []*etensor.Float32{pattern(0), pattern(1), pattern(1), pattern(0)}
+	learned := func() bool {
+		for pi, inp := range inps {
+			if err := nt.Forward(inp); err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(float64(targs[pi].Values[0]-nt.Output().Acts[0])) > 0.2 {
+				return false
+			}
+		}
+		return true
+	}
+	epc := 0
+	for ; epc < 1000 && !learned(); epc++ {
+		for _, pi := range rand.Perm(len(inps)) {
+			if err := nt.Trial(inps[pi], targs[pi], true); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if !learned() {
+		t.Fatalf("XOR not learned in %d epochs", epc)
+	}
+
+	// running in place of the leabra network gives it the same activations
+	inp := lnet.LayerByName("Layer0").(leabra.LeabraLayer).AsLeabra()
+	out := lnet.LayerByName("Layer2").(leabra.LeabraLayer).AsLeabra()
+	for pi := range inps {
+		inp.ApplyExt(inps[pi])
+		out.ApplyExt(targs[pi])
+		if err := nt.LeabraTrial(lnet, false); err != nil {
+			t.Fatal(err)
+		}
+		if act := out.Neurons[0].Act; act != nt.Output().Acts[0] {
+			t.Errorf("pattern %d: leabra output Act %g, want %g", pi, act, nt.Output().Acts[0])
+		}
+		if d := math.Abs(float64(targs[pi].Values[0] - out.Neurons[0].Act)); d > 0.2 {
+			t.Errorf("pattern %d: output %g, target %g", pi, out.Neurons[0].Act, targs[pi].Values[0])
+		}
+	}
+}
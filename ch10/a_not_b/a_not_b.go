@@ -23,6 +23,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/CompCogNeuro/sims/bench"
+	"github.com/CompCogNeuro/sims/patfile"
 	"github.com/emer/emergent/emer"
 	"github.com/emer/emergent/env"
 	"github.com/emer/emergent/netview"
@@ -44,9 +46,15 @@ import (
 
 // this is the stub main for gogi that calls our actual mainrun function, at end of file
 func main() {
-	gimain.Main(func() {
-		mainrun()
-	})
+	if bench.HasFlag(os.Args[1:]) {
+		TheSim.New()
+		TheSim.Config()
+		TheSim.Bench.RunArgs(TheSim.Benchmark) // -bench arg = run without the gui
+	} else {
+		gimain.Main(func() { // this starts gui -- requires valid OpenGL display connection (e.g., X11)
+			mainrun()
+		})
+	}
 }
 
 // LogPrec is precision for saving float values in logs
@@ -165,6 +173,7 @@ type Sim struct {
 	MaxEpcs     int               `desc:"maximum number of epochs to run per model run"`
 	TrainEnv    env.FixedTable    `desc:"Training environment -- contains everything about iterating over input / output patterns over training"`
 	Time        leabra.Time       `desc:"leabra timing parameters and state"`
+	Bench       bench.Bench       `view:"no-inline" desc:"benchmark parameters and results -- see Benchmark"`
 	ViewOn      bool              `desc:"whether to update the network view while running"`
 	TrainUpdt   leabra.TimeScales `desc:"at what time scale to update the display during training?  Anything longer than Epoch updates at Epoch in this model"`
 	TstRecLays  []string          `desc:"names of layers to record activations etc of during testing"`
@@ -207,6 +216,7 @@ func (ss *Sim) New() {
 	ss.TrainUpdt = leabra.Quarter
 	ss.TstRecLays = []string{"Location", "Cover", "Toy", "Hidden", "GazeExpect", "Reach"}
 	ss.Time.CycPerQtr = 4 // key!
+	ss.Bench.Defaults("a_not_b")
 }
 
 func (ss *Sim) Defaults() {
@@ -552,6 +562,11 @@ func (ss *Sim) Stopped() {
 	}
 }
 
+// Benchmark runs the Bench benchmark with TrainTrial (see bench.Bench.RunSim)
+func (ss *Sim) Benchmark() {
+	ss.Bench.RunSim(ss.Net, &ss.Time, ss.TrainTrial, ss.Init, ss.Stopped, &ss.NetView)
+}
+
 // SaveWeights saves the network weights -- when called with giv.CallMethod
 // it will auto-prompt for filename
 func (ss *Sim) SaveWeights(filename gi.FileName) {
@@ -639,6 +654,27 @@ func (ss *Sim) OpenPats() {
 	ss.OpenPatAsset(ss.Delay1Pats, "a_not_b_delay1.tsv", "AnotB Delay=1", "AnotB input patterns")
 }
 
+// OpenPatsFile opens patterns from given .tsv or .csv file in place of the
+// patterns for the current Delay, checking that they fit the input layers.
+func (ss *Sim) OpenPatsFile(filename gi.FileName) error {
+	var dt *etable.Table
+	switch ss.Delay {
+	case Delay3:
+		dt = ss.Delay3Pats
+	case Delay5:
+		dt = ss.Delay5Pats
+	case Delay1:
+		dt = ss.Delay1Pats
+	}
+	err := patfile.Open(dt, string(filename), ss.Net, "Location", "Cover", "Toy", "Reach")
+	if err != nil {
+		patfile.Report(ss.Win, err)
+		return err
+	}
+	ss.ConfigEnv()
+	return nil
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////
 // 		Logging
 
@@ -841,6 +877,11 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		}
 	})
 
+	tbar.AddAction(gi.ActOpts{Label: "Open Pats", Icon: "file-open", Tooltip: "Open your own patterns from a .tsv or .csv file, in place of the ones for the current Delay -- the columns must fit the Location, Cover, Toy and Reach layers."}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			giv.CallMethod(ss, "OpenPatsFile", vp)
+		})
+
 	tbar.AddSeparator("misc")
 
 	tbar.AddAction(gi.ActOpts{Label: "New Seed", Icon: "new", Tooltip: "Generate a new initial random seed to get different results.  By default, Init re-establishes the same initial seed every time."}, win.This(),
@@ -855,6 +896,8 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		vp.SetNeedsFullRender()
 	})
 
+	bench.AddAction(tbar, win, &ss.IsRunning, ss.Benchmark)
+
 	tbar.AddAction(gi.ActOpts{Label: "README", Icon: "file-markdown", Tooltip: "Opens your browser on the README file that contains instructions for how to run this model."}, win.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			gi.OpenURL("https://github.com/CompCogNeuro/sims/blob/master/ch10/a_not_b/README.md")
@@ -935,6 +978,16 @@ func (ss *Sim) ConfigGui() *gi.Window {
 // These props register Save methods so they can be used
 var SimProps = ki.Props{
 	"CallMethods": ki.PropSlice{
+		{"Benchmark", bench.MethodProps},
+		{"OpenPatsFile", ki.Props{
+			"desc": "open patterns from .tsv or .csv file, replacing the current ones",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".tsv,.csv",
+				}},
+			},
+		}},
 		{"SaveWeights", ki.Props{
 			"desc": "save network weights to file",
 			"icon": "file-save",
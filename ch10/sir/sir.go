@@ -18,6 +18,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/CompCogNeuro/sims/bench"
+	"github.com/CompCogNeuro/sims/progress"
+	"github.com/CompCogNeuro/sims/script"
 	"github.com/emer/emergent/emer"
 	"github.com/emer/emergent/env"
 	"github.com/emer/emergent/netview"
@@ -240,6 +243,7 @@ type Sim struct {
 	TrainEnv    SIREnv            `desc:"Training environment -- SIR environment"`
 	TestEnv     SIREnv            `desc:"Testing nvironment -- SIR environment"`
 	Time        leabra.Time       `desc:"leabra timing parameters and state"`
+	Bench       bench.Bench       `view:"no-inline" desc:"benchmark parameters and results -- see Benchmark"`
 	ViewOn      bool              `desc:"whether to update the network view while running"`
 	TrainUpdt   leabra.TimeScales `desc:"at what time scale to update the display during training?  Anything longer than Epoch updates at Epoch in this model"`
 	TestUpdt    leabra.TimeScales `desc:"at what time scale to update the display during testing?  Anything longer than Epoch updates at Epoch in this model"`
@@ -285,6 +289,7 @@ type Sim struct {
 	ValsTsrs     map[string]*etensor.Float32 `view:"-" desc:"for holding layer values"`
 	SaveWts      bool                        `view:"-" desc:"for command-line run only, auto-save final weights after each run"`
 	NoGui        bool                        `view:"-" desc:"if true, runing in no GUI mode"`
+	Progress     progress.Progress           `view:"-" desc:"progress display for runs without the gui"`
 	LogSetParams bool                        `view:"-" desc:"if true, print message for all params that are set"`
 	IsRunning    bool                        `view:"-" desc:"true if sim is running"`
 	StopNow      bool                        `view:"-" desc:"flag to stop running"`
@@ -316,6 +321,8 @@ func (ss *Sim) New() {
 	ss.TestUpdt = leabra.AlphaCycle
 	ss.TstRecLays = []string{"Input", "Output", "GPiThal", "PFCmntD", "PFCoutD"}
 	ss.Defaults()
+	ss.Bench.Defaults("sir")
+	ss.Progress.Defaults("sir")
 }
 
 func (ss *Sim) Defaults() {
@@ -616,6 +623,9 @@ func (ss *Sim) TrainTrial() {
 	ss.ApplyInputs(&ss.TrainEnv)
 	ss.AlphaCyc(true)   // train
 	ss.TrialStats(true) // accumulate
+	if ss.NoGui && ss.Progress.Due() {
+		ss.Progress.Update(ss.Counters(true), progress.Frac(ss.TrainEnv.Run.Cur, ss.MaxRuns, ss.TrainEnv.Epoch.Cur, ss.MaxEpcs, ss.TrainEnv.Trial.Cur, ss.TrainEnv.Trial.Max), ss.TrnEpcLog)
+	}
 }
 
 // RunEnd is called at the end of a run -- save weights, record final log, etc here
@@ -751,6 +761,11 @@ func (ss *Sim) Stopped() {
 	}
 }
 
+// Benchmark runs the Bench benchmark with TrainTrial (see bench.Bench.RunSim)
+func (ss *Sim) Benchmark() {
+	ss.Bench.RunSim(ss.Net.AsLeabra(), &ss.Time, ss.TrainTrial, ss.Init, ss.Stopped, &ss.NetView)
+}
+
 // SaveWeights saves the network weights -- when called with giv.CallMethod
 // it will auto-prompt for filename
 func (ss *Sim) SaveWeights(filename gi.FileName) {
@@ -1404,6 +1419,8 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		vp.SetNeedsFullRender()
 	})
 
+	bench.AddAction(tbar, win, &ss.IsRunning, ss.Benchmark)
+
 	tbar.AddAction(gi.ActOpts{Label: "README", Icon: "file-markdown", Tooltip: "Opens your browser on the README file that contains instructions for how to run this model."}, win.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			gi.OpenURL("https://github.com/CompCogNeuro/sims/blob/master/ch10/sir/README.md")
@@ -1484,6 +1501,7 @@ func (ss *Sim) ConfigGui() *gi.Window {
 // These props register Save methods so they can be used
 var SimProps = ki.Props{
 	"CallMethods": ki.PropSlice{
+		{"Benchmark", bench.MethodProps},
 		{"SaveWeights", ki.Props{
 			"desc": "save network weights to file",
 			"icon": "file-save",
@@ -1501,6 +1519,7 @@ func (ss *Sim) CmdArgs() {
 	var nogui bool
 	var saveEpcLog bool
 	var saveRunLog bool
+	var scriptFile string
 	var note string
 	flag.StringVar(&ss.ParamSet, "params", "", "ParamSet name to use -- must be valid name as listed in compiled-in params or loaded params")
 	flag.StringVar(&ss.Tag, "tag", "", "extra tag to add to file names saved from this run")
@@ -1510,9 +1529,16 @@ func (ss *Sim) CmdArgs() {
 	flag.BoolVar(&ss.SaveWts, "wts", false, "if true, save final weights after each run")
 	flag.BoolVar(&saveEpcLog, "epclog", true, "if true, save train epoch log to file")
 	flag.BoolVar(&saveRunLog, "runlog", true, "if true, save run epoch log to file")
+	flag.StringVar(&scriptFile, "script", "", "if set, run the commands in given script file instead of the default training")
 	flag.BoolVar(&nogui, "nogui", true, "if not passing any other args and want to run nogui, use nogui")
+	ss.Bench.AddFlags()
+	ss.Progress.AddFlags()
 	flag.Parse()
 	ss.Init()
+	if bench.Flagged() {
+		ss.Benchmark()
+		return
+	}
 
 	if note != "" {
 		fmt.Printf("note: %s\n", note)
@@ -1548,6 +1574,41 @@ func (ss *Sim) CmdArgs() {
 	if ss.SaveWts {
 		fmt.Printf("Saving final weights per run\n")
 	}
-	fmt.Printf("Running %d Runs\n", ss.MaxRuns)
-	ss.Train()
+	ss.Progress.Start()
+	if scriptFile != "" {
+		ss.RunScript(scriptFile)
+	} else {
+		fmt.Printf("Running %d Runs\n", ss.MaxRuns)
+		ss.Train()
+	}
+	ss.Progress.Done()
+}
+
+// ConfigScript registers the toolbar actions and the SimProps CallMethods
+// as commands that can be used in a script file passed with -script
+func (ss *Sim) ConfigScript(sc *script.Interp) {
+	sc.AddAction("Init", "initialize everything including network weights, and start over", ss.Init)
+	sc.AddSteps("Train", "run training to the end, or for given number of steps", ss.Train, map[string]func(){
+		"trial": ss.TrainTrial,
+		"epoch": ss.TrainEpoch,
+		"run":   ss.TrainRun,
+	})
+	sc.AddAction("TestTrial", "run the next testing trial", func() { ss.TestTrial(false) })
+	sc.AddAction("TestAll", "test all of the testing trials", ss.RunTestAll)
+	sc.AddAction("ResetRunLog", "reset the accumulated log of all Runs", func() { ss.RunLog.SetNumRows(0) })
+	sc.AddAction("NewSeed", "generate a new initial random seed to get different results", ss.NewRndSeed)
+	sc.AddAction("Defaults", "restore initial default parameters", ss.Defaults)
+	if err := sc.AddCallMethods(SimProps); err != nil {
+		log.Println(err)
+	}
+}
+
+// RunScript runs the commands in given script file, exiting on any error
+func (ss *Sim) RunScript(fnm string) {
+	sc := script.New(ss)
+	ss.ConfigScript(sc)
+	fmt.Printf("Running script: %s\n", fnm)
+	if err := sc.RunFile(fnm); err != nil {
+		log.Fatalln(err)
+	}
 }
@@ -17,6 +17,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/CompCogNeuro/sims/bench"
+	"github.com/CompCogNeuro/sims/patfile"
 	"github.com/emer/emergent/emer"
 	"github.com/emer/emergent/env"
 	"github.com/emer/emergent/netview"
@@ -40,9 +42,15 @@ import (
 
 // this is the stub main for gogi that calls our actual mainrun function, at end of file
 func main() {
-	gimain.Main(func() {
-		mainrun()
-	})
+	if bench.HasFlag(os.Args[1:]) {
+		TheSim.New()
+		TheSim.Config()
+		TheSim.Bench.RunArgs(TheSim.Benchmark) // -bench arg = run without the gui
+	} else {
+		gimain.Main(func() { // this starts gui -- requires valid OpenGL display connection (e.g., X11)
+			mainrun()
+		})
+	}
 }
 
 // LogPrec is precision for saving float values in logs
@@ -165,6 +173,7 @@ type Sim struct {
 	TestEnv      env.FixedTable    `desc:"Testing environment for std strooop -- manages iterating over testing"`
 	SOATestEnv   env.FixedTable    `desc:"Testing environment for SOA tests -- manages iterating over testing"`
 	Time         leabra.Time       `desc:"leabra timing parameters and state"`
+	Bench        bench.Bench       `view:"no-inline" desc:"benchmark parameters and results -- see Benchmark"`
 	ViewOn       bool              `desc:"whether to update the network view while running"`
 	TrainUpdt    leabra.TimeScales `desc:"at what time scale to update the display during training?  Anything longer than Epoch updates at Epoch in this model"`
 	TestUpdt     leabra.TimeScales `desc:"at what time scale to update the display during testing?  Anything longer than Epoch updates at Epoch in this model"`
@@ -234,6 +243,7 @@ func (ss *Sim) New() {
 	ss.TestUpdt = leabra.Cycle
 	ss.TestInterval = 5
 	ss.TstRecLays = []string{"Colors", "Words", "PFC", "Hidden", "Output"}
+	ss.Bench.Defaults("stroop")
 }
 
 func (ss *Sim) Defaults() {
@@ -700,6 +710,11 @@ func (ss *Sim) Stopped() {
 	}
 }
 
+// Benchmark runs the Bench benchmark with TrainTrial (see bench.Bench.RunSim)
+func (ss *Sim) Benchmark() {
+	ss.Bench.RunSim(ss.Net, &ss.Time, ss.TrainTrial, ss.Init, ss.Stopped, &ss.NetView)
+}
+
 // SaveWeights saves the network weights -- when called with giv.CallMethod
 // it will auto-prompt for filename
 func (ss *Sim) SaveWeights(filename gi.FileName) {
@@ -902,6 +917,19 @@ func (ss *Sim) OpenPats() {
 	ss.OpenPatAsset(ss.SOAPats, "stroop_soa.tsv", "Stroop SOA", "Stroop SOA Testing patterns")
 }
 
+// OpenPatsFile opens training patterns from given .tsv or .csv file in place
+// of the TrainPats, checking that they fit the input and output layers.
+func (ss *Sim) OpenPatsFile(filename gi.FileName) error {
+	err := patfile.Open(ss.TrainPats, string(filename), ss.Net, "Colors", "Words", "Output", "PFC")
+	if err != nil {
+		patfile.Report(ss.Win, err)
+		return err
+	}
+	ss.TrainEnv.Table = etable.NewIdxView(ss.TrainPats)
+	ss.TrainEnv.Init(0)
+	return nil
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////
 // 		Logging
 
@@ -1426,6 +1454,11 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		}
 	})
 
+	tbar.AddAction(gi.ActOpts{Label: "Open Pats", Icon: "file-open", Tooltip: "Open your own training patterns from a .tsv or .csv file, in place of the TrainPats -- the columns must fit the Colors, Words, Output and PFC layers."}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			giv.CallMethod(ss, "OpenPatsFile", vp)
+		})
+
 	tbar.AddSeparator("test")
 
 	tbar.AddAction(gi.ActOpts{Label: "Test Trial", Icon: "step-fwd", Tooltip: "Runs the next testing trial.", UpdateFunc: func(act *gi.Action) {
@@ -1492,6 +1525,8 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		vp.SetNeedsFullRender()
 	})
 
+	bench.AddAction(tbar, win, &ss.IsRunning, ss.Benchmark)
+
 	tbar.AddAction(gi.ActOpts{Label: "README", Icon: "file-markdown", Tooltip: "Opens your browser on the README file that contains instructions for how to run this model."}, win.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			gi.OpenURL("https://github.com/CompCogNeuro/sims/blob/master/ch10/stroop/README.md")
@@ -1572,6 +1607,16 @@ func (ss *Sim) ConfigGui() *gi.Window {
 // These props register Save methods so they can be used
 var SimProps = ki.Props{
 	"CallMethods": ki.PropSlice{
+		{"Benchmark", bench.MethodProps},
+		{"OpenPatsFile", ki.Props{
+			"desc": "open patterns from .tsv or .csv file, replacing the current ones",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".tsv,.csv",
+				}},
+			},
+		}},
 		{"SaveWeights", ki.Props{
 			"desc": "save network weights to file",
 			"icon": "file-save",
@@ -11,9 +11,12 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"os"
 	"strconv"
 	"strings"
 
+	"github.com/CompCogNeuro/sims/bench"
+	"github.com/CompCogNeuro/sims/patfile"
 	"github.com/emer/emergent/emer"
 	"github.com/emer/emergent/env"
 	"github.com/emer/emergent/netview"
@@ -34,9 +37,15 @@ import (
 
 // this is the stub main for gogi that calls our actual mainrun function, at end of file
 func main() {
-	gimain.Main(func() {
-		mainrun()
-	})
+	if bench.HasFlag(os.Args[1:]) {
+		TheSim.New()
+		TheSim.Config()
+		TheSim.Bench.RunArgs(TheSim.Benchmark) // -bench arg = run without the gui
+	} else {
+		gimain.Main(func() { // this starts gui -- requires valid OpenGL display connection (e.g., X11)
+			mainrun()
+		})
+	}
 }
 
 // LogPrec is precision for saving float values in logs
@@ -70,21 +79,28 @@ var ParamSets = params.Sets{
 // as arguments to methods, and provides the core GUI interface (note the view tags
 // for the fields which provide hints to how things should be displayed).
 type Sim struct {
-	GbarL     float32           `def:"2" min:"0" max:"4" step:"0.05" desc:"the leak conductance, which pulls against the excitatory input conductance to determine how hard it is to activate the receiving unit"`
-	Net       *leabra.Network   `view:"no-inline" desc:"the network -- click to view / edit parameters for layers, prjns, etc"`
-	Pats      *etable.Table     `view:"no-inline" desc:"click to see the testing input patterns to use (digits)"`
-	TstTrlLog *etable.Table     `view:"no-inline" desc:"testing trial-level log data -- click to see record of network's response to each input"`
-	Params    params.Sets       `view:"no-inline" desc:"full collection of param sets -- not really interesting for this model"`
-	ParamSet  string            `view:"-" desc:"which set of *additional* parameters to use -- always applies Base and optionaly this next if set -- can use multiple names separated by spaces (don't put spaces in ParamSet names!)"`
-	TestEnv   env.FixedTable    `desc:"Testing environment -- manages iterating over testing"`
-	Time      leabra.Time       `desc:"leabra timing parameters and state"`
-	ViewUpdt  leabra.TimeScales `desc:"at what time scale to update the display during testing?  Change to AlphaCyc to make display updating go faster"`
+	GbarL       float32           `def:"2" min:"0" max:"4" step:"0.05" desc:"the leak conductance, which pulls against the excitatory input conductance to determine how hard it is to activate the receiving unit"`
+	Digit       int               `def:"8" min:"0" desc:"index of the pattern in Pats (the digit, for the default digit patterns) that the detector weights are set to, unless UseTemplate is on -- press Init to apply"`
+	UseTemplate bool              `desc:"set the detector weights to the Template instead of the Digit pattern -- press Init to apply"`
+	Template    *etensor.Float32  `view:"no-inline" desc:"your own 7x5 target pattern for the detector weights, used if UseTemplate is on -- click to draw it, or use Digit To Template to start from the Digit pattern"`
+	Tuning      TuningParams      `view:"no-inline" desc:"parameters for the tuning-curve analysis (Tuning), which tests corrupted versions of the target pattern"`
+	TuningLog   *etable.Table     `view:"no-inline" desc:"tuning-curve analysis results: RecvNeuron activity as a function of Hamming distance from the target, for each GbarL"`
+	Net         *leabra.Network   `view:"no-inline" desc:"the network -- click to view / edit parameters for layers, prjns, etc"`
+	Pats        *etable.Table     `view:"no-inline" desc:"click to see the testing input patterns to use (digits)"`
+	TstTrlLog   *etable.Table     `view:"no-inline" desc:"testing trial-level log data -- click to see record of network's response to each input"`
+	Params      params.Sets       `view:"no-inline" desc:"full collection of param sets -- not really interesting for this model"`
+	ParamSet    string            `view:"-" desc:"which set of *additional* parameters to use -- always applies Base and optionaly this next if set -- can use multiple names separated by spaces (don't put spaces in ParamSet names!)"`
+	TestEnv     env.FixedTable    `desc:"Testing environment -- manages iterating over testing"`
+	Time        leabra.Time       `desc:"leabra timing parameters and state"`
+	Bench       bench.Bench       `view:"no-inline" desc:"benchmark parameters and results -- see Benchmark"`
+	ViewUpdt    leabra.TimeScales `desc:"at what time scale to update the display during testing?  Change to AlphaCyc to make display updating go faster"`
 
 	// internal state - view:"-"
 	Win        *gi.Window                  `view:"-" desc:"main GUI window"`
 	NetView    *netview.NetView            `view:"-" desc:"the network viewer"`
 	ToolBar    *gi.ToolBar                 `view:"-" desc:"the master toolbar"`
 	TstTrlPlot *eplot.Plot2D               `view:"-" desc:"the test-trial plot"`
+	TuningPlot *eplot.Plot2D               `view:"-" desc:"the tuning-curve plot"`
 	ValsTsrs   map[string]*etensor.Float32 `view:"-" desc:"for holding layer values"`
 	IsRunning  bool                        `view:"-" desc:"true if sim is running"`
 	StopNow    bool                        `view:"-" desc:"flag to stop running"`
@@ -102,14 +118,20 @@ func (ss *Sim) New() {
 	ss.Net = &leabra.Network{}
 	ss.Pats = &etable.Table{}
 	ss.TstTrlLog = &etable.Table{}
+	ss.TuningLog = &etable.Table{}
+	ss.Template = etensor.NewFloat32([]int{7, 5}, nil, []string{"Y", "X"})
 	ss.Params = ParamSets
 	ss.ViewUpdt = leabra.Cycle
 	ss.Defaults()
+	ss.Bench.Defaults("detector")
 }
 
 // Defaults sets default params
 func (ss *Sim) Defaults() {
 	ss.GbarL = 2
+	ss.Digit = 8
+	ss.UseTemplate = false
+	ss.Tuning.Defaults()
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////
@@ -121,6 +143,7 @@ func (ss *Sim) Config() {
 	ss.ConfigEnv()
 	ss.ConfigNet(ss.Net)
 	ss.ConfigTstTrlLog(ss.TstTrlLog)
+	ss.ConfigTuningLog(ss.TuningLog)
 }
 
 func (ss *Sim) ConfigEnv() {
@@ -149,12 +172,10 @@ func (ss *Sim) ConfigNet(net *leabra.Network) {
 	ss.InitWts(net)
 }
 
-// InitWts initializes weights to digit 8
+// InitWts initializes weights to the target pattern (see TargetPat)
 func (ss *Sim) InitWts(net *leabra.Network) {
 	net.InitWts()
-	digit := 8
-	pats := ss.Pats
-	dpat := pats.CellTensor("Input", digit)
+	dpat := ss.TargetPat()
 	recv := net.LayerByName("RecvNeuron")
 	prj := recv.(leabra.LeabraLayer).AsLeabra().SendName("Input")
 	for i := 0; i < dpat.Len(); i++ {
@@ -162,6 +183,37 @@ func (ss *Sim) InitWts(net *leabra.Network) {
 	}
 }
 
+// TargetPat returns the target pattern for the detector weights:
+// the Template if UseTemplate, and otherwise the Digit pattern
+func (ss *Sim) TargetPat() etensor.Tensor {
+	if ss.UseTemplate {
+		return ss.Template
+	}
+	return ss.Pats.CellTensor("Input", ss.DigitIdx())
+}
+
+// DigitIdx returns the Digit, limited to the number of Pats
+func (ss *Sim) DigitIdx() int {
+	if ss.Digit >= ss.Pats.Rows {
+		return ss.Pats.Rows - 1
+	}
+	if ss.Digit < 0 {
+		return 0
+	}
+	return ss.Digit
+}
+
+// DigitToTemplate copies the Digit pattern to the Template, as a
+// starting point for drawing your own, and turns on UseTemplate
+func (ss *Sim) DigitToTemplate() {
+	dpat := ss.Pats.CellTensor("Input", ss.DigitIdx())
+	for i := 0; i < dpat.Len() && i < ss.Template.Len(); i++ {
+		ss.Template.Values[i] = float32(dpat.FloatVal1D(i))
+	}
+	ss.UseTemplate = true
+	ss.Init()
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // 	    Init, utils
 
@@ -280,6 +332,11 @@ func (ss *Sim) Stopped() {
 	}
 }
 
+// Benchmark runs the Bench benchmark with TestTrial (see bench.Bench.RunSim)
+func (ss *Sim) Benchmark() {
+	ss.Bench.RunSim(ss.Net, &ss.Time, ss.TestTrial, ss.Init, ss.Stopped, &ss.NetView)
+}
+
 // SaveWeights saves the network weights -- when called with giv.CallMethod
 // it will auto-prompt for filename
 func (ss *Sim) SaveWeights(filename gi.FileName) {
@@ -414,6 +471,18 @@ func (ss *Sim) OpenPats() {
 	// dt.OpenCSV("digits.tsv", etable.Tab)
 }
 
+// OpenPatsFile opens patterns from given .tsv or .csv file in place of the
+// digit Pats, checking that they fit the Input layer.
+func (ss *Sim) OpenPatsFile(filename gi.FileName) error {
+	err := patfile.Open(ss.Pats, string(filename), ss.Net, "Input")
+	if err != nil {
+		patfile.Report(ss.Win, err)
+		return err
+	}
+	ss.ConfigEnv()
+	return nil
+}
+
 //////////////////////////////////////////////
 //  TstTrlLog
 
@@ -539,6 +608,9 @@ func (ss *Sim) ConfigGui() *gi.Window {
 	plt := tv.AddNewTab(eplot.KiT_Plot2D, "TstTrlPlot").(*eplot.Plot2D)
 	ss.TstTrlPlot = ss.ConfigTstTrlPlot(plt, ss.TstTrlLog)
 
+	plt = tv.AddNewTab(eplot.KiT_Plot2D, "TuningPlot").(*eplot.Plot2D)
+	ss.TuningPlot = ss.ConfigTuningPlot(plt, ss.TuningLog)
+
 	split.SetSplits(.2, .8)
 
 	tbar.AddAction(gi.ActOpts{Label: "Init", Icon: "update", Tooltip: "Initialize everything including network weights, and start over.  Also applies current params.", UpdateFunc: func(act *gi.Action) {
@@ -600,6 +672,28 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		}
 	})
 
+	tbar.AddAction(gi.ActOpts{Label: "Tuning", Icon: "fast-fwd", Tooltip: "Tests corrupted versions of the target pattern (flipped pixels and shifts), for each of the Tuning.GbarLs, and plots the RecvNeuron activity as a function of Hamming distance from the target in the TuningPlot.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if !ss.IsRunning {
+			ss.IsRunning = true
+			tbar.UpdateActions()
+			go ss.RunTuning()
+		}
+	})
+
+	tbar.AddAction(gi.ActOpts{Label: "Digit To Template", Icon: "copy", Tooltip: "Copies the Digit pattern to the Template, as a starting point for drawing your own target pattern, and turns on UseTemplate.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		ss.DigitToTemplate()
+		vp.SetNeedsFullRender()
+	})
+
+	tbar.AddAction(gi.ActOpts{Label: "Open Pats", Icon: "file-open", Tooltip: "Open your own patterns from a .tsv or .csv file, in place of the digits -- the Input column must fit the 7x5 Input layer."}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			giv.CallMethod(ss, "OpenPatsFile", vp)
+		})
+
 	tbar.AddAction(gi.ActOpts{Label: "Defaults", Icon: "update", Tooltip: "Restore initial default parameters.", UpdateFunc: func(act *gi.Action) {
 		act.SetActiveStateUpdt(!ss.IsRunning)
 	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
@@ -608,6 +702,8 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		vp.SetNeedsFullRender()
 	})
 
+	bench.AddAction(tbar, win, &ss.IsRunning, ss.Benchmark)
+
 	tbar.AddAction(gi.ActOpts{Label: "README", Icon: "file-markdown", Tooltip: "Opens your browser on the README file that contains instructions for how to run this model."}, win.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			gi.OpenURL("https://github.com/CompCogNeuro/sims/blob/master/ch2/detector/README.md")
@@ -675,6 +771,24 @@ func (ss *Sim) ConfigGui() *gi.Window {
 // These props register Save methods so they can be used
 var SimProps = ki.Props{
 	"CallMethods": ki.PropSlice{
+		{"RunTuning", ki.Props{
+			"desc": "tests corrupted versions of the target pattern for each of the Tuning.GbarLs, and plots the response vs. Hamming distance",
+			"icon": "fast-fwd",
+		}},
+		{"DigitToTemplate", ki.Props{
+			"desc": "copies the Digit pattern to the Template and turns on UseTemplate",
+			"icon": "copy",
+		}},
+		{"Benchmark", bench.MethodProps},
+		{"OpenPatsFile", ki.Props{
+			"desc": "open patterns from .tsv or .csv file, replacing the current ones",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".tsv,.csv",
+				}},
+			},
+		}},
 		{"SaveWeights", ki.Props{
 			"desc": "save network weights to file",
 			"icon": "file-save",
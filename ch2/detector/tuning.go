@@ -0,0 +1,196 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+)
+
+// TuningParams are the parameters for the tuning-curve analysis, which
+// presents systematically corrupted versions of the target pattern and
+// records the response of the RecvNeuron as a function of the Hamming
+// distance from the target, for several values of GbarL
+type TuningParams struct {
+	GbarLs   []float32 `desc:"values of GbarL to test, each of which produces a separate tuning curve"`
+	MaxFlips int       `min:"0" def:"10" desc:"maximum number of flipped pixels (active <-> inactive) -- all numbers from 0 to this are tested"`
+	NSamples int       `min:"1" def:"20" desc:"number of random patterns with each number of flipped pixels, over which the response is averaged"`
+	MaxShift int       `min:"0" def:"2" desc:"maximum shift of the target pattern, in each direction, horizontally and vertically -- 0 for none"`
+}
+
+func (tp *TuningParams) Defaults() {
+	tp.GbarLs = []float32{1.5, 1.8, 2, 2.3}
+	tp.MaxFlips = 10
+	tp.NSamples = 20
+	tp.MaxShift = 2
+}
+
+// Hamming returns the number of units that differ (above vs. below .5)
+// between the two patterns
+func Hamming(a, b etensor.Tensor) int {
+	n := 0
+	for i := 0; i < a.Len(); i++ {
+		if (a.FloatVal1D(i) > 0.5) != (b.FloatVal1D(i) > 0.5) {
+			n++
+		}
+	}
+	return n
+}
+
+// FlipPat returns a copy of the pattern with k randomly chosen units flipped
+func FlipPat(pat etensor.Tensor, k int) *etensor.Float32 {
+	fp := etensor.NewFloat32(pat.Shapes(), nil, nil)
+	for i := 0; i < pat.Len(); i++ {
+		fp.Values[i] = float32(pat.FloatVal1D(i))
+	}
+	for _, i := range rand.Perm(pat.Len())[:k] {
+		fp.Values[i] = 1 - fp.Values[i]
+	}
+	return fp
+}
+
+// ShiftPat returns a copy of the 2D pattern shifted by dy rows and dx
+// columns, filling in with 0
+func ShiftPat(pat etensor.Tensor, dy, dx int) *etensor.Float32 {
+	shp := pat.Shapes()
+	sp := etensor.NewFloat32(shp, nil, nil)
+	ny, nx := shp[0], shp[1]
+	for y := 0; y < ny; y++ {
+		for x := 0; x < nx; x++ {
+			sy, sx := y-dy, x-dx
+			if sy < 0 || sy >= ny || sx < 0 || sx >= nx {
+				continue
+			}
+			sp.Values[y*nx+x] = float32(pat.FloatVal1D(sy*nx + sx))
+		}
+	}
+	return sp
+}
+
+// TestPat runs a trial with given input pattern, returning the
+// resulting activation of the RecvNeuron
+func (ss *Sim) TestPat(pat etensor.Tensor) float64 {
+	ss.Net.InitExt()
+	inp := ss.Net.LayerByName("Input").(leabra.LeabraLayer).AsLeabra()
+	inp.ApplyExt(pat)
+	ss.AlphaCyc()
+	recv := ss.Net.LayerByName("RecvNeuron").(leabra.LeabraLayer).AsLeabra()
+	return float64(recv.Neurons[0].Act)
+}
+
+// shiftPt is the Hamming distance and response for one shift of the target
+type shiftPt struct {
+	ham int
+	act float64
+}
+
+// RunTuning runs the tuning-curve analysis: for each of the Tuning.GbarLs,
+// tests the target pattern with 0 to MaxFlips randomly flipped units
+// (averaging over NSamples patterns for each), and shifted by up to
+// MaxShift in each direction, recording the RecvNeuron Act as a function
+// of the Hamming distance to the target in the TuningLog.  The network
+// view is not updated, and GbarL is restored at the end.
+func (ss *Sim) RunTuning() {
+	nv := ss.NetView
+	ss.NetView = nil
+	gbarL := ss.GbarL
+	ss.Init()
+	tp := &ss.Tuning
+	trg := ss.TargetPat()
+	dt := ss.TuningLog
+	dt.SetNumRows(0)
+	for _, gl := range tp.GbarLs {
+		ss.GbarL = gl
+		ss.SetParams("", false)
+		for k := 0; k <= tp.MaxFlips && k <= trg.Len(); k++ {
+			sum := 0.0
+			for s := 0; s < tp.NSamples; s++ {
+				sum += ss.TestPat(FlipPat(trg, k))
+				if ss.StopNow {
+					break
+				}
+			}
+			if ss.StopNow {
+				break
+			}
+			ss.LogTuning(dt, gl, "Flip", k, sum/float64(tp.NSamples))
+		}
+		var shifts []shiftPt
+		for dy := -tp.MaxShift; dy <= tp.MaxShift && !ss.StopNow; dy++ {
+			for dx := -tp.MaxShift; dx <= tp.MaxShift; dx++ {
+				if dy == 0 && dx == 0 {
+					continue
+				}
+				sp := ShiftPat(trg, dy, dx)
+				shifts = append(shifts, shiftPt{Hamming(trg, sp), ss.TestPat(sp)})
+			}
+		}
+		if ss.StopNow {
+			break
+		}
+		sort.SliceStable(shifts, func(i, j int) bool { return shifts[i].ham < shifts[j].ham })
+		for _, sh := range shifts {
+			ss.LogTuning(dt, gl, "Shift", sh.ham, sh.act)
+		}
+		ss.TuningPlot.GoUpdate()
+	}
+	ss.GbarL = gbarL
+	ss.NetView = nv
+	ss.Init()
+	ss.Stopped()
+}
+
+//////////////////////////////////////////////
+//  TuningLog
+
+// LogTuning adds a row to the TuningLog with the response for given
+// GbarL, type of corruption and Hamming distance
+func (ss *Sim) LogTuning(dt *etable.Table, gbarL float32, typ string, ham int, act float64) {
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellString("Cond", row, fmt.Sprintf("%s GbarL=%g", typ, gbarL))
+	dt.SetCellFloat("GbarL", row, float64(gbarL))
+	dt.SetCellString("Type", row, typ)
+	dt.SetCellFloat("Hamming", row, float64(ham))
+	dt.SetCellFloat("Act", row, act)
+}
+
+func (ss *Sim) ConfigTuningLog(dt *etable.Table) {
+	dt.SetMetaData("name", "TuningLog")
+	dt.SetMetaData("desc", "RecvNeuron activity for corrupted versions of the target pattern, as a function of Hamming distance")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Cond", etensor.STRING, nil, nil},
+		{"GbarL", etensor.FLOAT64, nil, nil},
+		{"Type", etensor.STRING, nil, nil},
+		{"Hamming", etensor.FLOAT64, nil, nil},
+		{"Act", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigTuningPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "Detector Tuning Curve Plot"
+	plt.Params.XAxisCol = "Hamming"
+	plt.Params.LegendCol = "Cond"
+	plt.Params.Points = true
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Cond", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("GbarL", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Type", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Hamming", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Act", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	return plt
+}
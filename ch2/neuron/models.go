@@ -0,0 +1,233 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/emer/leabra/leabra"
+	"github.com/emer/leabra/spike"
+	"github.com/goki/ki/kit"
+	"github.com/goki/mat32"
+)
+
+// NeuronModel is the type of neuron model to run
+type NeuronModel int32
+
+//go:generate stringer -type=NeuronModel
+
+var KiT_NeuronModel = kit.Enums.AddEnum(NeuronModelN, kit.NotBitFlag, nil)
+
+func (ev NeuronModel) MarshalJSON() ([]byte, error)  { return kit.EnumMarshalJSON(ev) }
+func (ev *NeuronModel) UnmarshalJSON(b []byte) error { return kit.EnumUnmarshalJSON(ev, b) }
+
+const (
+	// Leabra is the standard Leabra point neuron, with Spike selecting
+	// discrete spiking vs. rate code
+	Leabra NeuronModel = iota
+
+	// AdEx is the adaptive exponential integrate-and-fire model
+	// of Brette & Gerstner (2005)
+	AdEx
+
+	// HH is the classic Hodgkin & Huxley (1952) model with
+	// voltage-gated Na and K channels
+	HH
+
+	NeuronModelN
+)
+
+// VmFmMV returns the normalized Vm used in Leabra (0 = -100mV, 1 = 0mV)
+// from given membrane potential in mV
+func VmFmMV(mv float32) float32 {
+	return (mv + 100) / 100
+}
+
+// ModelState is the state of the AdEx or HH neuron, in biological units
+type ModelState struct {
+	V float32 `desc:"membrane potential, mV"`
+	W float32 `desc:"AdEx adaptation current, pA"`
+	M float32 `desc:"HH Na activation gate"`
+	H float32 `desc:"HH Na inactivation gate"`
+	N float32 `desc:"HH K activation gate"`
+}
+
+// AdExParams are the parameters of the AdEx model, with defaults from
+// Brette & Gerstner (2005).  Units are pF, nS, mV, pA and msec,
+// with one cycle = 1 msec.
+type AdExParams struct {
+	C      float32 `def:"281" desc:"membrane capacitance, pF"`
+	GL     float32 `def:"30" desc:"leak conductance, nS"`
+	EL     float32 `def:"-70.6" desc:"leak reversal potential, mV"`
+	VT     float32 `def:"-50.4" desc:"threshold potential for the exponential spike current, mV"`
+	DeltaT float32 `def:"2" desc:"slope factor of the exponential spike current, mV"`
+	VPeak  float32 `def:"0" desc:"potential at which a spike is counted and Vm is reset, mV"`
+	VR     float32 `def:"-70.6" desc:"reset potential after a spike, mV"`
+	A      float32 `def:"4" desc:"subthreshold adaptation conductance, nS"`
+	B      float32 `def:"80.5" desc:"increment in adaptation current after each spike, pA"`
+	TauW   float32 `def:"144" desc:"time constant of the adaptation current, msec"`
+	GeMax  float32 `def:"100" desc:"excitatory synaptic conductance for a net Ge (Ge * GbarE) of 1, nS"`
+	EE     float32 `def:"0" desc:"excitatory reversal potential, mV"`
+	NSteps int     `def:"10" min:"1" desc:"number of integration steps per cycle"`
+}
+
+func (ap *AdExParams) Defaults() {
+	ap.C = 281
+	ap.GL = 30
+	ap.EL = -70.6
+	ap.VT = -50.4
+	ap.DeltaT = 2
+	ap.VPeak = 0
+	ap.VR = -70.6
+	ap.A = 4
+	ap.B = 80.5
+	ap.TauW = 144
+	ap.GeMax = 100
+	ap.EE = 0
+	ap.NSteps = 10
+}
+
+// Init initializes the state to rest
+func (ap *AdExParams) Init(st *ModelState) {
+	*st = ModelState{V: ap.EL}
+}
+
+// Cycle updates the state over one cycle with given net excitatory
+// conductance, returning whether it spiked and the average rate of
+// change of V over the cycle (mV / msec)
+func (ap *AdExParams) Cycle(st *ModelState, ge float32) (spiked bool, inet float32) {
+	ns := ap.NSteps
+	if ns < 1 {
+		ns = 1
+	}
+	dt := 1 / float32(ns)
+	gE := ge * ap.GeMax
+	for i := 0; i < ns; i++ {
+		v := st.V
+		iExp := ap.GL * ap.DeltaT * mat32.Exp(mat32.Min((v-ap.VT)/ap.DeltaT, 20)) // limit to avoid overflow
+		dv := (-ap.GL*(v-ap.EL) + iExp + gE*(ap.EE-v) - st.W) / ap.C
+		dw := (ap.A*(v-ap.EL) - st.W) / ap.TauW
+		st.V += dt * dv
+		st.W += dt * dw
+		inet += dt * dv
+		if st.V >= ap.VPeak {
+			st.V = ap.VR
+			st.W += ap.B
+			spiked = true
+		}
+	}
+	return
+}
+
+// HHParams are the parameters of the classic Hodgkin & Huxley (1952) model,
+// in the modern convention of rest at -65mV.  Units are uF/cm^2, mS/cm^2,
+// mV and msec, with one cycle = 1 msec.
+type HHParams struct {
+	C        float32 `def:"1" desc:"membrane capacitance, uF/cm^2"`
+	GNa      float32 `def:"120" desc:"maximal Na conductance, mS/cm^2"`
+	GK       float32 `def:"36" desc:"maximal K conductance, mS/cm^2"`
+	GL       float32 `def:"0.3" desc:"leak conductance, mS/cm^2"`
+	ENa      float32 `def:"50" desc:"Na reversal potential, mV"`
+	EK       float32 `def:"-77" desc:"K reversal potential, mV"`
+	EL       float32 `def:"-54.387" desc:"leak reversal potential, mV"`
+	GeMax    float32 `def:"1" desc:"excitatory synaptic conductance for a net Ge (Ge * GbarE) of 1, mS/cm^2"`
+	EE       float32 `def:"0" desc:"excitatory reversal potential, mV"`
+	SpikeThr float32 `def:"0" desc:"a spike is counted when V crosses this potential going up, mV"`
+	NSteps   int     `def:"100" min:"1" desc:"number of integration steps per cycle"`
+}
+
+func (hp *HHParams) Defaults() {
+	hp.C = 1
+	hp.GNa = 120
+	hp.GK = 36
+	hp.GL = 0.3
+	hp.ENa = 50
+	hp.EK = -77
+	hp.EL = -54.387
+	hp.GeMax = 1
+	hp.EE = 0
+	hp.SpikeThr = 0
+	hp.NSteps = 100
+}
+
+// vtrap returns x / (1 - exp(-x / y)), handling the singularity at x = 0
+func vtrap(x, y float32) float32 {
+	if mat32.Abs(x/y) < 1e-6 {
+		return y * (1 + x/(2*y))
+	}
+	return x / (1 - mat32.Exp(-x/y))
+}
+
+// Rates returns the opening (alpha) and closing (beta) rates of the
+// m, h and n gates at given potential, per msec
+func (hp *HHParams) Rates(v float32) (am, bm, ah, bh, an, bn float32) {
+	am = 0.1 * vtrap(v+40, 10)
+	bm = 4 * mat32.Exp(-(v+65)/18)
+	ah = 0.07 * mat32.Exp(-(v+65)/20)
+	bh = 1 / (1 + mat32.Exp(-(v+35)/10))
+	an = 0.01 * vtrap(v+55, 10)
+	bn = 0.125 * mat32.Exp(-(v+65)/80)
+	return
+}
+
+// Init initializes the state to rest, with the gates at their
+// steady-state values
+func (hp *HHParams) Init(st *ModelState) {
+	v := float32(-65)
+	am, bm, ah, bh, an, bn := hp.Rates(v)
+	*st = ModelState{V: v, M: am / (am + bm), H: ah / (ah + bh), N: an / (an + bn)}
+}
+
+// Cycle updates the state over one cycle with given net excitatory
+// conductance, returning whether it spiked and the average rate of
+// change of V over the cycle (mV / msec)
+func (hp *HHParams) Cycle(st *ModelState, ge float32) (spiked bool, inet float32) {
+	ns := hp.NSteps
+	if ns < 1 {
+		ns = 1
+	}
+	dt := 1 / float32(ns)
+	gE := ge * hp.GeMax
+	for i := 0; i < ns; i++ {
+		v := st.V
+		am, bm, ah, bh, an, bn := hp.Rates(v)
+		st.M += dt * (am*(1-st.M) - bm*st.M)
+		st.H += dt * (ah*(1-st.H) - bh*st.H)
+		st.N += dt * (an*(1-st.N) - bn*st.N)
+		iNa := hp.GNa * st.M * st.M * st.M * st.H * (hp.ENa - v)
+		n2 := st.N * st.N
+		iK := hp.GK * n2 * n2 * (hp.EK - v)
+		dv := (iNa + iK + hp.GL*(hp.EL-v) + gE*(hp.EE-v)) / hp.C
+		st.V += dt * dv
+		inet += dt * dv
+		if v < hp.SpikeThr && st.V >= hp.SpikeThr {
+			spiked = true
+		}
+	}
+	return
+}
+
+// SpikeRate updates the Spike, ISI, ISIAvg and Act of the neuron given
+// whether it spiked on this cycle, using the same estimate of the rate
+// from the ISI as the Leabra spiking neuron (see spike.ActParams)
+func SpikeRate(sk *spike.ActParams, nrn *leabra.Neuron, spiked bool) {
+	if spiked {
+		nrn.Spike = 1
+		if nrn.ISIAvg == -1 {
+			nrn.ISIAvg = -2
+		} else if nrn.ISI > 0 {
+			sk.Spike.AvgFmISI(&nrn.ISIAvg, nrn.ISI+1)
+		}
+		nrn.ISI = 0
+	} else {
+		nrn.Spike = 0
+		if nrn.ISI >= 0 {
+			nrn.ISI += 1
+		}
+		if nrn.ISIAvg >= 0 && nrn.ISI > 0 && nrn.ISI > 1.2*nrn.ISIAvg {
+			sk.Spike.AvgFmISI(&nrn.ISIAvg, nrn.ISI)
+		}
+	}
+	nwAct := mat32.Min(sk.Spike.ActFmISI(nrn.ISIAvg, .001, 1), 1)
+	nrn.Act += sk.Dt.VmDt * (nwAct - nrn.Act)
+}
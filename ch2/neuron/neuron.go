@@ -71,6 +71,7 @@ var ParamSets = params.Sets{
 // as arguments to methods, and provides the core GUI interface (note the view tags
 // for the fields which provide hints to how things should be displayed).
 type Sim struct {
+	Model          NeuronModel     `desc:"which neuron model to run: the Leabra point neuron (using Spike), or the AdEx or Hodgkin-Huxley (HH) models, which have their own parameters and are driven by the same Ge input (scaled by GbarE)"`
 	Spike          bool            `desc:"use discrete spiking equations -- otherwise use Noisy X-over-X-plus-1 rate code activation function"`
 	GbarE          float32         `min:"0" step:"0.01" def:"0.3" desc:"excitatory conductance multiplier -- determines overall value of Ge which drives neuron to be more excited -- pushes up over threshold to fire if strong enough"`
 	GbarL          float32         `min:"0" step:"0.01" def:"0.3" desc:"leak conductance -- determines overall value of Gl which drives neuron to be less excited (inhibited) -- pushes back to resting membrane potential"`
@@ -81,11 +82,21 @@ type Sim struct {
 	NCycles        int             `min:"10" def:"200" desc:"total number of cycles to run"`
 	OnCycle        int             `min:"0" def:"10" desc:"when does excitatory input into neuron come on?"`
 	OffCycle       int             `min:"0" def:"160" desc:"when does excitatory input into neuron go off?"`
+	Input          InputParams     `view:"no-inline" desc:"time course of the excitatory input between OnCycle and OffCycle: steps, pulse trains, ramps, sinusoids, Poisson synaptic input, or values from a table"`
 	UpdtInterval   int             `min:"1" def:"10"  desc:"how often to update display (in cycles)"`
 	Net            *leabra.Network `view:"no-inline" desc:"the network -- click to view / edit parameters for layers, prjns, etc"`
 	SpikeParams    spike.ActParams `view:"no-inline" desc:"parameters for spiking funcion"`
+	AdEx           AdExParams      `view:"no-inline" desc:"parameters for the AdEx model"`
+	HH             HHParams        `view:"no-inline" desc:"parameters for the Hodgkin-Huxley model"`
+	ModelState     ModelState      `view:"-" desc:"state of the AdEx or HH model"`
 	TstCycLog      *etable.Table   `view:"no-inline" desc:"testing trial-level log data -- click to see record of network's response to each input"`
 	SpikeVsRateLog *etable.Table   `view:"no-inline" desc:"plot of measured spike rate vs. noisy X/X+1 rate function"`
+	SpikeStatsRuns int             `min:"1" def:"50" desc:"number of runs for the spike train statistics (Spike Stats), which should have Noise or PoissonInput to vary across runs"`
+	SpikeStats     SpikeStats      `view:"no-inline" desc:"spike train statistics over SpikeStatsRuns runs, with ISI histogram, PSTH aligned to OnCycle, and Summary of rate, CV and Fano factor"`
+	ParamMap       ParamMapParams  `view:"no-inline" desc:"parameters for the parameter map (Run Param Map), sweeping one or two parameters and measuring the response"`
+	ParamMapLog    *etable.Table   `view:"no-inline" desc:"parameter map measures (rate, latency, adaptation) for each combination of X and Y parameter values"`
+	ParamMapGrid   *etable.Table   `view:"no-inline" desc:"parameter map measures as heatmaps of Y by X parameter values"`
+	ParamMapFit    *etable.Table   `view:"no-inline" desc:"XX1 gain and threshold fit to the parameter map Act as a function of X, for each Y value"`
 	Params         params.Sets     `view:"no-inline" desc:"full collection of param sets -- not really interesting for this model"`
 
 	Cycle int `inactive:"+" desc:"current cycle of updating"`
@@ -96,6 +107,10 @@ type Sim struct {
 	ToolBar         *gi.ToolBar      `view:"-" desc:"the master toolbar"`
 	TstCycPlot      *eplot.Plot2D    `view:"-" desc:"the test-trial plot"`
 	SpikeVsRatePlot *eplot.Plot2D    `view:"-" desc:"the spike vs. rate plot"`
+	ParamMapPlot    *eplot.Plot2D    `view:"-" desc:"the parameter map plot"`
+	ParamMapFitPlot *eplot.Plot2D    `view:"-" desc:"the parameter map XX1 fit plot"`
+	ISIHistPlot     *eplot.Plot2D    `view:"-" desc:"the spike stats ISI histogram plot"`
+	PSTHPlot        *eplot.Plot2D    `view:"-" desc:"the spike stats PSTH plot"`
 	IsRunning       bool             `view:"-" desc:"true if sim is running"`
 	StopNow         bool             `view:"-" desc:"flag to stop running"`
 }
@@ -112,9 +127,17 @@ func (ss *Sim) New() {
 	ss.Net = &leabra.Network{}
 	ss.TstCycLog = &etable.Table{}
 	ss.SpikeVsRateLog = &etable.Table{}
+	ss.ParamMapLog = &etable.Table{}
+	ss.ParamMapGrid = &etable.Table{}
+	ss.ParamMapFit = &etable.Table{}
+	ss.SpikeStats.ISIHist = &etable.Table{}
+	ss.SpikeStats.PSTH = &etable.Table{}
+	ss.SpikeStats.Summary = &etable.Table{}
 	ss.Params = ParamSets
 	ss.Defaults()
 	ss.SpikeParams.Defaults()
+	ss.ParamMap.Defaults()
+	ss.SpikeStats.Defaults()
 }
 
 // Defaults sets default params
@@ -131,6 +154,11 @@ func (ss *Sim) Defaults() {
 	ss.NCycles = 200
 	ss.OnCycle = 10
 	ss.OffCycle = 160
+	ss.SpikeStatsRuns = 50
+	ss.Input.Defaults()
+	ss.Model = Leabra
+	ss.AdEx.Defaults()
+	ss.HH.Defaults()
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////
@@ -141,6 +169,10 @@ func (ss *Sim) Config() {
 	ss.ConfigNet(ss.Net)
 	ss.ConfigTstCycLog(ss.TstCycLog)
 	ss.ConfigSpikeVsRateLog(ss.SpikeVsRateLog)
+	ss.ConfigParamMapLog(ss.ParamMapLog, 0)
+	ss.ConfigParamMapGrid(ss.ParamMapGrid, ss.ParamMap.YN, ss.ParamMap.XN)
+	ss.ConfigParamMapFit(ss.ParamMapFit, 0)
+	ss.SpikeStats.Compute()
 }
 
 func (ss *Sim) ConfigNet(net *leabra.Network) {
@@ -202,6 +234,8 @@ func (ss *Sim) RunCycles() {
 	ss.StopNow = false
 	ss.Net.InitActs()
 	ss.SetParams("", false)
+	ss.InitModel()
+	ss.Input.Init()
 	ly := ss.Net.LayerByName("Neuron").(leabra.LeabraLayer).AsLeabra()
 	nrn := &(ly.Neurons[0])
 	inputOn := false
@@ -214,16 +248,17 @@ func (ss *Sim) RunCycles() {
 			inputOn = false
 		}
 		nrn.Noise = float32(ly.Act.Noise.Gen(-1))
-		if inputOn {
-			nrn.Ge = 1
-		} else {
-			nrn.Ge = 0
-		}
+		nrn.Ge = ss.Input.Input(cyc, ss.OnCycle, ss.OffCycle)
 		nrn.Ge += nrn.Noise // GeNoise
 		nrn.Gi = 0
-		if ss.Spike {
+		switch {
+		case ss.Model == AdEx:
+			ss.AdExUpdt(ss.Net, inputOn)
+		case ss.Model == HH:
+			ss.HHUpdt(ss.Net, inputOn)
+		case ss.Spike:
 			ss.SpikeUpdt(ss.Net, inputOn)
-		} else {
+		default:
 			ss.RateUpdt(ss.Net, inputOn)
 		}
 		ss.LogTstCyc(ss.TstCycLog, ss.Cycle)
@@ -257,6 +292,61 @@ func (ss *Sim) SpikeUpdt(nt *leabra.Network, inputOn bool) {
 	nrn.Ge = nrn.Ge * ly.Act.Gbar.E // display effective Ge
 }
 
+// InitModel initializes the state of the AdEx or HH model, if selected
+func (ss *Sim) InitModel() {
+	switch ss.Model {
+	case AdEx:
+		ss.AdEx.Init(&ss.ModelState)
+	case HH:
+		ss.HH.Init(&ss.ModelState)
+	default:
+		return
+	}
+	ly := ss.Net.LayerByName("Neuron").(leabra.LeabraLayer).AsLeabra()
+	nrn := &(ly.Neurons[0])
+	nrn.Vm = VmFmMV(ss.ModelState.V)
+}
+
+// AdExUpdt updates the neuron using the AdEx model, with Vm and Inet
+// in normalized units as for the Leabra neuron, and the adaptation
+// current in nA as Gk
+func (ss *Sim) AdExUpdt(nt *leabra.Network, inputOn bool) {
+	ly := ss.Net.LayerByName("Neuron").(leabra.LeabraLayer).AsLeabra()
+	nrn := &(ly.Neurons[0])
+	nrn.Ge = nrn.Ge * ly.Act.Gbar.E // display effective Ge
+	spiked, inet := ss.AdEx.Cycle(&ss.ModelState, nrn.Ge)
+	nrn.Vm = VmFmMV(ss.ModelState.V)
+	nrn.Inet = inet / 100
+	nrn.Gk = ss.ModelState.W / 1000
+	SpikeRate(&ss.SpikeParams, nrn, spiked)
+}
+
+// HHUpdt updates the neuron using the Hodgkin-Huxley model, with Vm and
+// Inet in normalized units as for the Leabra neuron, and the proportion
+// of open K channels (n^4) as Gk
+func (ss *Sim) HHUpdt(nt *leabra.Network, inputOn bool) {
+	ly := ss.Net.LayerByName("Neuron").(leabra.LeabraLayer).AsLeabra()
+	nrn := &(ly.Neurons[0])
+	nrn.Ge = nrn.Ge * ly.Act.Gbar.E // display effective Ge
+	spiked, inet := ss.HH.Cycle(&ss.ModelState, nrn.Ge)
+	nrn.Vm = VmFmMV(ss.ModelState.V)
+	nrn.Inet = inet / 100
+	n2 := ss.ModelState.N * ss.ModelState.N
+	nrn.Gk = n2 * n2
+	SpikeRate(&ss.SpikeParams, nrn, spiked)
+}
+
+// OpenInputTable opens the Input.Table for the TableInput protocol from
+// given file, with a Ge column of input values, one row per cycle
+// starting at OnCycle, and selects the TableInput protocol
+func (ss *Sim) OpenInputTable(filename gi.FileName) {
+	if err := ss.Input.OpenTable(string(filename)); err != nil {
+		log.Println(err)
+		return
+	}
+	ss.Input.Protocol = TableInput
+}
+
 // Stop tells the sim to stop running
 func (ss *Sim) Stop() {
 	ss.StopNow = true
@@ -267,6 +357,10 @@ func (ss *Sim) SpikeVsRate() {
 	row := 0
 	nsamp := 100
 	// ss.KNaAdapt = false
+	// Defaults at the end resets these user settings too
+	model, input, adex, hh, nstats := ss.Model, ss.Input, ss.AdEx, ss.HH, ss.SpikeStatsRuns
+	ss.Model = Leabra
+	ss.Input.Protocol = StepInput
 	for gbarE := 0.1; gbarE <= 0.7; gbarE += 0.025 {
 		ss.GbarE = float32(gbarE)
 		spike := float64(0)
@@ -300,6 +394,10 @@ func (ss *Sim) SpikeVsRate() {
 		row++
 	}
 	ss.Defaults()
+	ss.Model = model
+	ss.Input = input
+	ss.AdEx, ss.HH = adex, hh
+	ss.SpikeStatsRuns = nstats
 	ss.SpikeVsRatePlot.GoUpdate()
 }
 
@@ -323,6 +421,7 @@ func (ss *Sim) SetParams(sheet string, setMsg bool) error {
 	ly.Act.Erev.L = float32(ss.ErevL)
 	ly.Act.Noise.Var = float64(ss.Noise)
 	ly.Act.KNa.On = ss.KNaAdapt
+	ss.ParamMap.ApplySweep()
 	ly.Act.Update()
 	ss.SpikeParams.ActParams = ly.Act // keep sync'd
 	ss.SpikeParams.KNa.On = ss.KNaAdapt
@@ -370,6 +469,7 @@ func (ss *Sim) LogTstCyc(dt *etable.Table, cyc int) {
 	nrn := &(ly.Neurons[0])
 
 	dt.SetCellFloat("Cycle", row, float64(cyc))
+	dt.SetCellFloat("Input", row, float64(ss.Input.Cur))
 	dt.SetCellFloat("Ge", row, float64(nrn.Ge))
 	dt.SetCellFloat("Inet", row, float64(nrn.Inet))
 	dt.SetCellFloat("Vm", row, float64(nrn.Vm))
@@ -394,6 +494,7 @@ func (ss *Sim) ConfigTstCycLog(dt *etable.Table) {
 	nt := ss.NCycles // max cycles
 	sch := etable.Schema{
 		{"Cycle", etensor.INT64, nil, nil},
+		{"Input", etensor.FLOAT64, nil, nil},
 		{"Ge", etensor.FLOAT64, nil, nil},
 		{"Inet", etensor.FLOAT64, nil, nil},
 		{"Vm", etensor.FLOAT64, nil, nil},
@@ -412,6 +513,7 @@ func (ss *Sim) ConfigTstCycPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot
 	plt.SetTable(dt)
 	// order of params: on, fixMin, min, fixMax, max
 	plt.SetColParams("Cycle", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Input", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 1)
 	plt.SetColParams("Ge", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
 	plt.SetColParams("Inet", eplot.On, eplot.FixMin, -.2, eplot.FixMax, 1)
 	plt.SetColParams("Vm", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
@@ -467,6 +569,58 @@ func (ss *Sim) ConfigSpikeVsRatePlot(plt *eplot.Plot2D, dt *etable.Table) *eplot
 	return plt
 }
 
+//////////////////////////////////////////////
+//  SpikeStats
+
+// RunSpikeStats runs RunCycles SpikeStatsRuns times in spiking mode,
+// recording the spike train of each run in SpikeStats, and computes
+// the statistics, with the PSTH aligned to OnCycle and the other
+// statistics computed between OnCycle and OffCycle
+func (ss *Sim) RunSpikeStats() {
+	if ss.Model == Leabra && !ss.Spike {
+		log.Println("Spike Stats: turn on Spike (or use the AdEx or HH Model) to run in spiking mode")
+		return
+	}
+	st := &ss.SpikeStats
+	st.Reset()
+	st.Align = ss.OnCycle
+	st.Start = ss.OnCycle
+	st.End = ss.OffCycle
+	for run := 0; run < ss.SpikeStatsRuns; run++ {
+		ss.RunCycles()
+		if ss.StopNow {
+			break
+		}
+		st.AddFromTable(ss.TstCycLog, "Spike")
+	}
+	st.Compute()
+	ss.ISIHistPlot.GoUpdate()
+	ss.PSTHPlot.GoUpdate()
+}
+
+func (ss *Sim) ConfigISIHistPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "Neuron ISI Histogram Plot"
+	plt.Params.XAxisCol = "ISI"
+	plt.Params.Type = eplot.Bar
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("ISI", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 1)
+	plt.SetColParams("Count", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 1)
+	plt.SetColParams("Prob", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 1)
+	return plt
+}
+
+func (ss *Sim) ConfigPSTHPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "Neuron Peri-Stimulus Time Histogram Plot"
+	plt.Params.XAxisCol = "Time"
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Time", eplot.Off, eplot.FloatMin, 0, eplot.FloatMax, 1)
+	plt.SetColParams("Count", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 1)
+	plt.SetColParams("Rate", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 1)
+	return plt
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////
 // 		Gui
 
@@ -519,6 +673,18 @@ See <a href="https://github.com/CompCogNeuro/sims/blob/master/ch2/neuron/README.
 	plt = tv.AddNewTab(eplot.KiT_Plot2D, "SpikeVsRatePlot").(*eplot.Plot2D)
 	ss.SpikeVsRatePlot = ss.ConfigSpikeVsRatePlot(plt, ss.SpikeVsRateLog)
 
+	plt = tv.AddNewTab(eplot.KiT_Plot2D, "ParamMapPlot").(*eplot.Plot2D)
+	ss.ParamMapPlot = ss.ConfigParamMapPlot(plt, ss.ParamMapLog)
+
+	plt = tv.AddNewTab(eplot.KiT_Plot2D, "ParamMapFitPlot").(*eplot.Plot2D)
+	ss.ParamMapFitPlot = ss.ConfigParamMapFitPlot(plt, ss.ParamMapFit)
+
+	plt = tv.AddNewTab(eplot.KiT_Plot2D, "ISIHistPlot").(*eplot.Plot2D)
+	ss.ISIHistPlot = ss.ConfigISIHistPlot(plt, ss.SpikeStats.ISIHist)
+
+	plt = tv.AddNewTab(eplot.KiT_Plot2D, "PSTHPlot").(*eplot.Plot2D)
+	ss.PSTHPlot = ss.ConfigPSTHPlot(plt, ss.SpikeStats.PSTH)
+
 	split.SetSplits(.2, .8)
 
 	tbar.AddAction(gi.ActOpts{Label: "Init", Icon: "update", Tooltip: "Initialize everything including network weights, and start over.  Also applies current params.", UpdateFunc: func(act *gi.Action) {
@@ -566,6 +732,28 @@ See <a href="https://github.com/CompCogNeuro/sims/blob/master/ch2/neuron/README.
 		}
 	})
 
+	tbar.AddAction(gi.ActOpts{Label: "Spike Stats", Icon: "play", Tooltip: "Runs RunCycles SpikeStatsRuns times, and computes spike train statistics: ISI histogram, PSTH, CV and Fano factor -- see ISIHistPlot, PSTHPlot and SpikeStats.Summary.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if !ss.IsRunning {
+			ss.IsRunning = true
+			ss.RunSpikeStats()
+			ss.IsRunning = false
+			vp.SetNeedsFullRender()
+		}
+	})
+
+	tbar.AddAction(gi.ActOpts{Label: "Param Map", Icon: "play", Tooltip: "Sweeps the ParamMap X and Y parameters over their values, measuring firing rate, latency and adaptation, and fitting the XX1 function -- see ParamMapPlot, ParamMapFitPlot and ParamMapGrid.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if !ss.IsRunning {
+			ss.IsRunning = true
+			ss.RunParamMap()
+			ss.IsRunning = false
+			vp.SetNeedsFullRender()
+		}
+	})
+
 	tbar.AddAction(gi.ActOpts{Label: "Defaults", Icon: "update", Tooltip: "Restore initial default parameters.", UpdateFunc: func(act *gi.Action) {
 		act.SetActiveStateUpdt(!ss.IsRunning)
 	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
@@ -641,6 +829,23 @@ See <a href="https://github.com/CompCogNeuro/sims/blob/master/ch2/neuron/README.
 // These props register Save methods so they can be used
 var SimProps = ki.Props{
 	"CallMethods": ki.PropSlice{
+		{"RunSpikeStats", ki.Props{
+			"desc": "runs RunCycles SpikeStatsRuns times, and computes spike train statistics: ISI histogram, PSTH, CV and Fano factor",
+			"icon": "play",
+		}},
+		{"RunParamMap", ki.Props{
+			"desc": "sweeps the ParamMap X and Y parameters over their values, measuring firing rate, latency and adaptation, and fitting the XX1 function",
+			"icon": "play",
+		}},
+		{"OpenInputTable", ki.Props{
+			"desc": "open a table of input values for the TableInput protocol, with a Ge column and one row per cycle starting at OnCycle",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".tsv,.csv",
+				}},
+			},
+		}},
 		{"SaveWeights", ki.Props{
 			"desc": "save network weights to file",
 			"icon": "file-save",
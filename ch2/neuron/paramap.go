@@ -0,0 +1,493 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+)
+
+// ParamMapParams are the parameters for the parameter map, which sweeps one
+// or two parameters over a grid of values, running RunCycles for each
+// combination and measuring the response of the neuron.  Parameters are
+// given by the path to a numeric or bool field of the Sim (e.g., GbarE,
+// Noise, KNaAdapt, AdEx.A), or of the neuron layer with a Layer. prefix
+// (e.g., Layer.Act.KNa.Fast.Max).  Bool fields are true for values >= 0.5.
+type ParamMapParams struct {
+	XParam string  `desc:"parameter varied along the X axis, e.g., GbarE for a standard f-I curve"`
+	XMin   float32 `desc:"first value of the X parameter"`
+	XMax   float32 `desc:"last value of the X parameter"`
+	XN     int     `min:"1" desc:"number of values of the X parameter"`
+	YParam string  `desc:"parameter varied across conditions (separate lines in the plot) -- leave empty for a single f-I curve"`
+	YMin   float32 `desc:"first value of the Y parameter"`
+	YMax   float32 `desc:"last value of the Y parameter"`
+	YN     int     `min:"1" desc:"number of values of the Y parameter"`
+	NReps  int     `min:"1" def:"1" desc:"number of runs for each combination of values, which are averaged -- use more with Noise"`
+
+	sweep []sweepVal // current values of the params being swept, re-applied after SetParams
+}
+
+// sweepVal is the current value of a parameter being swept by RunParamMap
+type sweepVal struct {
+	v   reflect.Value
+	val float64
+}
+
+func (pm *ParamMapParams) Defaults() {
+	pm.XParam = "GbarE"
+	pm.XMin = 0.1
+	pm.XMax = 0.7
+	pm.XN = 25
+	pm.YParam = "GbarL"
+	pm.YMin = 0.1
+	pm.YMax = 0.5
+	pm.YN = 5
+	pm.NReps = 1
+}
+
+// Vals returns n values evenly spaced from min to max
+func Vals(min, max float32, n int) []float64 {
+	if n < 1 {
+		n = 1
+	}
+	vals := make([]float64, n)
+	for i := range vals {
+		if n == 1 {
+			vals[i] = float64(min)
+		} else {
+			vals[i] = float64(min) + float64(i)*float64(max-min)/float64(n-1)
+		}
+	}
+	return vals
+}
+
+// SpikeMeas are measures of the response of the neuron to the input
+type SpikeMeas struct {
+	Rate    float64 `desc:"spikes per second (with 1 cycle = 1 msec) between OnCycle and OffCycle"`
+	Act     float64 `desc:"Act (rate code estimate) on the last cycle before OffCycle"`
+	Latency float64 `desc:"cycles from OnCycle to the first spike -- NaN if no spikes"`
+	Adapt   float64 `desc:"adaptation index: average over successive ISIs of (ISI[k+1] - ISI[k]) / (ISI[k+1] + ISI[k]) -- positive for slowing, NaN if fewer than 3 spikes"`
+}
+
+// MeasureSpikes returns the SpikeMeas from the Spike and Act columns of
+// the TstCycLog, for input between onCyc and offCyc
+func MeasureSpikes(dt *etable.Table, onCyc, offCyc int) SpikeMeas {
+	ms := SpikeMeas{Latency: math.NaN(), Adapt: math.NaN()}
+	if offCyc > dt.Rows {
+		offCyc = dt.Rows
+	}
+	if onCyc < 0 || offCyc <= onCyc {
+		ms.Rate = math.NaN()
+		ms.Act = math.NaN()
+		return ms
+	}
+	var spks []int
+	for cyc := onCyc; cyc < offCyc; cyc++ {
+		if dt.CellFloat("Spike", cyc) > 0.5 {
+			spks = append(spks, cyc)
+		}
+	}
+	ms.Rate = 1000 * float64(len(spks)) / float64(offCyc-onCyc)
+	ms.Act = dt.CellFloat("Act", offCyc-1)
+	if len(spks) > 0 {
+		ms.Latency = float64(spks[0] - onCyc)
+	}
+	if len(spks) >= 3 {
+		sum := 0.0
+		for i := 2; i < len(spks); i++ {
+			isi0 := float64(spks[i-1] - spks[i-2])
+			isi1 := float64(spks[i] - spks[i-1])
+			sum += (isi1 - isi0) / (isi1 + isi0)
+		}
+		ms.Adapt = sum / float64(len(spks)-2)
+	}
+	return ms
+}
+
+// MeanMeas returns the mean of given measures, ignoring NaN values
+func MeanMeas(ms []SpikeMeas) SpikeMeas {
+	var sum [4]float64
+	var n [4]int
+	for _, m := range ms {
+		for i, v := range []float64{m.Rate, m.Act, m.Latency, m.Adapt} {
+			if !math.IsNaN(v) {
+				sum[i] += v
+				n[i]++
+			}
+		}
+	}
+	var mn [4]float64
+	for i := range mn {
+		if n[i] == 0 {
+			mn[i] = math.NaN()
+		} else {
+			mn[i] = sum[i] / float64(n[i])
+		}
+	}
+	return SpikeMeas{Rate: mn[0], Act: mn[1], Latency: mn[2], Adapt: mn[3]}
+}
+
+// XX1 is the X-over-X-plus-1 function with given gain and threshold
+func XX1(x, gain, thr float64) float64 {
+	z := gain * (x - thr)
+	if z <= 0 {
+		return 0
+	}
+	return z / (z + 1)
+}
+
+// FitXX1 fits the XX1 function to given activations as a function of x,
+// by least squares, returning the gain, threshold and sum squared error.
+// NaN acts are ignored, and gain and thr are NaN if no act is > 0.
+func FitXX1(xs, acts []float64) (gain, thr, sse float64) {
+	gain, thr, sse = math.NaN(), math.NaN(), math.NaN()
+	xmin, xmax := math.Inf(1), math.Inf(-1)
+	anyAct := false
+	for i, a := range acts {
+		if math.IsNaN(a) {
+			continue
+		}
+		xmin = math.Min(xmin, xs[i])
+		xmax = math.Max(xmax, xs[i])
+		if a > 0 {
+			anyAct = true
+		}
+	}
+	if !anyAct {
+		return
+	}
+	err := func(g, t float64) float64 {
+		e := 0.0
+		for i, a := range acts {
+			if math.IsNaN(a) {
+				continue
+			}
+			d := a - XX1(xs[i], g, t)
+			e += d * d
+		}
+		return e
+	}
+	// grid search over threshold and log gain, refined around the best
+	rng := xmax - xmin
+	if rng == 0 {
+		rng = math.Max(math.Abs(xmin), 1)
+	}
+	tlo, thi := xmin-rng, xmax
+	glo, ghi := -1.0, 4.0 // log10 gain
+	n := 50
+	for iter := 0; iter < 5; iter++ {
+		bt, bg := tlo, glo
+		best := math.Inf(1)
+		for ti := 0; ti <= n; ti++ {
+			t := tlo + float64(ti)*(thi-tlo)/float64(n)
+			for gi := 0; gi <= n; gi++ {
+				g := glo + float64(gi)*(ghi-glo)/float64(n)
+				if e := err(math.Pow(10, g), t); e < best {
+					best, bt, bg = e, t, g
+				}
+			}
+		}
+		thr, gain, sse = bt, math.Pow(10, bg), best
+		tst := 2 * (thi - tlo) / float64(n)
+		gst := 2 * (ghi - glo) / float64(n)
+		tlo, thi = bt-tst, bt+tst
+		glo, ghi = bg-gst, bg+gst
+	}
+	return
+}
+
+// ParamByPath returns the value of the parameter at given path, which is
+// a field of the Sim, or of the neuron layer with a Layer. prefix
+func (ss *Sim) ParamByPath(path string) (reflect.Value, error) {
+	v := reflect.ValueOf(ss).Elem()
+	fp := path
+	if strings.HasPrefix(path, "Layer.") {
+		ly := ss.Net.LayerByName("Neuron").(leabra.LeabraLayer).AsLeabra()
+		v = reflect.ValueOf(ly).Elem()
+		fp = strings.TrimPrefix(path, "Layer.")
+	}
+	for _, nm := range strings.Split(fp, ".") {
+		if v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return v, fmt.Errorf("ParamMap: %s: %s is not within a struct", path, nm)
+		}
+		v = v.FieldByName(nm)
+		if !v.IsValid() {
+			return v, fmt.Errorf("ParamMap: %s: field %s not found", path, nm)
+		}
+	}
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64, reflect.Int, reflect.Int32, reflect.Int64, reflect.Bool:
+	default:
+		return v, fmt.Errorf("ParamMap: %s is not a number or bool", path)
+	}
+	if !v.CanSet() {
+		return v, fmt.Errorf("ParamMap: %s cannot be set", path)
+	}
+	return v, nil
+}
+
+// SetParamVal sets given parameter value (from ParamByPath) to given value
+func SetParamVal(v reflect.Value, val float64) {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(val)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(math.Round(val)))
+	case reflect.Bool:
+		v.SetBool(val >= 0.5)
+	}
+}
+
+// SetSweep sets given parameter (from ParamByPath) to given value, and
+// records it so that ApplySweep sets it again after SetParams, which
+// otherwise overwrites the Layer. params with those from the Base params
+func (pm *ParamMapParams) SetSweep(v reflect.Value, val float64) {
+	SetParamVal(v, val)
+	for i := range pm.sweep {
+		if pm.sweep[i].v.UnsafeAddr() == v.UnsafeAddr() {
+			pm.sweep[i].val = val
+			return
+		}
+	}
+	pm.sweep = append(pm.sweep, sweepVal{v: v, val: val})
+}
+
+// ApplySweep sets the params being swept by RunParamMap to their current
+// values -- called in SetParams, after the params have been set
+func (pm *ParamMapParams) ApplySweep() {
+	for _, sv := range pm.sweep {
+		SetParamVal(sv.v, sv.val)
+	}
+}
+
+// RunParamMap runs the parameter map: for each combination of the X and Y
+// parameter values, runs RunCycles NReps times and records the average
+// SpikeMeas in the ParamMapLog and ParamMapGrid, and fits the XX1 function
+// to the Act as a function of X for each Y value, in the ParamMapFit.
+// The parameters are restored at the end.  The Rate, Latency and Adapt
+// are measured from the spikes, so it must run in spiking mode.
+func (ss *Sim) RunParamMap() {
+	if ss.Model == Leabra && !ss.Spike {
+		log.Println("Param Map: turn on Spike (or use the AdEx or HH Model) to run in spiking mode")
+		return
+	}
+	pm := &ss.ParamMap
+	xv, err := ss.ParamByPath(pm.XParam)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	xs := Vals(pm.XMin, pm.XMax, pm.XN)
+	ys := []float64{0}
+	var yv reflect.Value
+	hasY := pm.YParam != ""
+	if hasY {
+		yv, err = ss.ParamByPath(pm.YParam)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		ys = Vals(pm.YMin, pm.YMax, pm.YN)
+	}
+	xsave := reflect.ValueOf(xv.Interface())
+	var ysave reflect.Value
+	if hasY {
+		ysave = reflect.ValueOf(yv.Interface())
+	}
+	nreps := pm.NReps
+	if nreps < 1 {
+		nreps = 1
+	}
+
+	ss.ConfigParamMapLog(ss.ParamMapLog, len(xs)*len(ys))
+	ss.ConfigParamMapGrid(ss.ParamMapGrid, len(ys), len(xs))
+	ss.ConfigParamMapFit(ss.ParamMapFit, len(ys))
+	if ss.ParamMapPlot != nil {
+		ss.ParamMapPlot.Params.XAxisLabel = pm.XParam
+	}
+	if ss.ParamMapFitPlot != nil {
+		ss.ParamMapFitPlot.Params.XAxisLabel = pm.YParam
+	}
+	acts := make([]float64, len(xs))
+	row := 0
+	for yi, y := range ys {
+		if hasY {
+			pm.SetSweep(yv, y)
+		}
+		for xi, x := range xs {
+			pm.SetSweep(xv, x)
+			ms := make([]SpikeMeas, 0, nreps)
+			for rep := 0; rep < nreps; rep++ {
+				ss.RunCycles()
+				if ss.StopNow {
+					break
+				}
+				ms = append(ms, MeasureSpikes(ss.TstCycLog, ss.OnCycle, ss.OffCycle))
+			}
+			if ss.StopNow {
+				break
+			}
+			m := MeanMeas(ms)
+			acts[xi] = m.Act
+			ss.LogParamMap(ss.ParamMapLog, row, x, y, m)
+			ss.LogParamMapGrid(ss.ParamMapGrid, yi*len(xs)+xi, m)
+			row++
+		}
+		if ss.StopNow {
+			break
+		}
+		gain, thr, sse := FitXX1(xs, acts)
+		ss.LogParamMapFit(ss.ParamMapFit, yi, y, gain, thr, sse)
+		if ss.ParamMapPlot != nil {
+			ss.ParamMapPlot.GoUpdate()
+		}
+		if ss.ParamMapFitPlot != nil {
+			ss.ParamMapFitPlot.GoUpdate()
+		}
+	}
+	pm.sweep = nil
+	xv.Set(xsave)
+	if hasY {
+		yv.Set(ysave)
+	}
+	ss.Init()
+}
+
+//////////////////////////////////////////////
+//  ParamMapLog
+
+// LogParamMap adds the measures for given X and Y values to the ParamMapLog
+func (ss *Sim) LogParamMap(dt *etable.Table, row int, x, y float64, m SpikeMeas) {
+	if dt.Rows <= row {
+		dt.SetNumRows(row + 1)
+	}
+	dt.SetCellFloat("X", row, x)
+	dt.SetCellFloat("Y", row, y)
+	dt.SetCellFloat("Rate", row, m.Rate)
+	dt.SetCellFloat("Act", row, m.Act)
+	dt.SetCellFloat("Latency", row, m.Latency)
+	dt.SetCellFloat("Adapt", row, m.Adapt)
+}
+
+func (ss *Sim) ConfigParamMapLog(dt *etable.Table, nt int) {
+	dt.SetMetaData("name", "ParamMapLog")
+	dt.SetMetaData("desc", "Record of neuron response measures for each X, Y parameter value: "+ss.ParamMap.XParam+", "+ss.ParamMap.YParam)
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"X", etensor.FLOAT64, nil, nil},
+		{"Y", etensor.FLOAT64, nil, nil},
+		{"Rate", etensor.FLOAT64, nil, nil},
+		{"Act", etensor.FLOAT64, nil, nil},
+		{"Latency", etensor.FLOAT64, nil, nil},
+		{"Adapt", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, nt)
+}
+
+func (ss *Sim) ConfigParamMapPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "Neuron Parameter Map (f-I curves) Plot"
+	plt.Params.XAxisCol = "X"
+	plt.Params.LegendCol = "Y"
+	plt.Params.XAxisLabel = ss.ParamMap.XParam
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("X", eplot.Off, eplot.FloatMin, 0, eplot.FloatMax, 1)
+	plt.SetColParams("Y", eplot.Off, eplot.FloatMin, 0, eplot.FloatMax, 1)
+	plt.SetColParams("Rate", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 1)
+	plt.SetColParams("Act", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("Latency", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 1)
+	plt.SetColParams("Adapt", eplot.Off, eplot.FloatMin, 0, eplot.FloatMax, 1)
+	return plt
+}
+
+//////////////////////////////////////////////
+//  ParamMapGrid
+
+// LogParamMapGrid sets the measures at given flat index (Y index * number
+// of X values + X index) in the ParamMapGrid
+func (ss *Sim) LogParamMapGrid(dt *etable.Table, idx int, m SpikeMeas) {
+	dt.SetCellTensorFloat1D("Rate", 0, idx, m.Rate)
+	dt.SetCellTensorFloat1D("Act", 0, idx, m.Act)
+	dt.SetCellTensorFloat1D("Latency", 0, idx, m.Latency)
+	dt.SetCellTensorFloat1D("Adapt", 0, idx, m.Adapt)
+}
+
+// ConfigParamMapGrid configures the ParamMapGrid, which has a single row
+// with the measures as heatmaps of Y (rows) by X (columns) values
+func (ss *Sim) ConfigParamMapGrid(dt *etable.Table, ny, nx int) {
+	dt.SetMetaData("name", "ParamMapGrid")
+	dt.SetMetaData("desc", "Heatmaps of neuron response measures, Y parameter (rows) by X parameter (columns): "+ss.ParamMap.YParam+" by "+ss.ParamMap.XParam)
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	shp := []int{ny, nx}
+	dnms := []string{"Y", "X"}
+	sch := etable.Schema{
+		{"Rate", etensor.FLOAT64, shp, dnms},
+		{"Act", etensor.FLOAT64, shp, dnms},
+		{"Latency", etensor.FLOAT64, shp, dnms},
+		{"Adapt", etensor.FLOAT64, shp, dnms},
+	}
+	dt.SetFromSchema(sch, 1)
+	for _, cl := range dt.Cols {
+		cl.SetMetaData("grid-fill", "1")
+	}
+}
+
+//////////////////////////////////////////////
+//  ParamMapFit
+
+// LogParamMapFit adds the XX1 fit for given Y value to the ParamMapFit
+func (ss *Sim) LogParamMapFit(dt *etable.Table, row int, y, gain, thr, sse float64) {
+	if dt.Rows <= row {
+		dt.SetNumRows(row + 1)
+	}
+	dt.SetCellFloat("Y", row, y)
+	dt.SetCellFloat("Gain", row, gain)
+	dt.SetCellFloat("Thr", row, thr)
+	dt.SetCellFloat("SSE", row, sse)
+}
+
+func (ss *Sim) ConfigParamMapFit(dt *etable.Table, nt int) {
+	dt.SetMetaData("name", "ParamMapFit")
+	dt.SetMetaData("desc", "XX1 function fit to Act as a function of "+ss.ParamMap.XParam+", for each value of "+ss.ParamMap.YParam)
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Y", etensor.FLOAT64, nil, nil},
+		{"Gain", etensor.FLOAT64, nil, nil},
+		{"Thr", etensor.FLOAT64, nil, nil},
+		{"SSE", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, nt)
+}
+
+func (ss *Sim) ConfigParamMapFitPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "Neuron Parameter Map XX1 Fit Plot"
+	plt.Params.XAxisCol = "Y"
+	plt.Params.XAxisLabel = ss.ParamMap.YParam
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Y", eplot.Off, eplot.FloatMin, 0, eplot.FloatMax, 1)
+	plt.SetColParams("Gain", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 1)
+	plt.SetColParams("Thr", eplot.On, eplot.FloatMin, 0, eplot.FloatMax, 1)
+	plt.SetColParams("SSE", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 1)
+	return plt
+}
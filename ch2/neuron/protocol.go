@@ -0,0 +1,172 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/emer/emergent/erand"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/gi"
+	"github.com/goki/ki/kit"
+	"github.com/goki/mat32"
+)
+
+// InputProtocol is the time course of the excitatory input to the neuron
+type InputProtocol int32
+
+//go:generate stringer -type=InputProtocol
+
+var KiT_InputProtocol = kit.Enums.AddEnum(InputProtocolN, kit.NotBitFlag, nil)
+
+func (ev InputProtocol) MarshalJSON() ([]byte, error)  { return kit.EnumMarshalJSON(ev) }
+func (ev *InputProtocol) UnmarshalJSON(b []byte) error { return kit.EnumUnmarshalJSON(ev, b) }
+
+const (
+	// StepInput is a constant input of Max from OnCycle to OffCycle
+	StepInput InputProtocol = iota
+
+	// TrainInput is a train of pulses of Max, each lasting PulseOn cycles,
+	// separated by PulseOff cycles, from OnCycle to OffCycle
+	TrainInput
+
+	// RampInput increases linearly from RampStart at OnCycle to Max at OffCycle
+	RampInput
+
+	// SineInput is a sinusoid of SineHz frequency, varying between 0 and Max
+	// from OnCycle to OffCycle, starting at 0 (minimum) at OnCycle
+	SineInput
+
+	// PoissonInput is the conductance from PoissonN synapses, each receiving
+	// Poisson spike trains at PoissonHz from OnCycle to OffCycle, with each
+	// spike adding PoissonWt, which decays with PoissonTau
+	PoissonInput
+
+	// TableInput takes the input from the Ge column of the Table, with one
+	// row per cycle starting at OnCycle, until OffCycle or the end of the table
+	TableInput
+
+	InputProtocolN
+)
+
+// InputParams are the parameters of the input protocol
+type InputParams struct {
+	Protocol   InputProtocol `desc:"time course of the excitatory input, which is multiplied by GbarE"`
+	Max        float32       `min:"0" step:"0.1" def:"1" viewif:"Protocol!=PoissonInput" desc:"maximum value of the input: level of the steps, end of the ramp, and peak of the sinusoid"`
+	PulseOn    int           `min:"1" def:"10" viewif:"Protocol=TrainInput" desc:"number of cycles that each pulse is on, for TrainInput"`
+	PulseOff   int           `min:"0" def:"20" viewif:"Protocol=TrainInput" desc:"number of cycles between pulses, for TrainInput"`
+	RampStart  float32       `min:"0" step:"0.1" def:"0" viewif:"Protocol=RampInput" desc:"value of the input at the start of the ramp, for RampInput"`
+	SineHz     float32       `min:"0" step:"1" def:"10" viewif:"Protocol=SineInput" desc:"frequency of the sinusoid, in Hz (cycles per second, with 1 cycle = 1 msec), for SineInput"`
+	PoissonN   int           `min:"1" def:"100" viewif:"Protocol=PoissonInput" desc:"number of input synapses, for PoissonInput"`
+	PoissonHz  float32       `min:"0" step:"1" def:"20" viewif:"Protocol=PoissonInput" desc:"firing rate of each input, in Hz, for PoissonInput"`
+	PoissonWt  float32       `min:"0" step:"0.01" def:"0.1" viewif:"Protocol=PoissonInput" desc:"increment in the input for each input spike, for PoissonInput"`
+	PoissonTau float32       `min:"1" def:"5" viewif:"Protocol=PoissonInput" desc:"time constant of the decay of the input from each spike, in cycles, for PoissonInput"`
+	Table      *etable.Table `view:"no-inline" viewif:"Protocol=TableInput" desc:"input values for TableInput, in a Ge column, one row per cycle starting at OnCycle -- use OpenInputTable to load from a file"`
+
+	Cur       float32 `view:"-" desc:"current value of the input, as returned by Input"`
+	PoissonGe float32 `view:"-" desc:"current value of the PoissonInput conductance"`
+}
+
+func (ip *InputParams) Defaults() {
+	ip.Protocol = StepInput
+	ip.Max = 1
+	ip.PulseOn = 10
+	ip.PulseOff = 20
+	ip.RampStart = 0
+	ip.SineHz = 10
+	ip.PoissonN = 100
+	ip.PoissonHz = 20
+	ip.PoissonWt = 0.1
+	ip.PoissonTau = 5
+}
+
+// Init initializes the state at the start of a run
+func (ip *InputParams) Init() {
+	ip.Cur = 0
+	ip.PoissonGe = 0
+}
+
+// Input returns the input on given cycle, with the input window starting
+// at onCyc and ending before offCyc.  It must be called once per cycle,
+// in order, after Init, as the PoissonInput integrates over time.
+func (ip *InputParams) Input(cyc, onCyc, offCyc int) float32 {
+	ip.Cur = ip.input(cyc, onCyc, offCyc)
+	return ip.Cur
+}
+
+// input computes the input for Input
+func (ip *InputParams) input(cyc, onCyc, offCyc int) float32 {
+	on := cyc >= onCyc && cyc < offCyc
+	t := cyc - onCyc
+	switch ip.Protocol {
+	case TrainInput:
+		if !on || t%(ip.PulseOn+ip.PulseOff) >= ip.PulseOn {
+			return 0
+		}
+		return ip.Max
+	case RampInput:
+		if !on {
+			return 0
+		}
+		dur := offCyc - onCyc
+		if dur <= 1 {
+			return ip.Max
+		}
+		return ip.RampStart + (ip.Max-ip.RampStart)*float32(t)/float32(dur-1)
+	case SineInput:
+		if !on {
+			return 0
+		}
+		return 0.5 * ip.Max * (1 - mat32.Cos(2*mat32.Pi*ip.SineHz*float32(t)/1000))
+	case PoissonInput:
+		ip.PoissonGe -= ip.PoissonGe / mat32.Max(ip.PoissonTau, 1)
+		if on {
+			lmb := float64(ip.PoissonN) * float64(ip.PoissonHz) / 1000
+			ip.PoissonGe += ip.PoissonWt * float32(erand.PoissonGen(lmb, -1))
+		}
+		return ip.PoissonGe
+	case TableInput:
+		if !on || ip.Table == nil || t >= ip.Table.Rows {
+			return 0
+		}
+		if _, err := ip.Table.ColByNameTry("Ge"); err != nil {
+			return 0
+		}
+		return float32(ip.Table.CellFloat("Ge", t))
+	default:
+		if !on {
+			return 0
+		}
+		return ip.Max
+	}
+}
+
+// OpenTable opens the Table for TableInput from given file, which must
+// have a numeric Ge column.  Files ending in .csv are comma-delimited,
+// and all others are tab-delimited.
+func (ip *InputParams) OpenTable(fname string) error {
+	delim := etable.Tab
+	if strings.ToLower(filepath.Ext(fname)) == ".csv" {
+		delim = etable.Comma
+	}
+	dt := &etable.Table{}
+	if err := dt.OpenCSV(gi.FileName(fname), delim); err != nil {
+		return fmt.Errorf("input table: %s: %v", fname, err)
+	}
+	cl, err := dt.ColByNameTry("Ge")
+	if err != nil {
+		return fmt.Errorf("input table: %s: no Ge column", fname)
+	}
+	if cl.DataType() == etensor.STRING {
+		return fmt.Errorf("input table: %s: Ge column is not numeric", fname)
+	}
+	dt.SetMetaData("name", "InputTable")
+	dt.SetMetaData("desc", "input values for TableInput, one row per cycle starting at OnCycle")
+	ip.Table = dt
+	return nil
+}
@@ -0,0 +1,379 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"sort"
+
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+)
+
+// SpikeTrain is the spike train of one unit on one run, as the cycles
+// on which it spiked
+type SpikeTrain struct {
+	Unit   int   `desc:"index of the unit in the layer"`
+	Spikes []int `desc:"cycles on which the unit spiked, in order"`
+}
+
+// SpikeStats computes statistics of spike trains recorded over repeated
+// runs in spiking mode: inter-spike interval (ISI) histograms, the
+// coefficient of variation (CV) of the ISIs, the Fano factor of the spike
+// counts across runs, and the peri-stimulus time histogram (PSTH) aligned
+// to the onset of the input.  Spikes are recorded cycle by cycle with
+// Record or RecordUnit, and EndTrial ends the spike trains of the current
+// run -- alternatively, whole trains can be added with AddTrain or
+// AddFromTable (e.g., from the Spike column of the TstCycLog).  Compute then
+// computes the ISIHist, PSTH and Summary tables, from the spikes in the
+// [Start, End) window of cycles, separately for each unit, and averaged
+// over units.
+type SpikeStats struct {
+	Align   int           `desc:"cycle that the PSTH is aligned to, typically the onset of the input -- PSTH times are relative to this"`
+	Start   int           `desc:"start of the window of cycles for the ISI, count and rate statistics"`
+	End     int           `desc:"end of the window of cycles for the ISI, count and rate statistics (exclusive) -- if 0, the end of the recorded cycles"`
+	ISIBin  int           `min:"1" def:"2" desc:"size of the ISI histogram bins, in cycles"`
+	ISIMax  int           `min:"1" def:"100" desc:"maximum ISI in the histogram -- longer ISIs are counted in the last bin"`
+	PSTHBin int           `min:"1" def:"10" desc:"size of the PSTH bins, in cycles"`
+	Trains  []SpikeTrain  `view:"-" desc:"recorded spike trains"`
+	NCycles int           `inactive:"+" desc:"number of cycles recorded in the longest run"`
+	ISIHist *etable.Table `view:"no-inline" desc:"histogram of ISIs within the window"`
+	PSTH    *etable.Table `view:"no-inline" desc:"peri-stimulus time histogram: firing rate (Hz, with 1 cycle = 1 msec) in each bin, averaged over trains"`
+	Summary *etable.Table `view:"no-inline" desc:"summary statistics: rate, ISI mean and CV, Fano factor"`
+
+	cur map[int][]int
+}
+
+// Defaults sets default parameters
+func (st *SpikeStats) Defaults() {
+	st.ISIBin = 2
+	st.ISIMax = 100
+	st.PSTHBin = 10
+}
+
+// Reset removes all recorded spike trains
+func (st *SpikeStats) Reset() {
+	st.Trains = nil
+	st.NCycles = 0
+	st.cur = nil
+}
+
+// Record records whether the neuron spiked on given cycle of the current run
+func (st *SpikeStats) Record(cyc int, spiked bool) {
+	st.RecordUnit(0, cyc, spiked)
+}
+
+// RecordUnit records whether given unit spiked on given cycle of the current run
+func (st *SpikeStats) RecordUnit(unit, cyc int, spiked bool) {
+	if st.cur == nil {
+		st.cur = make(map[int][]int)
+	}
+	if _, has := st.cur[unit]; !has {
+		st.cur[unit] = nil
+	}
+	if spiked {
+		st.cur[unit] = append(st.cur[unit], cyc)
+	}
+	if cyc+1 > st.NCycles {
+		st.NCycles = cyc + 1
+	}
+}
+
+// EndTrial ends the spike trains of the current run, adding them to Trains
+func (st *SpikeStats) EndTrial() {
+	units := make([]int, 0, len(st.cur))
+	for u := range st.cur {
+		units = append(units, u)
+	}
+	sort.Ints(units)
+	for _, u := range units {
+		st.Trains = append(st.Trains, SpikeTrain{Unit: u, Spikes: st.cur[u]})
+	}
+	st.cur = nil
+}
+
+// AddTrain adds the spike train of a run of given unit, over nCycles
+func (st *SpikeStats) AddTrain(unit int, spikes []int, nCycles int) {
+	st.Trains = append(st.Trains, SpikeTrain{Unit: unit, Spikes: spikes})
+	if nCycles > st.NCycles {
+		st.NCycles = nCycles
+	}
+}
+
+// AddFromTable adds the spike train of a run of a single neuron from
+// given column of a table with one row per cycle, with values > 0.5
+// for spikes (e.g., the Spike column of a cycle log)
+func (st *SpikeStats) AddFromTable(dt *etable.Table, col string) {
+	var spks []int
+	for cyc := 0; cyc < dt.Rows; cyc++ {
+		if dt.CellFloat(col, cyc) > 0.5 {
+			spks = append(spks, cyc)
+		}
+	}
+	st.AddTrain(0, spks, dt.Rows)
+}
+
+// Window returns the effective start and end of the window
+func (st *SpikeStats) Window() (start, end int) {
+	start, end = st.Start, st.End
+	if end <= 0 || end > st.NCycles {
+		end = st.NCycles
+	}
+	if start < 0 {
+		start = 0
+	}
+	return
+}
+
+// InWindow returns the spikes of the train within the window
+func (st *SpikeStats) InWindow(tr *SpikeTrain) []int {
+	start, end := st.Window()
+	var spks []int
+	for _, s := range tr.Spikes {
+		if s >= start && s < end {
+			spks = append(spks, s)
+		}
+	}
+	return spks
+}
+
+// ISIs returns the inter-spike intervals of given spike times
+func ISIs(spikes []int) []int {
+	if len(spikes) < 2 {
+		return nil
+	}
+	isis := make([]int, len(spikes)-1)
+	for i := range isis {
+		isis[i] = spikes[i+1] - spikes[i]
+	}
+	return isis
+}
+
+// MeanStd returns the mean and (population) standard deviation of values,
+// NaN if there are none
+func MeanStd(vals []float64) (mean, std float64) {
+	if len(vals) == 0 {
+		return math.NaN(), math.NaN()
+	}
+	for _, v := range vals {
+		mean += v
+	}
+	mean /= float64(len(vals))
+	for _, v := range vals {
+		d := v - mean
+		std += d * d
+	}
+	std = math.Sqrt(std / float64(len(vals)))
+	return
+}
+
+// CV returns the coefficient of variation (std / mean) of the ISIs,
+// NaN if there are fewer than 2
+func CV(isis []int) float64 {
+	if len(isis) < 2 {
+		return math.NaN()
+	}
+	vals := make([]float64, len(isis))
+	for i, v := range isis {
+		vals[i] = float64(v)
+	}
+	mean, std := MeanStd(vals)
+	return std / mean
+}
+
+// Fano returns the Fano factor (variance / mean) of the spike counts
+// across runs, NaN if there are fewer than 2 or the mean is 0
+func Fano(counts []float64) float64 {
+	if len(counts) < 2 {
+		return math.NaN()
+	}
+	mean, std := MeanStd(counts)
+	if mean == 0 {
+		return math.NaN()
+	}
+	return std * std / mean
+}
+
+// Units returns the trains for each unit, in order of first recording
+func (st *SpikeStats) Units() [][]*SpikeTrain {
+	var units [][]*SpikeTrain
+	idx := make(map[int]int)
+	for ti := range st.Trains {
+		tr := &st.Trains[ti]
+		ui, has := idx[tr.Unit]
+		if !has {
+			ui = len(units)
+			idx[tr.Unit] = ui
+			units = append(units, nil)
+		}
+		units[ui] = append(units[ui], tr)
+	}
+	return units
+}
+
+// Compute computes the ISIHist, PSTH and Summary tables from the Trains
+func (st *SpikeStats) Compute() {
+	st.ComputeISIHist()
+	st.ComputePSTH()
+	st.ComputeSummary()
+}
+
+// ComputeISIHist computes the ISIHist table from the Trains
+func (st *SpikeStats) ComputeISIHist() {
+	bin := st.ISIBin
+	if bin < 1 {
+		bin = 1
+	}
+	nb := (st.ISIMax + bin - 1) / bin
+	if nb < 1 {
+		nb = 1
+	}
+	counts := make([]float64, nb)
+	n := 0
+	for ti := range st.Trains {
+		for _, isi := range ISIs(st.InWindow(&st.Trains[ti])) {
+			b := isi / bin
+			if b >= nb {
+				b = nb - 1
+			}
+			counts[b]++
+			n++
+		}
+	}
+	dt := st.ISIHist
+	if dt == nil {
+		dt = &etable.Table{}
+		st.ISIHist = dt
+	}
+	dt.SetMetaData("name", "ISIHist")
+	dt.SetMetaData("desc", "histogram of inter-spike intervals")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", "4")
+	dt.SetFromSchema(etable.Schema{
+		{"ISI", etensor.FLOAT64, nil, nil},
+		{"Count", etensor.FLOAT64, nil, nil},
+		{"Prob", etensor.FLOAT64, nil, nil},
+	}, nb)
+	for b, c := range counts {
+		dt.SetCellFloat("ISI", b, float64(b*bin))
+		dt.SetCellFloat("Count", b, c)
+		if n > 0 {
+			dt.SetCellFloat("Prob", b, c/float64(n))
+		}
+	}
+}
+
+// ComputePSTH computes the PSTH table from the Trains, over all of the
+// recorded cycles, with the Time of each bin relative to Align
+func (st *SpikeStats) ComputePSTH() {
+	bin := st.PSTHBin
+	if bin < 1 {
+		bin = 1
+	}
+	// bins are aligned so that one starts at Align
+	first := st.Align - bin*((st.Align+bin-1)/bin)
+	nb := 0
+	if st.NCycles > first {
+		nb = (st.NCycles - first + bin - 1) / bin
+	}
+	counts := make([]float64, nb)
+	for ti := range st.Trains {
+		for _, s := range st.Trains[ti].Spikes {
+			b := (s - first) / bin
+			if b >= 0 && b < nb {
+				counts[b]++
+			}
+		}
+	}
+	dt := st.PSTH
+	if dt == nil {
+		dt = &etable.Table{}
+		st.PSTH = dt
+	}
+	dt.SetMetaData("name", "PSTH")
+	dt.SetMetaData("desc", "peri-stimulus time histogram")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", "4")
+	dt.SetFromSchema(etable.Schema{
+		{"Time", etensor.FLOAT64, nil, nil},
+		{"Count", etensor.FLOAT64, nil, nil},
+		{"Rate", etensor.FLOAT64, nil, nil},
+	}, nb)
+	ntr := float64(len(st.Trains))
+	for b, c := range counts {
+		dt.SetCellFloat("Time", b, float64(first+b*bin-st.Align))
+		dt.SetCellFloat("Count", b, c)
+		if ntr > 0 {
+			dt.SetCellFloat("Rate", b, 1000*c/(ntr*float64(bin)))
+		}
+	}
+}
+
+// ComputeSummary computes the Summary table from the Trains: the number
+// of trains and spikes, and the rate, mean ISI, ISI CV and Fano factor
+// within the window, computed for each unit and averaged over units
+func (st *SpikeStats) ComputeSummary() {
+	start, end := st.Window()
+	units := st.Units()
+	var rates, isiMeans, cvs, fanos []float64
+	nspk := 0
+	for _, trs := range units {
+		counts := make([]float64, len(trs))
+		var isis []int
+		for i, tr := range trs {
+			spks := st.InWindow(tr)
+			counts[i] = float64(len(spks))
+			nspk += len(spks)
+			isis = append(isis, ISIs(spks)...)
+		}
+		mc, _ := MeanStd(counts)
+		if end > start {
+			rates = append(rates, 1000*mc/float64(end-start))
+		}
+		if len(isis) > 0 {
+			vals := make([]float64, len(isis))
+			for i, v := range isis {
+				vals[i] = float64(v)
+			}
+			m, _ := MeanStd(vals)
+			isiMeans = append(isiMeans, m)
+		}
+		if cv := CV(isis); !math.IsNaN(cv) {
+			cvs = append(cvs, cv)
+		}
+		if ff := Fano(counts); !math.IsNaN(ff) {
+			fanos = append(fanos, ff)
+		}
+	}
+	dt := st.Summary
+	if dt == nil {
+		dt = &etable.Table{}
+		st.Summary = dt
+	}
+	dt.SetMetaData("name", "SpikeStats")
+	dt.SetMetaData("desc", "summary spike train statistics, averaged over units")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", "4")
+	dt.SetFromSchema(etable.Schema{
+		{"NUnits", etensor.INT64, nil, nil},
+		{"NTrains", etensor.INT64, nil, nil},
+		{"NSpikes", etensor.INT64, nil, nil},
+		{"Rate", etensor.FLOAT64, nil, nil},
+		{"MeanISI", etensor.FLOAT64, nil, nil},
+		{"CV", etensor.FLOAT64, nil, nil},
+		{"Fano", etensor.FLOAT64, nil, nil},
+	}, 1)
+	mean := func(vals []float64) float64 {
+		m, _ := MeanStd(vals)
+		return m
+	}
+	dt.SetCellFloat("NUnits", 0, float64(len(units)))
+	dt.SetCellFloat("NTrains", 0, float64(len(st.Trains)))
+	dt.SetCellFloat("NSpikes", 0, float64(nspk))
+	dt.SetCellFloat("Rate", 0, mean(rates))
+	dt.SetCellFloat("MeanISI", 0, mean(isiMeans))
+	dt.SetCellFloat("CV", 0, mean(cvs))
+	dt.SetCellFloat("Fano", 0, mean(fanos))
+}
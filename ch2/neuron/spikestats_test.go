@@ -0,0 +1,269 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// regular returns a regular spike train from start to before end
+func regular(start, end, isi int) []int {
+	var spks []int
+	for s := start; s < end; s += isi {
+		spks = append(spks, s)
+	}
+	return spks
+}
+
+// sameFloat returns true if the values are equal, within tol, or both NaN
+func sameFloat(a, b, tol float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	return math.Abs(a-b) <= tol
+}
+
+func TestISIsCV(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		name   string
+		spikes []int
+		isis   []int
+		cv     float64
+	}{
+		{"empty", nil, nil, nan},
+		{"single spike", []int{42}, nil, nan},
+		{"one ISI", []int{10, 15}, []int{5}, nan},
+		{"regular", regular(10, 100, 10), []int{10, 10, 10, 10, 10, 10, 10, 10}, 0},
+		{"alternating", []int{0, 2, 6, 8, 12}, []int{2, 4, 2, 4}, 1.0 / 3.0},
+		{"burst and pause", []int{0, 1, 2, 30}, []int{1, 1, 28}, math.Sqrt(162) / 10},
+	}
+	for _, tt := range tests {
+		isis := ISIs(tt.spikes)
+		if !reflect.DeepEqual(isis, tt.isis) {
+			t.Errorf("%s: ISIs = %v, want %v", tt.name, isis, tt.isis)
+		}
+		if cv := CV(isis); !sameFloat(cv, tt.cv, 1e-9) {
+			t.Errorf("%s: CV = %g, want %g", tt.name, cv, tt.cv)
+		}
+	}
+}
+
+func TestFano(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		name   string
+		counts []float64
+		fano   float64
+	}{
+		{"none", nil, nan},
+		{"one run", []float64{3}, nan},
+		{"no spikes", []float64{0, 0, 0}, nan},
+		{"same counts", []float64{5, 5, 5, 5}, 0},
+		{"two counts", []float64{2, 4}, 1.0 / 3.0},
+		{"poisson-like", []float64{1, 3, 1, 3, 2, 2}, 1.0 / 3.0},
+	}
+	for _, tt := range tests {
+		if ff := Fano(tt.counts); !sameFloat(ff, tt.fano, 1e-9) {
+			t.Errorf("%s: Fano = %g, want %g", tt.name, ff, tt.fano)
+		}
+	}
+}
+
+// summary returns the values of the Summary table by column name
+func summary(st *SpikeStats) map[string]float64 {
+	sm := make(map[string]float64)
+	for _, cn := range st.Summary.ColNames {
+		sm[cn] = st.Summary.CellFloat(cn, 0)
+	}
+	return sm
+}
+
+func TestSummary(t *testing.T) {
+	nan := math.NaN()
+	// trains with ISIs of 20 (x9) and 10 (x19), pooled over runs
+	vm := 370.0 / 28
+	vcv := math.Sqrt(5500.0/28-vm*vm) / vm
+	tests := []struct {
+		name       string
+		start, end int
+		trains     [][]int
+		want       map[string]float64
+	}{
+		{"regular", 0, 0, [][]int{regular(5, 200, 10), regular(5, 200, 10), regular(5, 200, 10)}, map[string]float64{
+			"NUnits": 1, "NTrains": 3, "NSpikes": 60, "Rate": 100, "MeanISI": 10, "CV": 0, "Fano": 0,
+		}},
+		{"regular window", 50, 150, [][]int{regular(5, 200, 10), regular(5, 200, 10)}, map[string]float64{
+			"NUnits": 1, "NTrains": 2, "NSpikes": 20, "Rate": 100, "MeanISI": 10, "CV": 0, "Fano": 0,
+		}},
+		{"varying counts", 0, 0, [][]int{regular(0, 200, 20), regular(0, 200, 10)}, map[string]float64{
+			"NUnits": 1, "NTrains": 2, "NSpikes": 30, "Rate": 75, "MeanISI": vm, "CV": vcv, "Fano": 25.0 / 15,
+		}},
+		{"single spikes", 0, 0, [][]int{{50}, {60}, {70}}, map[string]float64{
+			"NUnits": 1, "NTrains": 3, "NSpikes": 3, "Rate": 5, "MeanISI": nan, "CV": nan, "Fano": 0,
+		}},
+		{"empty trains", 0, 0, [][]int{nil, nil}, map[string]float64{
+			"NUnits": 1, "NTrains": 2, "NSpikes": 0, "Rate": 0, "MeanISI": nan, "CV": nan, "Fano": nan,
+		}},
+		{"no trains", 0, 0, nil, map[string]float64{
+			"NUnits": 0, "NTrains": 0, "NSpikes": 0, "Rate": nan, "MeanISI": nan, "CV": nan, "Fano": nan,
+		}},
+	}
+	for _, tt := range tests {
+		st := &SpikeStats{}
+		st.Defaults()
+		st.Start, st.End = tt.start, tt.end
+		for _, spks := range tt.trains {
+			st.AddTrain(0, spks, 200)
+		}
+		st.Compute()
+		sm := summary(st)
+		for cn, w := range tt.want {
+			if !sameFloat(sm[cn], w, 1e-9) {
+				t.Errorf("%s: %s = %g, want %g", tt.name, cn, sm[cn], w)
+			}
+		}
+	}
+}
+
+func TestPoisson(t *testing.T) {
+	// Bernoulli spikes with probability p per cycle approximate a Poisson
+	// process: CV = sqrt(1-p) and Fano = 1-p, both close to 1
+	rnd := rand.New(rand.NewSource(1))
+	p := 0.02
+	ncyc := 2000
+	st := &SpikeStats{}
+	st.Defaults()
+	for run := 0; run < 1000; run++ {
+		for cyc := 0; cyc < ncyc; cyc++ {
+			st.Record(cyc, rnd.Float64() < p)
+		}
+		st.EndTrial()
+	}
+	st.Compute()
+	sm := summary(st)
+	want := map[string]float64{
+		"Rate":    1000 * p,
+		"MeanISI": 1 / p,
+		"CV":      math.Sqrt(1 - p),
+		"Fano":    1 - p,
+	}
+	for cn, w := range want {
+		if math.Abs(sm[cn]-w) > 0.1*w {
+			t.Errorf("%s = %g, want %g", cn, sm[cn], w)
+		}
+	}
+	if sm["NTrains"] != 1000 || sm["NUnits"] != 1 {
+		t.Errorf("NTrains %g NUnits %g, want 1000, 1", sm["NTrains"], sm["NUnits"])
+	}
+	// ISIs are geometric: the probability falls off by (1-p)^ISIBin per bin
+	hist := st.ISIHist
+	r := hist.CellFloat("Prob", 10) / hist.CellFloat("Prob", 5)
+	if w := math.Pow(1-p, float64(5*st.ISIBin)); math.Abs(r-w) > 0.1 {
+		t.Errorf("ISI Prob ratio = %g, want %g", r, w)
+	}
+}
+
+func TestRecordUnits(t *testing.T) {
+	st := &SpikeStats{}
+	for run := 0; run < 2; run++ {
+		for cyc := 0; cyc < 50; cyc++ {
+			st.RecordUnit(3, cyc, cyc%10 == 0)
+			st.RecordUnit(1, cyc, run == 1 && cyc == 25)
+		}
+		st.EndTrial()
+	}
+	want := []SpikeTrain{
+		{Unit: 1, Spikes: nil},
+		{Unit: 3, Spikes: []int{0, 10, 20, 30, 40}},
+		{Unit: 1, Spikes: []int{25}},
+		{Unit: 3, Spikes: []int{0, 10, 20, 30, 40}},
+	}
+	if !reflect.DeepEqual(st.Trains, want) {
+		t.Errorf("Trains = %v, want %v", st.Trains, want)
+	}
+	if st.NCycles != 50 {
+		t.Errorf("NCycles = %d, want 50", st.NCycles)
+	}
+	units := st.Units()
+	if len(units) != 2 || units[0][0].Unit != 1 || units[1][0].Unit != 3 || len(units[0]) != 2 || len(units[1]) != 2 {
+		t.Errorf("Units not grouped by unit: %v", units)
+	}
+	st.Reset()
+	if st.Trains != nil || st.NCycles != 0 {
+		t.Errorf("Reset: %d trains, %d cycles", len(st.Trains), st.NCycles)
+	}
+}
+
+func TestISIHist(t *testing.T) {
+	st := &SpikeStats{}
+	st.Defaults()
+	st.ISIBin = 5
+	st.ISIMax = 50
+	st.AddTrain(0, []int{0, 10, 20, 27, 100, 200}, 250)
+	st.ComputeISIHist()
+	dt := st.ISIHist
+	if dt.Rows != 10 {
+		t.Fatalf("%d bins, want 10", dt.Rows)
+	}
+	// ISIs 10, 10, 7, 73, 100: 7 in bin 1, 10 in bin 2, longer in the last bin
+	want := map[int]float64{1: 1, 2: 2, 9: 2}
+	for b := 0; b < dt.Rows; b++ {
+		if isi := dt.CellFloat("ISI", b); isi != float64(5*b) {
+			t.Errorf("bin %d ISI = %g, want %d", b, isi, 5*b)
+		}
+		if c := dt.CellFloat("Count", b); c != want[b] {
+			t.Errorf("bin %d Count = %g, want %g", b, c, want[b])
+		}
+		if pr := dt.CellFloat("Prob", b); !sameFloat(pr, want[b]/5, 1e-9) {
+			t.Errorf("bin %d Prob = %g, want %g", b, pr, want[b]/5)
+		}
+	}
+
+	st.Reset()
+	st.ComputeISIHist()
+	for b := 0; b < st.ISIHist.Rows; b++ {
+		if c, pr := st.ISIHist.CellFloat("Count", b), st.ISIHist.CellFloat("Prob", b); c != 0 || pr != 0 {
+			t.Errorf("empty: bin %d Count %g Prob %g", b, c, pr)
+		}
+	}
+}
+
+func TestPSTH(t *testing.T) {
+	st := &SpikeStats{}
+	st.Defaults()
+	st.Align = 15
+	st.PSTHBin = 10
+	st.AddTrain(0, []int{0, 14, 15, 24, 99}, 100)
+	st.AddTrain(0, []int{15, 16}, 100)
+	st.ComputePSTH()
+	dt := st.PSTH
+	// bins start at -5, so that one starts at Align = 15: 11 bins to cover 100 cycles
+	if dt.Rows != 11 {
+		t.Fatalf("%d bins, want 11", dt.Rows)
+	}
+	want := map[int]float64{0: 1, 1: 1, 2: 4, 10: 1}
+	for b := 0; b < dt.Rows; b++ {
+		if tm := dt.CellFloat("Time", b); tm != float64(10*b-20) {
+			t.Errorf("bin %d Time = %g, want %d", b, tm, 10*b-20)
+		}
+		if c := dt.CellFloat("Count", b); c != want[b] {
+			t.Errorf("bin %d Count = %g, want %g", b, c, want[b])
+		}
+		if r := dt.CellFloat("Rate", b); !sameFloat(r, 1000*want[b]/20, 1e-9) {
+			t.Errorf("bin %d Rate = %g, want %g", b, r, 1000*want[b]/20)
+		}
+	}
+
+	st.Reset()
+	st.Align = 0
+	st.ComputePSTH()
+	if st.PSTH.Rows != 0 {
+		t.Errorf("empty: %d bins, want 0", st.PSTH.Rows)
+	}
+}
@@ -11,9 +11,13 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"os"
 	"strconv"
 	"strings"
 
+	"github.com/CompCogNeuro/sims/bench"
+	"github.com/CompCogNeuro/sims/csnet"
+	"github.com/CompCogNeuro/sims/patfile"
 	"github.com/emer/emergent/emer"
 	"github.com/emer/emergent/env"
 	"github.com/emer/emergent/netview"
@@ -35,9 +39,15 @@ import (
 
 // this is the stub main for gogi that calls our actual mainrun function, at end of file
 func main() {
-	gimain.Main(func() {
-		mainrun()
-	})
+	if bench.HasFlag(os.Args[1:]) {
+		TheSim.New()
+		TheSim.Config()
+		TheSim.Bench.RunArgs(TheSim.Benchmark) // -bench arg = run without the gui
+	} else {
+		gimain.Main(func() { // this starts gui -- requires valid OpenGL display connection (e.g., X11)
+			mainrun()
+		})
+	}
 }
 
 // LogPrec is precision for saving float values in logs
@@ -77,24 +87,34 @@ var ParamSets = params.Sets{
 // as arguments to methods, and provides the core GUI interface (note the view tags
 // for the fields which provide hints to how things should be displayed).
 type Sim struct {
-	Net        *leabra.Network   `view:"no-inline" desc:"the network -- click to view / edit parameters for layers, prjns, etc"`
-	Pats       *etable.Table     `view:"no-inline" desc:"click to see and edit the testing input patterns to use"`
-	TstCycLog  *etable.Table     `view:"no-inline" desc:"testing trial-level log data -- click to see record of network's response to each input"`
-	Params     params.Sets       `view:"no-inline" desc:"full collection of param sets -- not really interesting for this model"`
-	ParamSet   string            `view:"-" desc:"which set of *additional* parameters to use -- always applies Base and optionaly this next if set -- can use multiple names separated by spaces (don't put spaces in ParamSet names!)"`
-	TestEnv    env.FixedTable    `desc:"Testing environment -- manages iterating over testing"`
-	Time       leabra.Time       `desc:"leabra timing parameters and state"`
-	ViewUpdt   leabra.TimeScales `desc:"at what time scale to update the display during testing?  Change to AlphaCyc to make display updating go faster"`
-	TstRecLays []string          `desc:"names of layers to record activations etc of during testing"`
+	Net          *leabra.Network   `view:"no-inline" desc:"the network -- click to view / edit parameters for layers, prjns, etc"`
+	NetDesc      *csnet.Net        `view:"no-inline" desc:"description of the constraint-satisfaction network loaded with Open Net, which replaces the standard cats and dogs network -- nil for the standard network"`
+	Pats         *etable.Table     `view:"no-inline" desc:"click to see and edit the testing input patterns to use"`
+	TstCycLog    *etable.Table     `view:"no-inline" desc:"testing trial-level log data -- click to see record of network's response to each input"`
+	Params       params.Sets       `view:"no-inline" desc:"full collection of param sets -- not really interesting for this model"`
+	ParamSet     string            `view:"-" desc:"which set of *additional* parameters to use -- always applies Base and optionaly this next if set -- can use multiple names separated by spaces (don't put spaces in ParamSet names!)"`
+	TestEnv      env.FixedTable    `desc:"Testing environment -- manages iterating over testing"`
+	Time         leabra.Time       `desc:"leabra timing parameters and state"`
+	Bench        bench.Bench       `view:"no-inline" desc:"benchmark parameters and results -- see Benchmark"`
+	ViewUpdt     leabra.TimeScales `desc:"at what time scale to update the display during testing?  Change to AlphaCyc to make display updating go faster"`
+	TstRecLays   []string          `desc:"names of layers to record activations etc of during testing"`
+	Query        QueryParams       `desc:"parameters for the query battery (Query Battery), which tests completion from partial cues"`
+	Items        *etable.Table     `view:"no-inline" desc:"the individuals known by the network, from the weights from each Identity unit -- the ground truth for the query battery"`
+	Queries      *etable.Table     `view:"no-inline" desc:"partial cues for the query battery, one per row, with only the cued units active -- click to see and edit, or use Gen Queries or Open Queries"`
+	QueryLog     *etable.Table     `view:"no-inline" desc:"results of the query battery: the completed unit and its activation in each layer, for each query"`
+	QueryCycLog  *etable.Table     `view:"no-inline" desc:"harmony and activations on each cycle of each query in the query battery"`
+	QuerySummary *etable.Table     `view:"no-inline" desc:"accuracy of the query battery by number of cued attributes"`
 
 	// internal state - view:"-"
-	Win        *gi.Window                  `view:"-" desc:"main GUI window"`
-	NetView    *netview.NetView            `view:"-" desc:"the network viewer"`
-	ToolBar    *gi.ToolBar                 `view:"-" desc:"the master toolbar"`
-	TstCycPlot *eplot.Plot2D               `view:"-" desc:"the test-trial plot"`
-	ValsTsrs   map[string]*etensor.Float32 `view:"-" desc:"for holding layer values"`
-	IsRunning  bool                        `view:"-" desc:"true if sim is running"`
-	StopNow    bool                        `view:"-" desc:"flag to stop running"`
+	Win              *gi.Window                  `view:"-" desc:"main GUI window"`
+	NetView          *netview.NetView            `view:"-" desc:"the network viewer"`
+	ToolBar          *gi.ToolBar                 `view:"-" desc:"the master toolbar"`
+	TstCycPlot       *eplot.Plot2D               `view:"-" desc:"the test-trial plot"`
+	QueryCycPlot     *eplot.Plot2D               `view:"-" desc:"the query battery harmony plot"`
+	QuerySummaryPlot *eplot.Plot2D               `view:"-" desc:"the query battery accuracy plot"`
+	ValsTsrs         map[string]*etensor.Float32 `view:"-" desc:"for holding layer values"`
+	IsRunning        bool                        `view:"-" desc:"true if sim is running"`
+	StopNow          bool                        `view:"-" desc:"flag to stop running"`
 }
 
 // this registers this Sim Type and gives it properties that e.g.,
@@ -109,14 +129,21 @@ func (ss *Sim) New() {
 	ss.Net = &leabra.Network{}
 	ss.Pats = &etable.Table{}
 	ss.TstCycLog = &etable.Table{}
+	ss.Items = &etable.Table{}
+	ss.Queries = &etable.Table{}
+	ss.QueryLog = &etable.Table{}
+	ss.QueryCycLog = &etable.Table{}
+	ss.QuerySummary = &etable.Table{}
 	ss.Params = ParamSets
 	ss.ViewUpdt = leabra.Cycle
 	ss.TstRecLays = []string{"Name", "Identity", "Color", "FavoriteFood", "Size", "Species", "FavoriteToy"}
 	ss.Defaults()
+	ss.Bench.Defaults("cats_dogs")
 }
 
 // Defaults sets default params
 func (ss *Sim) Defaults() {
+	ss.Query.Defaults()
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////
@@ -128,6 +155,16 @@ func (ss *Sim) Config() {
 	ss.ConfigEnv()
 	ss.ConfigNet(ss.Net)
 	ss.ConfigTstCycLog(ss.TstCycLog)
+	ss.ConfigQueries()
+}
+
+// ConfigQueries generates the Queries for the current network, and
+// configures the query battery logs
+func (ss *Sim) ConfigQueries() {
+	ss.GenQueries()
+	ss.ConfigQueryLog(ss.QueryLog)
+	ss.ConfigQueryCycLog(ss.QueryCycLog)
+	ss.ConfigQuerySummary(ss.QuerySummary)
 }
 
 func (ss *Sim) ConfigEnv() {
@@ -140,6 +177,25 @@ func (ss *Sim) ConfigEnv() {
 }
 
 func (ss *Sim) ConfigNet(net *leabra.Network) {
+	if ss.NetDesc != nil {
+		ss.NetDesc.ConfigNet(net)
+	} else {
+		ss.ConfigCatsDogsNet(net)
+	}
+
+	net.Defaults()
+	ss.SetParams("Network", false) // only set Network params
+	err := net.Build()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	ss.InitWts(net)
+}
+
+// ConfigCatsDogsNet configures the layers and projections of the standard
+// cats and dogs network
+func (ss *Sim) ConfigCatsDogsNet(net *leabra.Network) {
 	net.InitName(net, "CatsAndDogs")
 	name := net.AddLayer2D("Name", 1, 10, emer.Input)
 	iden := net.AddLayer2D("Identity", 1, 10, emer.Input)
@@ -168,20 +224,18 @@ func (ss *Sim) ConfigNet(net *leabra.Network) {
 	size.SetRelPos(relpos.Rel{Rel: relpos.Above, Other: "Color", YAlign: relpos.Front, XAlign: relpos.Left})
 	spec.SetRelPos(relpos.Rel{Rel: relpos.Above, Other: "Color", YAlign: relpos.Front, XAlign: relpos.Right, XOffset: 2})
 	toy.SetRelPos(relpos.Rel{Rel: relpos.Above, Other: "FavoriteFood", YAlign: relpos.Front, XAlign: relpos.Right, XOffset: 1})
-
-	net.Defaults()
-	ss.SetParams("Network", false) // only set Network params
-	err := net.Build()
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	ss.InitWts(net)
 }
 
-// InitWts loads the saved weights
+// InitWts loads the saved weights, or sets them from the constraints
+// of the NetDesc if loaded
 func (ss *Sim) InitWts(net *leabra.Network) {
 	net.InitWts()
+	if ss.NetDesc != nil {
+		if err := ss.NetDesc.SetWts(net); err != nil {
+			log.Println(err)
+		}
+		return
+	}
 	ab, err := Asset("cats_dogs.wts") // embedded in executable
 	if err != nil {
 		log.Println(err)
@@ -286,8 +340,7 @@ func (ss *Sim) ApplyInputs(en env.Env) {
 	ss.Net.InitExt() // clear any existing inputs -- not strictly necessary if always
 	// going to the same layers, but good practice and cheap anyway
 
-	lays := []string{"Name", "Identity", "Color", "FavoriteFood", "Size", "Species", "FavoriteToy"}
-	for _, lnm := range lays {
+	for _, lnm := range ss.InputLays() {
 		ly := ss.Net.LayerByName(lnm).(leabra.LeabraLayer).AsLeabra()
 		pats := en.State(ly.Nm)
 		if pats != nil {
@@ -296,6 +349,15 @@ func (ss *Sim) ApplyInputs(en env.Env) {
 	}
 }
 
+// InputLays returns the names of the layers that receive input patterns:
+// all of the layers, either of the standard network or of the NetDesc
+func (ss *Sim) InputLays() []string {
+	if ss.NetDesc != nil {
+		return ss.NetDesc.GroupNames()
+	}
+	return []string{"Name", "Identity", "Color", "FavoriteFood", "Size", "Species", "FavoriteToy"}
+}
+
 // Stop tells the sim to stop running
 func (ss *Sim) Stop() {
 	ss.StopNow = true
@@ -313,6 +375,11 @@ func (ss *Sim) Stopped() {
 	}
 }
 
+// Benchmark runs the Bench benchmark with TestTrial (see bench.Bench.RunSim)
+func (ss *Sim) Benchmark() {
+	ss.Bench.RunSim(ss.Net, &ss.Time, ss.TestTrial, ss.Init, ss.Stopped, &ss.NetView)
+}
+
 // SaveWeights saves the network weights -- when called with giv.CallMethod
 // it will auto-prompt for filename
 func (ss *Sim) SaveWeights(filename gi.FileName) {
@@ -387,6 +454,9 @@ func (ss *Sim) SetParams(sheet string, setMsg bool) error {
 			err = ss.SetParamsSet(ps, sheet, setMsg)
 		}
 	}
+	if ss.NetDesc != nil && (sheet == "" || sheet == "Network") {
+		ss.NetDesc.SetParams(ss.Net)
+	}
 	return err
 }
 
@@ -463,6 +533,50 @@ func (ss *Sim) OpenPats() {
 	// dt.OpenCSV("cats_dogs_pats.tsv", etable.Tab)
 }
 
+// OpenPatsFile opens patterns from given .tsv or .csv file in place of the
+// Pats, checking that they fit all of the layers.
+func (ss *Sim) OpenPatsFile(filename gi.FileName) error {
+	err := patfile.Open(ss.Pats, string(filename), ss.Net, ss.InputLays()...)
+	if err != nil {
+		patfile.Report(ss.Win, err)
+		return err
+	}
+	ss.ConfigEnv()
+	return nil
+}
+
+// OpenNet opens a description of a constraint-satisfaction network from
+// given file (see the csnet package for the format), and replaces the
+// network with it, along with the testing patterns, from the inputs in
+// the description.  Does Init at the end.
+func (ss *Sim) OpenNet(filename gi.FileName) error {
+	cn, err := csnet.Open(string(filename))
+	if err != nil {
+		log.Println(err)
+		if ss.Win != nil {
+			gi.PromptDialog(ss.Win.WinViewport2D(), gi.DlgOpts{Title: "Invalid Network", Prompt: strings.ReplaceAll(err.Error(), "\n", "<br>")}, gi.AddOk, gi.NoCancel, nil, nil)
+		}
+		return err
+	}
+	ss.NetDesc = cn
+	ss.Net = &leabra.Network{}
+	ss.ConfigNet(ss.Net)
+	ss.TstRecLays = cn.GroupNames()
+	cn.ConfigPats(ss.Pats)
+	ss.ConfigEnv()
+	ss.ConfigTstCycLog(ss.TstCycLog)
+	ss.ConfigQueries()
+	if ss.NetView != nil {
+		ss.NetView.SetNet(ss.Net)
+		ss.ConfigNetView(ss.NetView)
+	}
+	if ss.TstCycPlot != nil {
+		ss.ConfigTstCycPlot(ss.TstCycPlot, ss.TstCycLog)
+	}
+	ss.Init()
+	return nil
+}
+
 //////////////////////////////////////////////
 //  TstCycLog
 
@@ -509,6 +623,9 @@ func (ss *Sim) LogTstCyc(dt *etable.Table, cyc int) {
 	row := cyc
 
 	harm := ss.Harmony(ss.Net)
+	if ss.NetDesc != nil {
+		harm = ss.NetDesc.Harmony(ss.Net)
+	}
 	dt.SetCellFloat("Cycle", row, float64(cyc))
 	dt.SetCellString("TrialName", row, ss.TestEnv.TrialName.Cur)
 	dt.SetCellFloat("Harmony", row, float64(harm))
@@ -568,6 +685,28 @@ func (ss *Sim) ConfigNetView(nv *netview.NetView) {
 	nv.Scene().Camera.Pose.Pos.Set(0, 1.5, 3.0) // more "head on" than default which is more "top down"
 	nv.Scene().Camera.LookAt(mat32.Vec3{0, 0, 0}, mat32.Vec3{0, 1, 0})
 
+	if ss.NetDesc != nil {
+		var labs, lays []string // labels must be unique, so duplicates are skipped
+		has := map[string]bool{}
+		for li, lb := range ss.NetDesc.Labels() {
+			if !has[lb] {
+				has[lb] = true
+				labs = append(labs, lb)
+				lays = append(lays, ss.NetDesc.Groups[li].Name)
+			}
+		}
+		nv.ConfigLabels(labs)
+		for li, lnm := range lays {
+			ly := nv.LayerByName(lnm)
+			lbl := nv.LabelByName(labs[li])
+			lbl.Pose = ly.Pose
+			lbl.Pose.Pos.Y += .2
+			lbl.Pose.Pos.Z += .02
+			lbl.Pose.Scale.SetMul(mat32.Vec3{0.4, 0.08, 0.5})
+		}
+		return
+	}
+
 	labs := []string{" Morr Socks Sylv Garf Fuzz Rex Fido Spot Snoop Butch",
 		" black white brown orange", "bugs grass scraps shoe", "small  med  large", "cat     dog", "string feath bone shoe"}
 	nv.ConfigLabels(labs)
@@ -623,6 +762,12 @@ func (ss *Sim) ConfigGui() *gi.Window {
 	plt := tv.AddNewTab(eplot.KiT_Plot2D, "TstCycPlot").(*eplot.Plot2D)
 	ss.TstCycPlot = ss.ConfigTstCycPlot(plt, ss.TstCycLog)
 
+	plt = tv.AddNewTab(eplot.KiT_Plot2D, "QueryCycPlot").(*eplot.Plot2D)
+	ss.QueryCycPlot = ss.ConfigQueryCycPlot(plt, ss.QueryCycLog)
+
+	plt = tv.AddNewTab(eplot.KiT_Plot2D, "QuerySummaryPlot").(*eplot.Plot2D)
+	ss.QuerySummaryPlot = ss.ConfigQuerySummaryPlot(plt, ss.QuerySummary)
+
 	split.SetSplits(.2, .8)
 
 	tbar.AddAction(gi.ActOpts{Label: "Init", Icon: "update", Tooltip: "Initialize everything including network weights, and start over.  Also applies current params.", UpdateFunc: func(act *gi.Action) {
@@ -684,6 +829,40 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		}
 	})
 
+	tbar.AddAction(gi.ActOpts{Label: "Query Battery", Icon: "fast-fwd", Tooltip: "Runs each of the Queries (partial cues) from a fresh start, and records the completed units in each layer in the QueryLog, the harmony on each cycle in the QueryCycPlot, and the accuracy by number of cues in the QuerySummaryPlot.  Does Init at the end.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if !ss.IsRunning {
+			ss.IsRunning = true
+			tbar.UpdateActions()
+			go ss.RunQueries()
+		}
+	})
+
+	tbar.AddAction(gi.ActOpts{Label: "Gen Queries", Icon: "update", Tooltip: "Generates the Queries from the individuals known by the network: all combinations of 1 to Query.MaxCues of the attributes of each.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		ss.GenQueries()
+		vp.SetNeedsFullRender()
+	})
+
+	tbar.AddAction(gi.ActOpts{Label: "Open Queries", Icon: "file-open", Tooltip: "Open your own queries from a .tsv or .csv file, in the same format as the patterns, with only the cued units active."}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			giv.CallMethod(ss, "OpenQueries", vp)
+		})
+
+	tbar.AddAction(gi.ActOpts{Label: "Open Pats", Icon: "file-open", Tooltip: "Open your own patterns from a .tsv or .csv file -- there must be a column for each layer, fitting its shape."}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			giv.CallMethod(ss, "OpenPatsFile", vp)
+		})
+
+	tbar.AddAction(gi.ActOpts{Label: "Open Net", Icon: "file-open", Tooltip: "Open a description of your own constraint-satisfaction network (units, groups, constraints and inputs) from a text or .json file, replacing the network and the testing patterns -- see README for the format."}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			giv.CallMethod(ss, "OpenNet", vp)
+		})
+
+	bench.AddAction(tbar, win, &ss.IsRunning, ss.Benchmark)
+
 	tbar.AddAction(gi.ActOpts{Label: "README", Icon: "file-markdown", Tooltip: "Opens your browser on the README file that contains instructions for how to run this model."}, win.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			gi.OpenURL("https://github.com/CompCogNeuro/sims/blob/master/ch3/cats_dogs/README.md")
@@ -751,6 +930,42 @@ func (ss *Sim) ConfigGui() *gi.Window {
 // These props register Save methods so they can be used
 var SimProps = ki.Props{
 	"CallMethods": ki.PropSlice{
+		{"Benchmark", bench.MethodProps},
+		{"RunQueries", ki.Props{
+			"desc": "runs the query battery on each of the Queries, and reports the completions and accuracy -- does Init at the end",
+			"icon": "fast-fwd",
+		}},
+		{"GenQueries", ki.Props{
+			"desc": "generates the Queries from the individuals known by the network",
+			"icon": "update",
+		}},
+		{"OpenQueries", ki.Props{
+			"desc": "open queries from .tsv or .csv file, replacing the current ones",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".tsv,.csv",
+				}},
+			},
+		}},
+		{"OpenPatsFile", ki.Props{
+			"desc": "open patterns from .tsv or .csv file, replacing the current ones",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".tsv,.csv",
+				}},
+			},
+		}},
+		{"OpenNet", ki.Props{
+			"desc": "open a constraint-satisfaction network description from a text (.csn) or .json file, replacing the network and the testing patterns",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".csn,.json",
+				}},
+			},
+		}},
 		{"SaveWeights", ki.Props{
 			"desc": "save network weights to file",
 			"icon": "file-save",
@@ -0,0 +1,522 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/CompCogNeuro/sims/patfile"
+	"github.com/emer/emergent/emer"
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+	"github.com/goki/gi/gi"
+)
+
+// StdUnitNames are the names of the units in each layer of the standard
+// cats and dogs network
+var StdUnitNames = map[string][]string{
+	"Name":         {"Morris", "Socks", "Sylvester", "Garfield", "Fuzzy", "Rex", "Fido", "Spot", "Snoopy", "Butch"},
+	"Identity":     {"Morris", "Socks", "Sylvester", "Garfield", "Fuzzy", "Rex", "Fido", "Spot", "Snoopy", "Butch"},
+	"Color":        {"black", "white", "brown", "orange"},
+	"FavoriteFood": {"bugs", "grass", "scraps", "shoe"},
+	"Size":         {"small", "medium", "large"},
+	"Species":      {"cat", "dog"},
+	"FavoriteToy":  {"string", "feather", "bone", "shoe"},
+}
+
+// UnitNames returns the names of the units in given layer, from the
+// NetDesc if loaded
+func (ss *Sim) UnitNames(lnm string) []string {
+	if ss.NetDesc != nil {
+		if gp := ss.NetDesc.GroupByName(lnm); gp != nil {
+			return gp.Units
+		}
+		return nil
+	}
+	return StdUnitNames[lnm]
+}
+
+// QueryParams are the parameters for the query battery, which clamps
+// partial cues about the individuals known by the network, and reports
+// how the network completes the rest of the pattern
+type QueryParams struct {
+	MaxCues int     `min:"1" def:"3" desc:"maximum number of attributes (layers other than Identity) in the generated queries -- all combinations of 1 to MaxCues attributes of each individual are generated"`
+	Thr     float32 `min:"0" max:"1" step:"0.05" def:"0.5" desc:"activation threshold for a unit to count as completed"`
+}
+
+func (qp *QueryParams) Defaults() {
+	qp.MaxCues = 3
+	qp.Thr = 0.5
+}
+
+// AttrLays returns the names of the attribute layers: all but Identity
+func (ss *Sim) AttrLays() []string {
+	var lays []string
+	for _, lnm := range ss.InputLays() {
+		if lnm != "Identity" {
+			lays = append(lays, lnm)
+		}
+	}
+	return lays
+}
+
+// ConfigItems configures the Items table with the individuals known by
+// the network, from its weights: one row for each Identity unit, with
+// the units in each of the other layers that it has a positive weight to
+// (ignoring the emer.Inhib projections of negative constraints).
+func (ss *Sim) ConfigItems(dt *etable.Table) error {
+	idl, err := ss.Net.LayerByNameTry("Identity")
+	if err != nil {
+		return fmt.Errorf("query battery needs an Identity layer: %v", err)
+	}
+	ss.ConfigPatsTable(dt, "Items", "Individuals known by the network, from the weights from each Identity unit")
+	nid := idl.Shape().Len()
+	dt.SetNumRows(nid)
+	inms := ss.UnitNames("Identity")
+	for ii := 0; ii < nid; ii++ {
+		if ii < len(inms) {
+			dt.SetCellString("Name", ii, inms[ii])
+		}
+		dt.CellTensor("Identity", ii).SetFloat1D(ii, 1)
+	}
+	for _, lnm := range ss.AttrLays() {
+		ly := ss.Net.LayerByName(lnm).(leabra.LeabraLayer).AsLeabra()
+		for _, p := range ly.RcvPrjns {
+			pj := p.(leabra.LeabraPrjn).AsLeabra()
+			if pj.Send.Name() != "Identity" || pj.Typ == emer.Inhib { // Inhib: negative constraints
+				continue
+			}
+			for ii := 0; ii < nid; ii++ {
+				tsr := dt.CellTensor(lnm, ii)
+				for ri := range ly.Neurons {
+					wt := pj.SynVal("Wt", ii, ri)
+					if wt > 0 {
+						tsr.SetFloat1D(ri, float64(wt))
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ConfigPatsTable configures given table with a Name column and a column
+// for each input layer, in the format of the Pats
+func (ss *Sim) ConfigPatsTable(dt *etable.Table, name, desc string) {
+	dt.SetMetaData("name", name)
+	dt.SetMetaData("desc", desc)
+	sch := etable.Schema{
+		{"Name", etensor.STRING, nil, nil},
+	}
+	for _, lnm := range ss.InputLays() {
+		ly := ss.Net.LayerByName(lnm)
+		sch = append(sch, etable.Column{lnm, etensor.FLOAT32, ly.Shape().Shp, []string{"Y", "X"}})
+	}
+	dt.SetFromSchema(sch, 0)
+	for i := 1; i < len(dt.Cols); i++ {
+		dt.Cols[i].SetMetaData("grid-fill", "0.9")
+	}
+}
+
+// ItemUnits returns the indexes of the active units in given layer
+// for given row of a table of patterns
+func ItemUnits(dt *etable.Table, lnm string, row int) []int {
+	var us []int
+	tsr := dt.CellTensor(lnm, row)
+	for i := 0; i < tsr.Len(); i++ {
+		if tsr.FloatVal1D(i) > 0 {
+			us = append(us, i)
+		}
+	}
+	return us
+}
+
+// GenQueries generates the Queries from the Items: for each individual,
+// all combinations of 1 to Query.MaxCues of its attributes, each of which
+// activates all of its units in that layer.  Duplicate queries are skipped.
+func (ss *Sim) GenQueries() error {
+	if err := ss.ConfigItems(ss.Items); err != nil {
+		log.Println(err)
+		return err
+	}
+	dt := ss.Queries
+	ss.ConfigPatsTable(dt, "Queries", "Partial cues for the query battery, one per row")
+	attrs := ss.AttrLays()
+	has := map[string]bool{}
+	for ii := 0; ii < ss.Items.Rows; ii++ {
+		for _, sub := range Subsets(len(attrs), ss.Query.MaxCues) {
+			var cues []string
+			for _, ai := range sub {
+				lnm := attrs[ai]
+				unms := ss.UnitNames(lnm)
+				for _, ui := range ItemUnits(ss.Items, lnm, ii) {
+					cues = append(cues, lnm+"="+UnitName(unms, ui))
+				}
+			}
+			nm := strings.Join(cues, " ")
+			if nm == "" || has[nm] {
+				continue
+			}
+			has[nm] = true
+			row := dt.Rows
+			dt.SetNumRows(row + 1)
+			dt.SetCellString("Name", row, nm)
+			for _, ai := range sub {
+				lnm := attrs[ai]
+				tsr := dt.CellTensor(lnm, row)
+				for _, ui := range ItemUnits(ss.Items, lnm, ii) {
+					tsr.SetFloat1D(ui, 1)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Subsets returns all subsets of 1 to max of the indexes 0..n-1, in
+// order of size
+func Subsets(n, max int) [][]int {
+	var subs [][]int
+	var gen func(st int, cur []int, k int)
+	gen = func(st int, cur []int, k int) {
+		if len(cur) == k {
+			subs = append(subs, append([]int{}, cur...))
+			return
+		}
+		for i := st; i < n; i++ {
+			gen(i+1, append(cur, i), k)
+		}
+	}
+	for k := 1; k <= max && k <= n; k++ {
+		gen(0, nil, k)
+	}
+	return subs
+}
+
+// UnitName returns the name of unit ui from given names, or its index
+func UnitName(unms []string, ui int) string {
+	if ui < len(unms) {
+		return unms[ui]
+	}
+	return strconv.Itoa(ui)
+}
+
+// OpenQueries opens the Queries from given .tsv or .csv file, in the same
+// format as the Pats, with only the cued units active.
+func (ss *Sim) OpenQueries(filename gi.FileName) error {
+	err := patfile.Open(ss.Queries, string(filename), ss.Net, ss.InputLays()...)
+	if err != nil {
+		patfile.Report(ss.Win, err)
+		return err
+	}
+	return nil
+}
+
+// QueryTrial runs the query in given row of the Queries, from a
+// fresh activation state, logging each cycle to the QueryCycLog
+func (ss *Sim) QueryTrial(row int) {
+	ss.Net.InitActs()
+	ss.Net.InitExt()
+	for _, lnm := range ss.InputLays() {
+		if len(ItemUnits(ss.Queries, lnm, row)) > 0 {
+			ly := ss.Net.LayerByName(lnm).(leabra.LeabraLayer).AsLeabra()
+			ly.ApplyExt(ss.Queries.CellTensor(lnm, row))
+		}
+	}
+	qnm := ss.Queries.CellString("Name", row)
+	ss.Net.AlphaCycInit(false)
+	ss.Time.AlphaCycStart()
+	for qtr := 0; qtr < 4; qtr++ {
+		for cyc := 0; cyc < ss.Time.CycPerQtr; cyc++ {
+			ss.Net.Cycle(&ss.Time)
+			ss.LogQueryCyc(ss.QueryCycLog, qnm, ss.Time.Cycle)
+			ss.Time.CycleInc()
+		}
+		ss.Net.QuarterFinal(&ss.Time)
+		ss.Time.QuarterInc()
+	}
+}
+
+// RunQueries runs the query battery: each of the Queries (generated with
+// GenQueries if there are none), recording the completed units in each
+// layer and their activations in the QueryLog, the harmony and
+// activations on each cycle in the QueryCycLog, and the accuracy by
+// number of cued attributes in the QuerySummary.  Does Init at the end.
+func (ss *Sim) RunQueries() {
+	nv := ss.NetView
+	ss.NetView = nil
+	ss.StopNow = false
+	ss.Init()
+	err := ss.ConfigItems(ss.Items)
+	if err == nil && ss.Queries.Rows == 0 {
+		err = ss.GenQueries()
+	}
+	if err != nil {
+		log.Println(err)
+	} else {
+		ss.ConfigQueryLog(ss.QueryLog)
+		ss.ConfigQueryCycLog(ss.QueryCycLog)
+		for row := 0; row < ss.Queries.Rows; row++ {
+			ss.QueryTrial(row)
+			ss.LogQuery(ss.QueryLog, row)
+			if ss.StopNow {
+				break
+			}
+		}
+		ss.LogQuerySummary(ss.QuerySummary, ss.QueryLog)
+		ss.QueryCycPlot.GoUpdate()
+		ss.QuerySummaryPlot.GoUpdate()
+	}
+	ss.NetView = nv
+	ss.Init()
+	ss.Stopped()
+}
+
+//////////////////////////////////////////////
+//  QueryLog
+
+// LogQuery adds a row to the QueryLog with the results of the query in
+// given row of the Queries: the individuals that match the cue, and for
+// each layer, the most active unit if above Query.Thr and its activation.
+// Accuracy is only computed for queries that match at least one individual:
+// IdentCor is 1 if the most active Identity unit is one of the matches,
+// and FeatCor is the proportion of the uncued attribute layers whose
+// completed unit is a value of one of the matches.
+func (ss *Sim) LogQuery(dt *etable.Table, qrow int) {
+	qp := &ss.Query
+	lays := ss.InputLays()
+	var matches []int
+	for ii := 0; ii < ss.Items.Rows; ii++ {
+		match := true
+		for _, lnm := range lays {
+			itsr := ss.Items.CellTensor(lnm, ii)
+			for _, ui := range ItemUnits(ss.Queries, lnm, qrow) {
+				if itsr.FloatVal1D(ui) <= 0 {
+					match = false
+				}
+			}
+		}
+		if match {
+			matches = append(matches, ii)
+		}
+	}
+	var mnms []string
+	for _, ii := range matches {
+		mnms = append(mnms, ss.Items.CellString("Name", ii))
+	}
+
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellString("Query", row, ss.Queries.CellString("Name", qrow))
+	dt.SetCellString("Matches", row, strings.Join(mnms, " "))
+	dt.SetCellFloat("NMatches", row, float64(len(matches)))
+	dt.SetCellFloat("Harmony", row, ss.QueryCycLog.CellFloat("Harmony", ss.QueryCycLog.Rows-1))
+
+	ncues, nfeat, nfcor := 0, 0, 0
+	identCor := 0.0
+	for _, lnm := range lays {
+		ly := ss.Net.LayerByName(lnm).(leabra.LeabraLayer).AsLeabra()
+		mx, mi := float32(0), -1
+		for ni := range ly.Neurons {
+			if act := ly.Neurons[ni].Act; act > mx {
+				mx, mi = act, ni
+			}
+		}
+		comp := ""
+		if mx >= qp.Thr {
+			comp = UnitName(ss.UnitNames(lnm), mi)
+		} else {
+			mi = -1
+		}
+		dt.SetCellString(lnm, row, comp)
+		dt.SetCellFloat(lnm+"Act", row, float64(mx))
+		cued := len(ItemUnits(ss.Queries, lnm, qrow)) > 0
+		if cued && lnm != "Identity" {
+			ncues++
+		}
+		if cued || len(matches) == 0 {
+			continue
+		}
+		cor := false
+		for _, ii := range matches {
+			if mi >= 0 && ss.Items.CellTensor(lnm, ii).FloatVal1D(mi) > 0 {
+				cor = true
+				break
+			}
+		}
+		if lnm == "Identity" {
+			if cor {
+				identCor = 1
+			}
+			continue
+		}
+		nfeat++
+		if cor {
+			nfcor++
+		}
+	}
+	featCor := 0.0
+	if nfeat > 0 {
+		featCor = float64(nfcor) / float64(nfeat)
+	}
+	dt.SetCellFloat("NCues", row, float64(ncues))
+	dt.SetCellFloat("IdentCor", row, identCor)
+	dt.SetCellFloat("FeatCor", row, featCor)
+}
+
+func (ss *Sim) ConfigQueryLog(dt *etable.Table) {
+	dt.SetMetaData("name", "QueryLog")
+	dt.SetMetaData("desc", "Results of the query battery: the completed unit and its activation in each layer, for each query")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Query", etensor.STRING, nil, nil},
+		{"NCues", etensor.FLOAT64, nil, nil},
+		{"Matches", etensor.STRING, nil, nil},
+		{"NMatches", etensor.FLOAT64, nil, nil},
+		{"IdentCor", etensor.FLOAT64, nil, nil},
+		{"FeatCor", etensor.FLOAT64, nil, nil},
+		{"Harmony", etensor.FLOAT64, nil, nil},
+	}
+	for _, lnm := range ss.InputLays() {
+		sch = append(sch, etable.Column{lnm, etensor.STRING, nil, nil})
+		sch = append(sch, etable.Column{lnm + "Act", etensor.FLOAT64, nil, nil})
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+//////////////////////////////////////////////
+//  QueryCycLog
+
+// LogQueryCyc adds a row to the QueryCycLog for given cycle of given query
+func (ss *Sim) LogQueryCyc(dt *etable.Table, qnm string, cyc int) {
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	harm := ss.Harmony(ss.Net)
+	if ss.NetDesc != nil {
+		harm = ss.NetDesc.Harmony(ss.Net)
+	}
+	dt.SetCellString("Query", row, qnm)
+	dt.SetCellFloat("Cycle", row, float64(cyc))
+	dt.SetCellFloat("Harmony", row, float64(harm))
+	for _, lnm := range ss.InputLays() {
+		tsr := ss.ValsTsr(lnm)
+		ly := ss.Net.LayerByName(lnm).(leabra.LeabraLayer).AsLeabra()
+		ly.UnitValsTensor(tsr, "Act")
+		dt.SetCellTensor(lnm, row, tsr)
+	}
+}
+
+func (ss *Sim) ConfigQueryCycLog(dt *etable.Table) {
+	dt.SetMetaData("name", "QueryCycLog")
+	dt.SetMetaData("desc", "Harmony and activations on each cycle of each query in the query battery")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Query", etensor.STRING, nil, nil},
+		{"Cycle", etensor.INT64, nil, nil},
+		{"Harmony", etensor.FLOAT64, nil, nil},
+	}
+	for _, lnm := range ss.InputLays() {
+		ly := ss.Net.LayerByName(lnm)
+		sch = append(sch, etable.Column{lnm, etensor.FLOAT64, ly.Shape().Shp, nil})
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigQueryCycPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "CatsAndDogs Query Harmony Plot"
+	plt.Params.XAxisCol = "Cycle"
+	plt.Params.LegendCol = "Query"
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Query", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Cycle", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Harmony", eplot.On, eplot.FixMin, 0, eplot.FixMax, .25)
+	return plt
+}
+
+//////////////////////////////////////////////
+//  QuerySummary
+
+// LogQuerySummary computes the QuerySummary from the QueryLog: the mean
+// accuracy and harmony by number of cued attributes, and over All queries,
+// for the queries that match at least one individual
+func (ss *Sim) LogQuerySummary(dt *etable.Table, ql *etable.Table) {
+	ss.ConfigQuerySummary(dt)
+	maxc := 0
+	for ri := 0; ri < ql.Rows; ri++ {
+		if nc := int(ql.CellFloat("NCues", ri)); nc > maxc {
+			maxc = nc
+		}
+	}
+	for nc := 0; nc <= maxc+1; nc++ {
+		n := 0
+		var ident, feat, harm float64
+		for ri := 0; ri < ql.Rows; ri++ {
+			if ql.CellFloat("NMatches", ri) == 0 {
+				continue
+			}
+			if nc <= maxc && int(ql.CellFloat("NCues", ri)) != nc {
+				continue
+			}
+			n++
+			ident += ql.CellFloat("IdentCor", ri)
+			feat += ql.CellFloat("FeatCor", ri)
+			harm += ql.CellFloat("Harmony", ri)
+		}
+		if n == 0 {
+			continue
+		}
+		cues := strconv.Itoa(nc)
+		if nc > maxc {
+			cues = "All"
+		}
+		row := dt.Rows
+		dt.SetNumRows(row + 1)
+		dt.SetCellString("Cues", row, cues)
+		dt.SetCellFloat("N", row, float64(n))
+		dt.SetCellFloat("IdentCor", row, ident/float64(n))
+		dt.SetCellFloat("FeatCor", row, feat/float64(n))
+		dt.SetCellFloat("Harmony", row, harm/float64(n))
+	}
+}
+
+func (ss *Sim) ConfigQuerySummary(dt *etable.Table) {
+	dt.SetMetaData("name", "QuerySummary")
+	dt.SetMetaData("desc", "Accuracy of the query battery by number of cued attributes")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Cues", etensor.STRING, nil, nil},
+		{"N", etensor.FLOAT64, nil, nil},
+		{"IdentCor", etensor.FLOAT64, nil, nil},
+		{"FeatCor", etensor.FLOAT64, nil, nil},
+		{"Harmony", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigQuerySummaryPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "CatsAndDogs Query Accuracy Plot"
+	plt.Params.XAxisCol = "Cues"
+	plt.Params.Type = eplot.Bar
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Cues", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("N", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("IdentCor", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("FeatCor", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("Harmony", eplot.Off, eplot.FixMin, 0, eplot.FixMax, .25)
+	return plt
+}
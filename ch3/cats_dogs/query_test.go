@@ -0,0 +1,57 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/CompCogNeuro/sims/csnet"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+)
+
+func TestConfigItems(t *testing.T) {
+	text := `
+net Pets
+group Identity Rex Tom
+group Species cat dog
+constraint Identity.Rex Species.dog 1
+constraint Identity.Rex Species.cat -1    # not a cat
+constraint Identity.Tom Species.cat 0.5
+constraint Identity.Tom Species.dog -0.5  # not a dog
+`
+	cn := &csnet.Net{}
+	if err := cn.ReadText(strings.NewReader(text)); err != nil {
+		t.Fatal(err)
+	}
+	if err := cn.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	ss := &Sim{}
+	ss.New()
+	ss.NetDesc = cn
+	ss.ConfigNet(ss.Net)
+
+	dt := &etable.Table{}
+	if err := ss.ConfigItems(dt); err != nil {
+		t.Fatal(err)
+	}
+	if dt.Rows != 2 {
+		t.Fatalf("%d items, want 2", dt.Rows)
+	}
+	want := map[string][]float32{
+		"Rex": {0, 1},
+		"Tom": {0.5, 0},
+	}
+	for row := 0; row < dt.Rows; row++ {
+		nm := dt.CellString("Name", row)
+		spec := dt.CellTensor("Species", row).(*etensor.Float32).Values
+		if !reflect.DeepEqual(spec, want[nm]) {
+			t.Errorf("%s Species: %v, want %v", nm, spec, want[nm])
+		}
+	}
+}
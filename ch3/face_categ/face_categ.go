@@ -9,12 +9,16 @@ package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 	"log"
 	"math/rand"
+	"os"
 	"strconv"
 	"strings"
 
+	"github.com/CompCogNeuro/sims/bench"
+	"github.com/CompCogNeuro/sims/patfile"
 	"github.com/emer/emergent/emer"
 	"github.com/emer/emergent/env"
 	"github.com/emer/emergent/netview"
@@ -37,11 +41,22 @@ import (
 	"github.com/goki/mat32"
 )
 
-// this is the stub main for gogi that calls our actual mainrun function, at end of file
 func main() {
-	gimain.Main(func() {
-		mainrun()
-	})
+	TheSim.New()
+	TheSim.Config()
+	if len(os.Args) > 1 {
+		TheSim.CmdArgs() // simple assumption is that any args = no gui -- could add explicit arg if you want
+	} else {
+		gimain.Main(func() { // this starts gui -- requires valid OpenGL display connection (e.g., X11)
+			guirun()
+		})
+	}
+}
+
+func guirun() {
+	TheSim.Init()
+	win := TheSim.ConfigGui()
+	win.StartEventLoop()
 }
 
 // LogPrec is precision for saving float values in logs
@@ -99,6 +114,7 @@ type Sim struct {
 	ParamSet      string            `view:"-" desc:"which set of *additional* parameters to use -- always applies Base and optionaly this next if set -- can use multiple names separated by spaces (don't put spaces in ParamSet names!)"`
 	TestEnv       env.FixedTable    `desc:"Testing environment -- manages iterating over testing"`
 	Time          leabra.Time       `desc:"leabra timing parameters and state"`
+	Bench         bench.Bench       `view:"no-inline" desc:"benchmark parameters and results -- see Benchmark"`
 	ViewUpdt      leabra.TimeScales `desc:"at what time scale to update the display during testing?  Change to AlphaCyc to make display updating go faster"`
 	TstRecLays    []string          `desc:"names of layers to record activations etc of during testing"`
 	ClustFaces    *eplot.Plot2D     `view:"no-inline" desc:"cluster plot of faces"`
@@ -107,6 +123,11 @@ type Sim struct {
 	ClustIdent    *eplot.Plot2D     `view:"no-inline" desc:"cluster plot of identity"`
 	PrjnRandom    *eplot.Plot2D     `view:"no-inline" desc:"random projection plot"`
 	PrjnEmoteGend *eplot.Plot2D     `view:"no-inline" desc:"projection plot of emotions & gender"`
+	PrjnPCA       *eplot.Plot2D     `view:"no-inline" desc:"projection plot onto the first two principal components of the Prjn.Lay layer"`
+	PrjnMDS       *eplot.Plot2D     `view:"no-inline" desc:"classical multidimensional scaling plot of the Prjn.Lay layer"`
+	PrjnReadout   *eplot.Plot2D     `view:"no-inline" desc:"projection plot of the leave-one-out least-squares readouts of emotion & gender from the Prjn.Lay layer"`
+	Prjn          PrjnParams        `view:"inline" desc:"parameters for the PCA, MDS and readout projections"`
+	Import        ImportParams      `view:"inline" desc:"parameters for importing face image datasets with ImportFaces"`
 
 	// internal state - view:"-"
 	Win        *gi.Window                  `view:"-" desc:"main GUI window"`
@@ -141,11 +162,17 @@ func (ss *Sim) New() {
 	ss.ClustIdent = &eplot.Plot2D{}
 	ss.PrjnRandom = &eplot.Plot2D{}
 	ss.PrjnEmoteGend = &eplot.Plot2D{}
+	ss.PrjnPCA = &eplot.Plot2D{}
+	ss.PrjnMDS = &eplot.Plot2D{}
+	ss.PrjnReadout = &eplot.Plot2D{}
 	ss.Defaults()
+	ss.Bench.Defaults("face_categ")
 }
 
 // Defaults sets default params
 func (ss *Sim) Defaults() {
+	ss.Prjn.Defaults()
+	ss.Import.Defaults()
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////
@@ -328,6 +355,11 @@ func (ss *Sim) Stopped() {
 	}
 }
 
+// Benchmark runs the Bench benchmark with TestTrial (see bench.Bench.RunSim)
+func (ss *Sim) Benchmark() {
+	ss.Bench.RunSim(ss.Net, &ss.Time, ss.TestTrial, ss.Init, ss.Stopped, &ss.NetView)
+}
+
 // SaveWeights saves the network weights -- when called with giv.CallMethod
 // it will auto-prompt for filename
 func (ss *Sim) SaveWeights(filename gi.FileName) {
@@ -457,13 +489,12 @@ func (ss *Sim) SetInput(topDown bool) {
 func (ss *Sim) SetPats(partial bool) {
 	if partial {
 		ss.TestEnv.Table = etable.NewIdxView(ss.PartPats)
-		ss.TestEnv.Validate()
-		ss.TestEnv.Init(0)
 	} else {
 		ss.TestEnv.Table = etable.NewIdxView(ss.Pats)
-		ss.TestEnv.Validate()
-		ss.TestEnv.Init(0)
 	}
+	ss.TestEnv.Validate()
+	ss.TestEnv.Init(0)
+	ss.TstTrlLog.SetNumRows(ss.TestEnv.Table.Len()) // in case a different number were opened
 }
 
 // OpenPatAsset opens pattern file from embedded assets
@@ -495,6 +526,23 @@ func (ss *Sim) OpenPats() {
 	// err := ss.PartPats.OpenCSV("partial_faces.tsv", etable.Tab)
 }
 
+// OpenPatsFile opens patterns from given .tsv or .csv file in place of the
+// full (or partial) face Pats, checking that they fit the Input and
+// category layers, and presents them as in SetPats.
+func (ss *Sim) OpenPatsFile(filename gi.FileName, partial bool) error {
+	dt := ss.Pats
+	if partial {
+		dt = ss.PartPats
+	}
+	err := patfile.Open(dt, string(filename), ss.Net, "Input", "Emotion", "Gender", "Identity")
+	if err != nil {
+		patfile.Report(ss.Win, err)
+		return err
+	}
+	ss.SetPats(partial)
+	return nil
+}
+
 //////////////////////////////////////////////
 //  Cluster Plots
 
@@ -559,6 +607,7 @@ func (ss *Sim) PrjnPlot() {
 		dt.SetCellFloat("RndPrjn0", r, float64(rprjn0))
 		dt.SetCellFloat("RndPrjn1", r, float64(rprjn1))
 	}
+	ss.PrjnMethods(dt)
 
 	plt := ss.PrjnRandom
 	plt.InitName(plt, "PrjnRandom")
@@ -583,6 +632,8 @@ func (ss *Sim) PrjnPlot() {
 	plt.SetColParams("TrialName", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 0)
 	plt.SetColParams("GendPrjn", eplot.Off, eplot.FixMin, -1, eplot.FixMax, 1)
 	plt.SetColParams("EmotePrjn", eplot.On, eplot.FixMin, -1, eplot.FixMax, 1)
+
+	ss.ConfigPrjnMethodPlots(dt)
 }
 
 func (ss *Sim) ConfigPrjnTable(dt *etable.Table) {
@@ -600,6 +651,14 @@ func (ss *Sim) ConfigPrjnTable(dt *etable.Table) {
 	sch = append(sch, etable.Column{"EmotePrjn", etensor.FLOAT64, nil, nil})
 	sch = append(sch, etable.Column{"RndPrjn0", etensor.FLOAT64, nil, nil})
 	sch = append(sch, etable.Column{"RndPrjn1", etensor.FLOAT64, nil, nil})
+	for _, lnm := range ss.TstRecLays {
+		sch = append(sch, etable.Column{lnm + "PC0", etensor.FLOAT64, nil, nil})
+		sch = append(sch, etable.Column{lnm + "PC1", etensor.FLOAT64, nil, nil})
+		sch = append(sch, etable.Column{lnm + "MDS0", etensor.FLOAT64, nil, nil})
+		sch = append(sch, etable.Column{lnm + "MDS1", etensor.FLOAT64, nil, nil})
+		sch = append(sch, etable.Column{lnm + "EmoteRead", etensor.FLOAT64, nil, nil})
+		sch = append(sch, etable.Column{lnm + "GendRead", etensor.FLOAT64, nil, nil})
+	}
 	dt.SetFromSchema(sch, nt)
 }
 
@@ -816,12 +875,29 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			giv.CallMethod(ss, "SetPats", vp)
 		})
+	tbar.AddAction(gi.ActOpts{Label: "Open Pats", Icon: "file-open", Tooltip: "Open your own face patterns from a .tsv or .csv file, in place of the full or partial faces -- the columns must fit the Input, Emotion, Gender and Identity layers."}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			giv.CallMethod(ss, "OpenPatsFile", vp)
+		})
+
+	tbar.AddAction(gi.ActOpts{Label: "Import Faces", Icon: "file-open", Tooltip: "Import your own dataset of face images, given a .csv or .tsv label file with File, Emotion, Gender and Identity columns -- the images are converted to the Input layer using the Import params, and occluded partial faces are also generated."}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			giv.CallMethod(ss, "ImportFaces", vp)
+		})
+
 	tbar.AddAction(gi.ActOpts{Label: "Cluster Plots", Icon: "image", Tooltip: "generate cluster plots of the different layer patterns"}, win.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			ss.ClusterPlots()
 			vp.SetNeedsFullRender()
 		})
 
+	tbar.AddAction(gi.ActOpts{Label: "Save Prjns", Icon: "file-save", Tooltip: "compute all the projections of the testing data (random, emotion / gender, PCA, MDS and readout) and save the PrjnTable to a .tsv file"}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			giv.CallMethod(ss, "SavePrjns", vp)
+		})
+
+	bench.AddAction(tbar, win, &ss.IsRunning, ss.Benchmark)
+
 	tbar.AddAction(gi.ActOpts{Label: "README", Icon: "file-markdown", Tooltip: "Opens your browser on the README file that contains instructions for how to run this model."}, win.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			gi.OpenURL("https://github.com/CompCogNeuro/sims/blob/master/ch3/face_categ/README.md")
@@ -889,6 +965,35 @@ func (ss *Sim) ConfigGui() *gi.Window {
 // These props register Save methods so they can be used
 var SimProps = ki.Props{
 	"CallMethods": ki.PropSlice{
+		{"Benchmark", bench.MethodProps},
+		{"SavePrjns", ki.Props{
+			"desc": "compute all the projections of the testing data and save the PrjnTable to a .tsv file",
+			"icon": "file-save",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".tsv",
+				}},
+			},
+		}},
+		{"ImportFaces", ki.Props{
+			"desc": "import a dataset of face images, given a label file listing the image files and their Emotion, Gender and Identity -- see Import for the preprocessing params",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".csv,.tsv",
+				}},
+			},
+		}},
+		{"OpenPatsFile", ki.Props{
+			"desc": "open patterns from .tsv or .csv file, replacing the current ones",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".tsv,.csv",
+				}},
+				{"Partial", ki.Props{}},
+			},
+		}},
 		{"SaveWeights", ki.Props{
 			"desc": "save network weights to file",
 			"icon": "file-save",
@@ -915,11 +1020,30 @@ var SimProps = ki.Props{
 	},
 }
 
-func mainrun() {
-	TheSim.New()
-	TheSim.Config()
-
-	TheSim.Init()
-	win := TheSim.ConfigGui()
-	win.StartEventLoop()
+// CmdArgs runs the sim without the gui, according to the command-line
+// args: -faces first imports the faces from a label file (see ImportFaces),
+// then -bench runs the benchmark (with -benchthreads and -benchfile, see
+// Bench), and otherwise all the projections of the testing data are saved
+// to the -prjnfile (see SavePrjns)
+func (ss *Sim) CmdArgs() {
+	var nogui bool
+	var prjnFile, facesFile string
+	flag.StringVar(&facesFile, "faces", "", "if set, import the faces from this label file (.csv or .tsv) and their images, in place of the standard faces")
+	flag.StringVar(&prjnFile, "prjnfile", "face_categ_prjns.tsv", "save the PrjnTable of projections of the testing data to this .tsv file")
+	flag.BoolVar(&nogui, "nogui", true, "if not passing any other args and want to run nogui, use nogui")
+	ss.Bench.AddFlags()
+	flag.Parse()
+	if facesFile != "" {
+		if err := ss.ImportFaces(gi.FileName(facesFile)); err != nil {
+			return
+		}
+	}
+	ss.Init()
+	if bench.Flagged() {
+		ss.Benchmark()
+		return
+	}
+	if err := ss.SavePrjns(gi.FileName(prjnFile)); err == nil {
+		fmt.Printf("Saved %s\n", prjnFile)
+	}
 }
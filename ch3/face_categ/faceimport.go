@@ -0,0 +1,350 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // register image formats for image.Decode
+	_ "image/jpeg"
+	_ "image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/CompCogNeuro/sims/patfile"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+	"github.com/goki/gi/gi"
+)
+
+// ImportParams are the parameters for importing a face image dataset
+// with ImportFaces
+type ImportParams struct {
+	Crop       float32 `def:"1" min:"0.1" max:"1" step:"0.05" desc:"fraction of the largest centered square of each image to keep, in the center -- the rest is cropped away before resizing to the Input layer"`
+	Invert     bool    `def:"true" desc:"invert the grayscale values, so that dark features (lines) on a light background are active, as in the standard faces"`
+	Thr        float32 `def:"0.5" min:"0" max:"1" desc:"if > 0, binarize the contrast-normalized values at this threshold, as in the standard faces -- 0 keeps the graded values"`
+	NPartial   int     `def:"1" min:"0" desc:"number of occluded partial-face variants of each face to generate into PartPats -- 0 to leave PartPats as is"`
+	OccludePct float32 `def:"50" min:"0" max:"100" step:"5" desc:"percent of the active Input units in each face that are turned off in the partial-face variants"`
+}
+
+func (ip *ImportParams) Defaults() {
+	ip.Crop = 1
+	ip.Invert = true
+	ip.Thr = 0.5
+	ip.NPartial = 1
+	ip.OccludePct = 50
+}
+
+// CategLays are the category layers that are labeled for each face
+var CategLays = []string{"Emotion", "Gender", "Identity"}
+
+// StdCategs are the standard category names for each unit of the category
+// layers -- imported labels can use these, unit numbers, or new names,
+// which are assigned to the next unused unit
+var StdCategs = map[string][]string{
+	"Emotion":  {"happy", "sad"},
+	"Gender":   {"female", "male"},
+	"Identity": {"alberto", "betty", "lisa", "mark", "wendy", "zane"},
+}
+
+// categMap maps the category names of one layer onto its units
+type categMap struct {
+	lay   string
+	n     int
+	names map[string]int
+	next  int
+}
+
+func newCategMap(lay string, n int) *categMap {
+	cm := &categMap{lay: lay, n: n, names: make(map[string]int)}
+	for i, nm := range StdCategs[lay] {
+		if i < n {
+			cm.names[nm] = i
+		}
+	}
+	cm.next = len(cm.names)
+	return cm
+}
+
+// unit returns the unit for given category name or number
+func (cm *categMap) unit(val string) (int, error) {
+	nm := strings.ToLower(strings.TrimSpace(val))
+	if ui, ok := cm.names[nm]; ok {
+		return ui, nil
+	}
+	if ui, err := strconv.Atoi(nm); err == nil {
+		if ui < 0 || ui >= cm.n {
+			return 0, fmt.Errorf("%s unit %d out of range: layer has %d units", cm.lay, ui, cm.n)
+		}
+		return ui, nil
+	}
+	if cm.next >= cm.n {
+		return 0, fmt.Errorf("%s category %q: all %d units are already used", cm.lay, val, cm.n)
+	}
+	cm.names[nm] = cm.next
+	cm.next++
+	return cm.names[nm], nil
+}
+
+// FaceImage converts given image into a pattern for an Input layer of
+// given 2D shape: the largest centered square (times ip.Crop) is converted
+// to grayscale, averaged down to the layer resolution, and contrast
+// normalized so that values at or below the image mean are 0 and the
+// maximum is 1 (then binarized if ip.Thr > 0).  The top of the image goes
+// at the top of the layer, which has row 0 at the bottom.
+func FaceImage(img image.Image, ny, nx int, ip *ImportParams) *etensor.Float32 {
+	bnd := img.Bounds()
+	sz := bnd.Dx()
+	if bnd.Dy() < sz {
+		sz = bnd.Dy()
+	}
+	csz := int(float32(sz) * ip.Crop)
+	if csz < 1 {
+		csz = 1
+	}
+	x0 := bnd.Min.X + (bnd.Dx()-csz)/2
+	y0 := bnd.Min.Y + (bnd.Dy()-csz)/2
+	pat := etensor.NewFloat32([]int{ny, nx}, nil, nil)
+	for oy := 0; oy < ny; oy++ {
+		sy0, sy1 := y0+oy*csz/ny, y0+(oy+1)*csz/ny
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+		for ox := 0; ox < nx; ox++ {
+			sx0, sx1 := x0+ox*csz/nx, x0+(ox+1)*csz/nx
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+			sum := float32(0)
+			for y := sy0; y < sy1; y++ {
+				for x := sx0; x < sx1; x++ {
+					sum += float32(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y) / 255
+				}
+			}
+			v := sum / float32((sy1-sy0)*(sx1-sx0))
+			if ip.Invert {
+				v = 1 - v
+			}
+			pat.Values[(ny-1-oy)*nx+ox] = v // row 0 is the bottom
+		}
+	}
+	mean, max := float32(0), float32(0)
+	for _, v := range pat.Values {
+		mean += v
+		if v > max {
+			max = v
+		}
+	}
+	mean /= float32(len(pat.Values))
+	for i, v := range pat.Values {
+		if max > mean {
+			v = (v - mean) / (max - mean)
+		} else {
+			v = 0
+		}
+		if v < 0 {
+			v = 0
+		}
+		if ip.Thr > 0 {
+			if v >= ip.Thr {
+				v = 1
+			} else {
+				v = 0
+			}
+		}
+		pat.Values[i] = v
+	}
+	return pat
+}
+
+// OccludePat returns a copy of given pattern with pct percent of its
+// active units turned off, chosen at random
+func OccludePat(pat *etensor.Float32, pct float32) *etensor.Float32 {
+	op := pat.Clone().(*etensor.Float32)
+	var act []int
+	for i, v := range op.Values {
+		if v > 0 {
+			act = append(act, i)
+		}
+	}
+	noff := int(pct / 100 * float32(len(act)))
+	for _, pi := range rand.Perm(len(act))[:noff] {
+		op.Values[act[pi]] = 0
+	}
+	return op
+}
+
+// ConfigFacePats configures given table for face patterns, with a Name
+// column and a column for the Input and each of the category layers
+func (ss *Sim) ConfigFacePats(dt *etable.Table, nrows int) {
+	sch := etable.Schema{
+		{"Name", etensor.STRING, nil, nil},
+	}
+	for _, lnm := range append([]string{"Input"}, CategLays...) {
+		ly := ss.Net.LayerByName(lnm).(leabra.LeabraLayer).AsLeabra()
+		sch = append(sch, etable.Column{lnm, etensor.FLOAT32, ly.Shp.Shp, []string{"Y", "X"}})
+	}
+	dt.SetFromSchema(sch, nrows)
+	for i := 1; i < len(dt.Cols); i++ {
+		dt.Cols[i].SetMetaData("grid-fill", "0.9")
+	}
+}
+
+// ImportFaces imports a dataset of face images into the Pats (and, if
+// Import.NPartial > 0, occluded versions of them into the PartPats), given
+// a label file in .csv (or .tsv) format, with a header row and a row per
+// image.  The File column has the image file name (.png, .jpg or .gif),
+// relative to the directory of the label file, and the Emotion, Gender and
+// Identity columns have the category of each face, as one of the StdCategs
+// names, a unit number, or a new name (assigned to the next unused unit).
+// An optional Name column names each face -- otherwise it is Identity_Emotion.
+// Each image is converted with FaceImage, using the Import params.
+// All of the problems found are reported, and nothing is changed if there
+// are any.
+func (ss *Sim) ImportFaces(filename gi.FileName) error {
+	fnm := string(filename)
+	err := ss.ImportFacesFile(fnm)
+	if err != nil {
+		err = fmt.Errorf("ImportFaces: %s:\n%v", fnm, err)
+		patfile.Report(ss.Win, err)
+		return err
+	}
+	ss.SetPats(false)
+	return nil
+}
+
+// ImportFacesFile does the work for ImportFaces, without reporting errors
+func (ss *Sim) ImportFacesFile(fnm string) error {
+	fp, err := os.Open(fnm)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	rd := csv.NewReader(fp)
+	if strings.ToLower(filepath.Ext(fnm)) != ".csv" {
+		rd.Comma = '\t'
+	}
+	rd.TrimLeadingSpace = true
+	recs, err := rd.ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(recs) < 2 {
+		return errors.New("no faces: need a header row and a row for each image")
+	}
+	cols := make(map[string]int)
+	for ci, hd := range recs[0] {
+		cols[strings.ToLower(strings.TrimSpace(hd))] = ci
+	}
+	var errs []string
+	for _, cn := range append([]string{"File"}, CategLays...) {
+		if _, ok := cols[strings.ToLower(cn)]; !ok {
+			errs = append(errs, "no "+cn+" column")
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	cell := func(rec []string, cn string) string {
+		ci, ok := cols[strings.ToLower(cn)]
+		if !ok || ci >= len(rec) {
+			return ""
+		}
+		return strings.TrimSpace(rec[ci])
+	}
+
+	inp := ss.Net.LayerByName("Input").(leabra.LeabraLayer).AsLeabra()
+	ny, nx := inp.Shp.Dim(0), inp.Shp.Dim(1)
+	cmaps := make(map[string]*categMap)
+	for _, lnm := range CategLays {
+		ly := ss.Net.LayerByName(lnm).(leabra.LeabraLayer).AsLeabra()
+		cmaps[lnm] = newCategMap(lnm, ly.Shp.Len())
+	}
+	dir := filepath.Dir(fnm)
+	recs = recs[1:]
+	dt := &etable.Table{}
+	ss.ConfigFacePats(dt, len(recs))
+	for ri, rec := range recs {
+		line := ri + 2
+		ifn := cell(rec, "File")
+		if !filepath.IsAbs(ifn) {
+			ifn = filepath.Join(dir, ifn)
+		}
+		img, err := openImage(ifn)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("line %d: %v", line, err))
+		} else {
+			dt.SetCellTensor("Input", ri, FaceImage(img, ny, nx, &ss.Import))
+		}
+		for _, lnm := range CategLays {
+			ui, err := cmaps[lnm].unit(cell(rec, lnm))
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("line %d: %v", line, err))
+				continue
+			}
+			dt.SetCellTensorFloat1D(lnm, ri, ui, 1)
+		}
+		nm := cell(rec, "Name")
+		if nm == "" {
+			nm = cell(rec, "Identity") + "_" + cell(rec, "Emotion")
+		}
+		dt.SetCellString("Name", ri, nm)
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	dt.CopyMetaDataFrom(ss.Pats)
+	*ss.Pats = *dt
+	if ss.Import.NPartial > 0 {
+		ss.OccludePats(ss.PartPats, dt)
+	}
+	return nil
+}
+
+// OccludePats generates Import.NPartial occluded versions of each of the
+// faces in given table into the partial-face table pt
+func (ss *Sim) OccludePats(pt, dt *etable.Table) {
+	np := ss.Import.NPartial
+	nt := &etable.Table{}
+	ss.ConfigFacePats(nt, dt.Rows*np)
+	for r := 0; r < dt.Rows; r++ {
+		nm := dt.CellString("Name", r)
+		for pi := 0; pi < np; pi++ {
+			row := r*np + pi
+			if np > 1 {
+				nt.SetCellString("Name", row, fmt.Sprintf("%s_p%d", nm, pi))
+			} else {
+				nt.SetCellString("Name", row, nm)
+			}
+			nt.SetCellTensor("Input", row, OccludePat(dt.CellTensor("Input", r).(*etensor.Float32), ss.Import.OccludePct))
+			for _, lnm := range CategLays {
+				nt.SetCellTensor(lnm, row, dt.CellTensor(lnm, r))
+			}
+		}
+	}
+	nt.CopyMetaDataFrom(pt)
+	*pt = *nt
+}
+
+// openImage opens and decodes the image in given file
+func openImage(fnm string) (image.Image, error) {
+	fp, err := os.Open(fnm)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	img, _, err := image.Decode(fp)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fnm, err)
+	}
+	return img, nil
+}
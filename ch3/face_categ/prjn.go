@@ -0,0 +1,293 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/etable/metric"
+	"github.com/emer/etable/pca"
+	"github.com/emer/etable/simat"
+	"github.com/goki/gi/gi"
+)
+
+// PrjnParams are the parameters for the data-driven projections of the
+// testing data in the PrjnTable, in addition to the random and hand-built ones
+type PrjnParams struct {
+	Lay   string  `desc:"layer whose PCA, MDS and readout projections are shown in the PrjnPCA, PrjnMDS and PrjnReadout plots -- all of the TstRecLays are always computed in the PrjnTable"`
+	Ridge float64 `def:"0.01" min:"0" desc:"ridge (L2) regularization of the least-squares readouts of the Emotion and Gender axes from each layer -- keeps them well-defined when there are more units than faces"`
+}
+
+func (pp *PrjnParams) Defaults() {
+	pp.Lay = "Input"
+	pp.Ridge = 0.01
+}
+
+// PCAPrjn returns the projections of the rows of given column onto its
+// first two principal components, computed from the covariance matrix
+func PCAPrjn(ix *etable.IdxView, colNm string) (pc0, pc1 []float64, err error) {
+	pc := &pca.PCA{}
+	pc.Init()
+	err = pc.TableCol(ix, colNm, metric.Covariance64)
+	if err != nil {
+		return
+	}
+	err = pc.ProjectCol(&pc0, ix, colNm, 0)
+	if err != nil {
+		return
+	}
+	err = pc.ProjectCol(&pc1, ix, colNm, 1)
+	return
+}
+
+// MDSPrjn returns the classical (Torgerson) multidimensional scaling of the
+// rows of given column into two dimensions, from the SimMat of Euclidean
+// distances: the double-centered squared distances are eigen-factored, and
+// the coordinates are the top two eigenvectors scaled by the square root of
+// their eigenvalues
+func MDSPrjn(ix *etable.IdxView, colNm string) (md0, md1 []float64, err error) {
+	smat := &simat.SimMat{}
+	err = smat.TableColStd(ix, colNm, "TrialName", false, metric.Euclidean)
+	if err != nil {
+		return
+	}
+	n := ix.Len()
+	d2 := make([]float64, n*n)
+	rmean := make([]float64, n)
+	mean := 0.0
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			d := smat.Mat.FloatVal([]int{i, j})
+			d2[i*n+j] = d * d
+			rmean[i] += d * d / float64(n)
+		}
+		mean += rmean[i] / float64(n)
+	}
+	pc := &pca.PCA{}
+	pc.Init()
+	pc.Covar.SetShape([]int{n, n}, nil, nil)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			pc.Covar.Values[i*n+j] = -0.5 * (d2[i*n+j] - rmean[i] - rmean[j] + mean)
+		}
+	}
+	err = pc.PCA()
+	if err != nil {
+		return
+	}
+	md0 = make([]float64, n)
+	md1 = make([]float64, n)
+	for k, md := range [][]float64{md0, md1} {
+		if k >= n {
+			break
+		}
+		ei := n - 1 - k // eigens in reverse order
+		sc := math.Sqrt(math.Max(pc.Values[ei], 0))
+		for i := 0; i < n; i++ {
+			md[i] = sc * pc.Vectors.Value([]int{i, ei})
+		}
+	}
+	return
+}
+
+// Readout returns the leave-one-out predictions of a least-squares linear
+// readout (with bias and ridge regularization) from the rows of given
+// column to the given target values: the prediction for each row is from
+// the readout fit to all of the other rows, so it shows how well the
+// readout generalizes, instead of how well it can fit (which is nearly
+// perfectly, with many more units than rows).  It is solved in the dual
+// form, with K = G + ridge*I for the Gram matrix G of the rows (plus 1
+// for the bias), and weights a = K^-1 trg.  The hat matrix of the fit is
+// G K^-1 = I - ridge*K^-1, so the usual closed-form leave-one-out
+// prediction for row i is trg[i] - a[i] / K^-1[i,i].
+func Readout(ix *etable.IdxView, colNm string, trg []float64, ridge float64) ([]float64, error) {
+	col, err := ix.Table.ColByNameTry(colNm)
+	if err != nil {
+		return nil, err
+	}
+	n := ix.Len()
+	if len(trg) != n {
+		return nil, fmt.Errorf("Readout: %d targets for %d rows", len(trg), n)
+	}
+	if n < 2 {
+		return nil, fmt.Errorf("Readout: need at least 2 rows to leave one out, have %d", n)
+	}
+	rows := make([]etensor.Tensor, n)
+	for i := 0; i < n; i++ {
+		rows[i] = col.SubSpace([]int{ix.Idxs[i]})
+	}
+	kmat := make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			g := 1.0 // bias
+			for ci := 0; ci < rows[i].Len(); ci++ {
+				g += rows[i].FloatVal1D(ci) * rows[j].FloatVal1D(ci)
+			}
+			kmat[i*n+j] = g
+			kmat[j*n+i] = g
+		}
+		kmat[i*n+i] += ridge
+	}
+	wts, err := SolveLin(kmat, trg)
+	if err != nil {
+		return nil, err
+	}
+	loo := make([]float64, n)
+	ei := make([]float64, n)
+	for i := 0; i < n; i++ {
+		ei[i] = 1
+		kinv, err := SolveLin(kmat, ei) // column i of K^-1
+		if err != nil {
+			return nil, err
+		}
+		ei[i] = 0
+		loo[i] = trg[i] - wts[i]/kinv[i]
+	}
+	return loo, nil
+}
+
+// SolveLin solves the square linear system a x = b, with a in row-major
+// order, by Gaussian elimination with partial pivoting.  a and b are not
+// modified.
+func SolveLin(a, b []float64) ([]float64, error) {
+	n := len(b)
+	m := make([]float64, n*n)
+	copy(m, a)
+	x := make([]float64, n)
+	copy(x, b)
+	for c := 0; c < n; c++ {
+		p := c
+		for r := c + 1; r < n; r++ {
+			if math.Abs(m[r*n+c]) > math.Abs(m[p*n+c]) {
+				p = r
+			}
+		}
+		if m[p*n+c] == 0 {
+			return nil, fmt.Errorf("SolveLin: singular matrix")
+		}
+		if p != c {
+			for k := 0; k < n; k++ {
+				m[c*n+k], m[p*n+k] = m[p*n+k], m[c*n+k]
+			}
+			x[c], x[p] = x[p], x[c]
+		}
+		for r := c + 1; r < n; r++ {
+			f := m[r*n+c] / m[c*n+c]
+			for k := c; k < n; k++ {
+				m[r*n+k] -= f * m[c*n+k]
+			}
+			x[r] -= f * x[c]
+		}
+	}
+	for c := n - 1; c >= 0; c-- {
+		for k := c + 1; k < n; k++ {
+			x[c] -= m[c*n+k] * x[k]
+		}
+		x[c] /= m[c*n+c]
+	}
+	return x, nil
+}
+
+// SetPrjnCol sets the values of given column of the PrjnTable, one per row
+func SetPrjnCol(dt *etable.Table, colNm string, vals []float64) {
+	for r, v := range vals {
+		dt.SetCellFloat(colNm, r, v)
+	}
+}
+
+// PrjnMethods computes the PCA and MDS projections, and the leave-one-out
+// least-squares readouts of the Emotion and Gender axes, of each of the
+// TstRecLays, from the TstTrlLog, into the PrjnTable (which must already
+// have its rows)
+func (ss *Sim) PrjnMethods(dt *etable.Table) {
+	tst := ss.TstTrlLog
+	ix := etable.NewIdxView(tst)
+	nr := tst.Rows
+	emote := make([]float64, nr)
+	gend := make([]float64, nr)
+	for r := 0; r < nr; r++ {
+		// same axes as the hand-built EmotePrjn and GendPrjn, without the jitter
+		emote[r] = 0.5*tst.CellTensorFloat1D("Emotion", r, 0) + -0.5*tst.CellTensorFloat1D("Emotion", r, 1)
+		gend[r] = 0.5*tst.CellTensorFloat1D("Gender", r, 0) + -0.5*tst.CellTensorFloat1D("Gender", r, 1)
+	}
+	for _, lnm := range ss.TstRecLays {
+		pc0, pc1, err := PCAPrjn(ix, lnm)
+		if err != nil {
+			log.Println(err)
+		} else {
+			SetPrjnCol(dt, lnm+"PC0", pc0)
+			SetPrjnCol(dt, lnm+"PC1", pc1)
+		}
+		md0, md1, err := MDSPrjn(ix, lnm)
+		if err != nil {
+			log.Println(err)
+		} else {
+			SetPrjnCol(dt, lnm+"MDS0", md0)
+			SetPrjnCol(dt, lnm+"MDS1", md1)
+		}
+		for _, rd := range []struct {
+			col string
+			trg []float64
+		}{{"EmoteRead", emote}, {"GendRead", gend}} {
+			loo, err := Readout(ix, lnm, rd.trg, ss.Prjn.Ridge)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			SetPrjnCol(dt, lnm+rd.col, loo)
+		}
+	}
+}
+
+// ConfigPrjnMethodPlots configures the PCA, MDS and readout projection plots
+// of the PrjnTable, all for the Prjn.Lay layer
+func (ss *Sim) ConfigPrjnMethodPlots(dt *etable.Table) {
+	lnm := ss.Prjn.Lay
+	plt := ss.PrjnPCA
+	plt.InitName(plt, "PrjnPCA")
+	plt.Params.Title = "Face PCA Prjn Plot: " + lnm
+	ss.ConfigPrjnXY(plt, dt, lnm+"PC0", lnm+"PC1")
+
+	plt = ss.PrjnMDS
+	plt.InitName(plt, "PrjnMDS")
+	plt.Params.Title = "Face MDS Prjn Plot: " + lnm
+	ss.ConfigPrjnXY(plt, dt, lnm+"MDS0", lnm+"MDS1")
+
+	plt = ss.PrjnReadout
+	plt.InitName(plt, "PrjnReadout")
+	plt.Params.Title = "Face Emotion / Gender Readout Prjn Plot: " + lnm
+	ss.ConfigPrjnXY(plt, dt, lnm+"GendRead", lnm+"EmoteRead")
+}
+
+// ConfigPrjnXY configures given plot of the PrjnTable to show just the
+// TrialName labels at the given x, y columns, with auto-scaled ranges
+func (ss *Sim) ConfigPrjnXY(plt *eplot.Plot2D, dt *etable.Table, xcol, ycol string) {
+	plt.Params.XAxisCol = xcol
+	plt.SetTable(dt)
+	plt.Params.Lines = false
+	plt.Params.Points = true
+	for _, cn := range dt.ColNames {
+		plt.SetColParams(cn, eplot.Off, eplot.FloatMin, 0, eplot.FloatMax, 0)
+	}
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("TrialName", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams(ycol, eplot.On, eplot.FloatMin, 0, eplot.FloatMax, 0)
+}
+
+// SavePrjns computes all the projections of the testing data (running
+// all the testing trials) and saves the PrjnTable to given .tsv file
+func (ss *Sim) SavePrjns(filename gi.FileName) error {
+	ss.PrjnPlot()
+	err := ss.PrjnTable.SaveCSV(filename, etable.Tab, etable.Headers)
+	if err != nil {
+		log.Println(err)
+	}
+	return err
+}
@@ -12,9 +12,11 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
 	"strconv"
 	"strings"
 
+	"github.com/CompCogNeuro/sims/bench"
 	"github.com/emer/emergent/emer"
 	"github.com/emer/emergent/netview"
 	"github.com/emer/emergent/params"
@@ -36,9 +38,15 @@ import (
 
 // this is the stub main for gogi that calls our actual mainrun function, at end of file
 func main() {
-	gimain.Main(func() {
-		mainrun()
-	})
+	if bench.HasFlag(os.Args[1:]) {
+		TheSim.New()
+		TheSim.Config()
+		TheSim.Bench.RunArgs(TheSim.Benchmark) // -bench arg = run without the gui
+	} else {
+		gimain.Main(func() { // this starts gui -- requires valid OpenGL display connection (e.g., X11)
+			mainrun()
+		})
+	}
 }
 
 // LogPrec is precision for saving float values in logs
@@ -110,14 +118,17 @@ type Sim struct {
 	TrainedWts bool    `desc:"simulate trained weights by having higher variance and Gaussian distributed weight values -- otherwise lower variance, uniform"`
 	InputPct   float32 `def:"20" min:"5" max:"50" step:"1" desc:"percent of active units in input layer (literally number of active units, because input has 100 units total)"`
 	FFFBInhib  bool    `def:"false" desc:"use feedforward, feedback (FFFB) computed inhibition instead of unit-level inhibition"`
-
-	HiddenGbarI       float32 `def:"0.4" min:"0" step:"0.05" desc:"inhibitory conductance strength for inhibition into Hidden layer"`
-	InhibGbarI        float32 `def:"0.75" min:"0" step:"0.05" desc:"inhibitory conductance strength for inhibition into Inhib layer (self-inhibition -- tricky!)"`
-	FFinhibWtScale    float32 `def:"1" min:"0" step:"0.1" desc:"feedforward (FF) inhibition relative strength: for FF projections into Inhib neurons"`
-	FBinhibWtScale    float32 `def:"1" min:"0" step:"0.1" desc:"feedback (FB) inhibition relative strength: for projections into Inhib neurons"`
-	HiddenGTau        float32 `def:"40" min:"1" step:"1" desc:"time constant (tau) for updating G conductances into Hidden neurons -- much slower than std default of 1.4"`
-	InhibGTau         float32 `def:"20" min:"1" step:"1" desc:"time constant (tau) for updating G conductances into Inhib neurons -- much slower than std default of 1.4, but 2x faster than Hidden"`
-	FmInhibWtScaleAbs float32 `def:"1" desc:"absolute weight scaling of projections from inhibition onto hidden and inhib layers -- this must be set to 0 to turn off the connection-based inhibition when using the FFFBInhib computed inbhition"`
+	KWTAInhib  bool    `def:"false" desc:"use k-winners-take-all (kWTA) computed inhibition for the hidden layers, with parameters in KWTA, instead of unit-level or FFFB inhibition"`
+
+	HiddenGbarI       float32       `def:"0.4" min:"0" step:"0.05" desc:"inhibitory conductance strength for inhibition into Hidden layer"`
+	InhibGbarI        float32       `def:"0.75" min:"0" step:"0.05" desc:"inhibitory conductance strength for inhibition into Inhib layer (self-inhibition -- tricky!)"`
+	FFinhibWtScale    float32       `def:"1" min:"0" step:"0.1" desc:"feedforward (FF) inhibition relative strength: for FF projections into Inhib neurons"`
+	FBinhibWtScale    float32       `def:"1" min:"0" step:"0.1" desc:"feedback (FB) inhibition relative strength: for projections into Inhib neurons"`
+	HiddenGTau        float32       `def:"40" min:"1" step:"1" desc:"time constant (tau) for updating G conductances into Hidden neurons -- much slower than std default of 1.4"`
+	InhibGTau         float32       `def:"20" min:"1" step:"1" desc:"time constant (tau) for updating G conductances into Inhib neurons -- much slower than std default of 1.4, but 2x faster than Hidden"`
+	FmInhibWtScaleAbs float32       `def:"1" desc:"absolute weight scaling of projections from inhibition onto hidden and inhib layers -- this must be set to 0 to turn off the connection-based inhibition when using the FFFBInhib computed inbhition"`
+	KWTA              KWTAParams    `view:"inline" desc:"parameters for the kWTA inhibition, used if KWTAInhib is on"`
+	Compare           CompareParams `view:"no-inline" desc:"parameters for the comparison of inhibition types (Compare Inhib)"`
 
 	NetFF      *leabra.Network   `view:"no-inline" desc:"the feedforward network -- click to view / edit parameters for layers, prjns, etc"`
 	NetBidir   *leabra.Network   `view:"no-inline" desc:"the bidirectional network -- click to view / edit parameters for layers, prjns, etc"`
@@ -125,9 +136,11 @@ type Sim struct {
 	Params     params.Sets       `view:"no-inline" desc:"full collection of param sets -- not really interesting for this model"`
 	ParamSet   string            `view:"-" desc:"which set of *additional* parameters to use -- always applies Base and optionaly this next if set -- can use multiple names separated by spaces (don't put spaces in ParamSet names!)"`
 	Time       leabra.Time       `desc:"leabra timing parameters and state"`
+	Bench      bench.Bench       `view:"no-inline" desc:"benchmark parameters and results -- see Benchmark"`
 	ViewUpdt   leabra.TimeScales `desc:"at what time scale to update the display during testing?  Change to AlphaCyc to make display updating go faster"`
 	TstRecLays []string          `desc:"names of layers to record activations etc of during testing"`
 	Pats       *etable.Table     `view:"no-inline" desc:"the input patterns to use -- randomly generated"`
+	CompareLog *etable.Table     `view:"no-inline" desc:"comparison of inhibition types: average hidden activity and settling time as a function of InputPct"`
 
 	// internal state - view:"-"
 	Win          *gi.Window                  `view:"-" desc:"main GUI window"`
@@ -135,6 +148,7 @@ type Sim struct {
 	NetViewBidir *netview.NetView            `view:"-" desc:"the network viewer"`
 	ToolBar      *gi.ToolBar                 `view:"-" desc:"the master toolbar"`
 	TstCycPlot   *eplot.Plot2D               `view:"-" desc:"the test-trial plot"`
+	ComparePlot  *eplot.Plot2D               `view:"-" desc:"the comparison of inhibition types plot"`
 	ValsTsrs     map[string]*etensor.Float32 `view:"-" desc:"for holding layer values"`
 	IsRunning    bool                        `view:"-" desc:"true if sim is running"`
 	StopNow      bool                        `view:"-" desc:"flag to stop running"`
@@ -156,7 +170,9 @@ func (ss *Sim) New() {
 	ss.ViewUpdt = leabra.Cycle
 	ss.TstRecLays = []string{"Hidden", "Inhib"}
 	ss.Pats = &etable.Table{}
+	ss.CompareLog = &etable.Table{}
 	ss.Defaults()
+	ss.Bench.Defaults("inhib")
 }
 
 // Defaults sets default params
@@ -164,6 +180,9 @@ func (ss *Sim) Defaults() {
 	ss.TrainedWts = false
 	ss.InputPct = 20
 	ss.FFFBInhib = false
+	ss.KWTAInhib = false
+	ss.KWTA.Defaults()
+	ss.Compare.Defaults()
 	ss.HiddenGbarI = 0.4
 	ss.InhibGbarI = 0.75
 	ss.FFinhibWtScale = 1
@@ -183,6 +202,7 @@ func (ss *Sim) Config() {
 	ss.ConfigNetFF(ss.NetFF)
 	ss.ConfigNetBidir(ss.NetBidir)
 	ss.ConfigTstCycLog(ss.TstCycLog)
+	ss.ConfigCompareLog(ss.CompareLog)
 }
 
 func (ss *Sim) ConfigNetFF(net *leabra.Network) {
@@ -342,7 +362,7 @@ func (ss *Sim) AlphaCyc() {
 	ss.Time.AlphaCycStart()
 	for qtr := 0; qtr < 4; qtr++ {
 		for cyc := 0; cyc < ss.Time.CycPerQtr; cyc++ {
-			nt.Cycle(&ss.Time)
+			ss.Cycle(nt)
 			ss.LogTstCyc(ss.TstCycLog, ss.Time.Cycle)
 			ss.Time.CycleInc()
 			switch viewUpdt {
@@ -406,6 +426,12 @@ func (ss *Sim) Stopped() {
 	}
 }
 
+// Benchmark runs the Bench benchmark with TestTrial on the current network
+// (see bench.Bench.RunSim)
+func (ss *Sim) Benchmark() {
+	ss.Bench.RunSim(ss.Net(), &ss.Time, ss.TestTrial, ss.Init, ss.Stopped, &ss.NetViewFF, &ss.NetViewBidir)
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////
 // Testing
 
@@ -460,7 +486,7 @@ func (ss *Sim) SetParams(sheet string, setMsg bool) error {
 	ff.WtScale.Rel = ffinhsc
 	fb := inh.SendName("Hidden").(leabra.LeabraPrjn).AsLeabra()
 	fb.WtScale.Rel = ss.FBinhibWtScale
-	hid.Inhib.Layer.On = ss.FFFBInhib
+	hid.Inhib.Layer.On = ss.FFFBInhib && !ss.KWTAInhib
 	inh.Inhib.Layer.On = ss.FFFBInhib
 	fi := hid.SendName("Inhib").(leabra.LeabraPrjn).AsLeabra()
 	fi.WtScale.Abs = ss.FmInhibWtScaleAbs
@@ -475,7 +501,7 @@ func (ss *Sim) SetParams(sheet string, setMsg bool) error {
 		inh.Act.Gbar.I = ss.InhibGbarI
 		inh.Act.Dt.GTau = ss.InhibGTau
 		inh.Act.Update()
-		hid.Inhib.Layer.On = ss.FFFBInhib
+		hid.Inhib.Layer.On = ss.FFFBInhib && !ss.KWTAInhib
 		inh.Inhib.Layer.On = ss.FFFBInhib
 		fi = hid.SendName("Inhib2").(leabra.LeabraPrjn).AsLeabra()
 		fi.WtScale.Abs = ss.FmInhibWtScaleAbs
@@ -641,6 +667,9 @@ feedforward and feedback inhibition to excitatory pyramidal neurons.
 	plt := tv.AddNewTab(eplot.KiT_Plot2D, "TstCycPlot").(*eplot.Plot2D)
 	ss.TstCycPlot = ss.ConfigTstCycPlot(plt, ss.TstCycLog)
 
+	plt = tv.AddNewTab(eplot.KiT_Plot2D, "ComparePlot").(*eplot.Plot2D)
+	ss.ComparePlot = ss.ConfigComparePlot(plt, ss.CompareLog)
+
 	split.SetSplits(.2, .8)
 
 	tbar.AddAction(gi.ActOpts{Label: "Init", Icon: "update", Tooltip: "Initialize everything including network weights, and start over.  Also applies current params.", UpdateFunc: func(act *gi.Action) {
@@ -684,6 +713,18 @@ feedforward and feedback inhibition to excitatory pyramidal neurons.
 		vp.SetNeedsFullRender()
 	})
 
+	tbar.AddAction(gi.ActOpts{Label: "Compare Inhib", Icon: "fast-fwd", Tooltip: "Compares interneuron, FFFB and kWTA inhibition in the current network, for Untrained and Trained weights and each of the Compare.InputPcts, and plots the hidden activity and settling time in the ComparePlot.  Does Init at the end.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if !ss.IsRunning {
+			ss.IsRunning = true
+			tbar.UpdateActions()
+			go ss.CompareInhib()
+		}
+	})
+
+	bench.AddAction(tbar, win, &ss.IsRunning, ss.Benchmark)
+
 	tbar.AddAction(gi.ActOpts{Label: "README", Icon: "file-markdown", Tooltip: "Opens your browser on the README file that contains instructions for how to run this model."}, win.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			gi.OpenURL("https://github.com/CompCogNeuro/sims/blob/master/ch3/inhib/README.md")
@@ -751,6 +792,11 @@ feedforward and feedback inhibition to excitatory pyramidal neurons.
 // These props register Save methods so they can be used
 var SimProps = ki.Props{
 	"CallMethods": ki.PropSlice{
+		{"CompareInhib", ki.Props{
+			"desc": "compares interneuron, FFFB and kWTA inhibition for Untrained and Trained weights and each of the Compare.InputPcts -- does Init at the end",
+			"icon": "fast-fwd",
+		}},
+		{"Benchmark", bench.MethodProps},
 		{"SaveWeights", ki.Props{
 			"desc": "save network weights to file",
 			"icon": "file-save",
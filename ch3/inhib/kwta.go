@@ -0,0 +1,330 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+
+	"github.com/emer/emergent/erand"
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+	"github.com/goki/ki/kit"
+	"github.com/goki/mat32"
+)
+
+// InhibTypes are the different ways of computing inhibition in the hidden layers
+type InhibTypes int32
+
+//go:generate stringer -type=InhibTypes
+
+var KiT_InhibTypes = kit.Enums.AddEnum(InhibTypesN, kit.NotBitFlag, nil)
+
+func (ev InhibTypes) MarshalJSON() ([]byte, error)  { return kit.EnumMarshalJSON(ev) }
+func (ev *InhibTypes) UnmarshalJSON(b []byte) error { return kit.EnumUnmarshalJSON(ev, b) }
+
+const (
+	// Interneurons uses the explicit inhibitory interneurons in the Inhib layers
+	Interneurons InhibTypes = iota
+
+	// FFFB uses the feedforward, feedback (FFFB) computed inhibition
+	FFFB
+
+	// KWTA uses the k-winners-take-all computed inhibition
+	KWTA
+
+	InhibTypesN
+)
+
+// KWTAParams are the parameters for k-winners-take-all (kWTA) inhibition,
+// which sets the inhibition for a layer so that the k most excited units
+// are above their firing threshold, and the rest are below
+type KWTAParams struct {
+	Pct float32 `def:"20" min:"1" max:"99" step:"1" desc:"percent of units in the layer that are allowed to be active (k)"`
+	Q   float32 `def:"0.25" min:"0" max:"1" step:"0.05" desc:"where the inhibition is placed between the threshold inhibition of the k+1th most excited unit (0) and that of the kth (1) -- lower values give the kth unit more room to be active"`
+}
+
+func (kp *KWTAParams) Defaults() {
+	kp.Pct = 20
+	kp.Q = 0.25
+}
+
+// GiThr returns the inhibitory conductance that would put the neuron
+// exactly at its firing threshold, given its other conductances
+func GiThr(ac *leabra.ActParams, nrn *leabra.Neuron) float32 {
+	return (nrn.Ge*ac.Gbar.E*ac.ErevSubThr.E + ac.Gbar.L*ac.ErevSubThr.L + nrn.Gk*ac.Gbar.K*ac.ErevSubThr.K) / (-ac.Gbar.I * ac.ErevSubThr.I)
+}
+
+// Inhib computes the kWTA inhibition for the layer and sets it as the
+// Gi of each neuron, replacing any other inhibition
+func (kp *KWTAParams) Inhib(ly *leabra.Layer) {
+	n := len(ly.Neurons)
+	if n < 2 {
+		return
+	}
+	k := int(mat32.Round(kp.Pct / 100 * float32(n)))
+	if k < 1 {
+		k = 1
+	}
+	if k > n-1 {
+		k = n - 1
+	}
+	gis := make([]float32, n)
+	for ni := range ly.Neurons {
+		gis[ni] = GiThr(&ly.Act, &ly.Neurons[ni])
+	}
+	sort.Slice(gis, func(i, j int) bool { return gis[i] > gis[j] })
+	gi := gis[k] + kp.Q*(gis[k-1]-gis[k])
+	if gi < 0 {
+		gi = 0
+	}
+	ly.Pools[0].Inhib.Gi = gi
+	for ni := range ly.Neurons {
+		ly.Neurons[ni].Gi = gi
+	}
+}
+
+// HiddenLays returns the names of the hidden layers of given network
+func HiddenLays(nt *leabra.Network) []string {
+	if nt.LayerByName("Hidden2") != nil {
+		return []string{"Hidden", "Hidden2"}
+	}
+	return []string{"Hidden"}
+}
+
+// Cycle runs one cycle of updating of given network, computing the kWTA
+// inhibition for the hidden layers if KWTAInhib is on
+func (ss *Sim) Cycle(nt *leabra.Network) {
+	if !ss.KWTAInhib {
+		nt.Cycle(&ss.Time)
+		return
+	}
+	nt.SendGDelta(&ss.Time)
+	nt.AvgMaxGe(&ss.Time)
+	nt.InhibFmGeAct(&ss.Time)
+	for _, lnm := range HiddenLays(nt) {
+		ss.KWTA.Inhib(nt.LayerByName(lnm).(leabra.LeabraLayer).AsLeabra())
+	}
+	nt.ActFmG(&ss.Time)
+	nt.AvgMaxAct(&ss.Time)
+	nt.CyclePost(&ss.Time)
+}
+
+// SetInhibType sets the parameters for given type of inhibition: for
+// Interneurons, the default conductance strengths and time constants,
+// and for FFFB and KWTA, the standard values for computed inhibition,
+// with the projections from the Inhib layers turned off
+func (ss *Sim) SetInhibType(it InhibTypes) {
+	ss.FFFBInhib = it == FFFB
+	ss.KWTAInhib = it == KWTA
+	if it == Interneurons {
+		ss.HiddenGbarI = 0.4
+		ss.InhibGbarI = 0.75
+		ss.HiddenGTau = 40
+		ss.InhibGTau = 20
+		ss.FmInhibWtScaleAbs = 1
+	} else {
+		ss.HiddenGbarI = 1
+		ss.InhibGbarI = 1
+		ss.HiddenGTau = 1.4
+		ss.InhibGTau = 1.4
+		ss.FmInhibWtScaleAbs = 0
+	}
+}
+
+// CompareParams are the parameters for the comparison of inhibition types
+type CompareParams struct {
+	InputPcts []float32 `desc:"values of InputPct to test"`
+	NPats     int       `min:"1" def:"5" desc:"number of random input patterns for each InputPct, which are averaged"`
+	SettleTol float32   `def:"0.01" desc:"tolerance for settling: the settling time is the cycle after which the average hidden activity stays within this amount of its final value"`
+	Seed      int64     `def:"1" desc:"random seed for the input patterns and weights, so that the comparison can be reproduced"`
+}
+
+func (cp *CompareParams) Defaults() {
+	cp.InputPcts = []float32{10, 15, 20, 25, 30, 40}
+	cp.NPats = 5
+	cp.SettleTol = 0.01
+	cp.Seed = 1
+}
+
+// ComparePats sets the one input pattern in Pats to InputPct random active
+// units, as ConfigPats does, using given random number generator
+func (ss *Sim) ComparePats(rnd *rand.Rand) {
+	tsr := ss.Pats.Cols[1]
+	non := int(ss.InputPct)
+	for i, pi := range rnd.Perm(tsr.Len()) {
+		if i < non {
+			tsr.SetFloat1D(pi, 1)
+		} else {
+			tsr.SetFloat1D(pi, 0)
+		}
+	}
+}
+
+// CompareWts initializes the weights of given network as InitWts does,
+// but using given random number generator for the weight values
+func CompareWts(nt *leabra.Network, rnd *rand.Rand) {
+	nt.InitWts()
+	ernd := &erand.SysRand{Rand: rnd}
+	for _, ly := range nt.Layers {
+		if ly.IsOff() {
+			continue
+		}
+		for _, p := range ly.(leabra.LeabraLayer).AsLeabra().RcvPrjns {
+			pj := p.AsLeabra()
+			for si := range pj.Syns {
+				sy := &pj.Syns[si]
+				wt := mat32.Clamp(float32(pj.WtInit.Gen(-1, ernd)), 0, 1)
+				sy.LWt = pj.Learn.WtSig.LinFmSigWt(wt)
+				sy.Wt = wt * sy.Scale
+			}
+		}
+	}
+	for _, ly := range nt.Layers {
+		if !ly.IsOff() {
+			ly.(leabra.LeabraLayer).InitWtSym()
+		}
+	}
+}
+
+// SettleCycles returns the number of cycles until the values in given
+// column of the TstCycLog stay within tol of their final value
+func SettleCycles(dt *etable.Table, col string, ncyc int, tol float64) int {
+	if ncyc > dt.Rows {
+		ncyc = dt.Rows
+	}
+	if ncyc == 0 {
+		return 0
+	}
+	fin := dt.CellFloat(col, ncyc-1)
+	for cyc := ncyc - 1; cyc >= 0; cyc-- {
+		if math.Abs(dt.CellFloat(col, cyc)-fin) > tol {
+			return cyc + 1
+		}
+	}
+	return 0
+}
+
+// CompareInhib runs the comparison of the three types of inhibition, in
+// the current network (NetBidir if BidirNet is on, else NetFF, as from
+// Net): for the Untrained and Trained weights, and each of the
+// Compare.InputPcts, tests Compare.NPats random input patterns with each
+// type of inhibition, using the same weights and patterns for each, and
+// records the average hidden activity and settling time in the CompareLog.
+// The patterns and weights are generated from Compare.Seed, with their own
+// random number generator, so the comparison is reproducible and the
+// global one is not reseeded.  The parameters are restored at the end.
+func (ss *Sim) CompareInhib() {
+	nvff, nvbi := ss.NetViewFF, ss.NetViewBidir
+	ss.NetViewFF, ss.NetViewBidir = nil, nil
+	ss.StopNow = false
+	trn, inpct, fffb, kwta := ss.TrainedWts, ss.InputPct, ss.FFFBInhib, ss.KWTAInhib
+	hgi, igi, hgt, igt, fmabs := ss.HiddenGbarI, ss.InhibGbarI, ss.HiddenGTau, ss.InhibGTau, ss.FmInhibWtScaleAbs
+	cp := &ss.Compare
+	dt := ss.CompareLog
+	dt.SetNumRows(0)
+	nt := ss.Net()
+	ncyc := 4 * ss.Time.CycPerQtr
+	prnd := rand.New(rand.NewSource(cp.Seed))
+	wrnd := rand.New(rand.NewSource(cp.Seed))
+	for wi, trained := range []bool{false, true} {
+		ss.TrainedWts = trained
+		for _, pct := range cp.InputPcts {
+			ss.InputPct = pct
+			var act, settle [InhibTypesN]float64
+			for pi := 0; pi < cp.NPats; pi++ {
+				ss.ComparePats(prnd)
+				for it := Interneurons; it < InhibTypesN; it++ {
+					ss.SetInhibType(it)
+					ss.SetParams("", false)
+					wrnd.Seed(cp.Seed + int64(wi))
+					CompareWts(nt, wrnd)
+					ss.TestTrial()
+					if ss.StopNow {
+						break
+					}
+					act[it] += ss.TstCycLog.CellFloat("HiddenActAvg", ncyc-1)
+					settle[it] += float64(SettleCycles(ss.TstCycLog, "HiddenActAvg", ncyc, float64(cp.SettleTol)))
+				}
+				if ss.StopNow {
+					break
+				}
+			}
+			if ss.StopNow {
+				break
+			}
+			for it := Interneurons; it < InhibTypesN; it++ {
+				ss.LogCompare(dt, trained, it, pct, act[it]/float64(cp.NPats), settle[it]/float64(cp.NPats))
+			}
+			ss.ComparePlot.GoUpdate()
+		}
+		if ss.StopNow {
+			break
+		}
+	}
+	ss.TrainedWts, ss.InputPct, ss.FFFBInhib, ss.KWTAInhib = trn, inpct, fffb, kwta
+	ss.HiddenGbarI, ss.InhibGbarI, ss.HiddenGTau, ss.InhibGTau, ss.FmInhibWtScaleAbs = hgi, igi, hgt, igt, fmabs
+	ss.ConfigPats()
+	ss.NetViewFF, ss.NetViewBidir = nvff, nvbi
+	ss.Init()
+	ss.Stopped()
+}
+
+//////////////////////////////////////////////
+//  CompareLog
+
+// LogCompare adds a row to the CompareLog with the results for given
+// weights, inhibition type and InputPct
+func (ss *Sim) LogCompare(dt *etable.Table, trained bool, it InhibTypes, pct float32, act, settle float64) {
+	wts := "Untrained"
+	if trained {
+		wts = "Trained"
+	}
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellString("Cond", row, it.String()+" "+wts)
+	dt.SetCellString("Inhib", row, it.String())
+	dt.SetCellString("Wts", row, wts)
+	dt.SetCellFloat("InputPct", row, float64(pct))
+	dt.SetCellFloat("HiddenAct", row, act)
+	dt.SetCellFloat("SettleCyc", row, settle)
+}
+
+func (ss *Sim) ConfigCompareLog(dt *etable.Table) {
+	dt.SetMetaData("name", "CompareLog")
+	dt.SetMetaData("desc", "Hidden activity and settling time for each type of inhibition, by InputPct")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Cond", etensor.STRING, nil, nil},
+		{"Inhib", etensor.STRING, nil, nil},
+		{"Wts", etensor.STRING, nil, nil},
+		{"InputPct", etensor.FLOAT64, nil, nil},
+		{"HiddenAct", etensor.FLOAT64, nil, nil},
+		{"SettleCyc", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigComparePlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "Inhib Comparison Plot"
+	plt.Params.XAxisCol = "InputPct"
+	plt.Params.LegendCol = "Cond"
+	plt.Params.Points = true
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Cond", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Inhib", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Wts", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("InputPct", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("HiddenAct", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("SettleCyc", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	return plt
+}
@@ -0,0 +1,418 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+)
+
+// PerceptNames are the names of the two interpretations of the cube,
+// corresponding to the left and right pools of the NeckerCube layer
+var PerceptNames = []string{"Left", "Right"}
+
+// DominanceParams are the parameters for the long-run bistability analysis,
+// which runs the network continuously for NCycles with noise and adaptation,
+// classifies which cube interpretation is dominant on each cycle, and
+// records the durations of dominance between switches
+type DominanceParams struct {
+	Noises  []float32 `desc:"values of Noise to test"`
+	Adapts  []float32 `desc:"values of the slow KNa adaptation strength (KNa.Slow.Max) to test -- 0 turns KNaAdapt off"`
+	NCycles int       `min:"100" def:"10000" desc:"number of cycles to run for each Noise and adaptation condition"`
+	Thr     float32   `min:"0" max:"1" step:"0.05" def:"0.2" desc:"difference in average activity between the two pools required to classify one interpretation as dominant -- the dominant interpretation only changes when the other one exceeds it by this amount"`
+	HistBin int       `min:"1" def:"50" desc:"size of the bins of the dominance-duration histograms, in cycles"`
+	HistMax int       `min:"1" def:"2000" desc:"maximum dominance duration in the histograms, in cycles -- longer durations are counted in the last bin"`
+}
+
+func (dp *DominanceParams) Defaults() {
+	dp.Noises = []float32{0.005, 0.01, 0.02}
+	dp.Adapts = []float32{0.1, 0.2, 0.3}
+	dp.NCycles = 10000
+	dp.Thr = 0.2
+	dp.HistBin = 50
+	dp.HistMax = 2000
+}
+
+// Classify returns the dominant interpretation (index into PerceptNames)
+// given the average activity of the left and right pools, and the
+// currently dominant one (-1 for none yet)
+func (dp *DominanceParams) Classify(left, right float32, cur int) int {
+	switch {
+	case left-right > dp.Thr:
+		return 0
+	case right-left > dp.Thr:
+		return 1
+	}
+	return cur
+}
+
+// PoolActs returns the average activity of the left and right pools
+// (cube interpretations) of the NeckerCube layer
+func PoolActs(ly *leabra.Layer) (left, right float32) {
+	nu := len(ly.Neurons) / 2
+	for ni := range ly.Neurons {
+		if ni < nu {
+			left += ly.Neurons[ni].Act
+		} else {
+			right += ly.Neurons[ni].Act
+		}
+	}
+	return left / float32(nu), right / float32(nu)
+}
+
+// FitGamma returns the maximum likelihood fit of the shape (k) and scale
+// (theta) parameters of a gamma distribution to the given durations, using
+// the closed-form approximation to the shape parameter (Minka, 2002)
+func FitGamma(durs []float64) (k, theta float64) {
+	n := float64(len(durs))
+	if n < 2 {
+		return 0, 0
+	}
+	mean, lmean := 0.0, 0.0
+	for _, d := range durs {
+		mean += d
+		lmean += math.Log(d)
+	}
+	mean /= n
+	lmean /= n
+	s := math.Log(mean) - lmean
+	if s <= 0 {
+		return 0, 0
+	}
+	k = (3 - s + math.Sqrt((s-3)*(s-3)+24*s)) / (12 * s)
+	return k, mean / k
+}
+
+// GammaPDF returns the gamma probability density with shape k and scale
+// theta at x
+func GammaPDF(x, k, theta float64) float64 {
+	if x <= 0 || k <= 0 || theta <= 0 {
+		return 0
+	}
+	lg, _ := math.Lgamma(k)
+	return math.Exp((k-1)*math.Log(x) - x/theta - lg - k*math.Log(theta))
+}
+
+// DominanceCond runs one long continuous trial with given noise and
+// adaptation strength (0 = off), logging each cycle to the DomCycLog and
+// each switch of the dominant interpretation to the DomSwitchLog, and
+// returns the dominance durations.  The first and last durations, which
+// are cut off by the start and end of the run, are not included.
+func (ss *Sim) DominanceCond(noise, adapt float32) []float64 {
+	dp := &ss.Dominance
+	ss.Noise = noise
+	ss.KNaAdapt = adapt > 0
+	ss.SetParams("", false)
+	ly := ss.Net.LayerByName("NeckerCube").(leabra.LeabraLayer).AsLeabra()
+	if adapt > 0 {
+		ly.Act.KNa.Slow.Max = adapt
+		ly.Act.Update()
+	}
+	ss.Time.Reset()
+	ss.Net.InitActs()
+	ss.ApplyInputs()
+	ss.Net.AlphaCycInit(false)
+	ss.Time.AlphaCycStart()
+	ss.DomCycLog.SetNumRows(0)
+	cur, start := -1, 0
+	var durs []float64
+	for cyc := 0; cyc < dp.NCycles; cyc++ {
+		ss.Net.Cycle(&ss.Time)
+		ss.Time.CycleInc()
+		left, right := PoolActs(ly)
+		prc := dp.Classify(left, right, cur)
+		if prc != cur {
+			if cur >= 0 {
+				ss.LogDomSwitch(ss.DomSwitchLog, noise, adapt, cyc, cur, prc, cyc-start)
+				durs = append(durs, float64(cyc-start))
+			}
+			cur, start = prc, cyc
+		}
+		ss.LogDomCyc(ss.DomCycLog, cyc, left, right, cur)
+		if ss.StopNow {
+			break
+		}
+	}
+	if len(durs) > 0 {
+		durs = durs[1:]
+	}
+	return durs
+}
+
+// RunDominance runs the long-run bistability analysis: for each of the
+// Dominance.Noises and Dominance.Adapts, runs the network continuously for
+// Dominance.NCycles, logs the switches between interpretations in the
+// DomSwitchLog, and the dominance-duration histogram and gamma fit in the
+// DomHist and DomFit tables.  The DomCycLog shows the most recent condition.
+// Noise and KNaAdapt are restored, and Init is done at the end.
+func (ss *Sim) RunDominance() {
+	if _, err := ss.Net.LayerByNameTry("NeckerCube"); err != nil {
+		log.Println(err) // Dominance needs the two cube pools of the NeckerCube layer
+		ss.Stopped()
+		return
+	}
+	nv := ss.NetView
+	ss.NetView = nil
+	ss.StopNow = false
+	noise, kna := ss.Noise, ss.KNaAdapt
+	dp := &ss.Dominance
+	ss.DomSwitchLog.SetNumRows(0)
+	ss.DomHist.SetNumRows(0)
+	ss.DomFit.SetNumRows(0)
+	for _, ad := range dp.Adapts {
+		for _, ns := range dp.Noises {
+			durs := ss.DominanceCond(ns, ad)
+			if ss.StopNow {
+				break
+			}
+			k, theta := FitGamma(durs)
+			ss.LogDomHist(ss.DomHist, ns, ad, durs, k, theta)
+			ss.LogDomFit(ss.DomFit, ns, ad, durs, k, theta)
+			ss.DomCycPlot.GoUpdate()
+			ss.DomHistPlot.GoUpdate()
+			ss.DomFitPlot.GoUpdate()
+		}
+		if ss.StopNow {
+			break
+		}
+	}
+	ss.Noise, ss.KNaAdapt = noise, kna
+	ss.NetView = nv
+	ss.Init()
+	ss.Stopped()
+}
+
+// DomCond returns the condition label for given noise and adaptation
+func DomCond(noise, adapt float32) string {
+	return fmt.Sprintf("Noise=%g Adapt=%g", noise, adapt)
+}
+
+//////////////////////////////////////////////
+//  DomCycLog
+
+// LogDomCyc adds a row to the DomCycLog for given cycle of the long run
+func (ss *Sim) LogDomCyc(dt *etable.Table, cyc int, left, right float32, prc int) {
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellFloat("Cycle", row, float64(cyc))
+	dt.SetCellFloat("Left", row, float64(left))
+	dt.SetCellFloat("Right", row, float64(right))
+	dt.SetCellFloat("Percept", row, float64(prc))
+	dt.SetCellFloat("Harmony", row, float64(ss.Harmony(ss.Net)))
+}
+
+func (ss *Sim) ConfigDomCycLog(dt *etable.Table) {
+	dt.SetMetaData("name", "DomCycLog")
+	dt.SetMetaData("desc", "Pool activities and dominant interpretation on each cycle of the most recent long run")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Cycle", etensor.INT64, nil, nil},
+		{"Left", etensor.FLOAT64, nil, nil},
+		{"Right", etensor.FLOAT64, nil, nil},
+		{"Percept", etensor.FLOAT64, nil, nil},
+		{"Harmony", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigDomCycPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "Necker Cube Dominance Cycle Plot"
+	plt.Params.XAxisCol = "Cycle"
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Cycle", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Left", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("Right", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("Percept", eplot.On, eplot.FixMin, -1, eplot.FixMax, 1)
+	plt.SetColParams("Harmony", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 0.25)
+	return plt
+}
+
+//////////////////////////////////////////////
+//  DomSwitchLog
+
+// LogDomSwitch adds a row to the DomSwitchLog for a switch from one
+// interpretation to the other on given cycle, after given duration
+func (ss *Sim) LogDomSwitch(dt *etable.Table, noise, adapt float32, cyc, from, to, dur int) {
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellString("Cond", row, DomCond(noise, adapt))
+	dt.SetCellFloat("Noise", row, float64(noise))
+	dt.SetCellFloat("Adapt", row, float64(adapt))
+	dt.SetCellFloat("Cycle", row, float64(cyc))
+	dt.SetCellString("From", row, PerceptNames[from])
+	dt.SetCellString("To", row, PerceptNames[to])
+	dt.SetCellFloat("Dur", row, float64(dur))
+}
+
+func (ss *Sim) ConfigDomSwitchLog(dt *etable.Table) {
+	dt.SetMetaData("name", "DomSwitchLog")
+	dt.SetMetaData("desc", "Switches between the two cube interpretations in the long runs, with the duration of the preceding dominance")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Cond", etensor.STRING, nil, nil},
+		{"Noise", etensor.FLOAT64, nil, nil},
+		{"Adapt", etensor.FLOAT64, nil, nil},
+		{"Cycle", etensor.INT64, nil, nil},
+		{"From", etensor.STRING, nil, nil},
+		{"To", etensor.STRING, nil, nil},
+		{"Dur", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+//////////////////////////////////////////////
+//  DomHist
+
+// LogDomHist adds the rows of the dominance-duration histogram for given
+// condition to the DomHist table, along with the fitted gamma distribution
+func (ss *Sim) LogDomHist(dt *etable.Table, noise, adapt float32, durs []float64, k, theta float64) {
+	dp := &ss.Dominance
+	nb := (dp.HistMax + dp.HistBin - 1) / dp.HistBin
+	cnts := make([]int, nb)
+	for _, d := range durs {
+		bi := int(d) / dp.HistBin
+		if bi >= nb {
+			bi = nb - 1
+		}
+		cnts[bi]++
+	}
+	bw := float64(dp.HistBin)
+	for bi, c := range cnts {
+		row := dt.Rows
+		dt.SetNumRows(row + 1)
+		x := (float64(bi) + 0.5) * bw
+		prob := 0.0
+		if len(durs) > 0 {
+			prob = float64(c) / float64(len(durs))
+		}
+		dt.SetCellString("Cond", row, DomCond(noise, adapt))
+		dt.SetCellFloat("Noise", row, float64(noise))
+		dt.SetCellFloat("Adapt", row, float64(adapt))
+		dt.SetCellFloat("Dur", row, x)
+		dt.SetCellFloat("Count", row, float64(c))
+		dt.SetCellFloat("Prob", row, prob)
+		dt.SetCellFloat("Gamma", row, bw*GammaPDF(x, k, theta))
+	}
+}
+
+func (ss *Sim) ConfigDomHist(dt *etable.Table) {
+	dt.SetMetaData("name", "DomHist")
+	dt.SetMetaData("desc", "Histograms of dominance durations for each condition, with the fitted gamma distribution (probability per bin)")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Cond", etensor.STRING, nil, nil},
+		{"Noise", etensor.FLOAT64, nil, nil},
+		{"Adapt", etensor.FLOAT64, nil, nil},
+		{"Dur", etensor.FLOAT64, nil, nil},
+		{"Count", etensor.FLOAT64, nil, nil},
+		{"Prob", etensor.FLOAT64, nil, nil},
+		{"Gamma", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigDomHistPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "Necker Cube Dominance Duration Histogram"
+	plt.Params.XAxisCol = "Dur"
+	plt.Params.LegendCol = "Cond"
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Cond", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Noise", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Adapt", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Dur", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Count", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Prob", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Gamma", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	return plt
+}
+
+//////////////////////////////////////////////
+//  DomFit
+
+// LogDomFit adds a row to the DomFit table with the summary statistics and
+// gamma fit of the dominance durations for given condition
+func (ss *Sim) LogDomFit(dt *etable.Table, noise, adapt float32, durs []float64, k, theta float64) {
+	n := float64(len(durs))
+	mean, sd := 0.0, 0.0
+	if n > 0 {
+		for _, d := range durs {
+			mean += d
+		}
+		mean /= n
+		for _, d := range durs {
+			sd += (d - mean) * (d - mean)
+		}
+		sd = math.Sqrt(sd / n)
+	}
+	cv := 0.0
+	if mean > 0 {
+		cv = sd / mean
+	}
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellString("Cond", row, DomCond(noise, adapt))
+	dt.SetCellString("AdaptCond", row, fmt.Sprintf("Adapt=%g", adapt))
+	dt.SetCellFloat("Noise", row, float64(noise))
+	dt.SetCellFloat("Adapt", row, float64(adapt))
+	dt.SetCellFloat("NDurs", row, n)
+	dt.SetCellFloat("MeanDur", row, mean)
+	dt.SetCellFloat("CV", row, cv)
+	dt.SetCellFloat("GammaK", row, k)
+	dt.SetCellFloat("GammaTheta", row, theta)
+}
+
+func (ss *Sim) ConfigDomFit(dt *etable.Table) {
+	dt.SetMetaData("name", "DomFit")
+	dt.SetMetaData("desc", "Dominance duration statistics and gamma fit parameters for each Noise and adaptation condition")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Cond", etensor.STRING, nil, nil},
+		{"AdaptCond", etensor.STRING, nil, nil},
+		{"Noise", etensor.FLOAT64, nil, nil},
+		{"Adapt", etensor.FLOAT64, nil, nil},
+		{"NDurs", etensor.FLOAT64, nil, nil},
+		{"MeanDur", etensor.FLOAT64, nil, nil},
+		{"CV", etensor.FLOAT64, nil, nil},
+		{"GammaK", etensor.FLOAT64, nil, nil},
+		{"GammaTheta", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigDomFitPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "Necker Cube Dominance Fit Plot"
+	plt.Params.XAxisCol = "Noise"
+	plt.Params.LegendCol = "AdaptCond"
+	plt.Params.Points = true
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Cond", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("AdaptCond", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Noise", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Adapt", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("NDurs", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("MeanDur", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("CV", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("GammaK", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("GammaTheta", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	return plt
+}
@@ -11,9 +11,12 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"os"
 	"strconv"
 	"strings"
 
+	"github.com/CompCogNeuro/sims/bench"
+	"github.com/CompCogNeuro/sims/csnet"
 	"github.com/emer/emergent/emer"
 	"github.com/emer/emergent/netview"
 	"github.com/emer/emergent/params"
@@ -33,9 +36,15 @@ import (
 
 // this is the stub main for gogi that calls our actual mainrun function, at end of file
 func main() {
-	gimain.Main(func() {
-		mainrun()
-	})
+	if bench.HasFlag(os.Args[1:]) {
+		TheSim.New()
+		TheSim.Config()
+		TheSim.Bench.RunArgs(TheSim.Benchmark) // -bench arg = run without the gui
+	} else {
+		gimain.Main(func() { // this starts gui -- requires valid OpenGL display connection (e.g., X11)
+			mainrun()
+		})
+	}
 }
 
 // LogPrec is precision for saving float values in logs
@@ -78,25 +87,36 @@ var ParamSets = params.Sets{
 // as arguments to methods, and provides the core GUI interface (note the view tags
 // for the fields which provide hints to how things should be displayed).
 type Sim struct {
-	Noise      float32           `min:"0" step:"0.01" desc:"the variance parameter for Gaussian noise added to unit activations on every cycle"`
-	KNaAdapt   bool              `desc:"apply sodium-gated potassium adaptation mechanisms that cause the neuron to reduce spiking over time"`
-	CycPerQtr  int               `def:"25,250" desc:"total number of cycles per quarter to run -- increase to 250 when testing adaptation"`
-	Net        *leabra.Network   `view:"no-inline" desc:"the network -- click to view / edit parameters for layers, prjns, etc"`
-	TstCycLog  *etable.Table     `view:"no-inline" desc:"testing trial-level log data -- click to see record of network's response to each input"`
-	Params     params.Sets       `view:"no-inline" desc:"full collection of param sets -- not really interesting for this model"`
-	ParamSet   string            `view:"-" desc:"which set of *additional* parameters to use -- always applies Base and optionaly this next if set -- can use multiple names separated by spaces (don't put spaces in ParamSet names!)"`
-	Time       leabra.Time       `desc:"leabra timing parameters and state"`
-	ViewUpdt   leabra.TimeScales `desc:"at what time scale to update the display during testing?  Change to AlphaCyc to make display updating go faster"`
-	TstRecLays []string          `desc:"names of layers to record activations etc of during testing"`
+	Noise        float32           `min:"0" step:"0.01" desc:"the variance parameter for Gaussian noise added to unit activations on every cycle"`
+	KNaAdapt     bool              `desc:"apply sodium-gated potassium adaptation mechanisms that cause the neuron to reduce spiking over time"`
+	CycPerQtr    int               `def:"25,250" desc:"total number of cycles per quarter to run -- increase to 250 when testing adaptation"`
+	Net          *leabra.Network   `view:"no-inline" desc:"the network -- click to view / edit parameters for layers, prjns, etc"`
+	NetDesc      *csnet.Net        `view:"no-inline" desc:"description of the constraint-satisfaction network loaded with Open Net, which replaces the standard Necker cube network -- nil for the standard network"`
+	Pats         *etable.Table     `view:"no-inline" desc:"input patterns of the network loaded with Open Net -- the first one is applied on each trial"`
+	TstCycLog    *etable.Table     `view:"no-inline" desc:"testing trial-level log data -- click to see record of network's response to each input"`
+	Params       params.Sets       `view:"no-inline" desc:"full collection of param sets -- not really interesting for this model"`
+	ParamSet     string            `view:"-" desc:"which set of *additional* parameters to use -- always applies Base and optionaly this next if set -- can use multiple names separated by spaces (don't put spaces in ParamSet names!)"`
+	Time         leabra.Time       `desc:"leabra timing parameters and state"`
+	Bench        bench.Bench       `view:"no-inline" desc:"benchmark parameters and results -- see Benchmark"`
+	ViewUpdt     leabra.TimeScales `desc:"at what time scale to update the display during testing?  Change to AlphaCyc to make display updating go faster"`
+	TstRecLays   []string          `desc:"names of layers to record activations etc of during testing"`
+	Dominance    DominanceParams   `view:"no-inline" desc:"parameters for the long-run bistability analysis (Dominance), which records how long each interpretation of the cube stays dominant"`
+	DomCycLog    *etable.Table     `view:"no-inline" desc:"pool activities and dominant interpretation (0 = Left, 1 = Right) on each cycle of the most recent long run"`
+	DomSwitchLog *etable.Table     `view:"no-inline" desc:"switches between the two interpretations in the long runs, with the duration of the preceding dominance"`
+	DomHist      *etable.Table     `view:"no-inline" desc:"histograms of dominance durations for each Noise and adaptation condition, with the fitted gamma distribution"`
+	DomFit       *etable.Table     `view:"no-inline" desc:"dominance duration statistics and gamma fit parameters for each Noise and adaptation condition"`
 
 	// internal state - view:"-"
-	Win        *gi.Window                  `view:"-" desc:"main GUI window"`
-	NetView    *netview.NetView            `view:"-" desc:"the network viewer"`
-	ToolBar    *gi.ToolBar                 `view:"-" desc:"the master toolbar"`
-	TstCycPlot *eplot.Plot2D               `view:"-" desc:"the test-trial plot"`
-	ValsTsrs   map[string]*etensor.Float32 `view:"-" desc:"for holding layer values"`
-	IsRunning  bool                        `view:"-" desc:"true if sim is running"`
-	StopNow    bool                        `view:"-" desc:"flag to stop running"`
+	Win         *gi.Window                  `view:"-" desc:"main GUI window"`
+	NetView     *netview.NetView            `view:"-" desc:"the network viewer"`
+	ToolBar     *gi.ToolBar                 `view:"-" desc:"the master toolbar"`
+	TstCycPlot  *eplot.Plot2D               `view:"-" desc:"the test-trial plot"`
+	DomCycPlot  *eplot.Plot2D               `view:"-" desc:"the dominance cycle plot"`
+	DomHistPlot *eplot.Plot2D               `view:"-" desc:"the dominance duration histogram plot"`
+	DomFitPlot  *eplot.Plot2D               `view:"-" desc:"the dominance fit plot"`
+	ValsTsrs    map[string]*etensor.Float32 `view:"-" desc:"for holding layer values"`
+	IsRunning   bool                        `view:"-" desc:"true if sim is running"`
+	StopNow     bool                        `view:"-" desc:"flag to stop running"`
 }
 
 // this registers this Sim Type and gives it properties that e.g.,
@@ -109,11 +129,17 @@ var TheSim Sim
 // New creates new blank elements and initializes defaults
 func (ss *Sim) New() {
 	ss.Net = &leabra.Network{}
+	ss.Pats = &etable.Table{}
 	ss.TstCycLog = &etable.Table{}
+	ss.DomCycLog = &etable.Table{}
+	ss.DomSwitchLog = &etable.Table{}
+	ss.DomHist = &etable.Table{}
+	ss.DomFit = &etable.Table{}
 	ss.Params = ParamSets
 	ss.ViewUpdt = leabra.Cycle
 	ss.TstRecLays = []string{"NeckerCube"}
 	ss.Defaults()
+	ss.Bench.Defaults("necker_cube")
 }
 
 // Defaults sets default params
@@ -121,6 +147,7 @@ func (ss *Sim) Defaults() {
 	ss.Noise = 0.01
 	ss.KNaAdapt = false
 	ss.CycPerQtr = 25
+	ss.Dominance.Defaults()
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////
@@ -130,13 +157,21 @@ func (ss *Sim) Defaults() {
 func (ss *Sim) Config() {
 	ss.ConfigNet(ss.Net)
 	ss.ConfigTstCycLog(ss.TstCycLog)
+	ss.ConfigDomCycLog(ss.DomCycLog)
+	ss.ConfigDomSwitchLog(ss.DomSwitchLog)
+	ss.ConfigDomHist(ss.DomHist)
+	ss.ConfigDomFit(ss.DomFit)
 }
 
 func (ss *Sim) ConfigNet(net *leabra.Network) {
-	net.InitName(net, "NeckerCube")
-	nc := net.AddLayer4D("NeckerCube", 1, 2, 4, 2, emer.Input)
+	if ss.NetDesc != nil {
+		ss.NetDesc.ConfigNet(net)
+	} else {
+		net.InitName(net, "NeckerCube")
+		nc := net.AddLayer4D("NeckerCube", 1, 2, 4, 2, emer.Input)
 
-	net.ConnectLayers(nc, nc, prjn.NewFull(), emer.Lateral)
+		net.ConnectLayers(nc, nc, prjn.NewFull(), emer.Lateral)
+	}
 
 	net.Defaults()
 	ss.SetParams("Network", false) // only set Network params
@@ -148,9 +183,16 @@ func (ss *Sim) ConfigNet(net *leabra.Network) {
 	ss.InitWts(net)
 }
 
-// InitWts loads the saved weights
+// InitWts loads the saved weights, or sets them from the constraints
+// of the NetDesc if loaded
 func (ss *Sim) InitWts(net *leabra.Network) {
 	net.InitWts()
+	if ss.NetDesc != nil {
+		if err := ss.NetDesc.SetWts(net); err != nil {
+			log.Println(err)
+		}
+		return
+	}
 	ab, err := Asset("necker_cube.wts") // embedded in executable
 	if err != nil {
 		log.Println(err)
@@ -252,6 +294,14 @@ func (ss *Sim) ApplyInputs() {
 	ss.Net.InitExt() // clear any existing inputs -- not strictly necessary if always
 	// going to the same layers, but good practice and cheap anyway
 
+	if ss.NetDesc != nil { // apply the first input pattern of the NetDesc
+		for _, lnm := range ss.NetDesc.GroupNames() {
+			ly := ss.Net.LayerByName(lnm).(leabra.LeabraLayer).AsLeabra()
+			ly.ApplyExt(ss.Pats.CellTensor(lnm, 0))
+		}
+		return
+	}
+
 	// just directly apply all 1s to input
 	ly := ss.Net.LayerByName("NeckerCube").(leabra.LeabraLayer).AsLeabra()
 	tsr := ss.ValsTsr("Inputs")
@@ -281,12 +331,47 @@ func (ss *Sim) Stopped() {
 	}
 }
 
+// Benchmark runs the Bench benchmark with TestTrial (see bench.Bench.RunSim)
+func (ss *Sim) Benchmark() {
+	ss.Bench.RunSim(ss.Net, &ss.Time, ss.TestTrial, ss.Init, ss.Stopped, &ss.NetView)
+}
+
 // SaveWeights saves the network weights -- when called with giv.CallMethod
 // it will auto-prompt for filename
 func (ss *Sim) SaveWeights(filename gi.FileName) {
 	ss.Net.SaveWtsJSON(filename)
 }
 
+// OpenNet opens a description of a constraint-satisfaction network from
+// given file (see the csnet package for the format), and replaces the
+// network with it -- the first of its input patterns is applied on each
+// trial.  Does Init at the end.
+func (ss *Sim) OpenNet(filename gi.FileName) error {
+	cn, err := csnet.Open(string(filename))
+	if err != nil {
+		log.Println(err)
+		if ss.Win != nil {
+			gi.PromptDialog(ss.Win.WinViewport2D(), gi.DlgOpts{Title: "Invalid Network", Prompt: strings.ReplaceAll(err.Error(), "\n", "<br>")}, gi.AddOk, gi.NoCancel, nil, nil)
+		}
+		return err
+	}
+	ss.NetDesc = cn
+	ss.Net = &leabra.Network{}
+	ss.ConfigNet(ss.Net)
+	ss.TstRecLays = cn.GroupNames()
+	cn.ConfigPats(ss.Pats)
+	ss.ConfigTstCycLog(ss.TstCycLog)
+	if ss.NetView != nil {
+		ss.NetView.SetNet(ss.Net)
+		ss.ConfigNetView(ss.NetView)
+	}
+	if ss.TstCycPlot != nil {
+		ss.ConfigTstCycPlot(ss.TstCycPlot, ss.TstCycLog)
+	}
+	ss.Init()
+	return nil
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////
 // Testing
 
@@ -318,10 +403,15 @@ func (ss *Sim) SetParams(sheet string, setMsg bool) error {
 		}
 	}
 
-	ly := ss.Net.LayerByName("NeckerCube").(leabra.LeabraLayer).AsLeabra()
-	ly.Act.Noise.Var = float64(ss.Noise)
-	ly.Act.KNa.On = ss.KNaAdapt
-	ly.Act.Update()
+	if ss.NetDesc != nil && (sheet == "" || sheet == "Network") {
+		ss.NetDesc.SetParams(ss.Net)
+	}
+	for _, ly := range ss.Net.Layers {
+		lly := ly.(leabra.LeabraLayer).AsLeabra()
+		lly.Act.Noise.Var = float64(ss.Noise)
+		lly.Act.KNa.On = ss.KNaAdapt
+		lly.Act.Update()
+	}
 	ss.Time.CycPerQtr = ss.CycPerQtr
 	return err
 }
@@ -399,7 +489,10 @@ func (ss *Sim) LogTstCyc(dt *etable.Table, cyc int) {
 	row := cyc
 
 	harm := ss.Harmony(ss.Net)
-	ly := ss.Net.LayerByName("NeckerCube").(leabra.LeabraLayer).AsLeabra()
+	if ss.NetDesc != nil {
+		harm = ss.NetDesc.Harmony(ss.Net)
+	}
+	ly := ss.Net.Layers[0].(leabra.LeabraLayer).AsLeabra() // NeckerCube in the standard network
 	dt.SetCellFloat("Cycle", row, float64(cyc))
 	dt.SetCellFloat("Harmony", row, float64(harm))
 	dt.SetCellFloat("GknaFast", row, float64(ly.Neurons[0].GknaFast))
@@ -465,6 +558,27 @@ func (ss *Sim) ConfigTstCycPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot
 func (ss *Sim) ConfigNetView(nv *netview.NetView) {
 	nv.ViewDefaults()
 	nv.Params.Raster.Max = 100
+
+	if ss.NetDesc != nil {
+		var labs, lays []string // labels must be unique, so duplicates are skipped
+		has := map[string]bool{}
+		for li, lb := range ss.NetDesc.Labels() {
+			if !has[lb] {
+				has[lb] = true
+				labs = append(labs, lb)
+				lays = append(lays, ss.NetDesc.Groups[li].Name)
+			}
+		}
+		nv.ConfigLabels(labs)
+		for li, lnm := range lays {
+			ly := nv.LayerByName(lnm)
+			lbl := nv.LabelByName(labs[li])
+			lbl.Pose = ly.Pose
+			lbl.Pose.Pos.Y += .2
+			lbl.Pose.Pos.Z += .02
+			lbl.Pose.Scale.SetMul(mat32.Vec3{0.4, 0.08, 0.5})
+		}
+	}
 }
 
 // ConfigGui configures the GoGi gui interface for this simulation,
@@ -507,6 +621,15 @@ func (ss *Sim) ConfigGui() *gi.Window {
 	plt := tv.AddNewTab(eplot.KiT_Plot2D, "TstCycPlot").(*eplot.Plot2D)
 	ss.TstCycPlot = ss.ConfigTstCycPlot(plt, ss.TstCycLog)
 
+	plt = tv.AddNewTab(eplot.KiT_Plot2D, "DomCycPlot").(*eplot.Plot2D)
+	ss.DomCycPlot = ss.ConfigDomCycPlot(plt, ss.DomCycLog)
+
+	plt = tv.AddNewTab(eplot.KiT_Plot2D, "DomHistPlot").(*eplot.Plot2D)
+	ss.DomHistPlot = ss.ConfigDomHistPlot(plt, ss.DomHist)
+
+	plt = tv.AddNewTab(eplot.KiT_Plot2D, "DomFitPlot").(*eplot.Plot2D)
+	ss.DomFitPlot = ss.ConfigDomFitPlot(plt, ss.DomFit)
+
 	split.SetSplits(.2, .8)
 
 	tbar.AddAction(gi.ActOpts{Label: "Init", Icon: "update", Tooltip: "Initialize everything including network weights, and start over.  Also applies current params.", UpdateFunc: func(act *gi.Action) {
@@ -545,6 +668,24 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		vp.SetNeedsFullRender()
 	})
 
+	tbar.AddAction(gi.ActOpts{Label: "Dominance", Icon: "fast-fwd", Tooltip: "Runs the network continuously for Dominance.NCycles for each of the Dominance.Noises and Dominance.Adapts, classifies which interpretation is dominant on each cycle, and reports the dominance-duration histograms and gamma fits.  Does Init at the end.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if !ss.IsRunning {
+			ss.IsRunning = true
+			tbar.UpdateActions()
+			go ss.RunDominance()
+		}
+	})
+
+	tbar.AddAction(gi.ActOpts{Label: "Open Net", Icon: "file-open", Tooltip: "Open a description of your own constraint-satisfaction network (units, groups, constraints and inputs) from a text or .json file, replacing the network -- see README for the format.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		giv.CallMethod(ss, "OpenNet", vp)
+	})
+
+	bench.AddAction(tbar, win, &ss.IsRunning, ss.Benchmark)
+
 	tbar.AddAction(gi.ActOpts{Label: "README", Icon: "file-markdown", Tooltip: "Opens your browser on the README file that contains instructions for how to run this model."}, win.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			gi.OpenURL("https://github.com/CompCogNeuro/sims/blob/master/ch3/necker_cube/README.md")
@@ -612,6 +753,20 @@ func (ss *Sim) ConfigGui() *gi.Window {
 // These props register Save methods so they can be used
 var SimProps = ki.Props{
 	"CallMethods": ki.PropSlice{
+		{"RunDominance", ki.Props{
+			"desc": "runs the long-run bistability analysis for each of the Dominance.Noises and Dominance.Adapts, and reports the dominance-duration histograms and gamma fits -- does Init at the end",
+			"icon": "fast-fwd",
+		}},
+		{"Benchmark", bench.MethodProps},
+		{"OpenNet", ki.Props{
+			"desc": "open a constraint-satisfaction network description from a text (.csn) or .json file, replacing the network",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".csn,.json",
+				}},
+			},
+		}},
 		{"SaveWeights", ki.Props{
 			"desc": "save network weights to file",
 			"icon": "file-save",
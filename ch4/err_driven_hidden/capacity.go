@@ -0,0 +1,296 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/emer/emergent/emer"
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+)
+
+// CapParams are the parameters for the hidden layer capacity experiment,
+// which trains networks with different numbers of hidden units on
+// increasing numbers of Impossible-style XOR patterns
+type CapParams struct {
+	NInput   int   `def:"8" min:"2" desc:"number of Input units for the Capacity patterns"`
+	NActive  int   `def:"2" min:"1" desc:"number of active Input units in each Capacity pattern -- the Output category is the parity (XOR) of a random binary feature of each of the active units, which for 2 active units out of 4 is the Impossible problem"`
+	NPats    []int `desc:"numbers of patterns to train in the capacity experiment -- limited to the number of distinct input patterns"`
+	HidSizes []int `desc:"numbers of Hidden units (Hid.HidX, with Hid.HidY = 1) to test in the capacity experiment"`
+	NSeeds   int   `def:"5" min:"1" desc:"number of random seeds (runs) for each hidden size and number of patterns -- each has a different random set of patterns and initial weights"`
+	MaxEpcs  int   `def:"200" min:"1" desc:"maximum number of training epochs -- a run that does not reach criterion (an epoch with no errors) by then is a failure"`
+}
+
+func (cp *CapParams) Defaults() {
+	cp.NInput = 8
+	cp.NActive = 2
+	cp.NPats = []int{4, 8, 12, 16, 20, 24, 28}
+	cp.HidSizes = []int{2, 4, 8, 16}
+	cp.NSeeds = 5
+	cp.MaxEpcs = 200
+}
+
+// Combos returns all the combinations of k of the numbers 0..n-1
+func Combos(n, k int) [][]int {
+	var cmbs [][]int
+	cur := make([]int, 0, k)
+	var add func(st int)
+	add = func(st int) {
+		if len(cur) == k {
+			cmbs = append(cmbs, append([]int{}, cur...))
+			return
+		}
+		for i := st; i < n; i++ {
+			cur = append(cur, i)
+			add(i + 1)
+			cur = cur[:len(cur)-1]
+		}
+	}
+	add(0)
+	return cmbs
+}
+
+// GenCapPats generates npats Capacity patterns (all distinct patterns if
+// npats <= 0) using given random seed: each has Cap.NActive random active
+// Input units, and the Output category is the parity of the sum of a
+// random binary feature of each active unit, with half of the units
+// having each feature value
+func (ss *Sim) GenCapPats(dt *etable.Table, npats int, seed int64) {
+	cp := &ss.Cap
+	rnd := rand.New(rand.NewSource(seed))
+	feat := make([]int, cp.NInput)
+	for i, ui := range rnd.Perm(cp.NInput) {
+		feat[ui] = i % 2
+	}
+	cmbs := Combos(cp.NInput, cp.NActive)
+	rnd.Shuffle(len(cmbs), func(i, j int) { cmbs[i], cmbs[j] = cmbs[j], cmbs[i] })
+	if npats > 0 && npats < len(cmbs) {
+		cmbs = cmbs[:npats]
+	}
+
+	dt.SetMetaData("name", "Capacity")
+	dt.SetMetaData("desc", "Capacity patterns: parity of random features of active inputs")
+	sch := etable.Schema{
+		{"Name", etensor.STRING, nil, nil},
+		{"Input", etensor.FLOAT32, []int{1, cp.NInput}, []string{"Y", "X"}},
+		{"Output", etensor.FLOAT32, []int{1, 2}, []string{"Y", "X"}},
+	}
+	dt.SetFromSchema(sch, len(cmbs))
+	for i := 1; i < len(dt.Cols); i++ {
+		dt.Cols[i].SetMetaData("grid-fill", "0.9")
+	}
+	for row, cmb := range cmbs {
+		nms := make([]string, len(cmb))
+		par := 0
+		for ci, ui := range cmb {
+			nms[ci] = strconv.Itoa(ui)
+			par += feat[ui]
+			dt.SetCellTensorFloat1D("Input", row, ui, 1)
+		}
+		dt.SetCellString("Name", row, "In_"+strings.Join(nms, "_"))
+		dt.SetCellTensorFloat1D("Output", row, par%2, 1)
+	}
+}
+
+// CapTrain trains the current network on the TrainEnv patterns until
+// an epoch with no errors, or Cap.MaxEpcs, returning the number of
+// epochs and whether it reached that criterion
+func (ss *Sim) CapTrain() (epcs int, ok bool) {
+	out := ss.Net.LayerByName("Output")
+	ntrl := ss.TrainEnv.Table.Len()
+	for epc := 0; epc < ss.Cap.MaxEpcs && !ss.StopNow; epc++ {
+		errs := 0.0
+		for trl := 0; trl < ntrl; trl++ {
+			ss.TrainEnv.Step()
+			out.SetType(emer.Target)
+			ss.ApplyInputs(&ss.TrainEnv)
+			ss.AlphaCyc(true)
+			ss.TrialStats(false)
+			errs += ss.TrlErr
+		}
+		if errs == 0 {
+			return epc + 1, true
+		}
+	}
+	return ss.Cap.MaxEpcs, false
+}
+
+// RunCapacity runs the hidden layer capacity experiment: for each of the
+// Cap.HidSizes and Cap.NPats, trains Cap.NSeeds networks (with the current
+// Learn type) on a different random set of Capacity patterns, recording
+// the epochs to criterion and whether it failed to learn in the CapLog,
+// and the mean epochs to criterion (of the successful runs) and failure
+// rate for each in CapStats.  The Pats and Hid params are restored at the end.
+func (ss *Sim) RunCapacity() {
+	nv := ss.NetView
+	ss.NetView = nil
+	ss.StopNow = false
+	pats, hid := ss.Pats, ss.Hid
+	ss.Pats = Capacity
+	ss.Hid.HidY = 1
+	cp := &ss.Cap
+	dt := ss.CapLog
+	dt.SetNumRows(0)
+	ss.CapStats.SetNumRows(0)
+	for _, hsz := range cp.HidSizes {
+		ss.Hid.HidX = hsz
+		lastPats := 0
+		for _, np := range cp.NPats {
+			npats := 0
+			for seed := 0; seed < cp.NSeeds; seed++ {
+				ss.GenCapPats(ss.CapPats, np, int64(seed))
+				rand.Seed(int64(seed + 1))
+				ss.ReConfigNet()
+				ss.SetParams("", false)
+				ss.UpdateEnv()
+				ss.NewRun()
+				epcs, ok := ss.CapTrain()
+				if ss.StopNow {
+					break
+				}
+				npats = ss.CapPats.Rows
+				ss.LogCap(dt, hsz, npats, seed, epcs, ok)
+			}
+			if ss.StopNow {
+				break
+			}
+			if npats != lastPats { // NPats beyond the number of distinct patterns are all the same
+				ss.LogCapStats(ss.CapStats, dt, hsz, npats)
+				lastPats = npats
+			}
+		}
+		if ss.StopNow {
+			break
+		}
+	}
+	ss.Pats, ss.Hid = pats, hid
+	ss.GenCapPats(ss.CapPats, 0, 0)
+	ss.NetView = nv
+	ss.Init()
+	if nv != nil { // the network may have been rebuilt during the sweep
+		nv.SetNet(ss.Net)
+		ss.ConfigNetView(nv)
+	}
+	ss.Stopped()
+}
+
+// CapArgs runs the capacity experiment without the gui, and saves the CapLog
+// and CapStats to err_driven_hidden_caplog.tsv and err_driven_hidden_capstats.tsv
+func (ss *Sim) CapArgs() {
+	ss.RunCapacity()
+	for _, dt := range []*etable.Table{ss.CapLog, ss.CapStats} {
+		SaveLog(dt, "err_driven_hidden_"+strings.ToLower(dt.MetaData["name"])+".tsv")
+	}
+}
+
+//////////////////////////////////////////////
+//  CapLog
+
+// LogCap adds a row to the CapLog with the results of one run
+func (ss *Sim) LogCap(dt *etable.Table, hsz, npats, seed, epcs int, ok bool) {
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	fail := 1.0
+	if ok {
+		fail = 0
+	}
+	dt.SetCellString("Hid", row, fmt.Sprintf("Hid=%d", hsz))
+	dt.SetCellFloat("HidSize", row, float64(hsz))
+	dt.SetCellFloat("NPats", row, float64(npats))
+	dt.SetCellFloat("Seed", row, float64(seed))
+	dt.SetCellFloat("Epochs", row, float64(epcs))
+	dt.SetCellFloat("Fail", row, fail)
+}
+
+func (ss *Sim) ConfigCapLog(dt *etable.Table) {
+	dt.SetMetaData("name", "CapLog")
+	dt.SetMetaData("desc", "Epochs to criterion and failures for each run of the capacity experiment")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Hid", etensor.STRING, nil, nil},
+		{"HidSize", etensor.INT64, nil, nil},
+		{"NPats", etensor.INT64, nil, nil},
+		{"Seed", etensor.INT64, nil, nil},
+		{"Epochs", etensor.INT64, nil, nil},
+		{"Fail", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+// LogCapStats adds a row to the CapStats with the mean epochs to criterion
+// over the successful runs, and the failure rate, in the CapLog for given
+// hidden size and number of patterns
+func (ss *Sim) LogCapStats(dt, clog *etable.Table, hsz, npats int) {
+	var n, nfail, sumEpcs float64
+	for r := 0; r < clog.Rows; r++ {
+		if int(clog.CellFloat("HidSize", r)) != hsz || int(clog.CellFloat("NPats", r)) != npats {
+			continue
+		}
+		n++
+		if clog.CellFloat("Fail", r) > 0 {
+			nfail++
+		} else {
+			sumEpcs += clog.CellFloat("Epochs", r)
+		}
+	}
+	if n == 0 {
+		return
+	}
+	epcs := float64(ss.Cap.MaxEpcs)
+	if n > nfail {
+		epcs = sumEpcs / (n - nfail)
+	}
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellString("Hid", row, fmt.Sprintf("Hid=%d", hsz))
+	dt.SetCellFloat("HidSize", row, float64(hsz))
+	dt.SetCellFloat("NPats", row, float64(npats))
+	dt.SetCellFloat("N", row, n)
+	dt.SetCellFloat("EpcsToCrit", row, epcs)
+	dt.SetCellFloat("FailRate", row, nfail/n)
+	if ss.CapPlot != nil {
+		ss.CapPlot.GoUpdate()
+	}
+}
+
+func (ss *Sim) ConfigCapStats(dt *etable.Table) {
+	dt.SetMetaData("name", "CapStats")
+	dt.SetMetaData("desc", "Mean epochs to criterion and failure rate for each hidden size and number of patterns in the capacity experiment")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Hid", etensor.STRING, nil, nil},
+		{"HidSize", etensor.INT64, nil, nil},
+		{"NPats", etensor.INT64, nil, nil},
+		{"N", etensor.INT64, nil, nil},
+		{"EpcsToCrit", etensor.FLOAT64, nil, nil},
+		{"FailRate", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigCapPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "Hidden Layer Capacity Plot"
+	plt.Params.XAxisCol = "NPats"
+	plt.Params.LegendCol = "Hid"
+	plt.Params.Points = true
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Hid", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("HidSize", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("NPats", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("N", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("EpcsToCrit", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("FailRate", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	return plt
+}
@@ -9,6 +9,7 @@ package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 	"log"
 	"math/rand"
@@ -17,12 +18,13 @@ import (
 	"strings"
 	"time"
 
+	"github.com/CompCogNeuro/sims/bench"
+	"github.com/CompCogNeuro/sims/bp"
+	"github.com/CompCogNeuro/sims/patfile"
 	"github.com/emer/emergent/emer"
 	"github.com/emer/emergent/env"
 	"github.com/emer/emergent/netview"
 	"github.com/emer/emergent/params"
-	"github.com/emer/emergent/prjn"
-	"github.com/emer/emergent/relpos"
 	"github.com/emer/etable/agg"
 	"github.com/emer/etable/eplot"
 	"github.com/emer/etable/etable"
@@ -38,11 +40,22 @@ import (
 	"github.com/goki/mat32"
 )
 
-// this is the stub main for gogi that calls our actual mainrun function, at end of file
 func main() {
-	gimain.Main(func() {
-		mainrun()
-	})
+	TheSim.New()
+	TheSim.Config()
+	if len(os.Args) > 1 {
+		TheSim.CmdArgs() // simple assumption is that any args = no gui -- could add explicit arg if you want
+	} else {
+		gimain.Main(func() { // this starts gui -- requires valid OpenGL display connection (e.g., X11)
+			guirun()
+		})
+	}
+}
+
+func guirun() {
+	TheSim.Init()
+	win := TheSim.ConfigGui()
+	win.StartEventLoop()
 }
 
 // LogPrec is precision for saving float values in logs
@@ -68,6 +81,10 @@ const (
 	// Impossible patterns require error-driven + a hidden layer
 	Impossible
 
+	// Capacity patterns are generated Impossible-style XOR patterns,
+	// for the capacity experiment -- see Cap
+	Capacity
+
 	PatsTypeN
 )
 
@@ -84,6 +101,8 @@ func (ev *LearnType) UnmarshalJSON(b []byte) error { return kit.EnumUnmarshalJSO
 const (
 	Hebbian LearnType = iota
 	ErrorDriven
+	Backprop
+
 	LearnTypeN
 )
 
@@ -141,16 +160,22 @@ var ParamSets = params.Sets{
 // for the fields which provide hints to how things should be displayed).
 type Sim struct {
 	Net          *leabra.Network   `view:"no-inline" desc:"the network -- click to view / edit parameters for layers, prjns, etc"`
+	BP           bp.Network        `view:"no-inline" desc:"backpropagation network with the same layer sizes as Net, which is used in its place when Learn is Backprop"`
+	Hid          HidParams         `view:"inline" desc:"size, number and projections of the hidden layers -- changes take effect on Init"`
 	Learn        LearnType         `desc:"select which type of learning to use"`
 	Pats         PatsType          `desc:"select which type of patterns to use"`
 	Easy         *etable.Table     `view:"no-inline" desc:"easy training patterns -- can be learned with Hebbian"`
 	Hard         *etable.Table     `view:"no-inline" desc:"hard training patterns -- require error-driven"`
 	Impossible   *etable.Table     `view:"no-inline" desc:"impossible training patterns -- require error-driven + hidden layer"`
+	CapPats      *etable.Table     `view:"no-inline" desc:"capacity training patterns -- generated Impossible-style XOR patterns, see Cap"`
 	TrnEpcLog    *etable.Table     `view:"no-inline" desc:"training epoch-level log data"`
 	TstEpcLog    *etable.Table     `view:"no-inline" desc:"testing epoch-level log data"`
 	TstTrlLog    *etable.Table     `view:"no-inline" desc:"testing trial-level log data"`
 	RunLog       *etable.Table     `view:"no-inline" desc:"summary log of each run"`
 	RunStats     *etable.Table     `view:"no-inline" desc:"aggregate stats on all runs"`
+	Cap          CapParams         `view:"inline" desc:"parameters for the hidden layer capacity experiment -- see RunCapacity"`
+	CapLog       *etable.Table     `view:"no-inline" desc:"results of each run of the capacity experiment"`
+	CapStats     *etable.Table     `view:"no-inline" desc:"epochs to criterion and failure rates for each hidden size and number of patterns in the capacity experiment"`
 	Params       params.Sets       `view:"no-inline" desc:"full collection of param sets"`
 	ParamSet     string            `view:"-" desc:"which set of *additional* parameters to use -- always applies Base and optionaly this next if set -- can use multiple names separated by spaces (don't put spaces in ParamSet names!)"`
 	MaxRuns      int               `desc:"maximum number of model runs to perform"`
@@ -159,6 +184,7 @@ type Sim struct {
 	TrainEnv     env.FixedTable    `desc:"Training environment -- contains everything about iterating over input / output patterns over training"`
 	TestEnv      env.FixedTable    `desc:"Testing environment -- manages iterating over testing"`
 	Time         leabra.Time       `desc:"leabra timing parameters and state"`
+	Bench        bench.Bench       `view:"no-inline" desc:"benchmark parameters and results -- see Benchmark"`
 	ViewOn       bool              `desc:"whether to update the network view while running"`
 	TrainUpdt    leabra.TimeScales `desc:"at what time scale to update the display during training?  Anything longer than Epoch updates at Epoch in this model"`
 	TestUpdt     leabra.TimeScales `desc:"at what time scale to update the display during testing?  Anything longer than Epoch updates at Epoch in this model"`
@@ -190,6 +216,8 @@ type Sim struct {
 	TstEpcPlot  *eplot.Plot2D               `view:"-" desc:"the testing epoch plot"`
 	TstTrlPlot  *eplot.Plot2D               `view:"-" desc:"the test-trial plot"`
 	RunPlot     *eplot.Plot2D               `view:"-" desc:"the run plot"`
+	CapPlot     *eplot.Plot2D               `view:"-" desc:"the capacity experiment plot"`
+	HidBuilt    HidParams                   `view:"-" desc:"the Hid params the network was last built with"`
 	TrnEpcFile  *os.File                    `view:"-" desc:"log file"`
 	RunFile     *os.File                    `view:"-" desc:"log file"`
 	ValsTsrs    map[string]*etensor.Float32 `view:"-" desc:"for holding layer values"`
@@ -214,11 +242,14 @@ func (ss *Sim) New() {
 	ss.Easy = &etable.Table{}
 	ss.Hard = &etable.Table{}
 	ss.Impossible = &etable.Table{}
+	ss.CapPats = &etable.Table{}
 	ss.TrnEpcLog = &etable.Table{}
 	ss.TstEpcLog = &etable.Table{}
 	ss.TstTrlLog = &etable.Table{}
 	ss.RunLog = &etable.Table{}
 	ss.RunStats = &etable.Table{}
+	ss.CapLog = &etable.Table{}
+	ss.CapStats = &etable.Table{}
 	ss.Params = ParamSets
 	ss.RndSeed = 1
 	ss.ViewOn = true
@@ -226,6 +257,10 @@ func (ss *Sim) New() {
 	ss.TestUpdt = leabra.Quarter
 	ss.TestInterval = 5
 	ss.LayStatNms = []string{"Input", "Output"}
+	ss.BP.Defaults()
+	ss.Hid.Defaults()
+	ss.Cap.Defaults()
+	ss.Bench.Defaults("err_driven_hidden")
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////
@@ -240,6 +275,8 @@ func (ss *Sim) Config() {
 	ss.ConfigTstEpcLog(ss.TstEpcLog)
 	ss.ConfigTstTrlLog(ss.TstTrlLog)
 	ss.ConfigRunLog(ss.RunLog)
+	ss.ConfigCapLog(ss.CapLog)
+	ss.ConfigCapStats(ss.CapStats)
 }
 
 func (ss *Sim) ConfigEnv() {
@@ -267,31 +304,27 @@ func (ss *Sim) ConfigEnv() {
 	ss.TestEnv.Init(0)
 }
 
-func (ss *Sim) UpdateEnv() {
+// PatsTable returns the table of patterns for the current Pats
+func (ss *Sim) PatsTable() *etable.Table {
 	switch ss.Pats {
-	case Easy:
-		ss.TrainEnv.Table = etable.NewIdxView(ss.Easy)
-		ss.TestEnv.Table = etable.NewIdxView(ss.Easy)
 	case Hard:
-		ss.TrainEnv.Table = etable.NewIdxView(ss.Hard)
-		ss.TestEnv.Table = etable.NewIdxView(ss.Hard)
+		return ss.Hard
 	case Impossible:
-		ss.TrainEnv.Table = etable.NewIdxView(ss.Impossible)
-		ss.TestEnv.Table = etable.NewIdxView(ss.Impossible)
+		return ss.Impossible
+	case Capacity:
+		return ss.CapPats
 	}
+	return ss.Easy
+}
+
+func (ss *Sim) UpdateEnv() {
+	ss.TrainEnv.Table = etable.NewIdxView(ss.PatsTable())
+	ss.TestEnv.Table = etable.NewIdxView(ss.PatsTable())
 }
 
 func (ss *Sim) ConfigNet(net *leabra.Network) {
 	net.InitName(net, "PatAssoc")
-	inp := net.AddLayer2D("Input", 1, 4, emer.Input)
-	hid := net.AddLayer2D("Hidden", 1, 4, emer.Hidden)
-	out := net.AddLayer2D("Output", 1, 2, emer.Target)
-
-	full := prjn.NewFull()
-	net.ConnectLayers(inp, hid, full, emer.Forward)
-	net.BidirConnectLayers(hid, out, full)
-
-	hid.SetRelPos(relpos.Rel{Rel: relpos.Above, Other: "Input", YAlign: relpos.Front, XAlign: relpos.Left, YOffset: 1})
+	ss.ConfigNetLays(net)
 
 	net.Defaults()
 	ss.SetParams("Network", false) // only set Network params
@@ -301,6 +334,9 @@ func (ss *Sim) ConfigNet(net *leabra.Network) {
 		return
 	}
 	net.InitWts()
+	if err := ss.BP.ConfigFrom(net, ss.Hid.LayNames()...); err != nil {
+		log.Println(err)
+	}
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -311,6 +347,7 @@ func (ss *Sim) ConfigNet(net *leabra.Network) {
 func (ss *Sim) Init() {
 	rand.Seed(ss.RndSeed)
 	ss.UpdateEnv()
+	ss.ReConfigNet()
 	ss.StopNow = false
 	ss.SetParams("", false) // all sheets
 	ss.NewRun()
@@ -354,6 +391,14 @@ func (ss *Sim) UpdateView(train bool, cyc int) {
 // If train is true, then learning DWt or WtFmDWt calls are made.
 // Handles netview updating within scope of AlphaCycle
 func (ss *Sim) AlphaCyc(train bool) {
+	if ss.Learn == Backprop {
+		if err := ss.BP.LeabraTrial(ss.Net, train); err != nil {
+			log.Println(err)
+		} else if ss.ViewOn {
+			ss.UpdateView(train, -1)
+		}
+		return
+	}
 	// ss.Win.PollEvents() // this can be used instead of running in a separate goroutine
 	viewUpdt := ss.TrainUpdt
 	if !train {
@@ -478,6 +523,7 @@ func (ss *Sim) NewRun() {
 	ss.TestEnv.Init(run)
 	ss.Time.Reset()
 	ss.Net.InitWts()
+	ss.BP.InitWts()
 	ss.InitStats()
 	ss.TrnEpcLog.SetNumRows(0)
 	ss.TstEpcLog.SetNumRows(0)
@@ -582,6 +628,12 @@ func (ss *Sim) Stopped() {
 	}
 }
 
+// Benchmark runs the Bench benchmark with TrainTrial (see bench.Bench.RunSim)
+func (ss *Sim) Benchmark() {
+	ss.ReConfigNet() // as Init would, so that the network passed is the one run
+	ss.Bench.RunSim(ss.Net, &ss.Time, ss.TrainTrial, ss.Init, ss.Stopped, &ss.NetView)
+}
+
 // SaveWeights saves the network weights -- when called with giv.CallMethod
 // it will auto-prompt for filename
 func (ss *Sim) SaveWeights(filename gi.FileName) {
@@ -733,6 +785,23 @@ func (ss *Sim) OpenPats() {
 	// err = ss.Hard.OpenCSV("hard.tsv", etable.Tab)
 	ss.OpenPatAsset(ss.Impossible, "impossible.tsv", "Impossible", "Impossible Training patterns")
 	// err = ss.Impossible.OpenCSV("impossible.tsv", etable.Tab)
+	ss.GenCapPats(ss.CapPats, 0, 0)
+}
+
+// OpenPatsFile opens patterns from given .tsv or .csv file in place of the
+// currently selected Pats, checking that they fit the Input and Output layers.
+// The network weights are not changed -- do Init to train on the new patterns.
+func (ss *Sim) OpenPatsFile(filename gi.FileName) error {
+	dt := ss.PatsTable()
+	err := patfile.Open(dt, string(filename), ss.Net, "Input", "Output")
+	if err != nil {
+		patfile.Report(ss.Win, err)
+		return err
+	}
+	ss.UpdateEnv()
+	ss.TrainEnv.Init(0)
+	ss.TestEnv.Init(0)
+	return nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////
@@ -1136,6 +1205,9 @@ func (ss *Sim) ConfigGui() *gi.Window {
 	plt = tv.AddNewTab(eplot.KiT_Plot2D, "RunPlot").(*eplot.Plot2D)
 	ss.RunPlot = ss.ConfigRunPlot(plt, ss.RunLog)
 
+	plt = tv.AddNewTab(eplot.KiT_Plot2D, "CapPlot").(*eplot.Plot2D)
+	ss.CapPlot = ss.ConfigCapPlot(plt, ss.CapStats)
+
 	split.SetSplits(.2, .8)
 
 	tbar.AddAction(gi.ActOpts{Label: "Init", Icon: "update", Tooltip: "Initialize everything including network weights, and start over.  Also applies current params.", UpdateFunc: func(act *gi.Action) {
@@ -1242,6 +1314,21 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		}
 	})
 
+	tbar.AddAction(gi.ActOpts{Label: "Open Pats", Icon: "file-open", Tooltip: "Open your own patterns from a .tsv or .csv file, in place of the currently selected Pats -- the columns must fit the Input and Output layers."}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			giv.CallMethod(ss, "OpenPatsFile", vp)
+		})
+
+	tbar.AddAction(gi.ActOpts{Label: "Capacity", Icon: "fast-fwd", Tooltip: "Runs the hidden layer capacity experiment: trains Cap.NSeeds networks for each of the Cap.HidSizes and Cap.NPats numbers of Capacity patterns, recording epochs to criterion and failures in the CapLog, with averages in CapStats and CapPlot.  Does Init at the end.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if !ss.IsRunning {
+			ss.IsRunning = true
+			tbar.UpdateActions()
+			go ss.RunCapacity()
+		}
+	})
+
 	tbar.AddSeparator("log")
 
 	tbar.AddAction(gi.ActOpts{Label: "Reset RunLog", Icon: "update", Tooltip: "Reset the accumulated log of all Runs, which are tagged with the ParamSet used"}, win.This(),
@@ -1257,6 +1344,8 @@ func (ss *Sim) ConfigGui() *gi.Window {
 			ss.NewRndSeed()
 		})
 
+	bench.AddAction(tbar, win, &ss.IsRunning, ss.Benchmark)
+
 	tbar.AddAction(gi.ActOpts{Label: "README", Icon: "file-markdown", Tooltip: "Opens your browser on the README file that contains instructions for how to run this model."}, win.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			gi.OpenURL("https://github.com/CompCogNeuro/sims/blob/master/ch4/err_driven_hidden/README.md")
@@ -1337,6 +1426,20 @@ func (ss *Sim) ConfigGui() *gi.Window {
 // These props register Save methods so they can be used
 var SimProps = ki.Props{
 	"CallMethods": ki.PropSlice{
+		{"Benchmark", bench.MethodProps},
+		{"RunCapacity", ki.Props{
+			"desc": "runs the hidden layer capacity experiment in Cap, recording the results in CapLog and CapStats -- does Init at the end",
+			"icon": "fast-fwd",
+		}},
+		{"OpenPatsFile", ki.Props{
+			"desc": "open patterns from .tsv or .csv file, replacing the current ones",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".tsv,.csv",
+				}},
+			},
+		}},
 		{"SaveWeights", ki.Props{
 			"desc": "save network weights to file",
 			"icon": "file-save",
@@ -1349,10 +1452,79 @@ var SimProps = ki.Props{
 	},
 }
 
-func mainrun() {
-	TheSim.New()
-	TheSim.Config()
-	TheSim.Init()
-	win := TheSim.ConfigGui()
-	win.StartEventLoop()
+// CmdArgs runs the sim without the gui, according to the command-line
+// args: -bench runs the benchmark (with -benchthreads and -benchfile, see
+// Bench), -capacity runs the capacity experiment (see CapArgs), and
+// otherwise it does the standard training, saving the training epoch and
+// run logs as it goes, and the RunStats at the end, to
+// err_driven_hidden_*.tsv files
+func (ss *Sim) CmdArgs() {
+	var nogui bool
+	var saveEpcLog bool
+	var saveRunLog bool
+	var capacity bool
+	var learn string
+	flag.StringVar(&ss.ParamSet, "params", "", "ParamSet name to use -- must be valid name as listed in compiled-in params or loaded params")
+	flag.IntVar(&ss.MaxRuns, "runs", ss.MaxRuns, "number of runs to do")
+	flag.StringVar(&learn, "learn", ss.Learn.String(), "type of learning to use: Hebbian, ErrorDriven or Backprop -- the log files are named by it, so that the learning curves of the different types can be overlaid")
+	flag.BoolVar(&saveEpcLog, "epclog", true, "if true, save train epoch log to file")
+	flag.BoolVar(&saveRunLog, "runlog", true, "if true, save run log to file")
+	flag.BoolVar(&capacity, "capacity", false, "run the hidden layer capacity experiment (see RunCapacity) instead of the standard training")
+	flag.BoolVar(&nogui, "nogui", true, "if not passing any other args and want to run nogui, use nogui")
+	ss.Bench.AddFlags()
+	flag.Parse()
+	if err := ss.Learn.FromString(learn); err != nil {
+		log.Println(err)
+		return
+	}
+	ss.TrainEnv.Run.Max = ss.MaxRuns
+	ss.Init()
+	if bench.Flagged() {
+		ss.Benchmark()
+		return
+	}
+	if capacity {
+		ss.CapArgs()
+		return
+	}
+
+	if ss.ParamSet != "" {
+		fmt.Printf("Using ParamSet: %s\n", ss.ParamSet)
+	}
+	fnm := "err_driven_hidden_" + ss.Learn.String()
+	if saveEpcLog {
+		var err error
+		ss.TrnEpcFile, err = os.Create(fnm + "_epc.tsv")
+		if err != nil {
+			log.Println(err)
+			ss.TrnEpcFile = nil
+		} else {
+			fmt.Printf("Saving epoch log to: %s\n", fnm+"_epc.tsv")
+			defer ss.TrnEpcFile.Close()
+		}
+	}
+	if saveRunLog {
+		var err error
+		ss.RunFile, err = os.Create(fnm + "_run.tsv")
+		if err != nil {
+			log.Println(err)
+			ss.RunFile = nil
+		} else {
+			fmt.Printf("Saving run log to: %s\n", fnm+"_run.tsv")
+			defer ss.RunFile.Close()
+		}
+	}
+	fmt.Printf("Running %d Runs\n", ss.MaxRuns)
+	ss.Train()
+	SaveLog(ss.RunStats, fnm+"_runstats.tsv")
+}
+
+// SaveLog saves given log table to given .tsv file, for the runs without
+// the gui
+func SaveLog(dt *etable.Table, fnm string) {
+	if err := dt.SaveCSV(gi.FileName(fnm), etable.Tab, etable.Headers); err != nil {
+		log.Println(err)
+	} else {
+		fmt.Printf("Saved %s\n", fnm)
+	}
 }
@@ -0,0 +1,152 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/emer/emergent/emer"
+	"github.com/emer/emergent/prjn"
+	"github.com/emer/emergent/relpos"
+	"github.com/emer/leabra/leabra"
+	"github.com/goki/ki/kit"
+)
+
+// PrjnType is the type of projection pattern for the feedforward
+// projections into and out of the hidden layers
+type PrjnType int32
+
+//go:generate stringer -type=PrjnType
+
+var KiT_PrjnType = kit.Enums.AddEnum(PrjnTypeN, kit.NotBitFlag, nil)
+
+func (ev PrjnType) MarshalJSON() ([]byte, error)  { return kit.EnumMarshalJSON(ev) }
+func (ev *PrjnType) UnmarshalJSON(b []byte) error { return kit.EnumUnmarshalJSON(ev, b) }
+
+const (
+	// FullPrjn connects every sending unit to every receiving unit
+	FullPrjn PrjnType = iota
+
+	// RandomPrjn connects each receiving unit to a random subset of
+	// Hid.PCon of the sending units
+	RandomPrjn
+
+	PrjnTypeN
+)
+
+// HidParams are the parameters for the configuration of the hidden
+// layers of the network -- the Input and Output layers are sized
+// to fit the current Pats
+type HidParams struct {
+	HidY     int      `def:"1" min:"1" desc:"number of rows of units in each hidden layer"`
+	HidX     int      `def:"4" min:"1" desc:"number of columns of units in each hidden layer"`
+	NHidLays int      `def:"1" min:"1" desc:"number of hidden layers, named Hidden, Hidden2, etc -- each is connected bidirectionally to the next, with the Input projecting only feedforward to the first"`
+	Prjn     PrjnType `desc:"projection pattern from the Input to the first hidden layer, and between hidden layers -- the projections to and from the Output are always full"`
+	PCon     float32  `viewif:"Prjn=RandomPrjn" def:"0.5" min:"0" max:"1" desc:"proportion of sending units connected to each receiving unit for RandomPrjn"`
+}
+
+func (hp *HidParams) Defaults() {
+	hp.HidY = 1
+	hp.HidX = 4
+	hp.NHidLays = 1
+	hp.Prjn = FullPrjn
+	hp.PCon = 0.5
+}
+
+// HidName returns the name of the hidden layer at given index
+func (hp *HidParams) HidName(idx int) string {
+	if idx == 0 {
+		return "Hidden"
+	}
+	return fmt.Sprintf("Hidden%d", idx+1)
+}
+
+// LayNames returns the names of all the layers, from Input to Output
+func (hp *HidParams) LayNames() []string {
+	lays := []string{"Input"}
+	for hi := 0; hi < hp.NHidLays; hi++ {
+		lays = append(lays, hp.HidName(hi))
+	}
+	return append(lays, "Output")
+}
+
+// Pattern returns the projection pattern for the Prjn type
+func (hp *HidParams) Pattern() prjn.Pattern {
+	if hp.Prjn == RandomPrjn {
+		rp := prjn.NewUnifRnd()
+		rp.PCon = hp.PCon
+		return rp
+	}
+	return prjn.NewFull()
+}
+
+// InputShape returns the shape of the Input layer, from the Input
+// column of the current Pats
+func (ss *Sim) InputShape() (y, x int) {
+	return ss.PatShape("Input", 1, 4)
+}
+
+// OutputShape returns the shape of the Output layer, from the Output
+// column of the current Pats
+func (ss *Sim) OutputShape() (y, x int) {
+	return ss.PatShape("Output", 1, 2)
+}
+
+// PatShape returns the 2D shape of given column of the current Pats,
+// or the given default shape if it is not present
+func (ss *Sim) PatShape(col string, defY, defX int) (y, x int) {
+	cl, err := ss.PatsTable().ColByNameTry(col)
+	if err != nil || cl.NumDims() != 3 {
+		return defY, defX
+	}
+	return cl.Dim(1), cl.Dim(2)
+}
+
+// ReConfigNet rebuilds the network if the Input or Output shape or Hid
+// params have changed since it was last built, along with the logs and
+// views that depend on the layers
+func (ss *Sim) ReConfigNet() {
+	iy, ix := ss.InputShape()
+	oy, ox := ss.OutputShape()
+	ishp := ss.Net.LayerByName("Input").Shape()
+	oshp := ss.Net.LayerByName("Output").Shape()
+	if ss.HidBuilt == ss.Hid && ishp.Dim(0) == iy && ishp.Dim(1) == ix && oshp.Dim(0) == oy && oshp.Dim(1) == ox {
+		return
+	}
+	ss.Net = &leabra.Network{}
+	ss.ConfigNet(ss.Net)
+	ss.ConfigTstTrlLog(ss.TstTrlLog)
+	if ss.NetView != nil {
+		ss.NetView.SetNet(ss.Net)
+		ss.ConfigNetView(ss.NetView)
+	}
+	if ss.TstTrlPlot != nil {
+		ss.ConfigTstTrlPlot(ss.TstTrlPlot, ss.TstTrlLog)
+	}
+}
+
+// ConfigNetLays adds the layers and projections to the network, per Hid
+func (ss *Sim) ConfigNetLays(net *leabra.Network) {
+	iy, ix := ss.InputShape()
+	inp := net.AddLayer2D("Input", iy, ix, emer.Input)
+	hp := &ss.Hid
+	pat := hp.Pattern()
+	full := prjn.NewFull()
+	var prv emer.Layer = inp
+	for hi := 0; hi < hp.NHidLays; hi++ {
+		hid := net.AddLayer2D(hp.HidName(hi), hp.HidY, hp.HidX, emer.Hidden)
+		if hi == 0 {
+			net.ConnectLayers(inp, hid, pat, emer.Forward)
+		} else {
+			net.BidirConnectLayers(prv, hid, pat)
+		}
+		hid.SetRelPos(relpos.Rel{Rel: relpos.Above, Other: prv.Name(), YAlign: relpos.Front, XAlign: relpos.Left, YOffset: 1})
+		prv = hid
+	}
+	oy, ox := ss.OutputShape()
+	out := net.AddLayer2D("Output", oy, ox, emer.Target)
+	net.BidirConnectLayers(prv, out, full)
+	ss.HidBuilt = *hp
+}
@@ -15,12 +15,13 @@ func _() {
 	var x [1]struct{}
 	_ = x[Hebbian-0]
 	_ = x[ErrorDriven-1]
-	_ = x[LearnTypeN-2]
+	_ = x[Backprop-2]
+	_ = x[LearnTypeN-3]
 }
 
-const _LearnType_name = "HebbianErrorDrivenLearnTypeN"
+const _LearnType_name = "HebbianErrorDrivenBackpropLearnTypeN"
 
-var _LearnType_index = [...]uint8{0, 7, 18, 28}
+var _LearnType_index = [...]uint8{0, 7, 18, 26, 36}
 
 func (i LearnType) String() string {
 	if i < 0 || i >= LearnType(len(_LearnType_index)-1) {
@@ -16,12 +16,13 @@ func _() {
 	_ = x[Easy-0]
 	_ = x[Hard-1]
 	_ = x[Impossible-2]
-	_ = x[PatsTypeN-3]
+	_ = x[Capacity-3]
+	_ = x[PatsTypeN-4]
 }
 
-const _PatsType_name = "EasyHardImpossiblePatsTypeN"
+const _PatsType_name = "EasyHardImpossibleCapacityPatsTypeN"
 
-var _PatsType_index = [...]uint8{0, 4, 8, 18, 27}
+var _PatsType_index = [...]uint8{0, 4, 8, 18, 26, 35}
 
 func (i PatsType) String() string {
 	if i < 0 || i >= PatsType(len(_PatsType_index)-1) {
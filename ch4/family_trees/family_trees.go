@@ -19,6 +19,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/CompCogNeuro/sims/bench"
+	"github.com/CompCogNeuro/sims/patfile"
+	"github.com/CompCogNeuro/sims/progress"
+	"github.com/CompCogNeuro/sims/script"
 	"github.com/emer/emergent/emer"
 	"github.com/emer/emergent/env"
 	"github.com/emer/emergent/netview"
@@ -161,6 +165,9 @@ type Reps struct {
 	ClustPlot *eplot.Plot2D `view:"no-inline" desc:"cluster plot"`
 	PCA       *pca.PCA      `view:"-" desc:"pca results"`
 	PCAPrjn   *etable.Table `view:"-" desc:"pca projections onto eigenvectors"`
+	Time      *etable.Table `view:"no-inline" desc:"snapshots of the representations over training, recorded if RepsTime.On: for each snapshot epoch, the PCA projection (X, Y) and activity pattern of the centroid of each label -- one frame per Epoch for animating"`
+	TimePlot  *eplot.Plot2D `view:"no-inline" desc:"plot of the trajectory of each label in the PCA projection over the Time snapshots"`
+	Prev      [][]float64   `view:"-" desc:"PCA projection of each label in the previous Time snapshot, for aligning the next one"`
 }
 
 func (rp *Reps) Init() {
@@ -173,6 +180,9 @@ func (rp *Reps) Init() {
 	rp.PCAPlot.InitName(rp.PCAPlot, "PCAPlot") // any Ki obj needs this
 	rp.ClustPlot = &eplot.Plot2D{}
 	rp.ClustPlot.InitName(rp.ClustPlot, "ClustPlot") // any Ki obj needs this
+	rp.Time = &etable.Table{}
+	rp.TimePlot = &eplot.Plot2D{}
+	rp.TimePlot.InitName(rp.TimePlot, "TimePlot") // any Ki obj needs this
 }
 
 // Sim encapsulates the entire simulation model, and we define all the
@@ -184,6 +194,8 @@ type Sim struct {
 	Net          *leabra.Network   `view:"no-inline" desc:"the network -- click to view / edit parameters for layers, prjns, etc"`
 	Learn        LearnType         `desc:"select which type of learning to use"`
 	Pats         *etable.Table     `view:"no-inline" desc:"training patterns"`
+	Fams         *FamTrees         `view:"no-inline" desc:"family trees that the Pats were generated from, if opened with OpenFamFile"`
+	GenTests     []string          `desc:"names of the held-out items for the GenTestEnv, which are not trained -- matched by the Agent.Relation part of the name, so they apply to patterns generated by OpenFamFile as well -- takes effect on OpenPatsFile or OpenFamFile"`
 	TrnEpcLog    *etable.Table     `view:"no-inline" desc:"training epoch-level log data"`
 	TstEpcLog    *etable.Table     `view:"no-inline" desc:"testing epoch-level log data"`
 	TstTrlLog    *etable.Table     `view:"no-inline" desc:"testing trial-level log data"`
@@ -199,6 +211,7 @@ type Sim struct {
 	GenTestEnv   env.FixedTable    `desc:"Generalization Testing environment (4 held-out items not trained -- not enough training data to really drive generalization here) -- manages iterating over testing"`
 	AllTestEnv   env.FixedTable    `desc:"Test all items -- manages iterating over testing"`
 	Time         leabra.Time       `desc:"leabra timing parameters and state"`
+	Bench        bench.Bench       `view:"no-inline" desc:"benchmark parameters and results -- see Benchmark"`
 	ViewOn       bool              `desc:"whether to update the network view while running"`
 	TrainUpdt    leabra.TimeScales `desc:"at what time scale to update the display during training?  Anything longer than Epoch updates at Epoch in this model"`
 	TestUpdt     leabra.TimeScales `desc:"at what time scale to update the display during testing?  Anything longer than Epoch updates at Epoch in this model"`
@@ -207,6 +220,10 @@ type Sim struct {
 	HiddenRel    Reps              `view:"inline" desc:"representational analysis of Hidden layer, sorted by relationship"`
 	HiddenAgent  Reps              `view:"inline" desc:"representational analysis of Hidden layer, sorted by agent"`
 	AgentAgent   Reps              `view:"inline" desc:"representational analysis of AgentCode layer, sorted by agent"`
+	RepsTime     RepsTimeParams    `view:"inline" desc:"parameters for recording the representational analyses over training, in the Time table of each"`
+	Hold         HoldParams        `view:"inline" desc:"parameters for the hold-out generalization experiment -- see RunHoldOut"`
+	HoldLog      *etable.Table     `view:"no-inline" desc:"results of testing each held-out item in the hold-out generalization experiment"`
+	HoldStats    *etable.Table     `view:"no-inline" desc:"generalization to the held-out items for each relation in the hold-out generalization experiment"`
 
 	// statistics: note use float64 as that is best for etable.Table
 	TrlErr        float64 `inactive:"+" desc:"1 if trial was error, 0 if correct -- based on SSE = 0 (subject to .5 unit-wise tolerance)"`
@@ -234,11 +251,13 @@ type Sim struct {
 	TstEpcPlot   *eplot.Plot2D               `view:"-" desc:"the testing epoch plot"`
 	TstTrlPlot   *eplot.Plot2D               `view:"-" desc:"the test-trial plot"`
 	RunPlot      *eplot.Plot2D               `view:"-" desc:"the run plot"`
+	HoldPlot     *eplot.Plot2D               `view:"-" desc:"the hold-out generalization plot"`
 	TrnEpcFile   *os.File                    `view:"-" desc:"log file"`
 	RunFile      *os.File                    `view:"-" desc:"log file"`
 	ValsTsrs     map[string]*etensor.Float32 `view:"-" desc:"for holding layer values"`
 	SaveWts      bool                        `view:"-" desc:"for command-line run only, auto-save final weights after each run"`
 	NoGui        bool                        `view:"-" desc:"if true, runing in no GUI mode"`
+	Progress     progress.Progress           `view:"-" desc:"progress display for runs without the gui"`
 	LogSetParams bool                        `view:"-" desc:"if true, print message for all params that are set"`
 	IsRunning    bool                        `view:"-" desc:"true if sim is running"`
 	StopNow      bool                        `view:"-" desc:"flag to stop running"`
@@ -264,6 +283,8 @@ func (ss *Sim) New() {
 	ss.TstTrlLog = &etable.Table{}
 	ss.RunLog = &etable.Table{}
 	ss.RunStats = &etable.Table{}
+	ss.HoldLog = &etable.Table{}
+	ss.HoldStats = &etable.Table{}
 	ss.Params = ParamSets
 	ss.RndSeed = 1
 	ss.ViewOn = true
@@ -271,9 +292,14 @@ func (ss *Sim) New() {
 	ss.TestUpdt = leabra.Quarter
 	ss.TestInterval = 5
 	ss.TstRecLays = []string{"Hidden", "AgentCode"}
+	ss.GenTests = []string{"James.Wife.Vicky", "Lucia.Fath.Robert", "Angela.Bro.Marco", "Christi.Daug.Jenn"}
 	ss.HiddenRel.Init()
 	ss.HiddenAgent.Init()
 	ss.AgentAgent.Init()
+	ss.Hold.Defaults()
+	ss.RepsTime.Defaults()
+	ss.Bench.Defaults("family_trees")
+	ss.Progress.Defaults("family_trees")
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////
@@ -288,6 +314,11 @@ func (ss *Sim) Config() {
 	ss.ConfigTstEpcLog(ss.TstEpcLog)
 	ss.ConfigTstTrlLog(ss.TstTrlLog)
 	ss.ConfigRunLog(ss.RunLog)
+	ss.ConfigHoldLog(ss.HoldLog)
+	ss.ConfigHoldStats(ss.HoldStats)
+	ss.ConfigRepsTime(&ss.HiddenRel, "HiddenRel", "Hidden")
+	ss.ConfigRepsTime(&ss.HiddenAgent, "HiddenAgent", "Hidden")
+	ss.ConfigRepsTime(&ss.AgentAgent, "AgentAgent", "AgentCode")
 }
 
 func (ss *Sim) ConfigEnv() {
@@ -299,19 +330,20 @@ func (ss *Sim) ConfigEnv() {
 		ss.NZeroStop = 1
 	}
 
-	// note: code below pulls out these specific testing patterns into test env
+	// note: code below pulls out the GenTests testing patterns into test env
 	// and removes from training
-	tsts := []string{"James.Wife.Vicky", "Lucia.Fath.Robert", "Angela.Bro.Marco", "Christi.Daug.Jenn"}
-
 	trix := etable.NewIdxView(ss.Pats)
 	tsix := etable.NewIdxView(ss.Pats)
 	tsix.Idxs = tsix.Idxs[:0]
 
-	tstmap := make(map[int]struct{}, len(tsts))
-	for _, ts := range tsts {
-		ix := ss.Pats.RowsByString("Name", ts, etable.Equals, etable.UseCase)
-		tsix.Idxs = append(tsix.Idxs, ix[0])
-		tstmap[ix[0]] = struct{}{}
+	tstmap := make(map[int]struct{}, len(ss.GenTests))
+	for _, ts := range ss.GenTests {
+		row := ss.AgentRelRow(ts)
+		if row < 0 { // not in user-supplied patterns
+			continue
+		}
+		tsix.Idxs = append(tsix.Idxs, row)
+		tstmap[row] = struct{}{}
 	}
 	trix.Filter(func(et *etable.Table, row int) bool {
 		_, has := tstmap[row]
@@ -343,13 +375,15 @@ func (ss *Sim) ConfigEnv() {
 
 func (ss *Sim) ConfigNet(net *leabra.Network) {
 	net.InitName(net, "FamTrees")
-	ag := net.AddLayer2D("Agent", 4, 6, emer.Input)
-	rl := net.AddLayer2D("Relation", 2, 6, emer.Input)
+	py, px := ss.PatShape("Agent")
+	ry, rx := ss.PatShape("Relation")
+	ag := net.AddLayer2D("Agent", py, px, emer.Input)
+	rl := net.AddLayer2D("Relation", ry, rx, emer.Input)
 	agcd := net.AddLayer2D("AgentCode", 7, 7, emer.Hidden)
 	rlcd := net.AddLayer2D("RelationCode", 7, 7, emer.Hidden)
 	hid := net.AddLayer2D("Hidden", 7, 7, emer.Hidden)
 	ptcd := net.AddLayer2D("PatientCode", 7, 7, emer.Hidden)
-	pt := net.AddLayer2D("Patient", 4, 6, emer.Target)
+	pt := net.AddLayer2D("Patient", py, px, emer.Target)
 
 	agcd.SetClass("Code")
 	rlcd.SetClass("Code")
@@ -515,6 +549,9 @@ func (ss *Sim) TrainTrial() {
 	if ss.NeedsNewRun {
 		ss.NewRun()
 	}
+	if ss.RepsTime.On && ss.TrainEnv.Epoch.Cur == 0 && ss.TrainEnv.Trial.Cur < 0 {
+		ss.RepsSnapshot(0) // before any training in this run
+	}
 
 	ss.TrainEnv.Step() // the Env encapsulates and manages all counter state
 
@@ -529,6 +566,9 @@ func (ss *Sim) TrainTrial() {
 		if ss.TestInterval > 0 && epc%ss.TestInterval == 0 { // note: epc is *next* so won't trigger first time
 			ss.GenTestAll()
 		}
+		if ss.RepsTime.On && epc%ss.RepsTime.Interval == 0 {
+			ss.RepsSnapshot(epc)
+		}
 		if epc >= ss.MaxEpcs || (ss.NZeroStop > 0 && ss.NZero >= ss.NZeroStop) {
 			// done with training..
 			ss.RunEnd()
@@ -545,6 +585,9 @@ func (ss *Sim) TrainTrial() {
 	ss.ApplyInputs(&ss.TrainEnv)
 	ss.AlphaCyc(true)   // train
 	ss.TrialStats(true) // accumulate
+	if ss.NoGui && ss.Progress.Due() {
+		ss.Progress.Update(ss.Counters(true), progress.Frac(ss.TrainEnv.Run.Cur, ss.MaxRuns, ss.TrainEnv.Epoch.Cur, ss.MaxEpcs, ss.TrainEnv.Trial.Cur, ss.TrainEnv.Trial.Max), ss.TrnEpcLog)
+	}
 }
 
 // RunEnd is called at the end of a run -- save weights, record final log, etc here
@@ -555,6 +598,9 @@ func (ss *Sim) RunEnd() {
 		fmt.Printf("Saving Weights to: %s\n", fnm)
 		ss.Net.SaveWtsJSON(gi.FileName(fnm))
 	}
+	if ss.NoGui && ss.RepsTime.On {
+		ss.SaveRepsTime(ss.TrainEnv.Run.Cur)
+	}
 }
 
 // NewRun intializes a new run of the model, using the TrainEnv.Run counter
@@ -569,6 +615,7 @@ func (ss *Sim) NewRun() {
 	ss.InitStats()
 	ss.TrnEpcLog.SetNumRows(0)
 	ss.TstEpcLog.SetNumRows(0)
+	ss.InitRepsTime() // each run has its own snapshots
 	ss.NeedsNewRun = false
 }
 
@@ -670,6 +717,11 @@ func (ss *Sim) Stopped() {
 	}
 }
 
+// Benchmark runs the Bench benchmark with TrainTrial (see bench.Bench.RunSim)
+func (ss *Sim) Benchmark() {
+	ss.Bench.RunSim(ss.Net, &ss.Time, ss.TrainTrial, ss.Init, ss.Stopped, &ss.NetView)
+}
+
 // SaveWeights saves the network weights -- when called with giv.CallMethod
 // it will auto-prompt for filename
 func (ss *Sim) SaveWeights(filename gi.FileName) {
@@ -930,6 +982,21 @@ func (ss *Sim) OpenPats() {
 	// err := ss.Pats.OpenCSV("family_trees.tsv", etable.Tab)
 }
 
+// OpenPatsFile opens patterns from given .tsv or .csv file in place of the
+// Pats, checking that they fit the Agent, Relation and Patient layers.
+// Any of the GenTests items that are present are again used for the
+// GenTestEnv instead of training.
+func (ss *Sim) OpenPatsFile(filename gi.FileName) error {
+	err := patfile.Open(ss.Pats, string(filename), ss.Net, "Agent", "Relation", "Patient")
+	if err != nil {
+		patfile.Report(ss.Win, err)
+		return err
+	}
+	ss.Fams = nil
+	ss.ConfigEnv()
+	return nil
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////
 // 		Logging
 
@@ -1282,6 +1349,10 @@ func (ss *Sim) ConfigRunPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D
 ////////////////////////////////////////////////////////////////////////////////////////////
 // 		Gui
 
+func (ss *Sim) ConfigNetView(nv *netview.NetView) {
+	nv.Params.Raster.Max = 100
+}
+
 // ConfigGui configures the GoGi gui interface for this simulation,
 func (ss *Sim) ConfigGui() *gi.Window {
 	width := 1600
@@ -1314,8 +1385,8 @@ func (ss *Sim) ConfigGui() *gi.Window {
 	nv := tv.AddNewTab(netview.KiT_NetView, "NetView").(*netview.NetView)
 	nv.Var = "Act"
 	nv.SetNet(ss.Net)
-	nv.Params.Raster.Max = 100
 	ss.NetView = nv
+	ss.ConfigNetView(nv)
 
 	plt := tv.AddNewTab(eplot.KiT_Plot2D, "TrnEpcPlot").(*eplot.Plot2D)
 	ss.TrnEpcPlot = ss.ConfigTrnEpcPlot(plt, ss.TrnEpcLog)
@@ -1329,6 +1400,9 @@ func (ss *Sim) ConfigGui() *gi.Window {
 	plt = tv.AddNewTab(eplot.KiT_Plot2D, "RunPlot").(*eplot.Plot2D)
 	ss.RunPlot = ss.ConfigRunPlot(plt, ss.RunLog)
 
+	plt = tv.AddNewTab(eplot.KiT_Plot2D, "HoldPlot").(*eplot.Plot2D)
+	ss.HoldPlot = ss.ConfigHoldPlot(plt, ss.HoldStats)
+
 	split.SetSplits(.2, .8)
 
 	tbar.AddAction(gi.ActOpts{Label: "Init", Icon: "update", Tooltip: "Initialize everything including network weights, and start over.  Also applies current params.", UpdateFunc: func(act *gi.Action) {
@@ -1441,6 +1515,26 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		}
 	})
 
+	tbar.AddAction(gi.ActOpts{Label: "Hold Out", Icon: "fast-fwd", Tooltip: "Runs the hold-out generalization experiment: trains a new network for each fold of held-out items (see Hold params), and tests it on them -- see HoldLog and HoldPlot for the results.  Does Init at the end.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if !ss.IsRunning {
+			ss.IsRunning = true
+			tbar.UpdateActions()
+			go ss.RunHoldOut()
+		}
+	})
+
+	tbar.AddAction(gi.ActOpts{Label: "Open Pats", Icon: "file-open", Tooltip: "Open your own patterns from a .tsv or .csv file, in place of the family trees Pats -- the columns must fit the Agent, Relation and Patient layers."}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			giv.CallMethod(ss, "OpenPatsFile", vp)
+		})
+
+	tbar.AddAction(gi.ActOpts{Label: "Open Fams", Icon: "file-open", Tooltip: "Open your own family trees from a text file (see family_trees.fam for the format), and generate all of the Agent.Relation.Patient patterns from them, in place of the Pats -- the network is rebuilt to fit the number of people."}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			giv.CallMethod(ss, "OpenFamFile", vp)
+		})
+
 	tbar.AddSeparator("log")
 
 	tbar.AddAction(gi.ActOpts{Label: "Reset RunLog", Icon: "update", Tooltip: "Reset the accumulated log of all Runs, which are tagged with the ParamSet used"}, win.This(),
@@ -1456,6 +1550,8 @@ func (ss *Sim) ConfigGui() *gi.Window {
 			ss.NewRndSeed()
 		})
 
+	bench.AddAction(tbar, win, &ss.IsRunning, ss.Benchmark)
+
 	tbar.AddAction(gi.ActOpts{Label: "README", Icon: "file-markdown", Tooltip: "Opens your browser on the README file that contains instructions for how to run this model."}, win.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			gi.OpenURL("https://github.com/CompCogNeuro/sims/blob/master/ch4/family_trees/README.md")
@@ -1536,6 +1632,29 @@ func (ss *Sim) ConfigGui() *gi.Window {
 // These props register Save methods so they can be used
 var SimProps = ki.Props{
 	"CallMethods": ki.PropSlice{
+		{"Benchmark", bench.MethodProps},
+		{"RunHoldOut", ki.Props{
+			"desc": "trains a new network for each fold of held-out items (see Hold params), and tests generalization to them -- does Init at the end",
+			"icon": "fast-fwd",
+		}},
+		{"OpenPatsFile", ki.Props{
+			"desc": "open patterns from .tsv or .csv file, replacing the current ones",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".tsv,.csv",
+				}},
+			},
+		}},
+		{"OpenFamFile", ki.Props{
+			"desc": "open family trees from a text file (see family_trees.fam), and generate all the relation patterns from them, replacing the current ones",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".fam,.txt",
+				}},
+			},
+		}},
 		{"SaveWeights", ki.Props{
 			"desc": "save network weights to file",
 			"icon": "file-save",
@@ -1553,7 +1672,10 @@ func (ss *Sim) CmdArgs() {
 	var nogui bool
 	var saveEpcLog bool
 	var saveRunLog bool
+	var scriptFile string
 	var note string
+	var holdout string
+	var repsTime int
 	flag.StringVar(&ss.ParamSet, "params", "", "ParamSet name to use -- must be valid name as listed in compiled-in params or loaded params")
 	flag.StringVar(&ss.Tag, "tag", "", "extra tag to add to file names saved from this run")
 	flag.StringVar(&note, "note", "", "user note -- describe the run params etc")
@@ -1562,9 +1684,30 @@ func (ss *Sim) CmdArgs() {
 	flag.BoolVar(&ss.SaveWts, "wts", false, "if true, save final weights after each run")
 	flag.BoolVar(&saveEpcLog, "epclog", true, "if true, save train epoch log to file")
 	flag.BoolVar(&saveRunLog, "runlog", true, "if true, save run epoch log to file")
+	flag.StringVar(&scriptFile, "script", "", "if set, run the commands in given script file instead of the default training")
+	flag.StringVar(&holdout, "holdout", "", "run the hold-out generalization experiment (see RunHoldOut) with given Hold.Type (RandomHold, RelHold, PersonHold or FamilyHold) instead of the default training")
+	flag.IntVar(&repsTime, "repstime", 0, "if > 0, record snapshots of the representations (see RepsTime) every given number of epochs, and save them to files at the end of each run")
 	flag.BoolVar(&nogui, "nogui", true, "if not passing any other args and want to run nogui, use nogui")
+	ss.Bench.AddFlags()
+	ss.Progress.AddFlags()
 	flag.Parse()
+	if repsTime > 0 {
+		ss.RepsTime.On = true
+		ss.RepsTime.Interval = repsTime
+	}
 	ss.Init()
+	if bench.Flagged() {
+		ss.Benchmark()
+		return
+	}
+	if holdout != "" {
+		if err := ss.Hold.Type.FromString(holdout); err != nil {
+			log.Println(err)
+			return
+		}
+		ss.HoldArgs()
+		return
+	}
 
 	if note != "" {
 		fmt.Printf("note: %s\n", note)
@@ -1600,6 +1743,43 @@ func (ss *Sim) CmdArgs() {
 	if ss.SaveWts {
 		fmt.Printf("Saving final weights per run\n")
 	}
-	fmt.Printf("Running %d Runs\n", ss.MaxRuns)
-	ss.Train()
+	ss.Progress.Start()
+	if scriptFile != "" {
+		ss.RunScript(scriptFile)
+	} else {
+		fmt.Printf("Running %d Runs\n", ss.MaxRuns)
+		ss.Train()
+	}
+	ss.Progress.Done()
+}
+
+// ConfigScript registers the toolbar actions and the SimProps CallMethods
+// as commands that can be used in a script file passed with -script
+func (ss *Sim) ConfigScript(sc *script.Interp) {
+	sc.AddAction("Init", "initialize everything including network weights, and start over", ss.Init)
+	sc.AddSteps("Train", "run training to the end, or for given number of steps", ss.Train, map[string]func(){
+		"trial": ss.TrainTrial,
+		"epoch": ss.TrainEpoch,
+		"run":   ss.TrainRun,
+	})
+	sc.AddAction("GenTestTrial", "run the next generalization testing trial", func() { ss.GenTestTrial(false) })
+	sc.AddAction("GenTestAll", "test all of the generalization testing trials", ss.RunGenTestAll)
+	sc.AddAction("AllTestTrial", "run the next testing trial over all items", func() { ss.AllTestTrial(false) })
+	sc.AddAction("AllTestAll", "test all of the items", ss.RunAllTestAll)
+	sc.AddAction("RepsAnalysis", "test all items and analyze the Hidden and AgentCode representations", ss.RepsAnalysis)
+	sc.AddAction("ResetRunLog", "reset the accumulated log of all Runs", func() { ss.RunLog.SetNumRows(0) })
+	sc.AddAction("NewSeed", "generate a new initial random seed to get different results", ss.NewRndSeed)
+	if err := sc.AddCallMethods(SimProps); err != nil {
+		log.Println(err)
+	}
+}
+
+// RunScript runs the commands in given script file, exiting on any error
+func (ss *Sim) RunScript(fnm string) {
+	sc := script.New(ss)
+	ss.ConfigScript(sc)
+	fmt.Printf("Running script: %s\n", fnm)
+	if err := sc.RunFile(fnm); err != nil {
+		log.Fatalln(err)
+	}
 }
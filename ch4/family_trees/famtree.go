@@ -0,0 +1,428 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/CompCogNeuro/sims/patfile"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+	"github.com/goki/gi/gi"
+)
+
+// Relations are the kinship relations generated for each person in a
+// FamTrees, in the order of the units in the Relation layer -- the names
+// are those used in the original family_trees.tsv patterns
+var Relations = []string{"Fath", "Moth", "Husb", "Wife", "Son", "Daug", "Bro", "Sis", "Uncle", "Aunt", "Nephew", "Neice"}
+
+// Person is one person in a FamTrees
+type Person struct {
+	Name    string `desc:"name of the person, used in the pattern names"`
+	Male    bool   `desc:"true if male, false if female"`
+	Family  string `desc:"name of the family that the person belongs to, used as the pattern Group"`
+	Spouse  int    `desc:"index of the spouse, -1 if none"`
+	Parents []int  `desc:"indexes of the parents"`
+	Kids    []int  `desc:"indexes of the children"`
+}
+
+// Triple is one Agent.Relation.Patient fact generated from a FamTrees,
+// with all of the people that are in the relation to the agent as patients
+type Triple struct {
+	Agent    int   `desc:"index of the agent person"`
+	Rel      int   `desc:"index of the relation in Relations"`
+	Patients []int `desc:"indexes of the patient people, in order"`
+}
+
+// FamTrees is a set of family trees, defined in a simple text format, one
+// statement per line, with # starting a comment:
+//
+//	family <name>                  -- following people belong to this family
+//	person <name> <M|F>            -- a person and their gender
+//	married <name> <name>          -- two people are married
+//	children <parent> [<parent>] : <child> ...   -- children of one or two parents
+//
+// People must be defined before they are referred to, and are numbered in
+// the order they are defined, which is the order of the units in the
+// Agent and Patient layers.  See family_trees.fam for the standard trees.
+type FamTrees struct {
+	People []*Person      `desc:"all the people, in order of definition"`
+	Idxs   map[string]int `view:"-" desc:"index of each person by name"`
+}
+
+// OpenFile reads the family trees from given file
+func (ft *FamTrees) OpenFile(fname string) error {
+	fp, err := os.Open(fname)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	if err := ft.Read(fp); err != nil {
+		return fmt.Errorf("%s: %v", fname, err)
+	}
+	return nil
+}
+
+// Read reads the family trees from given reader, replacing any existing ones,
+// returning an error for the first line that could not be parsed
+func (ft *FamTrees) Read(r io.Reader) error {
+	ft.People = nil
+	ft.Idxs = make(map[string]int)
+	fam := ""
+	sc := bufio.NewScanner(r)
+	ln := 0
+	for sc.Scan() {
+		ln++
+		s := sc.Text()
+		if ci := strings.Index(s, "#"); ci >= 0 {
+			s = s[:ci]
+		}
+		fs := strings.Fields(s)
+		if len(fs) == 0 {
+			continue
+		}
+		var err error
+		switch fs[0] {
+		case "family":
+			if len(fs) != 2 {
+				err = fmt.Errorf("family needs a name")
+				break
+			}
+			fam = fs[1]
+		case "person":
+			err = ft.AddPerson(fs[1:], fam)
+		case "married":
+			err = ft.AddMarried(fs[1:])
+		case "children":
+			err = ft.AddChildren(fs[1:])
+		default:
+			err = fmt.Errorf("unknown statement: %s", fs[0])
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %v", ln, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if len(ft.People) == 0 {
+		return fmt.Errorf("no people defined")
+	}
+	return nil
+}
+
+// AddPerson adds a person from the args of a person statement
+func (ft *FamTrees) AddPerson(args []string, fam string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("person needs a name and M or F")
+	}
+	nm := args[0]
+	if strings.Contains(nm, ".") {
+		return fmt.Errorf("person name cannot contain a '.': %s", nm)
+	}
+	if _, has := ft.Idxs[nm]; has {
+		return fmt.Errorf("person already defined: %s", nm)
+	}
+	var male bool
+	switch strings.ToUpper(args[1]) {
+	case "M":
+		male = true
+	case "F":
+	default:
+		return fmt.Errorf("gender of %s must be M or F, not: %s", nm, args[1])
+	}
+	ft.Idxs[nm] = len(ft.People)
+	ft.People = append(ft.People, &Person{Name: nm, Male: male, Family: fam, Spouse: -1})
+	return nil
+}
+
+// PersonIdx returns the index of given person, or an error if not defined
+func (ft *FamTrees) PersonIdx(nm string) (int, error) {
+	pi, has := ft.Idxs[nm]
+	if !has {
+		return -1, fmt.Errorf("person not defined: %s", nm)
+	}
+	return pi, nil
+}
+
+// AddMarried records the marriage in the args of a married statement
+func (ft *FamTrees) AddMarried(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("married needs two names")
+	}
+	a, err := ft.PersonIdx(args[0])
+	if err != nil {
+		return err
+	}
+	b, err := ft.PersonIdx(args[1])
+	if err != nil {
+		return err
+	}
+	if a == b || ft.People[a].Spouse >= 0 || ft.People[b].Spouse >= 0 {
+		return fmt.Errorf("cannot marry %s and %s", args[0], args[1])
+	}
+	ft.People[a].Spouse = b
+	ft.People[b].Spouse = a
+	return nil
+}
+
+// AddChildren records the parents and children in the args of a children statement
+func (ft *FamTrees) AddChildren(args []string) error {
+	ci := -1
+	for i, a := range args {
+		if a == ":" {
+			ci = i
+		}
+	}
+	if ci < 1 || ci > 2 || ci == len(args)-1 {
+		return fmt.Errorf("children needs one or two parents, then : and the children")
+	}
+	var pars []int
+	for _, nm := range args[:ci] {
+		pi, err := ft.PersonIdx(nm)
+		if err != nil {
+			return err
+		}
+		pars = append(pars, pi)
+	}
+	for _, nm := range args[ci+1:] {
+		ki, err := ft.PersonIdx(nm)
+		if err != nil {
+			return err
+		}
+		kp := ft.People[ki]
+		if len(kp.Parents) > 0 {
+			return fmt.Errorf("parents of %s already defined", nm)
+		}
+		for _, pi := range pars {
+			if pi == ki {
+				return fmt.Errorf("%s cannot be their own parent", nm)
+			}
+			kp.Parents = append(kp.Parents, pi)
+			ft.People[pi].Kids = append(ft.People[pi].Kids, ki)
+		}
+	}
+	return nil
+}
+
+// Siblings returns the indexes of the siblings of given person: those
+// that share a parent
+func (ft *FamTrees) Siblings(pi int) []int {
+	var sibs []int
+	for _, par := range ft.People[pi].Parents {
+		for _, ki := range ft.People[par].Kids {
+			if ki != pi {
+				sibs = append(sibs, ki)
+			}
+		}
+	}
+	return sibs
+}
+
+// InLaws returns the indexes of the given people and their spouses
+func (ft *FamTrees) InLaws(pis []int) []int {
+	all := append([]int{}, pis...)
+	for _, pi := range pis {
+		if sp := ft.People[pi].Spouse; sp >= 0 {
+			all = append(all, sp)
+		}
+	}
+	return all
+}
+
+// Related returns the indexes of the people in given relation (index in
+// Relations) to given person, sorted and without duplicates: uncles and
+// aunts include the spouses of the siblings of the parents, and nephews
+// and nieces include the children of the siblings of the spouse
+func (ft *FamTrees) Related(pi, rel int) []int {
+	p := ft.People[pi]
+	var cands []int
+	male := true
+	switch Relations[rel] {
+	case "Fath", "Moth":
+		cands = p.Parents
+		male = Relations[rel] == "Fath"
+	case "Husb", "Wife":
+		if p.Spouse >= 0 {
+			cands = []int{p.Spouse}
+		}
+		male = Relations[rel] == "Husb"
+	case "Son", "Daug":
+		cands = p.Kids
+		male = Relations[rel] == "Son"
+	case "Bro", "Sis":
+		cands = ft.Siblings(pi)
+		male = Relations[rel] == "Bro"
+	case "Uncle", "Aunt":
+		for _, par := range p.Parents {
+			cands = append(cands, ft.InLaws(ft.Siblings(par))...)
+		}
+		male = Relations[rel] == "Uncle"
+	case "Nephew", "Neice":
+		sibs := ft.Siblings(pi)
+		if p.Spouse >= 0 {
+			sibs = append(sibs, ft.Siblings(p.Spouse)...)
+		}
+		for _, sib := range sibs {
+			cands = append(cands, ft.People[sib].Kids...)
+		}
+		male = Relations[rel] == "Nephew"
+	}
+	var rels []int
+	seen := make(map[int]bool)
+	for _, ci := range cands {
+		if ci == pi || seen[ci] || ft.People[ci].Male != male {
+			continue
+		}
+		seen[ci] = true
+		rels = append(rels, ci)
+	}
+	sort.Ints(rels)
+	return rels
+}
+
+// Triples returns all of the Agent.Relation.Patient facts in the trees,
+// for each person in order, and each relation in order, that has at least
+// one patient
+func (ft *FamTrees) Triples() []Triple {
+	var trps []Triple
+	for pi := range ft.People {
+		for ri := range Relations {
+			pts := ft.Related(pi, ri)
+			if len(pts) > 0 {
+				trps = append(trps, Triple{Agent: pi, Rel: ri, Patients: pts})
+			}
+		}
+	}
+	return trps
+}
+
+// TripleName returns the name of given triple as Agent.Relation.Patient,
+// where multiple patients are named by the first 3 letters of each name,
+// as in the original family_trees.tsv patterns (e.g., Alf.Aunt.GinAng)
+func (ft *FamTrees) TripleName(tr Triple) string {
+	pnm := ""
+	for _, pi := range tr.Patients {
+		nm := ft.People[pi].Name
+		if len(tr.Patients) > 1 && len(nm) > 3 {
+			nm = nm[:3]
+		}
+		pnm += nm
+	}
+	return ft.People[tr.Agent].Name + "." + Relations[tr.Rel] + "." + pnm
+}
+
+// UnitsShape returns the 2D shape of a localist layer with n units,
+// with rows of 6 units as in the original 4x6 Agent and 2x6 Relation layers
+func UnitsShape(n int) []int {
+	return []int{(n + 5) / 6, 6}
+}
+
+// AgentRel returns the Agent.Relation part of given pattern name
+func AgentRel(nm string) string {
+	fs := strings.Split(nm, ".")
+	if len(fs) < 2 {
+		return nm
+	}
+	return fs[0] + "." + fs[1]
+}
+
+// AgentRelRow returns the row in Pats of the pattern with the same
+// Agent.Relation part of its name as given name, or -1 if none
+func (ss *Sim) AgentRelRow(nm string) int {
+	ar := AgentRel(nm)
+	nc := ss.Pats.ColByName("Name")
+	for row := 0; row < ss.Pats.Rows; row++ {
+		if AgentRel(nc.StringVal1D(row)) == ar {
+			return row
+		}
+	}
+	return -1
+}
+
+// PatShape returns the 2D shape of the layer of given name, from its
+// column in the Pats
+func (ss *Sim) PatShape(lnm string) (y, x int) {
+	col, err := ss.Pats.ColByNameTry(lnm)
+	if err != nil || col.NumDims() != 3 {
+		if lnm == "Relation" {
+			return 2, 6
+		}
+		return 4, 6
+	}
+	return col.Dim(1), col.Dim(2)
+}
+
+// FamPats generates the patterns for all of the Triples in given family
+// trees into dt, with a localist unit for each person in the Agent and
+// Patient columns and each of the Relations in the Relation column, and
+// the family of the agent as the Group
+func (ss *Sim) FamPats(ft *FamTrees, dt *etable.Table) {
+	trps := ft.Triples()
+	pshp := UnitsShape(len(ft.People))
+	sch := etable.Schema{
+		{"Name", etensor.STRING, nil, nil},
+		{"Group", etensor.STRING, nil, nil},
+		{"Agent", etensor.FLOAT32, pshp, []string{"Y", "X"}},
+		{"Relation", etensor.FLOAT32, UnitsShape(len(Relations)), []string{"Y", "X"}},
+		{"Patient", etensor.FLOAT32, pshp, []string{"Y", "X"}},
+	}
+	dt.SetFromSchema(sch, len(trps))
+	for i := 2; i < len(dt.Cols); i++ {
+		dt.Cols[i].SetMetaData("grid-fill", "0.9")
+	}
+	for row, tr := range trps {
+		dt.SetCellString("Name", row, ft.TripleName(tr))
+		dt.SetCellString("Group", row, ft.People[tr.Agent].Family)
+		dt.SetCellTensorFloat1D("Agent", row, tr.Agent, 1)
+		dt.SetCellTensorFloat1D("Relation", row, tr.Rel, 1)
+		for _, pi := range tr.Patients {
+			dt.SetCellTensorFloat1D("Patient", row, pi, 1)
+		}
+	}
+}
+
+// OpenFamFile opens family trees from given file (see FamTrees for the
+// format) and generates the Pats from them, in place of the current ones,
+// rebuilding the network if the number of people is different.
+// Any of the GenTests items that are present are again used for the
+// GenTestEnv instead of training.
+func (ss *Sim) OpenFamFile(filename gi.FileName) error {
+	ft := &FamTrees{}
+	if err := ft.OpenFile(string(filename)); err != nil {
+		patfile.Report(ss.Win, err)
+		return err
+	}
+	ss.Fams = ft
+	ss.FamPats(ft, ss.Pats)
+	ss.ConfigEnv()
+	ss.ReConfigNet()
+	ss.ConfigTstTrlLog(ss.TstTrlLog)
+	return nil
+}
+
+// ReConfigNet rebuilds the network if the shapes of the Agent, Relation
+// or Patient layers do not match the Pats
+func (ss *Sim) ReConfigNet() {
+	py, px := ss.PatShape("Agent")
+	ry, rx := ss.PatShape("Relation")
+	ashp := ss.Net.LayerByName("Agent").Shape()
+	rshp := ss.Net.LayerByName("Relation").Shape()
+	if ashp.Dim(0) == py && ashp.Dim(1) == px && rshp.Dim(0) == ry && rshp.Dim(1) == rx {
+		return
+	}
+	ss.Net = &leabra.Network{}
+	ss.ConfigNet(ss.Net)
+	if ss.NetView != nil {
+		ss.NetView.SetNet(ss.Net)
+		ss.ConfigNetView(ss.NetView)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+)
+
+func TestFamPats(t *testing.T) {
+	ft := &FamTrees{}
+	if err := ft.OpenFile("family_trees.fam"); err != nil {
+		t.Fatal(err)
+	}
+	ss := &Sim{}
+	fam := &etable.Table{}
+	ss.FamPats(ft, fam)
+
+	orig := &etable.Table{}
+	if err := orig.OpenCSV("family_trees.tsv", etable.Tab); err != nil {
+		t.Fatal(err)
+	}
+	if orig.Rows != 104 || fam.Rows != orig.Rows {
+		t.Fatalf("%d generated triples, %d in family_trees.tsv, want 104", fam.Rows, orig.Rows)
+	}
+	// the original names Rob as Robert in this one triple -- the Group is
+	// not compared, as the original puts some agents (e.g., Maria) in the
+	// other family
+	renames := map[string]string{"Lucia.Fath.Robert": "Lucia.Fath.Rob"}
+
+	rows := make(map[string]int)
+	for row := 0; row < fam.Rows; row++ {
+		rows[fam.CellString("Name", row)] = row
+	}
+	for orow := 0; orow < orig.Rows; orow++ {
+		nm := orig.CellString("Name", orow)
+		if rn, has := renames[nm]; has {
+			nm = rn
+		}
+		row, has := rows[nm]
+		if !has {
+			t.Errorf("%s not generated", nm)
+			continue
+		}
+		delete(rows, nm)
+		for _, col := range []string{"Agent", "Relation", "Patient"} {
+			pat := fam.CellTensor(col, row).(*etensor.Float32).Values
+			opat := orig.CellTensor(col, orow).(*etensor.Float32).Values
+			if !reflect.DeepEqual(pat, opat) {
+				t.Errorf("%s %s: %v, want %v", nm, col, pat, opat)
+			}
+		}
+	}
+	for nm := range rows {
+		t.Errorf("%s is not in family_trees.tsv", nm)
+	}
+}
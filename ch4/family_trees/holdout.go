@@ -0,0 +1,352 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/gi"
+	"github.com/goki/ki/kit"
+)
+
+// HoldType is the way that the patterns are split into folds of held-out
+// items for the hold-out generalization experiment
+type HoldType int32
+
+//go:generate stringer -type=HoldType
+
+var KiT_HoldType = kit.Enums.AddEnum(HoldTypeN, kit.NotBitFlag, nil)
+
+func (ev HoldType) MarshalJSON() ([]byte, error)  { return kit.EnumMarshalJSON(ev) }
+func (ev *HoldType) UnmarshalJSON(b []byte) error { return kit.EnumUnmarshalJSON(ev, b) }
+
+const (
+	// RandomHold holds out Hold.K random items in each fold, so that
+	// every item is held out once over all the folds (leave-K-out)
+	RandomHold HoldType = iota
+
+	// RelHold holds out all of the items for one relation in each fold
+	RelHold
+
+	// PersonHold holds out all of the items with one person as the
+	// agent in each fold
+	PersonHold
+
+	// FamilyHold holds out Hold.K random items of the Hold.Family family
+	// in each fold, while all of the items of the other, isomorphic family
+	// are always trained
+	FamilyHold
+
+	HoldTypeN
+)
+
+// HoldParams are the parameters for the hold-out generalization
+// experiment, which trains a new network for each fold of held-out items,
+// and tests generalization to them
+type HoldParams struct {
+	Type     HoldType `desc:"how to split the items into folds of held-out items"`
+	K        int      `viewif:"Type=RandomHold|FamilyHold" def:"4" min:"1" desc:"number of items held out in each fold for RandomHold and FamilyHold"`
+	Family   string   `viewif:"Type=FamilyHold" def:"Italian" desc:"family (Group of the patterns) that items are held out from for FamilyHold"`
+	MaxFolds int      `def:"0" min:"0" desc:"if > 0, only run this many of the folds, to save time"`
+	MaxEpcs  int      `def:"100" min:"1" desc:"maximum number of epochs to train on each fold -- training also stops after NZeroStop epochs with no errors"`
+}
+
+func (hp *HoldParams) Defaults() {
+	hp.Type = RandomHold
+	hp.K = 4
+	hp.Family = "Italian"
+	hp.MaxFolds = 0
+	hp.MaxEpcs = 100
+}
+
+// HoldFolds returns the rows in Pats of the held-out items for each fold,
+// according to Hold.Type, using the current random seed
+func (ss *Sim) HoldFolds() [][]int {
+	hp := &ss.Hold
+	var folds [][]int
+	switch hp.Type {
+	case RelHold, PersonHold:
+		fidx := make(map[string]int)
+		for row := 0; row < ss.Pats.Rows; row++ {
+			nm := strings.Split(ss.Pats.CellString("Name", row), ".")
+			key := nm[0]
+			if hp.Type == RelHold && len(nm) > 1 {
+				key = nm[1]
+			}
+			fi, has := fidx[key]
+			if !has {
+				fi = len(folds)
+				fidx[key] = fi
+				folds = append(folds, nil)
+			}
+			folds[fi] = append(folds[fi], row)
+		}
+	default:
+		var rows []int
+		gc, _ := ss.Pats.ColByNameTry("Group")
+		for row := 0; row < ss.Pats.Rows; row++ {
+			if hp.Type == FamilyHold && (gc == nil || gc.StringVal1D(row) != hp.Family) {
+				continue
+			}
+			rows = append(rows, row)
+		}
+		rand.Shuffle(len(rows), func(i, j int) { rows[i], rows[j] = rows[j], rows[i] })
+		k := hp.K
+		if k < 1 {
+			k = 1
+		}
+		for st := 0; st < len(rows); st += k {
+			ed := st + k
+			if ed > len(rows) {
+				ed = len(rows)
+			}
+			folds = append(folds, rows[st:ed])
+		}
+	}
+	if hp.MaxFolds > 0 && len(folds) > hp.MaxFolds {
+		folds = folds[:hp.MaxFolds]
+	}
+	return folds
+}
+
+// HoldTrain trains the network on the TrainEnv until there are no errors
+// for NZeroStop epochs, or for Hold.MaxEpcs, without logging, and returns
+// the number of epochs trained
+func (ss *Sim) HoldTrain() int {
+	ntrl := ss.TrainEnv.Table.Len()
+	nzero := 0
+	epc := 0
+	for epc < ss.Hold.MaxEpcs && !ss.StopNow {
+		errs := 0.0
+		for trl := 0; trl < ntrl; trl++ {
+			ss.TrainEnv.Step()
+			ss.ApplyInputs(&ss.TrainEnv)
+			ss.AlphaCyc(true)
+			ss.TrialStats(false)
+			errs += ss.TrlErr
+		}
+		epc++
+		if errs == 0 {
+			nzero++
+		} else {
+			nzero = 0
+		}
+		if ss.NZeroStop > 0 && nzero >= ss.NZeroStop {
+			break
+		}
+	}
+	return epc
+}
+
+// HoldTest tests each of the held-out items in the GenTestEnv, logging
+// the results for each item to the HoldLog
+func (ss *Sim) HoldTest(fold, epcs int) {
+	ss.GenTestEnv.Init(0)
+	gc, _ := ss.Pats.ColByNameTry("Group")
+	tix := ss.GenTestEnv.Table
+	for i := 0; i < tix.Len(); i++ {
+		ss.GenTestEnv.Step()
+		ss.ApplyInputs(&ss.GenTestEnv)
+		ss.AlphaCyc(false)
+		ss.TrialStats(false)
+		grp := ""
+		if gc != nil {
+			grp = gc.StringVal1D(tix.Idxs[i])
+		}
+		ss.LogHold(ss.HoldLog, fold, epcs, ss.GenTestEnv.TrialName.Cur, grp)
+	}
+}
+
+// RunHoldOut runs the hold-out generalization experiment: for each fold of
+// held-out items (see Hold), a new network is trained on all the other
+// items, and then tested on the held-out ones, with the results for each
+// item in the HoldLog, and the generalization for each relation in HoldStats.
+// The current Learn and params are used.  Does Init at the end.
+func (ss *Sim) RunHoldOut() {
+	nv := ss.NetView
+	ss.NetView = nil
+	ss.StopNow = false
+	rand.Seed(ss.RndSeed)
+	ss.SetParams("", false)
+	ss.HoldLog.SetNumRows(0)
+	ss.HoldStats.SetNumRows(0)
+	folds := ss.HoldFolds()
+	for fi, tst := range folds {
+		tstmap := make(map[int]bool, len(tst))
+		for _, row := range tst {
+			tstmap[row] = true
+		}
+		trix := etable.NewIdxView(ss.Pats)
+		trix.Filter(func(et *etable.Table, row int) bool {
+			return !tstmap[row]
+		})
+		tsix := etable.NewIdxView(ss.Pats)
+		tsix.Idxs = append([]int{}, tst...)
+		ss.TrainEnv.Table = trix
+		ss.TrainEnv.Init(fi)
+		ss.GenTestEnv.Table = tsix
+		ss.Time.Reset()
+		ss.Net.InitWts()
+		epcs := ss.HoldTrain()
+		if ss.StopNow {
+			break
+		}
+		ss.HoldTest(fi, epcs)
+		ss.LogHoldStats(ss.HoldStats, ss.HoldLog)
+		if ss.NoGui {
+			fmt.Printf("hold-out fold: %d / %d  trained epochs: %d\n", fi+1, len(folds), epcs)
+		}
+	}
+	ss.ConfigEnv() // restore train / test split
+	ss.NetView = nv
+	ss.Init()
+	ss.Stopped()
+}
+
+// HoldArgs runs the hold-out experiment without the gui, and saves the
+// HoldLog and HoldStats to family_trees_<type>_holdlog.tsv and
+// family_trees_<type>_holdstats.tsv
+func (ss *Sim) HoldArgs() {
+	fmt.Printf("Running hold-out experiment: %s\n", ss.Hold.Type)
+	ss.RunHoldOut()
+	for _, dt := range []*etable.Table{ss.HoldLog, ss.HoldStats} {
+		fnm := "family_trees_" + strings.ToLower(ss.Hold.Type.String()) + "_" + strings.ToLower(dt.MetaData["name"]) + ".tsv"
+		if err := dt.SaveCSV(gi.FileName(fnm), etable.Tab, etable.Headers); err != nil {
+			log.Println(err)
+		} else {
+			fmt.Printf("Saved %s\n", fnm)
+		}
+	}
+}
+
+//////////////////////////////////////////////
+//  HoldLog
+
+// LogHold adds a row to the HoldLog with the results of testing one
+// held-out item, after training for epcs epochs
+func (ss *Sim) LogHold(dt *etable.Table, fold, epcs int, nm, grp string) {
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	nms := strings.Split(nm, ".")
+	rel := ""
+	if len(nms) > 1 {
+		rel = nms[1]
+	}
+	dt.SetCellFloat("Fold", row, float64(fold))
+	dt.SetCellString("Name", row, nm)
+	dt.SetCellString("Agent", row, nms[0])
+	dt.SetCellString("Rel", row, rel)
+	dt.SetCellString("Group", row, grp)
+	dt.SetCellFloat("Epochs", row, float64(epcs))
+	dt.SetCellFloat("Err", row, ss.TrlErr)
+	dt.SetCellFloat("SSE", row, ss.TrlSSE)
+	dt.SetCellFloat("CosDiff", row, ss.TrlCosDiff)
+}
+
+func (ss *Sim) ConfigHoldLog(dt *etable.Table) {
+	dt.SetMetaData("name", "HoldLog")
+	dt.SetMetaData("desc", "Results of testing each held-out item in the hold-out generalization experiment")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Fold", etensor.INT64, nil, nil},
+		{"Name", etensor.STRING, nil, nil},
+		{"Agent", etensor.STRING, nil, nil},
+		{"Rel", etensor.STRING, nil, nil},
+		{"Group", etensor.STRING, nil, nil},
+		{"Epochs", etensor.FLOAT64, nil, nil},
+		{"Err", etensor.FLOAT64, nil, nil},
+		{"SSE", etensor.FLOAT64, nil, nil},
+		{"CosDiff", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+//////////////////////////////////////////////
+//  HoldStats
+
+// LogHoldStats computes the generalization for each relation over all
+// the items in the HoldLog so far into the HoldStats, with an All row
+// for all of the items
+func (ss *Sim) LogHoldStats(dt, hold *etable.Table) {
+	var rels []string
+	ns := make(map[string]float64)
+	cors := make(map[string]float64)
+	coss := make(map[string]float64)
+	for r := 0; r < hold.Rows; r++ {
+		rel := hold.CellString("Rel", r)
+		if _, has := ns[rel]; !has {
+			rels = append(rels, rel)
+		}
+		for _, k := range []string{"All", rel} {
+			ns[k]++
+			cors[k] += 1 - hold.CellFloat("Err", r)
+			coss[k] += hold.CellFloat("CosDiff", r)
+		}
+	}
+	relIdx := func(rel string) int {
+		for i, r := range Relations {
+			if r == rel {
+				return i
+			}
+		}
+		return len(Relations)
+	}
+	sort.SliceStable(rels, func(i, j int) bool { return relIdx(rels[i]) < relIdx(rels[j]) })
+	rels = append([]string{"All"}, rels...)
+
+	dt.SetNumRows(len(rels))
+	for row, rel := range rels {
+		n := ns[rel]
+		cor := cors[rel] / n
+		dt.SetCellString("Rel", row, rel)
+		dt.SetCellFloat("N", row, n)
+		dt.SetCellFloat("PctCor", row, cor)
+		dt.SetCellFloat("PctCorSEM", row, math.Sqrt(cor*(1-cor)/n))
+		dt.SetCellFloat("CosDiff", row, coss[rel]/n)
+	}
+	if ss.HoldPlot != nil {
+		ss.HoldPlot.GoUpdate()
+	}
+}
+
+func (ss *Sim) ConfigHoldStats(dt *etable.Table) {
+	dt.SetMetaData("name", "HoldStats")
+	dt.SetMetaData("desc", "Generalization to the held-out items for each relation in the hold-out generalization experiment")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Rel", etensor.STRING, nil, nil},
+		{"N", etensor.FLOAT64, nil, nil},
+		{"PctCor", etensor.FLOAT64, nil, nil},
+		{"PctCorSEM", etensor.FLOAT64, nil, nil},
+		{"CosDiff", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigHoldPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "Family Trees Hold-out Generalization Plot"
+	plt.Params.Type = eplot.Bar
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Rel", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("N", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("PctCor", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("PctCorSEM", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("CosDiff", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	return plt
+}
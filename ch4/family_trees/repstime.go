@@ -0,0 +1,250 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/etable/metric"
+	"github.com/emer/etable/pca"
+	"github.com/emer/leabra/leabra"
+	"github.com/goki/gi/gi"
+)
+
+// RepsTimeParams are the parameters for recording snapshots of the
+// representations analyzed in RepsAnalysis over the course of training
+type RepsTimeParams struct {
+	On       bool `desc:"record a snapshot of the HiddenRel, HiddenAgent and AgentAgent representations every Interval epochs during training, including before training starts, into the Time table of each -- each snapshot tests all the items, which slows down training"`
+	Interval int  `viewif:"On" def:"5" min:"1" desc:"number of training epochs between snapshots"`
+	Align    bool `viewif:"On" def:"true" desc:"align the PCA projection of each snapshot to the previous one with a Procrustes rotation (and reflection if that fits better), so that the points move smoothly from one snapshot to the next, instead of jumping when the PCA axes flip or rotate"`
+}
+
+func (rt *RepsTimeParams) Defaults() {
+	rt.Interval = 5
+	rt.Align = true
+}
+
+// RepsSnapshot tests all the items and records a snapshot of each of the
+// representations analyzed in RepsAnalysis, for given epoch -- the test
+// epoch that this logs is removed from the TstEpcLog, as it is not a
+// test run by the user
+func (ss *Sim) RepsSnapshot(epc int) {
+	nrows := ss.TstEpcLog.Rows
+	ss.AllTestAll()
+	ss.TstEpcLog.SetNumRows(nrows)
+	ss.TstEpcPlot.GoUpdate()
+	ss.RepsTimeSnap(&ss.HiddenRel, "Hidden", false, []int{0, 1}, epc)
+	ss.RepsTimeSnap(&ss.HiddenAgent, "Hidden", true, []int{2, 3}, epc)
+	ss.RepsTimeSnap(&ss.AgentAgent, "AgentCode", true, []int{0, 1}, epc)
+}
+
+// RepsTimeSnap adds a snapshot of the representations in given TstTrlLog
+// column to the Time table of rp: the PCA of all the items is projected onto
+// the given two components, and the centroid of the items for each label
+// (the agent if agent is true, else the relation) is recorded, centered on
+// the origin and aligned to the previous snapshot if RepsTime.Align, along
+// with the centroid activity pattern
+func (ss *Sim) RepsTimeSnap(rp *Reps, colNm string, agent bool, comps []int, epc int) {
+	trl := ss.TstTrlLog
+	ix := etable.NewIdxView(trl)
+	pc := &pca.PCA{}
+	if err := pc.TableCol(ix, colNm, metric.Covariance64); err != nil {
+		log.Println(err)
+		return
+	}
+	prjns := make([][]float64, len(comps))
+	for ci, comp := range comps {
+		if err := pc.ProjectCol(&prjns[ci], ix, colNm, comp); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+
+	patGrp := make(map[string]string) // group of each of the Pats, by name
+	if gc, err := ss.Pats.ColByNameTry("Group"); err == nil {
+		nc := ss.Pats.ColByName("Name")
+		for row := 0; row < ss.Pats.Rows; row++ {
+			patGrp[nc.StringVal1D(row)] = gc.StringVal1D(row)
+		}
+	}
+	col := trl.ColByName(colNm)
+	var labs []string
+	lidx := make(map[string]int)
+	var pts [][]float64
+	var acts [][]float64
+	var grps []string
+	var ns []float64
+	for row := 0; row < trl.Rows; row++ {
+		trlNm := trl.CellString("TrialName", row)
+		nm := strings.Split(trlNm, ".")
+		lab := nm[0]
+		if !agent && len(nm) > 1 {
+			lab = nm[1]
+		}
+		li, has := lidx[lab]
+		if !has {
+			li = len(labs)
+			lidx[lab] = li
+			labs = append(labs, lab)
+			pts = append(pts, make([]float64, 2))
+			acts = append(acts, make([]float64, col.Len()/col.Dim(0)))
+			grp := ""
+			if agent {
+				grp = patGrp[trlNm]
+			}
+			grps = append(grps, grp)
+			ns = append(ns, 0)
+		}
+		pts[li][0] += prjns[0][row]
+		pts[li][1] += prjns[1][row]
+		act := col.SubSpace([]int{row})
+		for i := range acts[li] {
+			acts[li][i] += act.FloatVal1D(i)
+		}
+		ns[li]++
+	}
+	var cx, cy float64
+	for li := range labs {
+		pts[li][0] /= ns[li]
+		pts[li][1] /= ns[li]
+		for i := range acts[li] {
+			acts[li][i] /= ns[li]
+		}
+		cx += pts[li][0]
+		cy += pts[li][1]
+	}
+	cx /= float64(len(labs))
+	cy /= float64(len(labs))
+	for li := range labs {
+		pts[li][0] -= cx
+		pts[li][1] -= cy
+	}
+	if ss.RepsTime.Align && len(rp.Prev) == len(pts) {
+		AlignPts(pts, rp.Prev)
+	}
+	rp.Prev = pts
+
+	ord := make([]int, len(labs))
+	for i := range ord {
+		ord[i] = i
+	}
+	sort.Slice(ord, func(i, j int) bool { return labs[ord[i]] < labs[ord[j]] })
+	dt := rp.Time
+	shp := col.Shapes()[1:]
+	for _, li := range ord {
+		row := dt.Rows
+		dt.SetNumRows(row + 1)
+		dt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
+		dt.SetCellFloat("Epoch", row, float64(epc))
+		dt.SetCellString("Label", row, labs[li])
+		dt.SetCellString("Group", row, grps[li])
+		dt.SetCellFloat("X", row, pts[li][0])
+		dt.SetCellFloat("Y", row, pts[li][1])
+		dt.SetCellTensor("Act", row, etensor.NewFloat64Shape(etensor.NewShape(shp, nil, nil), acts[li]))
+	}
+	rp.TimePlot.GoUpdate()
+}
+
+// AlignPts rotates the 2D points in pts around the origin to best match
+// the corresponding points in ref in the least-squares sense (orthogonal
+// Procrustes), also reflecting them if that gives a better match
+func AlignPts(pts, ref [][]float64) {
+	bestFit := -1.0
+	var bestTh float64
+	var bestFlip bool
+	for _, flip := range []bool{false, true} {
+		var a, b float64
+		for i, p := range pts {
+			x, y := p[0], p[1]
+			if flip {
+				y = -y
+			}
+			a += x*ref[i][0] + y*ref[i][1]
+			b += x*ref[i][1] - y*ref[i][0]
+		}
+		// rotating by th gives a match (sum of dot products) of a cos(th) + b sin(th)
+		if fit := math.Hypot(a, b); fit > bestFit {
+			bestFit = fit
+			bestTh = math.Atan2(b, a)
+			bestFlip = flip
+		}
+	}
+	cs, sn := math.Cos(bestTh), math.Sin(bestTh)
+	for _, p := range pts {
+		x, y := p[0], p[1]
+		if bestFlip {
+			y = -y
+		}
+		p[0] = x*cs - y*sn
+		p[1] = x*sn + y*cs
+	}
+}
+
+// InitRepsTime resets the Time tables of all the representational analyses,
+// at the start of each run, so that the TimePlot only shows the current run
+func (ss *Sim) InitRepsTime() {
+	for _, rp := range []*Reps{&ss.HiddenRel, &ss.HiddenAgent, &ss.AgentAgent} {
+		rp.Time.SetNumRows(0)
+		rp.Prev = nil
+		rp.TimePlot.GoUpdate()
+	}
+}
+
+// SaveRepsTime saves the Time tables of all the representational analyses,
+// for given run, to family_trees_<name>_<run>.tsv, e.g.,
+// family_trees_agentagenttime_000.tsv
+func (ss *Sim) SaveRepsTime(run int) {
+	for _, rp := range []*Reps{&ss.HiddenRel, &ss.HiddenAgent, &ss.AgentAgent} {
+		fnm := fmt.Sprintf("family_trees_%s_%03d.tsv", strings.ToLower(rp.Time.MetaData["name"]), run)
+		if err := rp.Time.SaveCSV(gi.FileName(fnm), etable.Tab, etable.Headers); err != nil {
+			log.Println(err)
+		} else {
+			fmt.Printf("Saved %s\n", fnm)
+		}
+	}
+}
+
+// ConfigRepsTime configures the Time table of given representational
+// analysis, named nm, for the activity of layer lnm, and its TimePlot
+func (ss *Sim) ConfigRepsTime(rp *Reps, nm, lnm string) {
+	dt := rp.Time
+	dt.SetMetaData("name", nm+"Time")
+	dt.SetMetaData("desc", "Snapshots over training of the "+nm+" representations: centroid of the items for each label, projected onto the PCA components, and its activity pattern")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	ly := ss.Net.LayerByName(lnm).(leabra.LeabraLayer).AsLeabra()
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"Label", etensor.STRING, nil, nil},
+		{"Group", etensor.STRING, nil, nil},
+		{"X", etensor.FLOAT64, nil, nil},
+		{"Y", etensor.FLOAT64, nil, nil},
+		{"Act", etensor.FLOAT64, ly.Shp.Shp, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+
+	plt := rp.TimePlot
+	plt.Params.Title = "Family Trees Representations over Training: " + nm
+	plt.Params.XAxisCol = "X"
+	plt.Params.LegendCol = "Label"
+	plt.Params.Points = true
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Epoch", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("X", eplot.Off, eplot.FloatMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Y", eplot.On, eplot.FloatMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Act", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+}
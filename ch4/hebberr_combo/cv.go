@@ -0,0 +1,145 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/emer/etable/etable"
+	"github.com/goki/ki/kit"
+)
+
+// CVType is the type of cross-validation used to split the patterns
+// into training and testing sets for each run
+type CVType int32
+
+//go:generate stringer -type=CVType
+
+var KiT_CVType = kit.Enums.AddEnum(CVTypeN, kit.NotBitFlag, nil)
+
+func (ev CVType) MarshalJSON() ([]byte, error)  { return kit.EnumMarshalJSON(ev) }
+func (ev *CVType) UnmarshalJSON(b []byte) error { return kit.EnumUnmarshalJSON(ev, b) }
+
+const (
+	// NoCV is the standard behavior: Lines2 is randomly split 90% / 10%
+	// into training and testing for each run, and the other patterns are
+	// used for both training and testing
+	NoCV CVType = iota
+
+	// KFold splits the patterns into CV.K folds, and each run tests on one
+	// fold and trains on the rest, so that each pattern is tested exactly
+	// once over K runs -- this is repeated CV.NReps times with a different
+	// random assignment of patterns to folds
+	KFold
+
+	// RandomCV tests on a random CV.TestPct of the patterns and trains on
+	// the rest, with a different random split for each of CV.NReps runs
+	RandomCV
+
+	CVTypeN
+)
+
+// CVParams are the cross-validation parameters, for splitting any
+// of the patterns into training and testing sets for each run
+type CVParams struct {
+	Type    CVType  `desc:"type of cross-validation -- NoCV is the standard behavior of a single random split of Lines2 for each run"`
+	K       int     `viewif:"Type=KFold" def:"10" min:"2" desc:"number of folds for KFold -- limited to the number of patterns"`
+	TestPct float64 `viewif:"Type=RandomCV" def:"0.1" min:"0" max:"1" desc:"proportion of patterns used for testing in each RandomCV split -- must be between 0 and 1, and at least 1 pattern is used for testing and for training"`
+	NReps   int     `viewif:"Type!=NoCV" def:"1" min:"1" desc:"number of repetitions: each KFold rep is K runs, with a different random assignment of patterns to folds, and each RandomCV rep is one run"`
+}
+
+func (cv *CVParams) Defaults() {
+	cv.Type = NoCV
+	cv.K = 10
+	cv.TestPct = 0.1
+	cv.NReps = 1
+}
+
+// NFolds returns the number of folds (runs per rep) for given number of patterns
+func (cv *CVParams) NFolds(npats int) int {
+	if cv.Type != KFold {
+		return 1
+	}
+	k := cv.K
+	if k > npats {
+		k = npats
+	}
+	if k < 2 {
+		k = 2
+	}
+	return k
+}
+
+// NRuns returns the total number of runs for given number of patterns
+func (cv *CVParams) NRuns(npats int) int {
+	return cv.NReps * cv.NFolds(npats)
+}
+
+// RepFold returns the rep and fold for given run
+func (cv *CVParams) RepFold(run, npats int) (rep, fold int) {
+	nf := cv.NFolds(npats)
+	return run / nf, run % nf
+}
+
+// Name returns a name for the cross-validation type and params, or ""
+// for NoCV, for labeling the runs in the RunLog
+func (cv *CVParams) Name() string {
+	switch cv.Type {
+	case KFold:
+		return fmt.Sprintf("KFold%d", cv.K)
+	case RandomCV:
+		return fmt.Sprintf("RandomCV%g", cv.TestPct)
+	}
+	return ""
+}
+
+// Validate returns an error if the params cannot split the given number
+// of patterns into non-empty training and testing sets
+func (cv *CVParams) Validate(npats int) error {
+	if cv.Type == NoCV {
+		return nil
+	}
+	if npats < 2 {
+		return fmt.Errorf("CV: need at least 2 patterns to split into training and testing, have %d", npats)
+	}
+	if cv.Type == RandomCV && (cv.TestPct <= 0 || cv.TestPct >= 1) {
+		return fmt.Errorf("CV: TestPct must be between 0 and 1 (exclusive), is %g", cv.TestPct)
+	}
+	return nil
+}
+
+// Split returns the training and testing splits of the given patterns for
+// given run, using seed for the random order of the patterns, which is the
+// same for all the folds of a KFold rep.  It can be used for any table.
+// The RandomCV testing set has at least 1 pattern, and at most all but 1.
+func (cv *CVParams) Split(ix *etable.IdxView, run int, seed int64) (trn, tst *etable.IdxView) {
+	n := ix.Len()
+	rep, fold := cv.RepFold(run, n)
+	rnd := rand.New(rand.NewSource(seed + int64(rep)))
+	perm := make([]int, n)
+	for i, pi := range rnd.Perm(n) {
+		perm[i] = ix.Idxs[pi]
+	}
+	var st, ed int
+	switch cv.Type {
+	case KFold:
+		nf := cv.NFolds(n)
+		st, ed = fold*n/nf, (fold+1)*n/nf
+	default:
+		ed = int(cv.TestPct*float64(n) + 0.5)
+		if ed > n-1 {
+			ed = n - 1
+		}
+		if ed < 1 {
+			ed = 1
+		}
+	}
+	trn = etable.NewIdxView(ix.Table)
+	tst = etable.NewIdxView(ix.Table)
+	tst.Idxs = append([]int{}, perm[st:ed]...)
+	trn.Idxs = append(append([]int{}, perm[:st]...), perm[ed:]...)
+	return
+}
@@ -9,6 +9,7 @@ package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 	"log"
 	"math/rand"
@@ -17,6 +18,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/CompCogNeuro/sims/bench"
+	"github.com/CompCogNeuro/sims/bp"
+	"github.com/CompCogNeuro/sims/patfile"
 	"github.com/emer/emergent/emer"
 	"github.com/emer/emergent/env"
 	"github.com/emer/emergent/netview"
@@ -38,11 +42,22 @@ import (
 	"github.com/goki/mat32"
 )
 
-// this is the stub main for gogi that calls our actual mainrun function, at end of file
 func main() {
-	gimain.Main(func() {
-		mainrun()
-	})
+	TheSim.New()
+	TheSim.Config()
+	if len(os.Args) > 1 {
+		TheSim.CmdArgs() // simple assumption is that any args = no gui -- could add explicit arg if you want
+	} else {
+		gimain.Main(func() { // this starts gui -- requires valid OpenGL display connection (e.g., X11)
+			guirun()
+		})
+	}
+}
+
+func guirun() {
+	TheSim.Init()
+	win := TheSim.ConfigGui()
+	win.StartEventLoop()
 }
 
 // LogPrec is precision for saving float values in logs
@@ -88,6 +103,8 @@ const (
 	Hebbian LearnType = iota
 	ErrorDriven
 	ErrorHebbIn
+	Backprop
+
 	LearnTypeN
 )
 
@@ -175,6 +192,7 @@ var ParamSets = params.Sets{
 // for the fields which provide hints to how things should be displayed).
 type Sim struct {
 	Net          *leabra.Network   `view:"no-inline" desc:"the network -- click to view / edit parameters for layers, prjns, etc"`
+	BP           bp.Network        `view:"no-inline" desc:"backpropagation network with the same layer sizes as Net, which is used in its place when Learn is Backprop"`
 	Learn        LearnType         `desc:"select which type of learning to use"`
 	Pats         PatsType          `desc:"select which type of patterns to use"`
 	Easy         *etable.Table     `view:"no-inline" desc:"easy training patterns -- can be learned with Hebbian"`
@@ -182,10 +200,14 @@ type Sim struct {
 	Impossible   *etable.Table     `view:"no-inline" desc:"impossible training patterns -- require error-driven + hidden layer"`
 	Lines2       *etable.Table     `view:"no-inline" desc:"lines training patterns"`
 	TrnEpcLog    *etable.Table     `view:"no-inline" desc:"training epoch-level log data"`
-	TstEpcLog    *etable.Table     `view:"no-inline" desc:"testing epoch-level log data"`
+	TstEpcLog    *etable.Table     `view:"no-inline" desc:"testing epoch-level log data -- with the CV cross-validation on, this has the test epochs of all of its runs (folds), by Run"`
 	TstTrlLog    *etable.Table     `view:"no-inline" desc:"testing trial-level log data"`
 	RunLog       *etable.Table     `view:"no-inline" desc:"summary log of each run"`
 	RunStats     *etable.Table     `view:"no-inline" desc:"aggregate stats on all runs"`
+	CV           CVParams          `view:"inline" desc:"cross-validation of the training / testing split of the patterns over runs"`
+	Mix          MixParams         `view:"inline" desc:"parameters for the sweep over mixtures of Hebbian and error-driven learning -- see RunMixture"`
+	MixLog       *etable.Table     `view:"no-inline" desc:"results of each run of the mixture sweep"`
+	MixStats     *etable.Table     `view:"no-inline" desc:"averages over runs of each mixture in the mixture sweep"`
 	Params       params.Sets       `view:"no-inline" desc:"full collection of param sets"`
 	ParamSet     string            `view:"-" desc:"which set of *additional* parameters to use -- always applies Base and optionaly this next if set -- can use multiple names separated by spaces (don't put spaces in ParamSet names!)"`
 	MaxRuns      int               `desc:"maximum number of model runs to perform"`
@@ -194,6 +216,7 @@ type Sim struct {
 	TrainEnv     env.FixedTable    `desc:"Training environment -- contains everything about iterating over input / output patterns over training"`
 	TestEnv      env.FixedTable    `desc:"Testing environment -- manages iterating over testing"`
 	Time         leabra.Time       `desc:"leabra timing parameters and state"`
+	Bench        bench.Bench       `view:"no-inline" desc:"benchmark parameters and results -- see Benchmark"`
 	ViewOn       bool              `desc:"whether to update the network view while running"`
 	TrainUpdt    leabra.TimeScales `desc:"at what time scale to update the display during training?  Anything longer than Epoch updates at Epoch in this model"`
 	TestUpdt     leabra.TimeScales `desc:"at what time scale to update the display during testing?  Anything longer than Epoch updates at Epoch in this model"`
@@ -225,6 +248,7 @@ type Sim struct {
 	TstEpcPlot  *eplot.Plot2D               `view:"-" desc:"the testing epoch plot"`
 	TstTrlPlot  *eplot.Plot2D               `view:"-" desc:"the test-trial plot"`
 	RunPlot     *eplot.Plot2D               `view:"-" desc:"the run plot"`
+	MixPlot     *eplot.Plot2D               `view:"-" desc:"the mixture sweep plot"`
 	TrnEpcFile  *os.File                    `view:"-" desc:"log file"`
 	RunFile     *os.File                    `view:"-" desc:"log file"`
 	ValsTsrs    map[string]*etensor.Float32 `view:"-" desc:"for holding layer values"`
@@ -255,6 +279,8 @@ func (ss *Sim) New() {
 	ss.TstTrlLog = &etable.Table{}
 	ss.RunLog = &etable.Table{}
 	ss.RunStats = &etable.Table{}
+	ss.MixLog = &etable.Table{}
+	ss.MixStats = &etable.Table{}
 	ss.Params = ParamSets
 	ss.RndSeed = 1
 	ss.ViewOn = true
@@ -262,6 +288,10 @@ func (ss *Sim) New() {
 	ss.TestUpdt = leabra.Quarter
 	ss.TestInterval = 5
 	ss.LayStatNms = []string{"Input", "Output"}
+	ss.BP.Defaults()
+	ss.CV.Defaults()
+	ss.Mix.Defaults()
+	ss.Bench.Defaults("hebberr_combo")
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////
@@ -276,6 +306,8 @@ func (ss *Sim) Config() {
 	ss.ConfigTstEpcLog(ss.TstEpcLog)
 	ss.ConfigTstTrlLog(ss.TstTrlLog)
 	ss.ConfigRunLog(ss.RunLog)
+	ss.ConfigMixLog(ss.MixLog)
+	ss.ConfigMixStats(ss.MixStats)
 }
 
 func (ss *Sim) ConfigEnv() {
@@ -291,7 +323,7 @@ func (ss *Sim) ConfigEnv() {
 	ss.TrainEnv.Dsc = "training params and state"
 	ss.TrainEnv.Table = etable.NewIdxView(ss.Easy)
 	ss.TrainEnv.Validate()
-	ss.TrainEnv.Run.Max = ss.MaxRuns // note: we are not setting epoch max -- do that manually
+	ss.TrainEnv.Run.Max = ss.NRuns() // note: we are not setting epoch max -- do that manually
 
 	ss.TestEnv.Nm = "TestEnv"
 	ss.TestEnv.Dsc = "testing params and state"
@@ -303,7 +335,41 @@ func (ss *Sim) ConfigEnv() {
 	ss.TestEnv.Init(0)
 }
 
+// PatsTable returns the table of patterns for the current Pats
+func (ss *Sim) PatsTable() *etable.Table {
+	switch ss.Pats {
+	case Hard:
+		return ss.Hard
+	case Impossible:
+		return ss.Impossible
+	case Lines2:
+		return ss.Lines2
+	}
+	return ss.Easy
+}
+
+// NRuns returns the number of runs to perform: MaxRuns, or the number of
+// runs for all the folds and reps of the CV cross-validation if it is on
+func (ss *Sim) NRuns() int {
+	if ss.CV.Type == NoCV {
+		return ss.MaxRuns
+	}
+	return ss.CV.NRuns(ss.PatsTable().Rows)
+}
+
+// UpdateEnv sets the training and testing patterns for the current run,
+// using the CV cross-validation split if it is on -- if its params are not
+// valid, the problem is logged and the standard patterns are used
 func (ss *Sim) UpdateEnv() {
+	if ss.CV.Type != NoCV {
+		err := ss.CV.Validate(ss.PatsTable().Rows)
+		if err == nil {
+			all := etable.NewIdxView(ss.PatsTable())
+			ss.TrainEnv.Table, ss.TestEnv.Table = ss.CV.Split(all, ss.TrainEnv.Run.Cur, ss.RndSeed)
+			return
+		}
+		log.Println(err)
+	}
 	switch ss.Pats {
 	case Easy:
 		ss.TrainEnv.Table = etable.NewIdxView(ss.Easy)
@@ -350,6 +416,9 @@ func (ss *Sim) ConfigNet(net *leabra.Network) {
 		return
 	}
 	net.InitWts()
+	if err := ss.BP.ConfigFrom(net, "Input", "Hidden", "Output"); err != nil {
+		log.Println(err)
+	}
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -400,6 +469,14 @@ func (ss *Sim) UpdateView(train bool, cyc int) {
 // If train is true, then learning DWt or WtFmDWt calls are made.
 // Handles netview updating within scope of AlphaCycle
 func (ss *Sim) AlphaCyc(train bool) {
+	if ss.Learn == Backprop {
+		if err := ss.BP.LeabraTrial(ss.Net, train); err != nil {
+			log.Println(err)
+		} else if ss.ViewOn {
+			ss.UpdateView(train, -1)
+		}
+		return
+	}
 	// ss.Win.PollEvents() // this can be used instead of running in a separate goroutine
 	viewUpdt := ss.TrainUpdt
 	if !train {
@@ -525,12 +602,14 @@ func (ss *Sim) NewRun() {
 	ss.TestEnv.Init(run)
 	ss.Time.Reset()
 	ss.Net.InitWts()
+	ss.BP.InitWts()
 	ss.InitStats()
 	ss.TrnEpcLog.SetNumRows(0)
-	ss.TstEpcLog.SetNumRows(0)
+	if ss.CV.Type == NoCV || run == 0 { // keep the test epochs of all the CV folds
+		ss.TstEpcLog.SetNumRows(0)
+	}
 	ss.NeedsNewRun = false
-	ss.TrainEnv.Run.Max = ss.MaxRuns // note: we are not setting epoch max -- do that manually
-
+	ss.TrainEnv.Run.Max = ss.NRuns() // note: we are not setting epoch max -- do that manually
 }
 
 // InitStats initializes all the statistics, especially important for the
@@ -631,6 +710,11 @@ func (ss *Sim) Stopped() {
 	}
 }
 
+// Benchmark runs the Bench benchmark with TrainTrial (see bench.Bench.RunSim)
+func (ss *Sim) Benchmark() {
+	ss.Bench.RunSim(ss.Net, &ss.Time, ss.TrainTrial, ss.Init, ss.Stopped, &ss.NetView)
+}
+
 // SaveWeights saves the network weights -- when called with giv.CallMethod
 // it will auto-prompt for filename
 func (ss *Sim) SaveWeights(filename gi.FileName) {
@@ -788,6 +872,22 @@ func (ss *Sim) OpenPats() {
 	// err = ss.Lines2.OpenCSV("lines2out1.tsv", etable.Tab)
 }
 
+// OpenPatsFile opens patterns from given .tsv or .csv file in place of the
+// currently selected Pats, checking that they fit the Input and Output layers.
+// The network weights are not changed -- do Init to train on the new patterns.
+func (ss *Sim) OpenPatsFile(filename gi.FileName) error {
+	dt := ss.PatsTable()
+	err := patfile.Open(dt, string(filename), ss.Net, "Input", "Output")
+	if err != nil {
+		patfile.Report(ss.Win, err)
+		return err
+	}
+	ss.UpdateEnv()
+	ss.TrainEnv.Init(0)
+	ss.TestEnv.Init(0)
+	return nil
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////
 // 		Logging
 
@@ -1008,7 +1108,9 @@ func (ss *Sim) LogTstEpc(dt *etable.Table) {
 
 	// note: this shows how to use agg methods to compute summary data from another
 	// data table, instead of incrementing on the Sim
+	_, fold := ss.CV.RepFold(ss.TrainEnv.Run.Cur, ss.PatsTable().Rows)
 	dt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
+	dt.SetCellFloat("Fold", row, float64(fold))
 	dt.SetCellFloat("Epoch", row, float64(epc))
 	dt.SetCellFloat("SSE", row, agg.Sum(tix, "SSE")[0])
 	dt.SetCellFloat("AvgSSE", row, agg.Mean(tix, "AvgSSE")[0])
@@ -1028,6 +1130,7 @@ func (ss *Sim) ConfigTstEpcLog(dt *etable.Table) {
 
 	sch := etable.Schema{
 		{"Run", etensor.INT64, nil, nil},
+		{"Fold", etensor.INT64, nil, nil},
 		{"Epoch", etensor.INT64, nil, nil},
 		{"SSE", etensor.FLOAT64, nil, nil},
 		{"AvgSSE", etensor.FLOAT64, nil, nil},
@@ -1041,9 +1144,11 @@ func (ss *Sim) ConfigTstEpcLog(dt *etable.Table) {
 func (ss *Sim) ConfigTstEpcPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
 	plt.Params.Title = "Pattern Associator Testing Epoch Plot"
 	plt.Params.XAxisCol = "Epoch"
+	plt.Params.LegendCol = "Run" // one line for each CV fold
 	plt.SetTable(dt)
 	// order of params: on, fixMin, min, fixMax, max
 	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Fold", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
 	plt.SetColParams("Epoch", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
 	plt.SetColParams("SSE", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0) // default plot
 	plt.SetColParams("AvgSSE", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
@@ -1064,6 +1169,9 @@ func (ss *Sim) LogRun(dt *etable.Table) {
 
 	epclog := ss.TstEpcLog
 	epcix := etable.NewIdxView(epclog)
+	epcix.Filter(func(et *etable.Table, row int) bool {
+		return int(et.CellFloat("Run", row)) == run // has all the CV folds
+	})
 	// compute mean over last N epochs for run level
 	nlast := 5
 	if nlast > epcix.Len()-1 {
@@ -1072,8 +1180,14 @@ func (ss *Sim) LogRun(dt *etable.Table) {
 	epcix.Idxs = epcix.Idxs[epcix.Len()-nlast:]
 
 	params := ss.Learn.String() + "_" + ss.Pats.String()
+	if ss.CV.Type != NoCV {
+		params += "_" + ss.CV.Name()
+	}
+	rep, fold := ss.CV.RepFold(run, ss.PatsTable().Rows)
 
 	dt.SetCellFloat("Run", row, float64(run))
+	dt.SetCellFloat("Rep", row, float64(rep))
+	dt.SetCellFloat("Fold", row, float64(fold))
 	dt.SetCellString("Params", row, params)
 	dt.SetCellFloat("FirstZero", row, float64(ss.FirstZero))
 	dt.SetCellFloat("SSE", row, agg.Mean(epcix, "SSE")[0])
@@ -1086,6 +1200,7 @@ func (ss *Sim) LogRun(dt *etable.Table) {
 	spl := split.GroupBy(runix, []string{"Params"})
 	split.Desc(spl, "FirstZero")
 	split.Desc(spl, "PctCor")
+	split.Desc(spl, "PctErr") // Mean and Sem over runs = cross-validated test error
 	split.Desc(spl, "SSE")
 	ss.RunStats = spl.AggsToTable(etable.AddAggName)
 
@@ -1107,6 +1222,8 @@ func (ss *Sim) ConfigRunLog(dt *etable.Table) {
 
 	sch := etable.Schema{
 		{"Run", etensor.INT64, nil, nil},
+		{"Rep", etensor.INT64, nil, nil},
+		{"Fold", etensor.INT64, nil, nil},
 		{"Params", etensor.STRING, nil, nil},
 		{"FirstZero", etensor.FLOAT64, nil, nil},
 		{"SSE", etensor.FLOAT64, nil, nil},
@@ -1124,6 +1241,8 @@ func (ss *Sim) ConfigRunPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D
 	plt.SetTable(dt)
 	// order of params: on, fixMin, min, fixMax, max
 	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Rep", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Fold", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
 	plt.SetColParams("FirstZero", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 0) // default plot
 	plt.SetColParams("SSE", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
 	plt.SetColParams("AvgSSE", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
@@ -1192,6 +1311,9 @@ func (ss *Sim) ConfigGui() *gi.Window {
 	plt = tv.AddNewTab(eplot.KiT_Plot2D, "RunPlot").(*eplot.Plot2D)
 	ss.RunPlot = ss.ConfigRunPlot(plt, ss.RunLog)
 
+	plt = tv.AddNewTab(eplot.KiT_Plot2D, "MixPlot").(*eplot.Plot2D)
+	ss.MixPlot = ss.ConfigMixPlot(plt, ss.MixStats)
+
 	split.SetSplits(.2, .8)
 
 	tbar.AddAction(gi.ActOpts{Label: "Init", Icon: "update", Tooltip: "Initialize everything including network weights, and start over.  Also applies current params.", UpdateFunc: func(act *gi.Action) {
@@ -1298,6 +1420,21 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		}
 	})
 
+	tbar.AddAction(gi.ActOpts{Label: "Open Pats", Icon: "file-open", Tooltip: "Open your own patterns from a .tsv or .csv file, in place of the currently selected Pats -- the columns must fit the Input and Output layers."}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			giv.CallMethod(ss, "OpenPatsFile", vp)
+		})
+
+	tbar.AddAction(gi.ActOpts{Label: "Mixture Sweep", Icon: "fast-fwd", Tooltip: "Trains Mix.NSeeds runs on the Lines2 patterns for each mixture of error-driven (MLrn) and Hebbian (LLrn = 1 - MLrn) learning and AvgL.Gain in Mix, recording generalization error on the held-out test patterns and Hidden representation quality in the MixLog, with averages in MixStats and MixPlot.  Does Init at the end.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		if !ss.IsRunning {
+			ss.IsRunning = true
+			tbar.UpdateActions()
+			go ss.RunMixture()
+		}
+	})
+
 	tbar.AddSeparator("log")
 
 	tbar.AddAction(gi.ActOpts{Label: "Reset RunLog", Icon: "update", Tooltip: "Reset the accumulated log of all Runs, which are tagged with the ParamSet used"}, win.This(),
@@ -1313,6 +1450,8 @@ func (ss *Sim) ConfigGui() *gi.Window {
 			ss.NewRndSeed()
 		})
 
+	bench.AddAction(tbar, win, &ss.IsRunning, ss.Benchmark)
+
 	tbar.AddAction(gi.ActOpts{Label: "README", Icon: "file-markdown", Tooltip: "Opens your browser on the README file that contains instructions for how to run this model."}, win.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			gi.OpenURL("https://github.com/CompCogNeuro/sims/blob/master/ch4/hebberr_combo/README.md")
@@ -1393,6 +1532,20 @@ func (ss *Sim) ConfigGui() *gi.Window {
 // These props register Save methods so they can be used
 var SimProps = ki.Props{
 	"CallMethods": ki.PropSlice{
+		{"Benchmark", bench.MethodProps},
+		{"RunMixture", ki.Props{
+			"desc": "runs the sweep over mixtures of Hebbian and error-driven learning in Mix, recording the results in MixLog and MixStats -- does Init at the end",
+			"icon": "fast-fwd",
+		}},
+		{"OpenPatsFile", ki.Props{
+			"desc": "open patterns from .tsv or .csv file, replacing the current ones",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".tsv,.csv",
+				}},
+			},
+		}},
 		{"SaveWeights", ki.Props{
 			"desc": "save network weights to file",
 			"icon": "file-save",
@@ -1405,11 +1558,99 @@ var SimProps = ki.Props{
 	},
 }
 
-func mainrun() {
-	TheSim.New()
-	TheSim.Config()
+// CmdArgs runs the sim without the gui, according to the command-line
+// args: -bench runs the benchmark (with -benchthreads and -benchfile, see
+// Bench), -mixture runs the mixture sweep (see MixArgs), and otherwise it
+// does the standard training, saving the training epoch and run logs as
+// it goes, and the RunStats at the end, to hebberr_combo_*.tsv files
+func (ss *Sim) CmdArgs() {
+	var nogui bool
+	var saveEpcLog bool
+	var saveRunLog bool
+	var mixture bool
+	var learn string
+	var pats string
+	var cv string
+	flag.StringVar(&ss.ParamSet, "params", "", "ParamSet name to use -- must be valid name as listed in compiled-in params or loaded params")
+	flag.IntVar(&ss.MaxRuns, "runs", ss.MaxRuns, "number of runs to do when CV is off (with CV on, it is all of its folds)")
+	flag.StringVar(&learn, "learn", ss.Learn.String(), "type of learning to use: Hebbian, ErrorDriven, ErrorHebbIn or Backprop -- the log files are named by it, so that the learning curves of the different types can be overlaid")
+	flag.StringVar(&pats, "pats", ss.Pats.String(), "training patterns to use: Easy, Hard, Impossible or Lines2")
+	flag.StringVar(&cv, "cv", ss.CV.Type.String(), "type of cross-validation of the training / testing split: NoCV, KFold or RandomCV")
+	flag.IntVar(&ss.CV.K, "k", ss.CV.K, "number of folds for -cv KFold")
+	flag.Float64Var(&ss.CV.TestPct, "testpct", ss.CV.TestPct, "proportion of patterns used for testing in each -cv RandomCV split")
+	flag.IntVar(&ss.CV.NReps, "cvreps", ss.CV.NReps, "number of repetitions of the -cv KFold folds or RandomCV splits")
+	flag.BoolVar(&saveEpcLog, "epclog", true, "if true, save train epoch log to file")
+	flag.BoolVar(&saveRunLog, "runlog", true, "if true, save run log to file")
+	flag.BoolVar(&mixture, "mixture", false, "run the sweep over mixtures of Hebbian and error-driven learning (see RunMixture) instead of the standard training")
+	flag.BoolVar(&nogui, "nogui", true, "if not passing any other args and want to run nogui, use nogui")
+	ss.Bench.AddFlags()
+	flag.Parse()
+	if err := ss.Learn.FromString(learn); err != nil {
+		log.Println(err)
+		return
+	}
+	if err := ss.Pats.FromString(pats); err != nil {
+		log.Println(err)
+		return
+	}
+	if err := ss.CV.Type.FromString(cv); err != nil {
+		log.Println(err)
+		return
+	}
+	if err := ss.CV.Validate(ss.PatsTable().Rows); err != nil {
+		log.Println(err)
+		return
+	}
+	ss.Init()
+	if bench.Flagged() {
+		ss.Benchmark()
+		return
+	}
+	if mixture {
+		ss.MixArgs()
+		return
+	}
 
-	TheSim.Init()
-	win := TheSim.ConfigGui()
-	win.StartEventLoop()
+	if ss.ParamSet != "" {
+		fmt.Printf("Using ParamSet: %s\n", ss.ParamSet)
+	}
+	fnm := "hebberr_combo_" + ss.Learn.String() + "_" + ss.Pats.String()
+	if ss.CV.Type != NoCV {
+		fnm += "_" + ss.CV.Type.String()
+	}
+	if saveEpcLog {
+		var err error
+		ss.TrnEpcFile, err = os.Create(fnm + "_epc.tsv")
+		if err != nil {
+			log.Println(err)
+			ss.TrnEpcFile = nil
+		} else {
+			fmt.Printf("Saving epoch log to: %s\n", fnm+"_epc.tsv")
+			defer ss.TrnEpcFile.Close()
+		}
+	}
+	if saveRunLog {
+		var err error
+		ss.RunFile, err = os.Create(fnm + "_run.tsv")
+		if err != nil {
+			log.Println(err)
+			ss.RunFile = nil
+		} else {
+			fmt.Printf("Saving run log to: %s\n", fnm+"_run.tsv")
+			defer ss.RunFile.Close()
+		}
+	}
+	fmt.Printf("Running %d Runs\n", ss.NRuns())
+	ss.Train()
+	SaveLog(ss.RunStats, fnm+"_runstats.tsv")
+}
+
+// SaveLog saves given log table to given .tsv file, for the runs without
+// the gui
+func SaveLog(dt *etable.Table, fnm string) {
+	if err := dt.SaveCSV(gi.FileName(fnm), etable.Tab, etable.Headers); err != nil {
+		log.Println(err)
+	} else {
+		fmt.Printf("Saved %s\n", fnm)
+	}
 }
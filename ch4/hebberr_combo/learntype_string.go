@@ -2,7 +2,12 @@
 
 package main
 
-import "strconv"
+import (
+	"errors"
+	"strconv"
+)
+
+var _ = errors.New("dummy error")
 
 func _() {
 	// An "invalid array index" compiler error signifies that the constant values have changed.
@@ -11,12 +16,13 @@ func _() {
 	_ = x[Hebbian-0]
 	_ = x[ErrorDriven-1]
 	_ = x[ErrorHebbIn-2]
-	_ = x[LearnTypeN-3]
+	_ = x[Backprop-3]
+	_ = x[LearnTypeN-4]
 }
 
-const _LearnType_name = "HebbianErrorDrivenErrorHebbInLearnTypeN"
+const _LearnType_name = "HebbianErrorDrivenErrorHebbInBackpropLearnTypeN"
 
-var _LearnType_index = [...]uint8{0, 7, 18, 29, 39}
+var _LearnType_index = [...]uint8{0, 7, 18, 29, 37, 47}
 
 func (i LearnType) String() string {
 	if i < 0 || i >= LearnType(len(_LearnType_index)-1) {
@@ -24,3 +30,13 @@ func (i LearnType) String() string {
 	}
 	return _LearnType_name[_LearnType_index[i]:_LearnType_index[i+1]]
 }
+
+func (i *LearnType) FromString(s string) error {
+	for j := 0; j < len(_LearnType_index)-1; j++ {
+		if s == _LearnType_name[_LearnType_index[j]:_LearnType_index[j+1]] {
+			*i = LearnType(j)
+			return nil
+		}
+	}
+	return errors.New("String: " + s + " is not a valid option for type: LearnType")
+}
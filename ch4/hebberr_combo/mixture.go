@@ -0,0 +1,338 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/emer/emergent/emer"
+	"github.com/emer/emergent/params"
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+)
+
+// MixParams are the parameters for the sweep over mixtures of Hebbian
+// and error-driven learning
+type MixParams struct {
+	MLrns  []float32 `desc:"values of Prjn.Learn.XCal.MLrn (the proportion of error-driven learning) to test -- LLrn (the proportion of Hebbian learning) is set to 1 - MLrn"`
+	Gains  []float32 `desc:"values of Layer.Learn.AvgL.Gain to test, which sets the level of the Hebbian floating threshold relative to the unit's activity"`
+	NSeeds int       `def:"5" min:"1" desc:"number of random seeds (runs) for each mixture -- each has a different random 90% train / 10% test split of the Lines2 patterns"`
+	NEpcs  int       `def:"50" min:"1" desc:"number of training epochs for each run"`
+}
+
+func (mp *MixParams) Defaults() {
+	mp.MLrns = []float32{0, 0.25, 0.5, 0.75, 1}
+	mp.Gains = []float32{2, 3}
+	mp.NSeeds = 5
+	mp.NEpcs = 50
+}
+
+// SetMix sets the learning params for given mixture of error-driven
+// (mlrn) and Hebbian (1 - mlrn) learning in all projections, and given
+// AvgL.Gain in all layers
+func (ss *Sim) SetMix(mlrn, gain float32) {
+	sheet := &params.Sheet{
+		{Sel: "Prjn", Desc: "mixture of error-driven and Hebbian learning",
+			Params: params.Params{
+				"Prjn.Learn.XCal.MLrn":    fmt.Sprint(mlrn),
+				"Prjn.Learn.XCal.SetLLrn": "true",
+				"Prjn.Learn.XCal.LLrn":    fmt.Sprint(1 - mlrn),
+			}},
+		{Sel: "Layer", Desc: "Hebbian floating threshold gain",
+			Params: params.Params{
+				"Layer.Learn.AvgL.Gain": fmt.Sprint(gain),
+			}},
+	}
+	ss.Net.ApplyParams(sheet, false)
+}
+
+// MixTrain trains the network for Mix.NEpcs epochs on the TrainEnv,
+// without logging, and returns the proportion of errors in the last epoch
+func (ss *Sim) MixTrain() float64 {
+	out := ss.Net.LayerByName("Output")
+	ntrl := ss.TrainEnv.Table.Len()
+	errs := 0.0
+	for epc := 0; epc < ss.Mix.NEpcs && !ss.StopNow; epc++ {
+		errs = 0
+		for trl := 0; trl < ntrl; trl++ {
+			ss.TrainEnv.Step()
+			out.SetType(emer.Target)
+			ss.ApplyInputs(&ss.TrainEnv)
+			ss.AlphaCyc(true)
+			ss.TrialStats(false)
+			errs += ss.TrlErr
+		}
+	}
+	return errs / float64(ntrl)
+}
+
+// MixTest tests each of the items in the TestEnv, which is the held-out
+// test split for the Lines2 patterns, and returns the proportion of errors
+func (ss *Sim) MixTest() float64 {
+	n := ss.TestEnv.Table.Len()
+	errs := 0.0
+	for i := 0; i < n; i++ {
+		ss.TestItem(i)
+		errs += ss.TrlErr
+	}
+	return errs / float64(n)
+}
+
+// UniqHidPats tests all of the Lines2 patterns, and returns the proportion
+// of them that have a unique pattern of Hidden activity (units above .5)
+func (ss *Sim) UniqHidPats() float64 {
+	tst := ss.TestEnv.Table
+	ss.TestEnv.Table = etable.NewIdxView(ss.Lines2)
+	ss.TestEnv.Validate()
+	hid := ss.Net.LayerByName("Hidden").(leabra.LeabraLayer).AsLeabra()
+	n := ss.TestEnv.Table.Len()
+	pats := make(map[string]int)
+	for i := 0; i < n; i++ {
+		ss.TestItem(i)
+		pat := make([]byte, len(hid.Neurons))
+		for ni := range hid.Neurons {
+			pat[ni] = '0'
+			if hid.Neurons[ni].ActM > 0.5 {
+				pat[ni] = '1'
+			}
+		}
+		pats[string(pat)]++
+	}
+	ss.TestEnv.Table = tst
+	ss.TestEnv.Validate()
+	uniq := 0
+	for _, cnt := range pats {
+		if cnt == 1 {
+			uniq++
+		}
+	}
+	return float64(uniq) / float64(n)
+}
+
+// WtTopo returns the weight topography of the Input to Hidden weights:
+// the correlation between the weights from neighboring Input units
+// (horizontally and vertically), pooled over all Hidden units -- this is
+// high when the hidden units have smooth, line-like receptive fields, and
+// near 0 when their weights are unstructured
+func (ss *Sim) WtTopo() float64 {
+	inp := ss.Net.LayerByName("Input").(leabra.LeabraLayer).AsLeabra()
+	hid := ss.Net.LayerByName("Hidden").(leabra.LeabraLayer).AsLeabra()
+	var pj *leabra.Prjn
+	for _, p := range hid.RcvPrjns {
+		if p.SendLay().Name() == "Input" {
+			pj = p.AsLeabra()
+		}
+	}
+	if pj == nil {
+		return 0
+	}
+	ny, nx := inp.Shp.Dim(0), inp.Shp.Dim(1)
+	var sa, sb, saa, sbb, sab, n float64
+	pair := func(a, b float64) {
+		sa += a
+		sb += b
+		saa += a * a
+		sbb += b * b
+		sab += a * b
+		n++
+	}
+	for ri := range hid.Neurons {
+		wt := func(y, x int) float64 { return float64(pj.SynVal("Wt", y*nx+x, ri)) }
+		for y := 0; y < ny; y++ {
+			for x := 0; x < nx; x++ {
+				if x+1 < nx {
+					pair(wt(y, x), wt(y, x+1))
+				}
+				if y+1 < ny {
+					pair(wt(y, x), wt(y+1, x))
+				}
+			}
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	cov := sab/n - (sa/n)*(sb/n)
+	va := saa/n - (sa/n)*(sa/n)
+	vb := sbb/n - (sb/n)*(sb/n)
+	if va <= 0 || vb <= 0 {
+		return 0
+	}
+	return cov / math.Sqrt(va*vb)
+}
+
+// RunMixture runs the sweep over mixtures of Hebbian and error-driven
+// learning: for each of the Mix.Gains and Mix.MLrns, trains Mix.NSeeds
+// runs on the Lines2 patterns (with a different random train / test split
+// for each seed), recording the training and generalization (test split)
+// error, and the representation quality of the Hidden layer (UniqHidPats,
+// WtTopo), in the MixLog, with the averages for each mixture in MixStats.
+// The learning params and patterns are restored at the end.
+func (ss *Sim) RunMixture() {
+	nv := ss.NetView
+	ss.NetView = nil
+	ss.StopNow = false
+	learn, pats, cv := ss.Learn, ss.Pats, ss.CV.Type
+	ss.Learn, ss.Pats = ErrorDriven, Lines2 // base params, with Output inhib
+	ss.CV.Type = NoCV                       // new random split for each seed
+	mp := &ss.Mix
+	dt := ss.MixLog
+	dt.SetNumRows(0)
+	ss.MixStats.SetNumRows(0)
+	for _, gain := range mp.Gains {
+		for _, mlrn := range mp.MLrns {
+			for seed := 0; seed < mp.NSeeds; seed++ {
+				rand.Seed(int64(seed + 1))
+				ss.SetParams("", false)
+				ss.SetMix(mlrn, gain)
+				ss.NewRun()
+				trnErr := ss.MixTrain()
+				if ss.StopNow {
+					break
+				}
+				ss.LogMix(dt, gain, mlrn, seed, trnErr, ss.MixTest(), ss.UniqHidPats(), ss.WtTopo())
+			}
+			if ss.StopNow {
+				break
+			}
+			ss.LogMixStats(ss.MixStats, dt, gain, mlrn)
+		}
+		if ss.StopNow {
+			break
+		}
+	}
+	ss.Learn, ss.Pats, ss.CV.Type = learn, pats, cv
+	ss.NetView = nv
+	ss.Init()
+	ss.Stopped()
+}
+
+// MixArgs runs the mixture sweep without the gui, and saves the MixLog
+// and MixStats to hebberr_combo_mixlog.tsv and hebberr_combo_mixstats.tsv
+func (ss *Sim) MixArgs() {
+	ss.RunMixture()
+	for _, dt := range []*etable.Table{ss.MixLog, ss.MixStats} {
+		SaveLog(dt, "hebberr_combo_"+strings.ToLower(dt.MetaData["name"])+".tsv")
+	}
+}
+
+//////////////////////////////////////////////
+//  MixLog
+
+// LogMix adds a row to the MixLog with the results for one run of given mixture
+func (ss *Sim) LogMix(dt *etable.Table, gain, mlrn float32, seed int, trnErr, tstErr, uniq, topo float64) {
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellString("Gain", row, fmt.Sprintf("Gain=%g", gain))
+	dt.SetCellFloat("AvgLGain", row, float64(gain))
+	dt.SetCellFloat("MLrn", row, float64(mlrn))
+	dt.SetCellFloat("LLrn", row, float64(1-mlrn))
+	dt.SetCellFloat("Seed", row, float64(seed))
+	dt.SetCellFloat("TrnPctErr", row, trnErr)
+	dt.SetCellFloat("TstPctErr", row, tstErr)
+	dt.SetCellFloat("UniqPats", row, uniq)
+	dt.SetCellFloat("WtTopo", row, topo)
+}
+
+func (ss *Sim) ConfigMixLog(dt *etable.Table) {
+	dt.SetMetaData("name", "MixLog")
+	dt.SetMetaData("desc", "Training and generalization error and Hidden representation quality for each run of each mixture of Hebbian and error-driven learning")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Gain", etensor.STRING, nil, nil},
+		{"AvgLGain", etensor.FLOAT64, nil, nil},
+		{"MLrn", etensor.FLOAT64, nil, nil},
+		{"LLrn", etensor.FLOAT64, nil, nil},
+		{"Seed", etensor.INT64, nil, nil},
+		{"TrnPctErr", etensor.FLOAT64, nil, nil},
+		{"TstPctErr", etensor.FLOAT64, nil, nil},
+		{"UniqPats", etensor.FLOAT64, nil, nil},
+		{"WtTopo", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+// LogMixStats adds a row to the MixStats with the averages over the seeds
+// in the MixLog for given mixture
+func (ss *Sim) LogMixStats(dt, mix *etable.Table, gain, mlrn float32) {
+	cols := []string{"TrnPctErr", "TstPctErr", "UniqPats", "WtTopo"}
+	sums := make([]float64, len(cols))
+	var sumSq, n float64
+	for r := 0; r < mix.Rows; r++ {
+		if float32(mix.CellFloat("AvgLGain", r)) != gain || float32(mix.CellFloat("MLrn", r)) != mlrn {
+			continue
+		}
+		for ci, cn := range cols {
+			sums[ci] += mix.CellFloat(cn, r)
+		}
+		tst := mix.CellFloat("TstPctErr", r)
+		sumSq += tst * tst
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellString("Gain", row, fmt.Sprintf("Gain=%g", gain))
+	dt.SetCellFloat("AvgLGain", row, float64(gain))
+	dt.SetCellFloat("MLrn", row, float64(mlrn))
+	dt.SetCellFloat("N", row, n)
+	for ci, cn := range cols {
+		dt.SetCellFloat(cn, row, sums[ci]/n)
+	}
+	mean := sums[1] / n
+	dt.SetCellFloat("TstPctErrSEM", row, math.Sqrt(math.Max(sumSq/n-mean*mean, 0)/n))
+	if ss.MixPlot != nil {
+		ss.MixPlot.GoUpdate()
+	}
+}
+
+func (ss *Sim) ConfigMixStats(dt *etable.Table) {
+	dt.SetMetaData("name", "MixStats")
+	dt.SetMetaData("desc", "Averages over seeds of the MixLog for each mixture of Hebbian and error-driven learning")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Gain", etensor.STRING, nil, nil},
+		{"AvgLGain", etensor.FLOAT64, nil, nil},
+		{"MLrn", etensor.FLOAT64, nil, nil},
+		{"N", etensor.FLOAT64, nil, nil},
+		{"TrnPctErr", etensor.FLOAT64, nil, nil},
+		{"TstPctErr", etensor.FLOAT64, nil, nil},
+		{"TstPctErrSEM", etensor.FLOAT64, nil, nil},
+		{"UniqPats", etensor.FLOAT64, nil, nil},
+		{"WtTopo", etensor.FLOAT64, nil, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigMixPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "Hebbian / Error-driven Mixture Plot"
+	plt.Params.XAxisCol = "MLrn"
+	plt.Params.LegendCol = "Gain"
+	plt.Params.Points = true
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Gain", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("AvgLGain", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("MLrn", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("N", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("TrnPctErr", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("TstPctErr", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("TstPctErrSEM", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("UniqPats", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("WtTopo", eplot.Off, eplot.FloatMin, 0, eplot.FixMax, 1)
+	return plt
+}
@@ -2,7 +2,12 @@
 
 package main
 
-import "strconv"
+import (
+	"errors"
+	"strconv"
+)
+
+var _ = errors.New("dummy error")
 
 func _() {
 	// An "invalid array index" compiler error signifies that the constant values have changed.
@@ -25,3 +30,13 @@ func (i PatsType) String() string {
 	}
 	return _PatsType_name[_PatsType_index[i]:_PatsType_index[i+1]]
 }
+
+func (i *PatsType) FromString(s string) error {
+	for j := 0; j < len(_PatsType_index)-1; j++ {
+		if s == _PatsType_name[_PatsType_index[j]:_PatsType_index[j+1]] {
+			*i = PatsType(j)
+			return nil
+		}
+	}
+	return errors.New("String: " + s + " is not a valid option for type: PatsType")
+}
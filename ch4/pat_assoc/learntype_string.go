@@ -9,9 +9,9 @@ import (
 
 var _ = errors.New("dummy error")
 
-const _LearnType_name = "HebbianErrorDrivenLearnTypeN"
+const _LearnType_name = "HebbianErrorDrivenBackpropLearnTypeN"
 
-var _LearnType_index = [...]uint8{0, 7, 18, 28}
+var _LearnType_index = [...]uint8{0, 7, 18, 26, 36}
 
 func (i LearnType) String() string {
 	if i < 0 || i >= LearnType(len(_LearnType_index)-1) {
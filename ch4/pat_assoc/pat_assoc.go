@@ -11,6 +11,7 @@ package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 	"log"
 	"math/rand"
@@ -19,6 +20,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/CompCogNeuro/sims/bench"
+	"github.com/CompCogNeuro/sims/bp"
+	"github.com/CompCogNeuro/sims/patfile"
 	"github.com/emer/emergent/emer"
 	"github.com/emer/emergent/env"
 	"github.com/emer/emergent/netview"
@@ -39,11 +43,22 @@ import (
 	"github.com/goki/mat32"
 )
 
-// this is the stub main for gogi that calls our actual mainrun function, at end of file
 func main() {
-	gimain.Main(func() {
-		mainrun()
-	})
+	TheSim.New()
+	TheSim.Config()
+	if len(os.Args) > 1 {
+		TheSim.CmdArgs() // simple assumption is that any args = no gui -- could add explicit arg if you want
+	} else {
+		gimain.Main(func() { // this starts gui -- requires valid OpenGL display connection (e.g., X11)
+			guirun()
+		})
+	}
+}
+
+func guirun() {
+	TheSim.Init()
+	win := TheSim.ConfigGui()
+	win.StartEventLoop()
 }
 
 // LogPrec is precision for saving float values in logs
@@ -85,6 +100,8 @@ func (ev *LearnType) UnmarshalJSON(b []byte) error { return kit.EnumUnmarshalJSO
 const (
 	Hebbian LearnType = iota
 	ErrorDriven
+	Backprop
+
 	LearnTypeN
 )
 
@@ -138,6 +155,7 @@ var ParamSets = params.Sets{
 // for the fields which provide hints to how things should be displayed).
 type Sim struct {
 	Net          *leabra.Network   `view:"no-inline" desc:"the network -- click to view / edit parameters for layers, prjns, etc"`
+	BP           bp.Network        `view:"no-inline" desc:"backpropagation network with the same layer sizes as Net, which is used in its place when Learn is Backprop"`
 	Learn        LearnType         `desc:"select which type of learning to use"`
 	Pats         PatsType          `desc:"select which type of patterns to use"`
 	Easy         *etable.Table     `view:"no-inline" desc:"easy training patterns -- can be learned with Hebbian"`
@@ -156,6 +174,7 @@ type Sim struct {
 	TrainEnv     env.FixedTable    `desc:"Training environment -- contains everything about iterating over input / output patterns over training"`
 	TestEnv      env.FixedTable    `desc:"Testing environment -- manages iterating over testing"`
 	Time         leabra.Time       `desc:"leabra timing parameters and state"`
+	Bench        bench.Bench       `view:"no-inline" desc:"benchmark parameters and results -- see Benchmark"`
 	ViewOn       bool              `desc:"whether to update the network view while running"`
 	TrainUpdt    leabra.TimeScales `desc:"at what time scale to update the display during training?  Anything longer than Epoch updates at Epoch in this model"`
 	TestUpdt     leabra.TimeScales `desc:"at what time scale to update the display during testing?  Anything longer than Epoch updates at Epoch in this model"`
@@ -221,6 +240,8 @@ func (ss *Sim) New() {
 	ss.TestUpdt = leabra.Quarter
 	ss.TestInterval = 5
 	ss.LayStatNms = []string{"Input", "Output"}
+	ss.BP.Defaults()
+	ss.Bench.Defaults("pat_assoc")
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////
@@ -291,6 +312,9 @@ func (ss *Sim) ConfigNet(net *leabra.Network) {
 		return
 	}
 	net.InitWts()
+	if err := ss.BP.ConfigFrom(net, "Input", "Output"); err != nil {
+		log.Println(err)
+	}
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -344,6 +368,14 @@ func (ss *Sim) UpdateView(train bool, cyc int) {
 // If train is true, then learning DWt or WtFmDWt calls are made.
 // Handles netview updating within scope of AlphaCycle
 func (ss *Sim) AlphaCyc(train bool) {
+	if ss.Learn == Backprop {
+		if err := ss.BP.LeabraTrial(ss.Net, train); err != nil {
+			log.Println(err)
+		} else if ss.ViewOn {
+			ss.UpdateView(train, -1)
+		}
+		return
+	}
 	// ss.Win.PollEvents() // this can be used instead of running in a separate goroutine
 	viewUpdt := ss.TrainUpdt
 	if !train {
@@ -468,6 +500,7 @@ func (ss *Sim) NewRun() {
 	ss.TestEnv.Init(run)
 	ss.Time.Reset()
 	ss.Net.InitWts()
+	ss.BP.InitWts()
 	ss.InitStats()
 	ss.TrnEpcLog.SetNumRows(0)
 	ss.TstEpcLog.SetNumRows(0)
@@ -572,6 +605,11 @@ func (ss *Sim) Stopped() {
 	}
 }
 
+// Benchmark runs the Bench benchmark with TrainTrial (see bench.Bench.RunSim)
+func (ss *Sim) Benchmark() {
+	ss.Bench.RunSim(ss.Net, &ss.Time, ss.TrainTrial, ss.Init, ss.Stopped, &ss.NetView)
+}
+
 // SaveWeights saves the network weights -- when called with giv.CallMethod
 // it will auto-prompt for filename
 func (ss *Sim) SaveWeights(filename gi.FileName) {
@@ -725,6 +763,30 @@ func (ss *Sim) OpenPats() {
 	// err = ss.Impossible.OpenCSV("impossible.tsv", etable.Tab)
 }
 
+// OpenPatsFile opens patterns from given .tsv or .csv file in place of the
+// currently selected Pats, checking that they fit the Input and Output layers.
+// The network weights are not changed -- do Init to train on the new patterns.
+func (ss *Sim) OpenPatsFile(filename gi.FileName) error {
+	var dt *etable.Table
+	switch ss.Pats {
+	case Easy:
+		dt = ss.Easy
+	case Hard:
+		dt = ss.Hard
+	case Impossible:
+		dt = ss.Impossible
+	}
+	err := patfile.Open(dt, string(filename), ss.Net, "Input", "Output")
+	if err != nil {
+		patfile.Report(ss.Win, err)
+		return err
+	}
+	ss.UpdateEnv()
+	ss.TrainEnv.Init(0)
+	ss.TestEnv.Init(0)
+	return nil
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////
 // 		Logging
 
@@ -1234,6 +1296,11 @@ layers. See <a href="https://github.com/CompCogNeuro/sims/blob/master/ch4/pat_as
 		}
 	})
 
+	tbar.AddAction(gi.ActOpts{Label: "Open Pats", Icon: "file-open", Tooltip: "Open your own patterns from a .tsv or .csv file, in place of the currently selected Pats -- the columns must fit the Input and Output layers."}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			giv.CallMethod(ss, "OpenPatsFile", vp)
+		})
+
 	tbar.AddSeparator("log")
 
 	tbar.AddAction(gi.ActOpts{Label: "Reset RunLog", Icon: "update", Tooltip: "Reset the accumulated log of all Runs, which are tagged with the ParamSet used"}, win.This(),
@@ -1249,6 +1316,8 @@ layers. See <a href="https://github.com/CompCogNeuro/sims/blob/master/ch4/pat_as
 			ss.NewRndSeed()
 		})
 
+	bench.AddAction(tbar, win, &ss.IsRunning, ss.Benchmark)
+
 	tbar.AddAction(gi.ActOpts{Label: "README", Icon: "file-markdown", Tooltip: "Opens your browser on the README file that contains instructions for how to run this model."}, win.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			gi.OpenURL("https://github.com/CompCogNeuro/sims/blob/master/ch4/pat_assoc/README.md")
@@ -1329,6 +1398,16 @@ layers. See <a href="https://github.com/CompCogNeuro/sims/blob/master/ch4/pat_as
 // These props register Save methods so they can be used
 var SimProps = ki.Props{
 	"CallMethods": ki.PropSlice{
+		{"Benchmark", bench.MethodProps},
+		{"OpenPatsFile", ki.Props{
+			"desc": "open patterns from .tsv or .csv file, replacing the current ones",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".tsv,.csv",
+				}},
+			},
+		}},
 		{"SaveWeights", ki.Props{
 			"desc": "save network weights to file",
 			"icon": "file-save",
@@ -1341,11 +1420,73 @@ var SimProps = ki.Props{
 	},
 }
 
-func mainrun() {
-	TheSim.New()
-	TheSim.Config()
+func (ss *Sim) CmdArgs() {
+	var nogui bool
+	var saveEpcLog bool
+	var saveRunLog bool
+	var learn string
+	var pats string
+	flag.StringVar(&ss.ParamSet, "params", "", "ParamSet name to use -- must be valid name as listed in compiled-in params or loaded params")
+	flag.IntVar(&ss.MaxRuns, "runs", ss.MaxRuns, "number of runs to do")
+	flag.StringVar(&learn, "learn", ss.Learn.String(), "type of learning to use: Hebbian, ErrorDriven or Backprop -- the log files are named by it, so that the learning curves of the different types can be overlaid")
+	flag.StringVar(&pats, "pats", ss.Pats.String(), "training patterns to use: Easy, Hard or Impossible")
+	flag.BoolVar(&saveEpcLog, "epclog", true, "if true, save train epoch log to file")
+	flag.BoolVar(&saveRunLog, "runlog", true, "if true, save run log to file")
+	flag.BoolVar(&nogui, "nogui", true, "if not passing any other args and want to run nogui, use nogui")
+	ss.Bench.AddFlags()
+	flag.Parse()
+	if err := ss.Learn.FromString(learn); err != nil {
+		log.Println(err)
+		return
+	}
+	if err := ss.Pats.FromString(pats); err != nil {
+		log.Println(err)
+		return
+	}
+	ss.TrainEnv.Run.Max = ss.MaxRuns
+	ss.Init()
+	if bench.Flagged() {
+		ss.Benchmark()
+		return
+	}
 
-	TheSim.Init()
-	win := TheSim.ConfigGui()
-	win.StartEventLoop()
+	if ss.ParamSet != "" {
+		fmt.Printf("Using ParamSet: %s\n", ss.ParamSet)
+	}
+	fnm := "pat_assoc_" + ss.Learn.String() + "_" + ss.Pats.String()
+	if saveEpcLog {
+		var err error
+		ss.TrnEpcFile, err = os.Create(fnm + "_epc.tsv")
+		if err != nil {
+			log.Println(err)
+			ss.TrnEpcFile = nil
+		} else {
+			fmt.Printf("Saving epoch log to: %s\n", fnm+"_epc.tsv")
+			defer ss.TrnEpcFile.Close()
+		}
+	}
+	if saveRunLog {
+		var err error
+		ss.RunFile, err = os.Create(fnm + "_run.tsv")
+		if err != nil {
+			log.Println(err)
+			ss.RunFile = nil
+		} else {
+			fmt.Printf("Saving run log to: %s\n", fnm+"_run.tsv")
+			defer ss.RunFile.Close()
+		}
+	}
+	fmt.Printf("Running %d Runs\n", ss.MaxRuns)
+	ss.Train()
+	SaveLog(ss.RunStats, fnm+"_runstats.tsv")
+}
+
+// SaveLog saves given log table to given .tsv file, for the runs without
+// the gui
+func SaveLog(dt *etable.Table, fnm string) {
+	if err := dt.SaveCSV(gi.FileName(fnm), etable.Tab, etable.Headers); err != nil {
+		log.Println(err)
+	} else {
+		fmt.Printf("Saved %s\n", fnm)
+	}
 }
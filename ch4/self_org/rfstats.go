@@ -0,0 +1,154 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+)
+
+// RFParams are the parameters for the receptive field statistics, which
+// measure how well the Hidden units have learned to represent the
+// individual lines in the testing (Lines1) patterns
+type RFParams struct {
+	SelThr float64 `def:"0.3" min:"0" max:"1" desc:"threshold on the line selectivity index (LineSel) for a Hidden unit to count as representing its preferred line, for the Coverage stat"`
+	ActThr float64 `def:"0.5" min:"0" max:"1" desc:"threshold on Hidden unit activity for the binary hidden codes used in the MutInfo stat"`
+}
+
+func (rp *RFParams) Defaults() {
+	rp.SelThr = 0.3
+	rp.ActThr = 0.5
+}
+
+// RFStats computes the receptive field and hidden code statistics:
+// the line selectivity of each Hidden unit (LineSel) and their mean
+// (MeanSel), the Coverage of the lines by selective units, from the
+// Input to Hidden weights, and the population Sparseness and MutInfo,
+// from the Hidden activity for each line in the TstTrlLog -- must be
+// called after testing all the lines, and before UniquePatStat, which
+// binarizes the Hidden activity
+func (ss *Sim) RFStats(dt *etable.Table) {
+	lines := ss.TestEnv.Table
+	nl := lines.Len()
+	ss.LineSels(lines)
+	ss.MeanSel = 0
+	covered := make(map[int]bool)
+	for ui, sel := range ss.LineSel.Values {
+		ss.MeanSel += sel
+		if sel >= ss.RF.SelThr {
+			covered[ss.PrefLine[ui]] = true
+		}
+	}
+	ss.MeanSel /= float64(ss.LineSel.Len())
+	ss.Coverage = float64(len(covered))
+
+	hc, err := dt.ColByNameTry("Hidden")
+	if err != nil { // not in TstRecLays
+		return
+	}
+	codes := make(map[string]int)
+	ss.Sparseness = 0
+	for row := 0; row < nl; row++ {
+		acts := hc.SubSpace([]int{row}).(*etensor.Float64).Values
+		ss.Sparseness += PopSparseness(acts)
+		code := make([]byte, len(acts))
+		for i, a := range acts {
+			code[i] = '0'
+			if a > ss.RF.ActThr {
+				code[i] = '1'
+			}
+		}
+		codes[string(code)]++
+	}
+	ss.Sparseness /= float64(nl)
+	// each line is presented once, with equal probability, and the code is a
+	// deterministic function of the line, so the mutual information between
+	// them is the entropy of the codes
+	ss.MutInfo = 0
+	for _, cnt := range codes {
+		p := float64(cnt) / float64(nl)
+		ss.MutInfo -= p * math.Log2(p)
+	}
+}
+
+// LineSels computes the line selectivity index of each Hidden unit into
+// LineSel, and its preferred line into PrefLine, from its weights from the
+// Input, for the given line patterns: the response to each line is the
+// mean weight from the Input units in that line, and the selectivity is
+// (max - mean of others) / (max + mean of others), which is 0 for equal
+// weights to all lines and 1 for weights only to the preferred line
+func (ss *Sim) LineSels(lines *etable.IdxView) {
+	inp := ss.Net.LayerByName("Input").(leabra.LeabraLayer).AsLeabra()
+	hid := ss.Net.LayerByName("Hidden").(leabra.LeabraLayer).AsLeabra()
+	isz := inp.Shape().Len()
+	nh := hid.Shape().Len()
+	nl := lines.Len()
+	ss.LineSel.SetShape(hid.Shp.Shp, nil, nil)
+	ss.PrefLine = make([]int, nh)
+	inc := lines.Table.ColByName("Input")
+	wts := make([]float32, isz)
+	resp := make([]float64, nl)
+	for ui := 0; ui < nh; ui++ {
+		inp.SendPrjnVals(&wts, "Wt", hid, ui, "")
+		for li := 0; li < nl; li++ {
+			pat := inc.SubSpace([]int{lines.Idxs[li]})
+			sum, n := 0.0, 0.0
+			for ii := 0; ii < isz; ii++ {
+				if pat.FloatVal1D(ii) > 0 {
+					sum += float64(wts[ii])
+					n++
+				}
+			}
+			resp[li] = 0
+			if n > 0 {
+				resp[li] = sum / n
+			}
+		}
+		mx := 0
+		for li := range resp {
+			if resp[li] > resp[mx] {
+				mx = li
+			}
+		}
+		oth := 0.0
+		for li := range resp {
+			if li != mx {
+				oth += resp[li]
+			}
+		}
+		if nl > 1 {
+			oth /= float64(nl - 1)
+		}
+		sel := 0.0
+		if resp[mx]+oth > 0 {
+			sel = (resp[mx] - oth) / (resp[mx] + oth)
+		}
+		ss.LineSel.Values[ui] = sel
+		ss.PrefLine[ui] = mx
+	}
+}
+
+// PopSparseness returns the Treves-Rolls population sparseness of the
+// given activities, normalized so that it is 0 when all units are equally
+// active and 1 when only one unit is active
+func PopSparseness(acts []float64) float64 {
+	n := float64(len(acts))
+	if n < 2 {
+		return 0
+	}
+	var sum, ssq float64
+	for _, a := range acts {
+		sum += a
+		ssq += a * a
+	}
+	if ssq == 0 {
+		return 0
+	}
+	a := (sum / n) * (sum / n) / (ssq / n)
+	return (1 - a) / (1 - 1/n)
+}
@@ -0,0 +1,357 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/emer/etable/eplot"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+)
+
+// LineOris are the orientations of the lines in AllLines, by the first
+// letter of their names: Vertical, Horizontal, Diagonal (\) and Anti-diagonal (/),
+// where the diagonals wrap around the edges of the input
+const LineOris = "VHDA"
+
+// SchedPhase is one phase of the training schedule, with a given
+// set of lines, and frequency of co-occurrence of lines in the
+// training patterns
+type SchedPhase struct {
+	NEpcs   int     `min:"1" desc:"number of epochs of training in this phase"`
+	Oris    string  `desc:"orientations of the lines present in this phase: any of V (vertical), H (horizontal), D (diagonal \\) and A (anti-diagonal /)"`
+	Omit    string  `desc:"names of individual lines to leave out in this phase, separated by spaces (e.g., V0 H4)"`
+	SameOri float32 `min:"0" desc:"relative frequency of pairs of lines with the same orientation in the training patterns, compared to pairs of different orientations (1 = all pairs equally frequent, 0 = only pairs of different orientations)"`
+}
+
+// Lines returns the indexes of the lines in AllLines present in this phase
+func (sp *SchedPhase) Lines(all *etable.Table) []int {
+	omit := strings.Fields(sp.Omit)
+	var lines []int
+	for li := 0; li < all.Rows; li++ {
+		nm := all.CellString("Name", li)
+		if !strings.Contains(sp.Oris, nm[:1]) {
+			continue
+		}
+		om := false
+		for _, o := range omit {
+			if o == nm {
+				om = true
+			}
+		}
+		if !om {
+			lines = append(lines, li)
+		}
+	}
+	return lines
+}
+
+// SchedParams is the schedule of changes in the training inputs over
+// each run, for studying continual self-organizing learning
+type SchedParams struct {
+	On      bool         `desc:"use the training schedule in place of the Lines2 patterns -- training patterns are pairs of lines drawn from AllLines for each phase, and testing is on all of AllLines -- the run lasts for all the phases, instead of MaxEpcs -- takes effect on Init"`
+	NTrials int          `viewif:"On" def:"45" min:"1" desc:"number of training patterns (pairs of lines) in each epoch"`
+	Phases  []SchedPhase `viewif:"On" desc:"the phases of the schedule, in order"`
+}
+
+func (sc *SchedParams) Defaults() {
+	sc.NTrials = 45
+	sc.Phases = []SchedPhase{
+		{NEpcs: 30, Oris: "VH", SameOri: 1},
+		{NEpcs: 30, Oris: "VHD", SameOri: 1},
+		{NEpcs: 30, Oris: "HD", SameOri: 1},
+		{NEpcs: 30, Oris: "VHD", SameOri: 0},
+	}
+}
+
+// NEpcs returns the total number of epochs in all the phases
+func (sc *SchedParams) NEpcs() int {
+	n := 0
+	for _, ph := range sc.Phases {
+		n += ph.NEpcs
+	}
+	return n
+}
+
+// PhaseAt returns the index of the phase for given epoch
+func (sc *SchedParams) PhaseAt(epc int) int {
+	st := 0
+	for pi, ph := range sc.Phases {
+		st += ph.NEpcs
+		if epc < st {
+			return pi
+		}
+	}
+	return len(sc.Phases) - 1
+}
+
+// RunEpcs returns the number of epochs to train per run: MaxEpcs,
+// or the length of the schedule if it is on
+func (ss *Sim) RunEpcs() int {
+	if ss.Sched.On && len(ss.Sched.Phases) > 0 {
+		return ss.Sched.NEpcs()
+	}
+	return ss.MaxEpcs
+}
+
+// GenAllLines generates all the single-line patterns in the 5x5 input:
+// 5 each of vertical (V), horizontal (H), diagonal (D) and anti-diagonal (A)
+// lines, with the diagonals wrapping around the edges
+func (ss *Sim) GenAllLines(dt *etable.Table) {
+	dt.SetMetaData("name", "AllLines")
+	dt.SetMetaData("desc", "All single-line patterns, for the training schedule")
+	sch := etable.Schema{
+		{"Name", etensor.STRING, nil, nil},
+		{"Input", etensor.FLOAT32, []int{5, 5}, []string{"Y", "X"}},
+	}
+	dt.SetFromSchema(sch, 4*5)
+	dt.Cols[1].SetMetaData("grid-fill", "0.9")
+	for oi, ori := range LineOris {
+		for k := 0; k < 5; k++ {
+			row := oi*5 + k
+			dt.SetCellString("Name", row, fmt.Sprintf("%c%d", ori, k))
+			for y := 0; y < 5; y++ {
+				for x := 0; x < 5; x++ {
+					on := false
+					switch ori {
+					case 'V':
+						on = x == k
+					case 'H':
+						on = y == k
+					case 'D':
+						on = x == (y+k)%5
+					case 'A':
+						on = x == (k-y+5)%5
+					}
+					if on {
+						dt.SetCellTensorFloat1D("Input", row, y*5+x, 1)
+					}
+				}
+			}
+		}
+	}
+}
+
+// GenSchedPats generates the training patterns for given phase of the
+// schedule into SchedPats: Sched.NTrials pairs of the lines in the phase,
+// drawn at random with relative frequency SameOri for pairs of the same
+// orientation, with the input the union of the two lines
+func (ss *Sim) GenSchedPats(phase int) {
+	if phase < 0 || phase >= len(ss.Sched.Phases) {
+		log.Printf("GenSchedPats: phase %d is not in the %d Sched.Phases\n", phase, len(ss.Sched.Phases))
+		return
+	}
+	ph := &ss.Sched.Phases[phase]
+	all := ss.AllLines
+	lines := ph.Lines(all)
+	type pair struct{ a, b int }
+	var pairs []pair
+	var wts []float32
+	tot := float32(0)
+	for i, a := range lines {
+		for _, b := range lines[i+1:] {
+			wt := float32(1)
+			if all.CellString("Name", a)[0] == all.CellString("Name", b)[0] {
+				wt = ph.SameOri
+			}
+			if wt <= 0 {
+				continue
+			}
+			pairs = append(pairs, pair{a, b})
+			wts = append(wts, wt)
+			tot += wt
+		}
+	}
+	dt := ss.SchedPats
+	dt.SetMetaData("name", "SchedPats")
+	dt.SetMetaData("desc", "Training patterns for the current phase of the schedule")
+	sch := etable.Schema{
+		{"Name", etensor.STRING, nil, nil},
+		{"Input", etensor.FLOAT32, []int{5, 5}, []string{"Y", "X"}},
+	}
+	dt.SetFromSchema(sch, ss.Sched.NTrials)
+	dt.Cols[1].SetMetaData("grid-fill", "0.9")
+	if len(pairs) == 0 {
+		return
+	}
+	inc := all.ColByName("Input").(*etensor.Float32)
+	for row := 0; row < ss.Sched.NTrials; row++ {
+		r := rand.Float32() * tot
+		pi := 0
+		for pi < len(pairs)-1 && r >= wts[pi] {
+			r -= wts[pi]
+			pi++
+		}
+		p := pairs[pi]
+		dt.SetCellString("Name", row, all.CellString("Name", p.a)+"_"+all.CellString("Name", p.b))
+		av := inc.SubSpace([]int{p.a}).(*etensor.Float32).Values
+		bv := inc.SubSpace([]int{p.b}).(*etensor.Float32).Values
+		for i := range av {
+			if av[i] > 0 || bv[i] > 0 {
+				dt.SetCellTensorFloat1D("Input", row, i, 1)
+			}
+		}
+	}
+}
+
+// UpdateSched sets the training patterns for the phase of the schedule at
+// given epoch, if it has changed, recording the lines that were present in
+// the previous phases, and the preferred lines of the Hidden units at the
+// start of the phase, for the SchedLog stats
+func (ss *Sim) UpdateSched(epc int) {
+	if !ss.Sched.On || len(ss.Sched.Phases) == 0 {
+		return
+	}
+	phase := ss.Sched.PhaseAt(epc)
+	if phase == ss.Phase {
+		return
+	}
+	ss.Phase = phase
+	ss.SeenLines = make([]bool, ss.AllLines.Rows)
+	for pi := 0; pi < phase; pi++ {
+		for _, li := range ss.Sched.Phases[pi].Lines(ss.AllLines) {
+			ss.SeenLines[li] = true
+		}
+	}
+	ss.LineSels(etable.NewIdxView(ss.AllLines))
+	ss.PhasePref = ss.SelPrefs()
+	ss.GenSchedPats(phase)
+}
+
+// SelPrefs returns the preferred line of each Hidden unit, or -1 if its
+// LineSel is below RF.SelThr -- LineSels must have been called
+func (ss *Sim) SelPrefs() []int {
+	prefs := make([]int, len(ss.PrefLine))
+	for ui, pl := range ss.PrefLine {
+		prefs[ui] = -1
+		if ss.LineSel.Values[ui] >= ss.RF.SelThr {
+			prefs[ui] = pl
+		}
+	}
+	return prefs
+}
+
+// PrefChanges returns the proportion of Hidden units with a different
+// preferred line (or selectivity below threshold) between the two
+func PrefChanges(prefs, prv []int) float64 {
+	if len(prv) != len(prefs) || len(prefs) == 0 {
+		return 0
+	}
+	n := 0
+	for ui := range prefs {
+		if prefs[ui] != prv[ui] {
+			n++
+		}
+	}
+	return float64(n) / float64(len(prefs))
+}
+
+//////////////////////////////////////////////
+//  SchedLog
+
+// LogSched adds a row to the SchedLog with the coverage of the lines in
+// the current, previous and new phases of the schedule, and the changes
+// in the preferred lines of the Hidden units, from the current weights
+func (ss *Sim) LogSched(dt *etable.Table) {
+	if !ss.Sched.On || ss.Phase < 0 {
+		return
+	}
+	all := ss.AllLines
+	ss.LineSels(etable.NewIdxView(all))
+	prefs := ss.SelPrefs()
+	cover := make([]float64, all.Rows)
+	for _, pl := range prefs {
+		if pl >= 0 {
+			cover[pl] = 1
+		}
+	}
+	cur := make([]bool, all.Rows)
+	for _, li := range ss.Sched.Phases[ss.Phase].Lines(all) {
+		cur[li] = true
+	}
+	var ncur, nold, nnew, ccur, cold, cnew float64
+	for li := range cover {
+		switch {
+		case cur[li]:
+			ncur++
+			ccur += cover[li]
+			if !ss.SeenLines[li] {
+				nnew++
+				cnew += cover[li]
+			}
+		case ss.SeenLines[li]:
+			nold++
+			cold += cover[li]
+		}
+	}
+	frac := func(c, n float64) float64 {
+		if n == 0 {
+			return 0
+		}
+		return c / n
+	}
+
+	row := dt.Rows
+	dt.SetNumRows(row + 1)
+	dt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
+	dt.SetCellFloat("Epoch", row, float64(ss.TrainEnv.Epoch.Prv))
+	dt.SetCellFloat("Phase", row, float64(ss.Phase))
+	dt.SetCellFloat("CurCover", row, frac(ccur, ncur))
+	dt.SetCellFloat("OldCover", row, frac(cold, nold))
+	dt.SetCellFloat("NewCover", row, frac(cnew, nnew))
+	dt.SetCellFloat("PrefChg", row, PrefChanges(prefs, ss.EpcPref))
+	dt.SetCellFloat("Reassign", row, PrefChanges(prefs, ss.PhasePref))
+	dt.SetCellFloat("UniqPats", row, ss.UniqPats)
+	dt.SetCellTensor("LineCover", row, etensor.NewFloat64Shape(etensor.NewShape([]int{all.Rows}, nil, nil), cover))
+	ss.EpcPref = prefs
+
+	if ss.SchedPlot != nil {
+		ss.SchedPlot.GoUpdate()
+	}
+}
+
+func (ss *Sim) ConfigSchedLog(dt *etable.Table) {
+	dt.SetMetaData("name", "SchedLog")
+	dt.SetMetaData("desc", "Coverage of the lines and reorganization of the Hidden units over the training schedule")
+	dt.SetMetaData("read-only", "true")
+	dt.SetMetaData("precision", strconv.Itoa(LogPrec))
+
+	sch := etable.Schema{
+		{"Run", etensor.INT64, nil, nil},
+		{"Epoch", etensor.INT64, nil, nil},
+		{"Phase", etensor.INT64, nil, nil},
+		{"CurCover", etensor.FLOAT64, nil, nil},
+		{"OldCover", etensor.FLOAT64, nil, nil},
+		{"NewCover", etensor.FLOAT64, nil, nil},
+		{"PrefChg", etensor.FLOAT64, nil, nil},
+		{"Reassign", etensor.FLOAT64, nil, nil},
+		{"UniqPats", etensor.FLOAT64, nil, nil},
+		{"LineCover", etensor.FLOAT64, []int{ss.AllLines.Rows}, nil},
+	}
+	dt.SetFromSchema(sch, 0)
+}
+
+func (ss *Sim) ConfigSchedPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
+	plt.Params.Title = "Self Organizing Schedule Plot"
+	plt.Params.XAxisCol = "Epoch"
+	plt.SetTable(dt)
+	// order of params: on, fixMin, min, fixMax, max
+	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Epoch", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Phase", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("CurCover", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("OldCover", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("NewCover", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("PrefChg", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("Reassign", eplot.On, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("UniqPats", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 20)
+	plt.SetColParams("LineCover", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	return plt
+}
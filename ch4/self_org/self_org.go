@@ -18,6 +18,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/CompCogNeuro/sims/bench"
+	"github.com/CompCogNeuro/sims/patfile"
 	"github.com/emer/emergent/emer"
 	"github.com/emer/emergent/env"
 	"github.com/emer/emergent/netview"
@@ -43,9 +45,15 @@ import (
 
 // this is the stub main for gogi that calls our actual mainrun function, at end of file
 func main() {
-	gimain.Main(func() {
-		mainrun()
-	})
+	if bench.HasFlag(os.Args[1:]) {
+		TheSim.New()
+		TheSim.Config()
+		TheSim.Bench.RunArgs(TheSim.Benchmark) // -bench arg = run without the gui
+	} else {
+		gimain.Main(func() { // this starts gui -- requires valid OpenGL display connection (e.g., X11)
+			mainrun()
+		})
+	}
 }
 
 // LogPrec is precision for saving float values in logs
@@ -110,9 +118,12 @@ type Sim struct {
 	Net           *leabra.Network   `view:"no-inline" desc:"the network -- click to view / edit parameters for layers, prjns, etc"`
 	Lines2        *etable.Table     `view:"no-inline" desc:"easy training patterns -- can be learned with Hebbian"`
 	Lines1        *etable.Table     `view:"no-inline" desc:"hard training patterns -- require error-driven"`
+	AllLines      *etable.Table     `view:"no-inline" desc:"all single-line patterns, including diagonals -- testing patterns for the training schedule"`
+	SchedPats     *etable.Table     `view:"no-inline" desc:"training patterns for the current phase of the training schedule"`
 	TrnEpcLog     *etable.Table     `view:"no-inline" desc:"training epoch-level log data"`
 	TstEpcLog     *etable.Table     `view:"no-inline" desc:"testing epoch-level log data"`
 	TstTrlLog     *etable.Table     `view:"no-inline" desc:"testing trial-level log data"`
+	SchedLog      *etable.Table     `view:"no-inline" desc:"coverage of the lines and reorganization of the Hidden units over the training schedule"`
 	HidFmInputWts etensor.Tensor    `view:"no-inline" desc:"weights from input to hidden layer"`
 	RunLog        *etable.Table     `view:"no-inline" desc:"summary log of each run"`
 	RunStats      *etable.Table     `view:"no-inline" desc:"aggregate stats on all runs"`
@@ -124,12 +135,22 @@ type Sim struct {
 	TrainEnv      env.FixedTable    `desc:"Training environment -- contains everything about iterating over input / output patterns over training"`
 	TestEnv       env.FixedTable    `desc:"Testing environment -- manages iterating over testing"`
 	Time          leabra.Time       `desc:"leabra timing parameters and state"`
+	Bench         bench.Bench       `view:"no-inline" desc:"benchmark parameters and results -- see Benchmark"`
 	ViewOn        bool              `desc:"whether to update the network view while running"`
 	TrainUpdt     leabra.TimeScales `desc:"at what time scale to update the display during training?  Anything longer than Epoch updates at Epoch in this model"`
 	TestUpdt      leabra.TimeScales `desc:"at what time scale to update the display during testing?  Anything longer than Epoch updates at Epoch in this model"`
 	TestInterval  int               `desc:"how often to run through all the test patterns, in terms of training epochs"`
 	TstRecLays    []string          `desc:"names of layers to record activations etc of during testing"`
+	RF            RFParams          `view:"inline" desc:"parameters for the receptive field statistics"`
 	UniqPats      float64           `inactive:"+" desc:"number of uniquely-coded line patterns, computed during testing -- maximum 10, higher is better"`
+	LineSel       *etensor.Float64  `view:"no-inline" desc:"line selectivity index of each Hidden unit, from its weights from the Input: (response to preferred line - mean of others) / (sum) -- 1 = responds only to one line"`
+	PrefLine      []int             `inactive:"+" desc:"index of the preferred line (in the testing patterns) of each Hidden unit"`
+	MeanSel       float64           `inactive:"+" desc:"mean LineSel over Hidden units"`
+	Coverage      float64           `inactive:"+" desc:"number of lines that are the preferred line of at least one Hidden unit with LineSel >= RF.SelThr -- maximum is the number of lines in TestEnv, higher is better"`
+	Sparseness    float64           `inactive:"+" desc:"mean Treves-Rolls population sparseness of the Hidden activity for each line, computed during testing -- 0 = all units equally active, 1 = only one unit active"`
+	MutInfo       float64           `inactive:"+" desc:"mutual information (bits) between the binarized Hidden code and line identity, computed during testing -- maximum is log2 of the number of test lines (3.32 for the 10 lines of the standard test set) when every line has a unique code"`
+	Sched         SchedParams       `desc:"schedule of changes in the training lines over each run, for studying continual self-organizing learning"`
+	Phase         int               `inactive:"+" desc:"current phase of the training schedule"`
 
 	// internal state - view:"-"
 	Win         *gi.Window                  `view:"-" desc:"main GUI window"`
@@ -140,6 +161,7 @@ type Sim struct {
 	TstEpcPlot  *eplot.Plot2D               `view:"-" desc:"the testing epoch plot"`
 	TstTrlPlot  *eplot.Plot2D               `view:"-" desc:"the test-trial plot"`
 	RunPlot     *eplot.Plot2D               `view:"-" desc:"the run plot"`
+	SchedPlot   *eplot.Plot2D               `view:"-" desc:"the training schedule plot"`
 	TrnEpcFile  *os.File                    `view:"-" desc:"log file"`
 	RunFile     *os.File                    `view:"-" desc:"log file"`
 	ValsTsrs    map[string]*etensor.Float32 `view:"-" desc:"for holding layer values"`
@@ -147,6 +169,9 @@ type Sim struct {
 	StopNow     bool                        `view:"-" desc:"flag to stop running"`
 	NeedsNewRun bool                        `view:"-" desc:"flag to initialize NewRun if last one finished"`
 	RndSeed     int64                       `view:"-" desc:"the current random seed"`
+	SeenLines   []bool                      `view:"-" desc:"lines in AllLines present in the phases of the schedule before the current one"`
+	PhasePref   []int                       `view:"-" desc:"preferred line of each Hidden unit at the start of the current phase of the schedule, -1 if not selective"`
+	EpcPref     []int                       `view:"-" desc:"preferred line of each Hidden unit at the end of the previous epoch, -1 if not selective"`
 }
 
 // this registers this Sim Type and gives it properties that e.g.,
@@ -161,10 +186,14 @@ func (ss *Sim) New() {
 	ss.Net = &leabra.Network{}
 	ss.Lines2 = &etable.Table{}
 	ss.Lines1 = &etable.Table{}
+	ss.AllLines = &etable.Table{}
+	ss.SchedPats = &etable.Table{}
 	ss.TrnEpcLog = &etable.Table{}
 	ss.TstEpcLog = &etable.Table{}
 	ss.TstTrlLog = &etable.Table{}
+	ss.SchedLog = &etable.Table{}
 	ss.HidFmInputWts = &etensor.Float32{}
+	ss.LineSel = &etensor.Float64{}
 	ss.RunLog = &etable.Table{}
 	ss.RunStats = &etable.Table{}
 	ss.SimMat = &simat.SimMat{}
@@ -176,6 +205,7 @@ func (ss *Sim) New() {
 	ss.TestInterval = 1
 	ss.TstRecLays = []string{"Input", "Hidden"}
 	ss.Defaults()
+	ss.Bench.Defaults("self_org")
 }
 
 func (ss *Sim) Defaults() {
@@ -183,6 +213,8 @@ func (ss *Sim) Defaults() {
 	ss.InputNoise = 0
 	ss.TrainGi = 1.8
 	ss.TestGi = 2.5
+	ss.RF.Defaults()
+	ss.Sched.Defaults()
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////
@@ -197,6 +229,7 @@ func (ss *Sim) Config() {
 	ss.ConfigTstEpcLog(ss.TstEpcLog)
 	ss.ConfigTstTrlLog(ss.TstTrlLog)
 	ss.ConfigRunLog(ss.RunLog)
+	ss.ConfigSchedLog(ss.SchedLog)
 }
 
 func (ss *Sim) ConfigEnv() {
@@ -209,20 +242,36 @@ func (ss *Sim) ConfigEnv() {
 
 	ss.TrainEnv.Nm = "TrainEnv"
 	ss.TrainEnv.Dsc = "training params and state"
-	ss.TrainEnv.Table = etable.NewIdxView(ss.Lines2)
-	ss.TrainEnv.Validate()
 	ss.TrainEnv.Run.Max = ss.MaxRuns // note: we are not setting epoch max -- do that manually
 
 	ss.TestEnv.Nm = "TestEnv"
 	ss.TestEnv.Dsc = "testing params and state"
-	ss.TestEnv.Table = etable.NewIdxView(ss.Lines1)
 	ss.TestEnv.Sequential = true
-	ss.TestEnv.Validate()
 
+	ss.SetEnvPats()
 	ss.TrainEnv.Init(0)
 	ss.TestEnv.Init(0)
 }
 
+// SetEnvPats sets the training and testing patterns of the environments:
+// Lines2 and Lines1, or SchedPats and AllLines if Sched.On
+// (with at least one phase)
+func (ss *Sim) SetEnvPats() {
+	if ss.Sched.On && len(ss.Sched.Phases) == 0 {
+		log.Println("Sched.On but there are no Sched.Phases -- using the Lines2 patterns")
+	}
+	if ss.Sched.On && len(ss.Sched.Phases) > 0 {
+		ss.GenSchedPats(0)
+		ss.TrainEnv.Table = etable.NewIdxView(ss.SchedPats)
+		ss.TestEnv.Table = etable.NewIdxView(ss.AllLines)
+	} else {
+		ss.TrainEnv.Table = etable.NewIdxView(ss.Lines2)
+		ss.TestEnv.Table = etable.NewIdxView(ss.Lines1)
+	}
+	ss.TrainEnv.Validate()
+	ss.TestEnv.Validate()
+}
+
 func (ss *Sim) ConfigNet(net *leabra.Network) {
 	net.InitName(net, "SelfOrg")
 	inp := net.AddLayer2D("Input", 5, 5, emer.Input)
@@ -249,6 +298,10 @@ func (ss *Sim) Init() {
 	rand.Seed(ss.RndSeed)
 	ss.StopNow = false
 	ss.SetParams("", false) // all sheets
+	ss.SetEnvPats()
+	if ss.TstTrlLog.Rows != ss.TestEnv.Table.Len() {
+		ss.ConfigTstTrlLog(ss.TstTrlLog)
+	}
 	ss.NewRun()
 	ss.UpdateView(true, -1)
 	if ss.NetView != nil && ss.NetView.IsVisible() {
@@ -382,7 +435,9 @@ func (ss *Sim) TrainTrial() {
 			ss.TestAll()
 		}
 		ss.LogTrnEpc(ss.TrnEpcLog)
-		if epc >= ss.MaxEpcs {
+		ss.LogSched(ss.SchedLog)
+		ss.UpdateSched(epc)
+		if epc >= ss.RunEpcs() {
 			// done with training..
 			ss.RunEnd()
 			if ss.TrainEnv.Run.Incr() { // we are done!
@@ -417,6 +472,10 @@ func (ss *Sim) NewRun() {
 	ss.InitStats()
 	ss.TrnEpcLog.SetNumRows(0)
 	ss.TstEpcLog.SetNumRows(0)
+	ss.SchedLog.SetNumRows(0)
+	ss.Phase = -1
+	ss.EpcPref = nil
+	ss.UpdateSched(0)
 	ss.NeedsNewRun = false
 }
 
@@ -509,6 +568,11 @@ func (ss *Sim) Stopped() {
 	}
 }
 
+// Benchmark runs the Bench benchmark with TrainTrial (see bench.Bench.RunSim)
+func (ss *Sim) Benchmark() {
+	ss.Bench.RunSim(ss.Net, &ss.Time, ss.TrainTrial, ss.Init, ss.Stopped, &ss.NetView)
+}
+
 // SaveWeights saves the network weights -- when called with giv.CallMethod
 // it will auto-prompt for filename
 func (ss *Sim) SaveWeights(filename gi.FileName) {
@@ -563,6 +627,7 @@ func (ss *Sim) TestAll() {
 			break
 		}
 	}
+	ss.RFStats(ss.TstTrlLog)
 	ss.UniqPats = ss.UniquePatStat(ss.TstTrlLog)
 }
 
@@ -668,6 +733,21 @@ func (ss *Sim) OpenPats() {
 	// err := ss.Lines2.OpenCSV("lines_5x5x2.tsv", etable.Tab)
 	ss.OpenPatAsset(ss.Lines1, "lines_5x5x1.tsv", "Lines1", "Lines1 Testing patterns")
 	// err = ss.Lines1.OpenCSV("lines_5x5x1.tsv", etable.Tab)
+	ss.GenAllLines(ss.AllLines)
+}
+
+// OpenPatsFile opens training patterns from given .tsv or .csv file in place
+// of the Lines2 patterns, checking that they fit the Input layer.
+// The Lines1 patterns are still used for testing.
+func (ss *Sim) OpenPatsFile(filename gi.FileName) error {
+	err := patfile.Open(ss.Lines2, string(filename), ss.Net, "Input")
+	if err != nil {
+		patfile.Report(ss.Win, err)
+		return err
+	}
+	ss.TrainEnv.Table = etable.NewIdxView(ss.Lines2)
+	ss.TrainEnv.Init(0)
+	return nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////
@@ -706,6 +786,11 @@ func (ss *Sim) LogTrnEpc(dt *etable.Table) {
 	dt.SetCellFloat("Run", row, float64(ss.TrainEnv.Run.Cur))
 	dt.SetCellFloat("Epoch", row, float64(epc))
 	dt.SetCellFloat("UniqPats", row, ss.UniqPats)
+	dt.SetCellFloat("MeanSel", row, ss.MeanSel)
+	dt.SetCellFloat("Coverage", row, ss.Coverage)
+	dt.SetCellFloat("Sparseness", row, ss.Sparseness)
+	dt.SetCellFloat("MutInfo", row, ss.MutInfo)
+	dt.SetCellTensor("LineSel", row, ss.LineSel)
 	dt.SetCellTensor("HidFmInputWts", row, ss.HidFmInputWts)
 
 	// note: essential to use Go version of update when called from another goroutine
@@ -728,10 +813,16 @@ func (ss *Sim) ConfigTrnEpcLog(dt *etable.Table) {
 		{"Run", etensor.INT64, nil, nil},
 		{"Epoch", etensor.INT64, nil, nil},
 		{"UniqPats", etensor.FLOAT64, nil, nil},
+		{"MeanSel", etensor.FLOAT64, nil, nil},
+		{"Coverage", etensor.FLOAT64, nil, nil},
+		{"Sparseness", etensor.FLOAT64, nil, nil},
+		{"MutInfo", etensor.FLOAT64, nil, nil},
+		{"LineSel", etensor.FLOAT64, []int{4, 5}, nil},
 		{"HidFmInputWts", etensor.FLOAT32, []int{4, 5, 5, 5}, nil},
 	}
 	dt.SetFromSchema(sch, 0)
 	ss.ConfigHidFmInput(ss.HidFmInputWts)
+	ss.LineSel.SetShape([]int{4, 5}, nil, nil)
 }
 
 func (ss *Sim) ConfigTrnEpcPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D {
@@ -742,6 +833,11 @@ func (ss *Sim) ConfigTrnEpcPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot
 	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
 	plt.SetColParams("Epoch", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
 	plt.SetColParams("UniqPats", eplot.On, eplot.FixMin, 0, eplot.FixMax, 10)
+	plt.SetColParams("MeanSel", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("Coverage", eplot.On, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Sparseness", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("MutInfo", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("LineSel", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
 	plt.SetColParams("HidFmInputWts", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
 
 	return plt
@@ -908,10 +1004,16 @@ func (ss *Sim) LogRun(dt *etable.Table) {
 	dt.SetCellFloat("Run", row, float64(run))
 	dt.SetCellString("Params", row, params)
 	dt.SetCellFloat("UniqPats", row, agg.Mean(epcix, "UniqPats")[0])
+	dt.SetCellFloat("MeanSel", row, agg.Mean(epcix, "MeanSel")[0])
+	dt.SetCellFloat("Coverage", row, agg.Mean(epcix, "Coverage")[0])
+	dt.SetCellFloat("Sparseness", row, agg.Mean(epcix, "Sparseness")[0])
+	dt.SetCellFloat("MutInfo", row, agg.Mean(epcix, "MutInfo")[0])
 
 	runix := etable.NewIdxView(dt)
 	spl := split.GroupBy(runix, []string{"Params"})
 	split.Desc(spl, "UniqPats")
+	split.Desc(spl, "Coverage")
+	split.Desc(spl, "MutInfo")
 	ss.RunStats = spl.AggsToTable(etable.AddAggName)
 
 	// note: essential to use Go version of update when called from another goroutine
@@ -928,6 +1030,10 @@ func (ss *Sim) ConfigRunLog(dt *etable.Table) {
 		{"Run", etensor.INT64, nil, nil},
 		{"Params", etensor.STRING, nil, nil},
 		{"UniqPats", etensor.FLOAT64, nil, nil},
+		{"MeanSel", etensor.FLOAT64, nil, nil},
+		{"Coverage", etensor.FLOAT64, nil, nil},
+		{"Sparseness", etensor.FLOAT64, nil, nil},
+		{"MutInfo", etensor.FLOAT64, nil, nil},
 	}
 	dt.SetFromSchema(sch, 0)
 }
@@ -939,6 +1045,10 @@ func (ss *Sim) ConfigRunPlot(plt *eplot.Plot2D, dt *etable.Table) *eplot.Plot2D
 	// order of params: on, fixMin, min, fixMax, max
 	plt.SetColParams("Run", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
 	plt.SetColParams("UniqPats", eplot.On, eplot.FixMin, 0, eplot.FixMax, 10)
+	plt.SetColParams("MeanSel", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("Coverage", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
+	plt.SetColParams("Sparseness", eplot.Off, eplot.FixMin, 0, eplot.FixMax, 1)
+	plt.SetColParams("MutInfo", eplot.Off, eplot.FixMin, 0, eplot.FloatMax, 0)
 	return plt
 }
 
@@ -998,6 +1108,9 @@ inhibitory competition, rich-get-richer Hebbian learning, and homeostasis (negat
 	plt = tv.AddNewTab(eplot.KiT_Plot2D, "RunPlot").(*eplot.Plot2D)
 	ss.RunPlot = ss.ConfigRunPlot(plt, ss.RunLog)
 
+	plt = tv.AddNewTab(eplot.KiT_Plot2D, "SchedPlot").(*eplot.Plot2D)
+	ss.SchedPlot = ss.ConfigSchedPlot(plt, ss.SchedLog)
+
 	split.SetSplits(.2, .8)
 
 	tbar.AddAction(gi.ActOpts{Label: "Init", Icon: "update", Tooltip: "Initialize everything including network weights, and start over.  Also applies current params.", UpdateFunc: func(act *gi.Action) {
@@ -1104,6 +1217,11 @@ inhibitory competition, rich-get-richer Hebbian learning, and homeostasis (negat
 		}
 	})
 
+	tbar.AddAction(gi.ActOpts{Label: "Open Pats", Icon: "file-open", Tooltip: "Open your own training patterns from a .tsv or .csv file, in place of the Lines2 patterns -- the column must fit the 5x5 Input layer."}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			giv.CallMethod(ss, "OpenPatsFile", vp)
+		})
+
 	tbar.AddSeparator("log")
 
 	tbar.AddAction(gi.ActOpts{Label: "Reset RunLog", Icon: "update", Tooltip: "Reset the accumulated log of all Runs, which are tagged with the ParamSet used"}, win.This(),
@@ -1127,6 +1245,8 @@ inhibitory competition, rich-get-richer Hebbian learning, and homeostasis (negat
 		vp.SetNeedsFullRender()
 	})
 
+	bench.AddAction(tbar, win, &ss.IsRunning, ss.Benchmark)
+
 	tbar.AddAction(gi.ActOpts{Label: "README", Icon: "file-markdown", Tooltip: "Opens your browser on the README file that contains instructions for how to run this model."}, win.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			gi.OpenURL("https://github.com/CompCogNeuro/sims/blob/master/ch4/self_org/README.md")
@@ -1207,6 +1327,16 @@ inhibitory competition, rich-get-richer Hebbian learning, and homeostasis (negat
 // These props register Save methods so they can be used
 var SimProps = ki.Props{
 	"CallMethods": ki.PropSlice{
+		{"Benchmark", bench.MethodProps},
+		{"OpenPatsFile", ki.Props{
+			"desc": "open patterns from .tsv or .csv file, replacing the current ones",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".tsv,.csv",
+				}},
+			},
+		}},
 		{"SaveWeights", ki.Props{
 			"desc": "save network weights to file",
 			"icon": "file-save",
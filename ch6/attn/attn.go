@@ -14,9 +14,12 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"os"
 	"strconv"
 	"strings"
 
+	"github.com/CompCogNeuro/sims/bench"
+	"github.com/CompCogNeuro/sims/patfile"
 	"github.com/emer/emergent/emer"
 	"github.com/emer/emergent/env"
 	"github.com/emer/emergent/netview"
@@ -39,9 +42,15 @@ import (
 
 // this is the stub main for gogi that calls our actual mainrun function, at end of file
 func main() {
-	gimain.Main(func() {
-		mainrun()
-	})
+	if bench.HasFlag(os.Args[1:]) {
+		TheSim.New()
+		TheSim.Config()
+		TheSim.Bench.RunArgs(TheSim.Benchmark) // -bench arg = run without the gui
+	} else {
+		gimain.Main(func() { // this starts gui -- requires valid OpenGL display connection (e.g., X11)
+			mainrun()
+		})
+	}
 }
 
 // LogPrec is precision for saving float values in logs
@@ -226,6 +235,7 @@ type Sim struct {
 	ParamSet      string            `view:"-" desc:"which set of *additional* parameters to use -- always applies Base and optionaly this next if set -- can use multiple names separated by spaces (don't put spaces in ParamSet names!)"`
 	TestEnv       env.FixedTable    `desc:"Testing environment -- manages iterating over testing"`
 	Time          leabra.Time       `desc:"leabra timing parameters and state"`
+	Bench         bench.Bench       `view:"no-inline" desc:"benchmark parameters and results -- see Benchmark"`
 	ViewUpdt      leabra.TimeScales `desc:"at what time scale to update the display during testing?  Change to AlphaCyc to make display updating go faster"`
 	TstRecLays    []string          `desc:"names of layers to record activations etc of during testing"`
 
@@ -262,6 +272,7 @@ func (ss *Sim) New() {
 	ss.ViewUpdt = leabra.FastSpike
 	ss.TstRecLays = []string{"Input", "V1", "Spat1", "Spat2", "Obj1", "Obj2", "Output"}
 	ss.Defaults()
+	ss.Bench.Defaults("attn")
 }
 
 // Defaults sets default params
@@ -620,6 +631,11 @@ func (ss *Sim) Stopped() {
 	}
 }
 
+// Benchmark runs the Bench benchmark with TestTrial (see bench.Bench.RunSim)
+func (ss *Sim) Benchmark() {
+	ss.Bench.RunSim(ss.Net, &ss.Time, ss.TestTrial, ss.Init, ss.Stopped, &ss.NetView)
+}
+
 // SaveWts saves the network weights -- when called with giv.CallMethod
 // it will auto-prompt for filename
 func (ss *Sim) SaveWts(filename gi.FileName) {
@@ -794,6 +810,33 @@ func (ss *Sim) OpenPats() {
 	ss.OpenPatAsset(ss.ObjAttn, "obj_attn.tsv", "ObjAttn", "object-based attention")
 }
 
+// OpenPatsFile opens patterns from given .tsv or .csv file in place of the
+// patterns for the current Test, checking that they fit the Input and
+// Output layers.
+func (ss *Sim) OpenPatsFile(filename gi.FileName) error {
+	var dt *etable.Table
+	switch ss.Test {
+	case MultiObjs:
+		dt = ss.MultiObjs
+	case StdPosner:
+		dt = ss.StdPosner
+	case ClosePosner:
+		dt = ss.ClosePosner
+	case ReversePosner:
+		dt = ss.ReversePosner
+	case ObjAttn:
+		dt = ss.ObjAttn
+	}
+	err := patfile.Open(dt, string(filename), ss.Net, "Input", "Output")
+	if err != nil {
+		patfile.Report(ss.Win, err)
+		return err
+	}
+	ss.UpdateEnv()
+	ss.TestEnv.Init(0)
+	return nil
+}
+
 //////////////////////////////////////////////
 //  TstTrlLog
 
@@ -963,6 +1006,11 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		}
 	})
 
+	tbar.AddAction(gi.ActOpts{Label: "Open Pats", Icon: "file-open", Tooltip: "Open your own patterns from a .tsv or .csv file, in place of the ones for the current Test -- the columns must fit the Input and Output layers."}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			giv.CallMethod(ss, "OpenPatsFile", vp)
+		})
+
 	tbar.AddSeparator("msep")
 
 	tbar.AddAction(gi.ActOpts{Label: "Lesion", Icon: "cut", Tooltip: "Lesion spatial pathways.", UpdateFunc: func(act *gi.Action) {
@@ -978,6 +1026,8 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		vp.SetNeedsFullRender()
 	})
 
+	bench.AddAction(tbar, win, &ss.IsRunning, ss.Benchmark)
+
 	tbar.AddAction(gi.ActOpts{Label: "README", Icon: "file-markdown", Tooltip: "Opens your browser on the README file that contains instructions for how to run this model."}, win.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			gi.OpenURL("https://github.com/CompCogNeuro/sims/blob/master/ch6/attn/README.md")
@@ -1045,6 +1095,16 @@ func (ss *Sim) ConfigGui() *gi.Window {
 // These props register Save methods so they can be used
 var SimProps = ki.Props{
 	"CallMethods": ki.PropSlice{
+		{"Benchmark", bench.MethodProps},
+		{"OpenPatsFile", ki.Props{
+			"desc": "open patterns from .tsv or .csv file, replacing the current ones",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".tsv,.csv",
+				}},
+			},
+		}},
 		{"SaveWts", ki.Props{
 			"desc": "save network weights to file",
 			"icon": "file-save",
@@ -21,6 +21,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/CompCogNeuro/sims/bench"
+	"github.com/CompCogNeuro/sims/progress"
+	"github.com/CompCogNeuro/sims/script"
 	"github.com/emer/emergent/actrf"
 	"github.com/emer/emergent/emer"
 	"github.com/emer/emergent/env"
@@ -189,7 +192,8 @@ type Sim struct {
 	TestEnv LEDEnv `desc:"Testing environment -- LED testing"`
 
 	// leabra timing parameters and state
-	Time leabra.Time `desc:"leabra timing parameters and state"`
+	Time  leabra.Time `desc:"leabra timing parameters and state"`
+	Bench bench.Bench `view:"no-inline" desc:"benchmark parameters and results -- see Benchmark"`
 
 	// whether to update the network view while running
 	ViewOn bool `desc:"whether to update the network view while running"`
@@ -294,7 +298,8 @@ type Sim struct {
 	SaveWts bool `view:"-" desc:"for command-line run only, auto-save final weights after each run"`
 
 	// [view: -] if true, runing in no GUI mode
-	NoGui bool `view:"-" desc:"if true, runing in no GUI mode"`
+	NoGui    bool              `view:"-" desc:"if true, runing in no GUI mode"`
+	Progress progress.Progress `view:"-" desc:"progress display for runs without the gui"`
 
 	// [view: -] if true, print message for all params that are set
 	LogSetParams bool `view:"-" desc:"if true, print message for all params that are set"`
@@ -347,6 +352,8 @@ func (ss *Sim) New() {
 	ss.LayStatNms = []string{"V1", "Output"}
 	ss.ActRFNms = []string{"V4:Image", "V4:Output", "IT:Image", "IT:Output"}
 	ss.PNovel = 0
+	ss.Bench.Defaults("objrec")
+	ss.Progress.Defaults("objrec")
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////
@@ -614,6 +621,9 @@ func (ss *Sim) TrainTrial() {
 	if ss.CurImgGrid != nil {
 		ss.CurImgGrid.UpdateSig()
 	}
+	if ss.NoGui && ss.Progress.Due() {
+		ss.Progress.Update(ss.Counters(true), progress.Frac(ss.TrainEnv.Run.Cur, ss.MaxRuns, ss.TrainEnv.Epoch.Cur, ss.MaxEpcs, ss.TrainEnv.Trial.Cur, ss.TrainEnv.Trial.Max), ss.TrnEpcLog)
+	}
 }
 
 // RunEnd is called at the end of a run -- save weights, record final log, etc here
@@ -740,6 +750,11 @@ func (ss *Sim) Stopped() {
 	}
 }
 
+// Benchmark runs the Bench benchmark with TrainTrial (see bench.Bench.RunSim)
+func (ss *Sim) Benchmark() {
+	ss.Bench.RunSim(ss.Net, &ss.Time, ss.TrainTrial, ss.Init, ss.Stopped, &ss.NetView)
+}
+
 // SaveWeights saves the network weights -- when called with giv.CallMethod
 // it will auto-prompt for filename
 func (ss *Sim) SaveWeights(filename gi.FileName) {
@@ -1509,6 +1524,8 @@ func (ss *Sim) ConfigGui() *gi.Window {
 			ss.NewRndSeed()
 		})
 
+	bench.AddAction(tbar, win, &ss.IsRunning, ss.Benchmark)
+
 	tbar.AddAction(gi.ActOpts{Label: "README", Icon: "file-markdown", Tooltip: "Opens your browser on the README file that contains instructions for how to run this model."}, win.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			gi.OpenURL("https://github.com/CompCogNeuro/sims/blob/master/ch6/objrec/README.md")
@@ -1589,7 +1606,8 @@ func (ss *Sim) ConfigGui() *gi.Window {
 // These props register Save methods so they can be used
 var SimProps = ki.Props{
 	"CallMethods": ki.PropSlice{
-		{"SaveWts", ki.Props{
+		{"Benchmark", bench.MethodProps},
+		{"SaveWeights", ki.Props{
 			"desc": "save network weights to file",
 			"icon": "file-save",
 			"Args": ki.PropSlice{
@@ -1606,6 +1624,7 @@ func (ss *Sim) CmdArgs() {
 	var nogui bool
 	var saveEpcLog bool
 	var saveRunLog bool
+	var scriptFile string
 	var note string
 	flag.StringVar(&ss.ParamSet, "params", "", "ParamSet name to use -- must be valid name as listed in compiled-in params or loaded params")
 	flag.StringVar(&ss.Tag, "tag", "", "extra tag to add to file names saved from this run")
@@ -1615,9 +1634,16 @@ func (ss *Sim) CmdArgs() {
 	flag.BoolVar(&ss.SaveWts, "wts", false, "if true, save final weights after each run")
 	flag.BoolVar(&saveEpcLog, "epclog", true, "if true, save train epoch log to file")
 	flag.BoolVar(&saveRunLog, "runlog", true, "if true, save run epoch log to file")
+	flag.StringVar(&scriptFile, "script", "", "if set, run the commands in given script file instead of the default training")
 	flag.BoolVar(&nogui, "nogui", true, "if not passing any other args and want to run nogui, use nogui")
+	ss.Bench.AddFlags()
+	ss.Progress.AddFlags()
 	flag.Parse()
 	ss.Init()
+	if bench.Flagged() {
+		ss.Benchmark()
+		return
+	}
 
 	if note != "" {
 		fmt.Printf("note: %s\n", note)
@@ -1653,6 +1679,43 @@ func (ss *Sim) CmdArgs() {
 	if ss.SaveWts {
 		fmt.Printf("Saving final weights per run\n")
 	}
-	fmt.Printf("Running %d Runs\n", ss.MaxRuns)
-	ss.Train()
+	ss.Progress.Start()
+	if scriptFile != "" {
+		ss.RunScript(scriptFile)
+	} else {
+		fmt.Printf("Running %d Runs\n", ss.MaxRuns)
+		ss.Train()
+	}
+	ss.Progress.Done()
+}
+
+// ConfigScript registers the toolbar actions and the SimProps CallMethods
+// as commands that can be used in a script file passed with -script
+func (ss *Sim) ConfigScript(sc *script.Interp) {
+	sc.AddAction("Init", "initialize everything including network weights, and start over", ss.Init)
+	sc.AddSteps("Train", "run training to the end, or for given number of steps", ss.Train, map[string]func(){
+		"trial": ss.TrainTrial,
+		"epoch": ss.TrainEpoch,
+		"run":   ss.TrainRun,
+	})
+	sc.AddAction("OpenTrainedWts", "open weights trained on first phase of training (excluding 'novel' objects)", ss.OpenTrainedWts)
+	sc.AddAction("TrainNovel", "prepare network for training novel items: loads saved weights, changes PNovel", ss.TrainNovel)
+	sc.AddAction("TestTrial", "run the next testing trial", func() { ss.TestTrial(false) })
+	sc.AddMethod("TestItem", "test the item at given index")
+	sc.AddAction("TestAll", "test all of the testing trials", ss.RunTestAll)
+	sc.AddAction("ResetRunLog", "reset the accumulated log of all Runs", func() { ss.RunLog.SetNumRows(0) })
+	sc.AddAction("NewSeed", "generate a new initial random seed to get different results", ss.NewRndSeed)
+	if err := sc.AddCallMethods(SimProps); err != nil {
+		log.Println(err)
+	}
+}
+
+// RunScript runs the commands in given script file, exiting on any error
+func (ss *Sim) RunScript(fnm string) {
+	sc := script.New(ss)
+	ss.ConfigScript(sc)
+	fmt.Printf("Running script: %s\n", fnm)
+	if err := sc.RunFile(fnm); err != nil {
+		log.Fatalln(err)
+	}
 }
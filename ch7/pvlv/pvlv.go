@@ -18,6 +18,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/CompCogNeuro/sims/bench"
+	"github.com/CompCogNeuro/sims/progress"
+	"github.com/CompCogNeuro/sims/script"
 	"github.com/emer/emergent/env"
 	"github.com/emer/emergent/stepper"
 	_ "github.com/emer/etable/agg"
@@ -47,7 +50,10 @@ var TheSim Sim // this is in a global mainly for debugging--otherwise it can be
 
 func main() {
 	// TheSim is the overall state for this simulation
-	TheSim.VerboseInit, TheSim.LayerThreads = TheSim.CmdArgs() // doesn't return if nogui command line arg set
+	TheSim.VerboseInit, TheSim.LayerThreads = TheSim.CmdArgs() // runs without the gui if nogui or script command line arg set
+	if TheSim.NoGui {
+		return
+	}
 	TheSim.New()
 	TheSim.Config()
 	gimain.Main(func() { // this starts the GUI
@@ -108,6 +114,8 @@ type Sim struct {
 	TimeLogBlock                 int                 `desc:"current block within current run phase"`
 	TimeLogBlockAll              int                 `desc:"current block across all phases of the run"`
 	Time                         leabra.Time         `desc:"leabra timing parameters and state"`
+	Bench                        bench.Bench         `view:"no-inline" desc:"benchmark parameters and results -- see Benchmark"`
+	Progress                     progress.Progress   `view:"-" desc:"progress display for runs without the gui"`
 	ViewOn                       bool                `desc:"whether to update the network view while running"`
 	TrainUpdt                    leabra.TimeScales   `desc:"at what time scale to update the display during training?  Anything longer than TrialGp updates at TrialGp in this model"`
 	TestUpdt                     leabra.TimeScales   `desc:"at what time scale to update the display during testing?  Anything longer than TrialGp updates at TrialGp in this model"`
@@ -160,6 +168,12 @@ type Sim struct {
 // prompt for filename for save methods.
 var KiT_Sim = kit.Types.AddType(&Sim{}, SimProps)
 
+// SaveWeights saves the network weights -- when called with giv.CallMethod
+// it will auto-prompt for filename
+func (ss *Sim) SaveWeights(filename gi.FileName) {
+	ss.Net.SaveWtsJSON(filename)
+}
+
 func (ss *Sim) OpenCemerWeights(fName string) {
 	err := ss.Net.OpenWtsCpp(gi.FileName(fName))
 	if err != nil {
@@ -200,7 +214,8 @@ func (ss *Sim) New() {
 	ss.Params = ParamSets
 	ss.CycleOutputDataRows = 10000
 	ss.InitHasRun = false
-
+	ss.Bench.Defaults("pvlv")
+	ss.Progress.Defaults("pvlv")
 }
 
 func (ss *Sim) Defaults() {
@@ -575,6 +590,38 @@ func (ss *Sim) Paused() bool {
 	return ss.Stepper.RunState == stepper.Paused
 }
 
+// Benchmark runs Bench.NTrials training alpha cycles for each of the thread
+// layouts in Bench, with the network view and cycle logging off, and reports
+// the speed of each -- the results are in Bench.  The alpha cycles all use
+// the current Env inputs, without stepping through the trial blocks, so this
+// only measures the speed of the network.  Does InitSim at the end to start over.
+func (ss *Sim) Benchmark() {
+	nv, clog := ss.NetView, ss.CycleLogUpdt
+	ss.NetView, ss.CycleLogUpdt = nil, leabra.AlphaCycle
+	ss.InitSim()
+	ss.Stepper.Enter(stepper.Running)
+	trial := func() {
+		ss.TrialStart(true)
+		ss.ApplyInputs()
+		ss.SettleMinus(true)
+		ss.ApplyInputs()
+		ss.ApplyPVInputs()
+		ss.SettlePlus(true)
+		ss.Net.DWt()
+	}
+	err := ss.Bench.Run(ss.Net.AsLeabra(), &ss.Time, trial)
+	if err != nil {
+		log.Println(err)
+	}
+	ss.Stepper.Stop()
+	ss.NetView, ss.CycleLogUpdt = nv, clog
+	ss.InitSim()
+	ss.IsRunning = false
+	if ss.ToolBar != nil {
+		ss.ToolBar.UpdateActions()
+	}
+}
+
 var CemerWtsFname = ""
 
 func FileViewLoadCemerWts(vp *gi.Viewport2D) {
@@ -819,6 +866,8 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		ss.StepsToRun = int(ss.nStepsBox.Value)
 	})
 
+	bench.AddAction(tbar, win, &ss.IsRunning, ss.Benchmark)
+
 	tbar.AddAction(gi.ActOpts{Label: "README", Icon: "file-markdown", Tooltip: "Opens your browser on the README file that contains instructions for how to run this model."}, win.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			gi.OpenURL("https://github.com/CompCogNeuro/sims/blob/master/ch7/pvlv/README.md")
@@ -971,6 +1020,7 @@ var SimProps = ki.Props{
 	"max-width":  -1,
 	"max-height": -1,
 	"CallMethods": ki.PropSlice{
+		{"Benchmark", bench.MethodProps},
 		{"SaveWeights", ki.Props{
 			"desc": "save network weights to file",
 			"icon": "file-save",
@@ -1105,7 +1155,9 @@ func (ss *Sim) InitRun() error {
 		fmt.Println("ERROR: InitCondition failed")
 	}
 	ss.UpdateView(-1)
-	ss.Win.Viewport.SetNeedsFullRender()
+	if ss.Win != nil {
+		ss.Win.Viewport.SetNeedsFullRender()
+	}
 	return nil
 }
 
@@ -1170,7 +1222,9 @@ func (ss *Sim) ExecuteRun() bool {
 		if err != nil {
 			fmt.Println("ERROR: InitCondition failed in activateCondition")
 		}
-		ss.Win.WinViewport2D().SetNeedsFullRender()
+		if ss.Win != nil {
+			ss.Win.WinViewport2D().SetNeedsFullRender()
+		}
 	}
 	ss.TimeLogBlockAll = 0
 	for i, condition := range conditions {
@@ -1195,6 +1249,35 @@ func (ss *Sim) ExecuteRun() bool {
 
 // end Run
 
+// Run runs the currently selected RunParams to the end, initializing first
+// if needed, as the Run toolbar action does, but without the gui
+func (ss *Sim) Run() {
+	if !ss.InitHasRun {
+		ss.InitSim()
+	}
+	if !ss.SimHasRun {
+		_ = ss.InitRun()
+	}
+	ss.SimHasRun = true
+	ss.Stepper.Enter(stepper.Running)
+	ss.ExecuteRun()
+}
+
+// RunFrac returns the fraction of the current RunParams that is done,
+// for the progress display: each condition counts the same, regardless
+// of how many blocks it has
+func (ss *Sim) RunFrac() float64 {
+	ev := &ss.Env
+	nConds := 0
+	for _, condition := range ss.GetRunConditions(ss.RunParams) {
+		if condition == nil || condition.Nm == "NullStep" {
+			break
+		}
+		nConds++
+	}
+	return progress.Frac(ev.ConditionCt.Cur, nConds, ev.TrialBlockCt.Cur, ev.TrialBlockCt.Max, ev.TrialCt.Cur, ev.TrialCt.Max)
+}
+
 // Multiple trial types
 func (ss *Sim) ExecuteBlocks(seqRun bool) {
 	ev := &ss.Env
@@ -1474,6 +1557,9 @@ func (ss *Sim) LogCycleData() {
 			dt.SetCellFloat(colNm, row, val)
 		}
 	}
+	if ss.CycleDataPlot == nil {
+		return
+	}
 	label := fmt.Sprintf("%20s: %3d", ev.AlphaTrialName, row)
 	ss.CycleDataPlot.Params.XAxisLabel = label
 	if ss.CycleLogUpdt == leabra.Quarter || row%25 == 0 {
@@ -1494,6 +1580,7 @@ func (ss *Sim) CmdArgs() (verbose, threads bool) {
 	var nogui bool
 	var saveEpcLog bool
 	var saveRunLog bool
+	var scriptFile string
 	var note string
 	flag.StringVar(&ss.ParamSet, "params", "", "ParamSet name to use -- must be valid name as listed in compiled-in params or loaded params")
 	flag.StringVar(&ss.Tag, "tag", "", "extra tag to add to file names saved from this run")
@@ -1503,17 +1590,37 @@ func (ss *Sim) CmdArgs() (verbose, threads bool) {
 	flag.BoolVar(&ss.SaveWts, "wts", false, "if true, save final weights after each run")
 	flag.BoolVar(&saveEpcLog, "blklog", true, "if true, save train block log to file")
 	flag.BoolVar(&saveRunLog, "runlog", true, "if true, save run log to file")
+	flag.StringVar(&scriptFile, "script", "", "if set, run the commands in given script file without the gui, instead of the default run")
 	flag.BoolVar(&nogui, "nogui", false, "if not passing any other args and want to run nogui, use nogui")
 	flag.BoolVar(&verbose, "verbose", false, "give more feedback during initialization")
 	flag.BoolVar(&threads, "threads", false, "use per-layer threads")
 	flag.BoolVar(&ss.devMenuSetup, "wide-step-menus", false, "use wide (development) stepping menu setup")
+	ss.Bench.Defaults("pvlv")
+	ss.Bench.AddFlags()
+	ss.Progress.Defaults("pvlv")
+	ss.Progress.AddFlags()
 	flag.Parse()
 
-	if !nogui {
+	if bench.Flagged() {
+		bm := ss.Bench
+		ss.New()
+		ss.LayerThreads = threads
+		ss.Config()
+		ss.Bench = bm
+		ss.Benchmark()
+		os.Exit(0)
+	}
+
+	if !nogui && scriptFile == "" {
 		return verbose, threads
 	}
 
-	ss.NoGui = nogui
+	ss.NoGui = true
+	pr := ss.Progress
+	ss.New()
+	ss.Progress = pr
+	ss.LayerThreads = threads
+	ss.Config()
 	ss.InitSim()
 
 	if note != "" {
@@ -1546,11 +1653,43 @@ func (ss *Sim) CmdArgs() (verbose, threads bool) {
 	if ss.SaveWts {
 		fmt.Printf("Saving final weights per run\n")
 	}
-	fmt.Printf("Running %d Conditions\n", ss.MaxConditions)
-	ss.ExecuteRun()
+	ss.Progress.Start()
+	if scriptFile != "" {
+		ss.RunScript(scriptFile)
+	} else {
+		fmt.Printf("Running %d Conditions\n", ss.MaxConditions)
+		ss.Run()
+	}
+	ss.Progress.Done()
 	return verbose, threads
 }
 
+// ConfigScript registers the toolbar actions and the SimProps CallMethods
+// as commands that can be used in a script file passed with -script
+func (ss *Sim) ConfigScript(sc *script.Interp) {
+	sc.AddAction("Init", "initialize the network weights and the current RunParams, and start over", func() {
+		ss.Stepper.Stop()
+		ss.InitSim()
+		ss.SimHasRun = false
+		_ = ss.InitRun()
+	})
+	sc.AddAction("Run", "run the currently selected RunParams (set with: Set RunParamsNm <name>)", ss.Run)
+	sc.AddAction("NewSeed", "generate a new initial random seed to get different results", ss.NewRndSeed)
+	if err := sc.AddCallMethods(SimProps); err != nil {
+		log.Println(err)
+	}
+}
+
+// RunScript runs the commands in given script file, exiting on any error
+func (ss *Sim) RunScript(fnm string) {
+	sc := script.New(ss)
+	ss.ConfigScript(sc)
+	fmt.Printf("Running script: %s\n", fnm)
+	if err := sc.RunFile(fnm); err != nil {
+		log.Fatalln(err)
+	}
+}
+
 // GetTrialBlockParams looks up a TrialBlockRecs by name. The second return value is true if found.
 func (ss *Sim) GetTrialBlockParams(nm string) (*data.TrialBlockRecs, bool) {
 	groups, ok := ss.MasterTrialBlockParams[nm]
@@ -151,6 +151,9 @@ func (ss *Sim) TrialEnd(_ *PVLVEnv, train bool) {
 	if ss.ViewOn && viewUpdt == leabra.Trial {
 		ss.UpdateView(-1)
 	}
+	if ss.NoGui && ss.Progress.Due() {
+		ss.Progress.Update(ss.Counters(), ss.RunFrac(), nil)
+	}
 }
 
 // ApplyInputs applies input patterns from given environment.
@@ -11,6 +11,7 @@ package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 	"log"
 	"math/rand"
@@ -19,6 +20,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/CompCogNeuro/sims/bench"
+	"github.com/CompCogNeuro/sims/pairpats"
+	"github.com/CompCogNeuro/sims/patfile"
+	"github.com/CompCogNeuro/sims/script"
 	"github.com/emer/emergent/emer"
 	"github.com/emer/emergent/env"
 	"github.com/emer/emergent/netview"
@@ -46,10 +51,15 @@ import (
 
 // this is the stub main for gogi that calls our actual mainrun function, at end of file
 func main() {
-	gimain.Main(func() {
-		mainrun()
-
-	})
+	if bench.HasFlag(os.Args[1:], "script") {
+		TheSim.New()
+		TheSim.Config()
+		TheSim.CmdArgs() // -bench or -script arg = run without the gui
+	} else {
+		gimain.Main(func() { // this starts gui -- requires valid OpenGL display connection (e.g., X11)
+			mainrun()
+		})
+	}
 }
 
 // LogPrec is precision for saving float values in logs
@@ -118,6 +128,7 @@ type Sim struct {
 	Net           *leabra.Network   `view:"no-inline" desc:"the network -- click to view / edit parameters for layers, prjns, etc"`
 	ABPats        *etable.Table     `view:"no-inline" desc:"AB paired associate training patterns"`
 	ACPats        *etable.Table     `view:"no-inline" desc:"AC paired associate training patterns"`
+	PairPats      pairpats.Params   `view:"no-inline" desc:"parameters for generating new AB and AC patterns with GenPats"`
 	TrnEpcLog     *etable.Table     `view:"no-inline" desc:"training epoch-level log data"`
 	TstEpcLog     *etable.Table     `view:"no-inline" desc:"testing epoch-level log data"`
 	TstTrlLog     *etable.Table     `view:"no-inline" desc:"testing trial-level log data"`
@@ -132,6 +143,7 @@ type Sim struct {
 	TrainEnv      env.FixedTable    `desc:"Training environment -- contains everything about iterating over input / output patterns over training"`
 	TestEnv       env.FixedTable    `desc:"Training environment -- contains everything about iterating over input / output patterns over training"`
 	Time          leabra.Time       `desc:"leabra timing parameters and state"`
+	Bench         bench.Bench       `view:"no-inline" desc:"benchmark parameters and results -- see Benchmark"`
 	ViewOn        bool              `desc:"whether to update the network view while running"`
 	TrainUpdt     leabra.TimeScales `desc:"at what time scale to update the display during training?  Anything longer than Epoch updates at Epoch in this model"`
 	TestUpdt      leabra.TimeScales `desc:"at what time scale to update the display during testing?  Anything longer than Epoch updates at Epoch in this model"`
@@ -189,6 +201,7 @@ func (ss *Sim) New() {
 	ss.Net = &leabra.Network{}
 	ss.ABPats = &etable.Table{}
 	ss.ACPats = &etable.Table{}
+	ss.PairPats.ABACDefaults()
 	ss.TrnEpcLog = &etable.Table{}
 	ss.TstEpcLog = &etable.Table{}
 	ss.TstTrlLog = &etable.Table{}
@@ -204,6 +217,7 @@ func (ss *Sim) New() {
 	ss.TstNms = []string{"AB", "AC"}
 	ss.TstStatNms = []string{"Err"}
 	ss.HiddenReps.Init()
+	ss.Bench.Defaults("abac")
 }
 
 func (ss *Sim) Defaults() {
@@ -253,6 +267,16 @@ func (ss *Sim) ConfigEnv() {
 	ss.TestEnv.Init(0)
 }
 
+// SetEnv select which set of patterns to train on: AB or AC
+func (ss *Sim) SetEnv(trainAC bool) {
+	if trainAC {
+		ss.TrainEnv.Table = etable.NewIdxView(ss.ACPats)
+	} else {
+		ss.TrainEnv.Table = etable.NewIdxView(ss.ABPats)
+	}
+	ss.TrainEnv.Init(0)
+}
+
 func (ss *Sim) ConfigNet(net *leabra.Network) {
 	net.InitName(net, "ABAC")
 	inp := net.AddLayer2D("Input", 5, 5, emer.Input)
@@ -562,6 +586,11 @@ func (ss *Sim) Stopped() {
 	}
 }
 
+// Benchmark runs the Bench benchmark with TrainTrial (see bench.Bench.RunSim)
+func (ss *Sim) Benchmark() {
+	ss.Bench.RunSim(ss.Net, &ss.Time, ss.TrainTrial, ss.Init, ss.Stopped, &ss.NetView)
+}
+
 // SaveWeights saves the network weights -- when called with giv.CallMethod
 // it will auto-prompt for filename
 func (ss *Sim) SaveWeights(filename gi.FileName) {
@@ -774,6 +803,56 @@ func (ss *Sim) OpenPats() {
 	// err := dt.OpenCSV("ac_pats.tsv", etable.Tab)
 }
 
+// OpenPatsFile opens patterns from given .tsv or .csv file in place of the
+// AB (or AC) training patterns, checking that they fit the Input, Context
+// and Output layers, and tests on them.  Training stays on the current
+// AB or AC list, using the new patterns if it is the one that was opened.
+func (ss *Sim) OpenPatsFile(filename gi.FileName, ac bool) error {
+	dt := ss.ABPats
+	if ac {
+		dt = ss.ACPats
+	}
+	err := patfile.Open(dt, string(filename), ss.Net, "Input", "Context", "Output")
+	if err != nil {
+		patfile.Report(ss.Win, err)
+		return err
+	}
+	if ss.TrainEnv.Table.Table == dt { // update the view for the new rows
+		ss.TrainEnv.Table = etable.NewIdxView(dt)
+		ss.TrainEnv.Init(0)
+	}
+	ss.TestEnv.Table = etable.NewIdxView(dt)
+	ss.TestEnv.Init(0)
+	return nil
+}
+
+// GenPats generates new AB and AC patterns according to the PairPats params,
+// replacing the current ones, e.g., to vary the list size or the overlap
+// between items.  Training starts again on the AB patterns.
+func (ss *Sim) GenPats() error {
+	train, test, err := ss.PairPats.Generate()
+	if err == nil {
+		err = patfile.ValidateTables(append(train, test...), ss.Net, "Input", "Context", "Output")
+	}
+	if err != nil {
+		patfile.Report(ss.Win, err)
+		return err
+	}
+	for i, ls := range ss.PairPats.Lists {
+		switch ls {
+		case "AB":
+			pairpats.SetTable(ss.ABPats, train[i])
+		case "AC":
+			pairpats.SetTable(ss.ACPats, train[i])
+		}
+	}
+	ss.TrainEnv.Table = etable.NewIdxView(ss.ABPats)
+	ss.TrainEnv.Init(0)
+	ss.TestEnv.Table = etable.NewIdxView(ss.ABPats)
+	ss.TestEnv.Init(0)
+	return nil
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////
 // 		Logging
 
@@ -1295,6 +1374,18 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		}
 	})
 
+	tbar.AddAction(gi.ActOpts{Label: "Open Pats", Icon: "file-open", Tooltip: "Open your own AB or AC training patterns from a .tsv or .csv file -- the columns must fit the Input, Context and Output layers."}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			giv.CallMethod(ss, "OpenPatsFile", vp)
+		})
+
+	tbar.AddAction(gi.ActOpts{Label: "Gen Pats", Icon: "new", Tooltip: "Generate new AB and AC patterns according to the PairPats params, e.g., to vary the list size or the overlap between items.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		ss.GenPats()
+		vp.SetNeedsFullRender()
+	})
+
 	tbar.AddSeparator("log")
 
 	tbar.AddAction(gi.ActOpts{Label: "Reset RunLog", Icon: "update", Tooltip: "Reset the accumulated log of all Runs, which are tagged with the ParamSet used"}, win.This(),
@@ -1326,6 +1417,8 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		vp.SetNeedsFullRender()
 	})
 
+	bench.AddAction(tbar, win, &ss.IsRunning, ss.Benchmark)
+
 	tbar.AddAction(gi.ActOpts{Label: "README", Icon: "file-markdown", Tooltip: "Opens your browser on the README file that contains instructions for how to run this model."}, win.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			gi.OpenURL("https://github.com/CompCogNeuro/sims/blob/master/ch8/abac/README.md")
@@ -1406,6 +1499,21 @@ func (ss *Sim) ConfigGui() *gi.Window {
 // These props register Save methods so they can be used
 var SimProps = ki.Props{
 	"CallMethods": ki.PropSlice{
+		{"Benchmark", bench.MethodProps},
+		{"GenPats", ki.Props{
+			"desc": "generate new AB and AC patterns according to the PairPats params",
+			"icon": "new",
+		}},
+		{"OpenPatsFile", ki.Props{
+			"desc": "open patterns from .tsv or .csv file, replacing the current ones",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".tsv,.csv",
+				}},
+				{"AC", ki.Props{}},
+			},
+		}},
 		{"SaveWeights", ki.Props{
 			"desc": "save network weights to file",
 			"icon": "file-save",
@@ -1415,9 +1523,71 @@ var SimProps = ki.Props{
 				}},
 			},
 		}},
+		{"SetEnv", ki.Props{
+			"desc": "select which set of patterns to train on: AB or AC",
+			"icon": "gear",
+			"Args": ki.PropSlice{
+				{"Train on AC", ki.Props{}},
+			},
+		}},
 	},
 }
 
+// CmdArgs runs without the gui: either the commands in the script file
+// given by the -script arg, or else the benchmark, using the -bench,
+// -benchthreads and -benchfile args (-bench defaults to 100 trials)
+func (ss *Sim) CmdArgs() {
+	var scriptFile string
+	flag.StringVar(&scriptFile, "script", "", "if set, run the commands in given script file instead of the benchmark")
+	ss.Bench.AddFlags()
+	flag.Parse()
+	if scriptFile != "" {
+		ss.Init()
+		ss.RunScript(scriptFile)
+		return
+	}
+	ss.Benchmark()
+}
+
+// ConfigScript registers the toolbar actions and the SimProps CallMethods
+// as commands that can be used in a script file passed with -script
+func (ss *Sim) ConfigScript(sc *script.Interp) {
+	sc.AddAction("Init", "initialize everything including network weights, and start over", ss.Init)
+	sc.AddSteps("Train", "run training to the end, or for given number of steps", ss.Train, map[string]func(){
+		"trial": ss.TrainTrial,
+		"epoch": ss.TrainEpoch,
+		"run":   ss.TrainRun,
+	})
+	sc.AddAction("TestTrial", "run the next testing trial", func() { ss.TestTrial(false) })
+	sc.AddFunc("TestItem", "test the first testing pattern whose Name contains given string", func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("expected one pattern name, got: %v", args)
+		}
+		idxs := ss.TestEnv.Table.RowsByString("Name", args[0], etable.Contains, etable.IgnoreCase)
+		if len(idxs) == 0 {
+			return fmt.Errorf("no patterns found containing: %s", args[0])
+		}
+		ss.TestItem(idxs[0])
+		return nil
+	})
+	sc.AddAction("TestAll", "test all of the testing trials", ss.RunTestAll)
+	sc.AddAction("RepsAnalysis", "do an All Test All and analyze the resulting Hidden activations", ss.RepsAnalysis)
+	sc.AddAction("NewSeed", "generate a new initial random seed to get different results", ss.NewRndSeed)
+	if err := sc.AddCallMethods(SimProps); err != nil {
+		log.Println(err)
+	}
+}
+
+// RunScript runs the commands in given script file, exiting on any error
+func (ss *Sim) RunScript(fnm string) {
+	sc := script.New(ss)
+	ss.ConfigScript(sc)
+	fmt.Printf("Running script: %s\n", fnm)
+	if err := sc.RunFile(fnm); err != nil {
+		log.Fatalln(err)
+	}
+}
+
 func mainrun() {
 	TheSim.New()
 	TheSim.Config()
@@ -16,6 +16,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/CompCogNeuro/sims/bench"
+	"github.com/CompCogNeuro/sims/pairpats"
+	"github.com/CompCogNeuro/sims/patfile"
+	"github.com/CompCogNeuro/sims/progress"
+	"github.com/CompCogNeuro/sims/script"
 	"github.com/emer/emergent/emer"
 	"github.com/emer/emergent/env"
 	"github.com/emer/emergent/netview"
@@ -186,6 +191,7 @@ type Sim struct {
 	TestAB       *etable.Table            `view:"no-inline" desc:"AB testing patterns to use"`
 	TestAC       *etable.Table            `view:"no-inline" desc:"AC testing patterns to use"`
 	TestLure     *etable.Table            `view:"no-inline" desc:"Lure testing patterns to use"`
+	PairPats     pairpats.Params          `view:"no-inline" desc:"parameters for generating new AB, AC and lure patterns with GenPats -- lists other than AB and AC are used for lures"`
 	TrnTrlLog    *etable.Table            `view:"no-inline" desc:"training trial-level log data"`
 	TrnEpcLog    *etable.Table            `view:"no-inline" desc:"training epoch-level log data"`
 	TstEpcLog    *etable.Table            `view:"no-inline" desc:"testing epoch-level log data"`
@@ -204,6 +210,7 @@ type Sim struct {
 	TrainEnv     env.FixedTable           `desc:"Training environment -- contains everything about iterating over input / output patterns over training"`
 	TestEnv      env.FixedTable           `desc:"Testing environment -- manages iterating over testing"`
 	Time         leabra.Time              `desc:"leabra timing parameters and state"`
+	Bench        bench.Bench              `view:"no-inline" desc:"benchmark parameters and results -- see Benchmark"`
 	ViewOn       bool                     `desc:"whether to update the network view while running"`
 	TrainUpdt    leabra.TimeScales        `desc:"at what time scale to update the display during training?  Anything longer than Epoch updates at Epoch in this model"`
 	TestUpdt     leabra.TimeScales        `desc:"at what time scale to update the display during testing?  Anything longer than Epoch updates at Epoch in this model"`
@@ -255,6 +262,7 @@ type Sim struct {
 	ValsTsrs     map[string]*etensor.Float32 `view:"-" desc:"for holding layer values"`
 	SaveWts      bool                        `view:"-" desc:"for command-line run only, auto-save final weights after each run"`
 	NoGui        bool                        `view:"-" desc:"if true, runing in no GUI mode"`
+	Progress     progress.Progress           `view:"-" desc:"progress display for runs without the gui"`
 	LogSetParams bool                        `view:"-" desc:"if true, print message for all params that are set"`
 	IsRunning    bool                        `view:"-" desc:"true if sim is running"`
 	StopNow      bool                        `view:"-" desc:"flag to stop running"`
@@ -278,6 +286,8 @@ func (ss *Sim) New() {
 	ss.TestAB = &etable.Table{}
 	ss.TestAC = &etable.Table{}
 	ss.TestLure = &etable.Table{}
+	ss.PairPats.HipDefaults()
+	ss.PairPats.Lists = append(ss.PairPats.Lists, "DE")
 	ss.TrnTrlLog = &etable.Table{}
 	ss.TrnEpcLog = &etable.Table{}
 	ss.TstEpcLog = &etable.Table{}
@@ -298,6 +308,8 @@ func (ss *Sim) New() {
 	ss.LayStatNms = []string{"ECin", "ECout", "DG", "CA3", "CA1"}
 	ss.TstNms = []string{"AB", "AC", "Lure"}
 	ss.TstStatNms = []string{"Mem", "TrgOnWasOff", "TrgOffWasOn"}
+	ss.Bench.Defaults("hip")
+	ss.Progress.Defaults("hip")
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////
@@ -646,6 +658,9 @@ func (ss *Sim) TrainTrial() {
 	ss.AlphaCyc(true)   // train
 	ss.TrialStats(true) // accumulate
 	ss.LogTrnTrl(ss.TrnTrlLog)
+	if ss.NoGui && ss.Progress.Due() {
+		ss.Progress.Update(ss.Counters(true), progress.Frac(ss.TrainEnv.Run.Cur, ss.MaxRuns, ss.TrainEnv.Epoch.Cur, ss.MaxEpcs, ss.TrainEnv.Trial.Cur, ss.TrainEnv.Trial.Max), ss.TrnEpcLog)
+	}
 }
 
 // RunEnd is called at the end of a run -- save weights, record final log, etc here
@@ -836,6 +851,11 @@ func (ss *Sim) Stopped() {
 	}
 }
 
+// Benchmark runs the Bench benchmark with TrainTrial (see bench.Bench.RunSim)
+func (ss *Sim) Benchmark() {
+	ss.Bench.RunSim(ss.Net, &ss.Time, ss.TrainTrial, ss.Init, ss.Stopped, &ss.NetView)
+}
+
 // SaveWeights saves the network weights -- when called with giv.CallMethod
 // it will auto-prompt for filename
 func (ss *Sim) SaveWeights(filename gi.FileName) {
@@ -1025,6 +1045,56 @@ func (ss *Sim) OpenPats() {
 	ss.OpenPatAsset(ss.TestLure, "test_lure.tsv", "TestLure", "Lure Testing Patterns")
 }
 
+// OpenPatsFile opens patterns from given .tsv or .csv file in place of the
+// AB (or AC) training patterns, checking that they fit the Input and ECout
+// layers, and trains on them as in SetEnv.  The testing patterns are not
+// changed.
+func (ss *Sim) OpenPatsFile(filename gi.FileName, trainAC bool) error {
+	dt := ss.TrainAB
+	if trainAC {
+		dt = ss.TrainAC
+	}
+	err := patfile.Open(dt, string(filename), ss.Net, "Input", "ECout")
+	if err != nil {
+		patfile.Report(ss.Win, err)
+		return err
+	}
+	ss.SetEnv(trainAC)
+	return nil
+}
+
+// GenPats generates new patterns according to the PairPats params,
+// replacing the current ones: the AB and AC lists are used for training
+// and testing, and the testing patterns of any other list (novel items)
+// are used as the lures.
+func (ss *Sim) GenPats() error {
+	train, test, err := ss.PairPats.Generate()
+	if err == nil && len(test) != len(train) {
+		err = fmt.Errorf("pairpats: no Test column for the testing patterns")
+	}
+	if err == nil {
+		err = patfile.ValidateTables(append(train, test...), ss.Net, "Input", "ECout")
+	}
+	if err != nil {
+		patfile.Report(ss.Win, err)
+		return err
+	}
+	for i, ls := range ss.PairPats.Lists {
+		switch ls {
+		case "AB":
+			pairpats.SetTable(ss.TrainAB, train[i])
+			pairpats.SetTable(ss.TestAB, test[i])
+		case "AC":
+			pairpats.SetTable(ss.TrainAC, train[i])
+			pairpats.SetTable(ss.TestAC, test[i])
+		default:
+			pairpats.SetTable(ss.TestLure, test[i])
+		}
+	}
+	ss.SetEnv(false)
+	return nil
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////
 // 		Logging
 
@@ -1837,6 +1907,18 @@ func (ss *Sim) ConfigGui() *gi.Window {
 			giv.CallMethod(ss, "SetEnv", vp)
 		})
 
+	tbar.AddAction(gi.ActOpts{Label: "Open Pats", Icon: "file-open", Tooltip: "Open your own AB or AC training patterns from a .tsv or .csv file -- the columns must fit the Input and ECout layers."}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			giv.CallMethod(ss, "OpenPatsFile", vp)
+		})
+
+	tbar.AddAction(gi.ActOpts{Label: "Gen Pats", Icon: "new", Tooltip: "Generate new AB, AC and lure patterns according to the PairPats params, e.g., to vary the list size or the overlap between items.", UpdateFunc: func(act *gi.Action) {
+		act.SetActiveStateUpdt(!ss.IsRunning)
+	}}, win.This(), func(recv, send ki.Ki, sig int64, data interface{}) {
+		ss.GenPats()
+		vp.SetNeedsFullRender()
+	})
+
 	tbar.AddSeparator("log")
 
 	tbar.AddAction(gi.ActOpts{Label: "Reset RunLog", Icon: "reset", Tooltip: "Reset the accumulated log of all Runs, which are tagged with the ParamSet used"}, win.This(),
@@ -1852,6 +1934,8 @@ func (ss *Sim) ConfigGui() *gi.Window {
 			ss.NewRndSeed()
 		})
 
+	bench.AddAction(tbar, win, &ss.IsRunning, ss.Benchmark)
+
 	tbar.AddAction(gi.ActOpts{Label: "README", Icon: "file-markdown", Tooltip: "Opens your browser on the README file that contains instructions for how to run this model."}, win.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			gi.OpenURL("https://github.com/CompCogNeuro/sims/blob/master/ch8/hip/README.md")
@@ -1932,6 +2016,21 @@ func (ss *Sim) ConfigGui() *gi.Window {
 // These props register Save methods so they can be used
 var SimProps = ki.Props{
 	"CallMethods": ki.PropSlice{
+		{"Benchmark", bench.MethodProps},
+		{"GenPats", ki.Props{
+			"desc": "generate new AB, AC and lure patterns according to the PairPats params",
+			"icon": "new",
+		}},
+		{"OpenPatsFile", ki.Props{
+			"desc": "open patterns from .tsv or .csv file, replacing the current ones",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".tsv,.csv",
+				}},
+				{"Train AC", ki.Props{}},
+			},
+		}},
 		{"SaveWeights", ki.Props{
 			"desc": "save network weights to file",
 			"icon": "file-save",
@@ -1956,6 +2055,7 @@ func (ss *Sim) CmdArgs() {
 	var nogui bool
 	var saveEpcLog bool
 	var saveRunLog bool
+	var scriptFile string
 	var note string
 	flag.StringVar(&ss.ParamSet, "params", "", "ParamSet name to use -- must be valid name as listed in compiled-in params or loaded params")
 	flag.StringVar(&ss.Tag, "tag", "", "extra tag to add to file names saved from this run")
@@ -1966,9 +2066,16 @@ func (ss *Sim) CmdArgs() {
 	flag.BoolVar(&ss.SaveWts, "wts", false, "if true, save final weights after each run")
 	flag.BoolVar(&saveEpcLog, "epclog", true, "if true, save train epoch log to file")
 	flag.BoolVar(&saveRunLog, "runlog", true, "if true, save run epoch log to file")
+	flag.StringVar(&scriptFile, "script", "", "if set, run the commands in given script file instead of the default training")
 	flag.BoolVar(&nogui, "nogui", true, "if not passing any other args and want to run nogui, use nogui")
+	ss.Bench.AddFlags()
+	ss.Progress.AddFlags()
 	flag.Parse()
 	ss.Init()
+	if bench.Flagged() {
+		ss.Benchmark()
+		return
+	}
 
 	if note != "" {
 		fmt.Printf("note: %s\n", note)
@@ -2004,8 +2111,53 @@ func (ss *Sim) CmdArgs() {
 	if ss.SaveWts {
 		fmt.Printf("Saving final weights per run\n")
 	}
-	fmt.Printf("Running %d Runs\n", ss.MaxRuns)
-	ss.Train()
+	ss.Progress.Start()
+	if scriptFile != "" {
+		ss.RunScript(scriptFile)
+	} else {
+		fmt.Printf("Running %d Runs\n", ss.MaxRuns)
+		ss.Train()
+	}
+	ss.Progress.Done()
 	fnm := ss.LogFileName("runs")
 	ss.RunStats.SaveCSV(gi.FileName(fnm), etable.Tab, etable.Headers)
 }
+
+// ConfigScript registers the toolbar actions and the SimProps CallMethods
+// as commands that can be used in a script file passed with -script
+func (ss *Sim) ConfigScript(sc *script.Interp) {
+	sc.AddAction("Init", "initialize everything including network weights, and start over", ss.Init)
+	sc.AddSteps("Train", "run training to the end, or for given number of steps", ss.Train, map[string]func(){
+		"trial": ss.TrainTrial,
+		"epoch": ss.TrainEpoch,
+		"run":   ss.TrainRun,
+	})
+	sc.AddAction("TestTrial", "run the next testing trial", func() { ss.TestTrial(false) })
+	sc.AddFunc("TestItem", "test the first testing pattern whose Name contains given string", func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("expected one pattern name, got: %v", args)
+		}
+		idxs := ss.TestEnv.Table.RowsByString("Name", args[0], etable.Contains, etable.IgnoreCase)
+		if len(idxs) == 0 {
+			return fmt.Errorf("no patterns found containing: %s", args[0])
+		}
+		ss.TestItem(idxs[0])
+		return nil
+	})
+	sc.AddAction("TestAll", "test all of the testing trials", ss.RunTestAll)
+	sc.AddAction("ResetRunLog", "reset the accumulated log of all Runs", func() { ss.RunLog.SetNumRows(0) })
+	sc.AddAction("NewSeed", "generate a new initial random seed to get different results", ss.NewRndSeed)
+	if err := sc.AddCallMethods(SimProps); err != nil {
+		log.Println(err)
+	}
+}
+
+// RunScript runs the commands in given script file, exiting on any error
+func (ss *Sim) RunScript(fnm string) {
+	sc := script.New(ss)
+	ss.ConfigScript(sc)
+	fmt.Printf("Running script: %s\n", fnm)
+	if err := sc.RunFile(fnm); err != nil {
+		log.Fatalln(err)
+	}
+}
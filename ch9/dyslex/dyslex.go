@@ -9,14 +9,19 @@ package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 	"log"
 	"math/rand"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/CompCogNeuro/sims/bench"
+	"github.com/CompCogNeuro/sims/patfile"
+	"github.com/CompCogNeuro/sims/script"
 	"github.com/emer/emergent/emer"
 	"github.com/emer/emergent/env"
 	"github.com/emer/emergent/netview"
@@ -43,9 +48,15 @@ import (
 
 // this is the stub main for gogi that calls our actual mainrun function, at end of file
 func main() {
-	gimain.Main(func() {
-		mainrun()
-	})
+	if bench.HasFlag(os.Args[1:], "script") {
+		TheSim.New()
+		TheSim.Config()
+		TheSim.CmdArgs() // -bench or -script arg = run without the gui
+	} else {
+		gimain.Main(func() { // this starts gui -- requires valid OpenGL display connection (e.g., X11)
+			mainrun()
+		})
+	}
 }
 
 // LogPrec is precision for saving float values in logs
@@ -155,6 +166,7 @@ type Sim struct {
 	TrainEnv     env.FixedTable    `desc:"Training environment -- contains everything about iterating over input / output patterns over training"`
 	TestEnv      env.FixedTable    `desc:"Testing environment -- manages iterating over testing"`
 	Time         leabra.Time       `desc:"leabra timing parameters and state"`
+	Bench        bench.Bench       `view:"no-inline" desc:"benchmark parameters and results -- see Benchmark"`
 	ViewOn       bool              `desc:"whether to update the network view while running"`
 	TrainUpdt    leabra.TimeScales `desc:"at what time scale to update the display during training?  Anything longer than Epoch updates at Epoch in this model"`
 	TestUpdt     leabra.TimeScales `desc:"at what time scale to update the display during testing?  Anything longer than Epoch updates at Epoch in this model"`
@@ -231,6 +243,7 @@ func (ss *Sim) New() {
 	ss.TestUpdt = leabra.Cycle
 	ss.TestInterval = 10
 	ss.LayStatNms = []string{"OShidden"}
+	ss.Bench.Defaults("dyslex")
 }
 
 func (ss *Sim) Defaults() {
@@ -778,6 +791,11 @@ func (ss *Sim) Stopped() {
 	}
 }
 
+// Benchmark runs the Bench benchmark with TrainTrial (see bench.Bench.RunSim)
+func (ss *Sim) Benchmark() {
+	ss.Bench.RunSim(ss.Net, &ss.Time, ss.TrainTrial, ss.Init, ss.Stopped, &ss.NetView)
+}
+
 // SaveWeights saves the network weights -- when called with giv.CallMethod
 // it will auto-prompt for filename
 func (ss *Sim) SaveWeights(filename gi.FileName) {
@@ -935,6 +953,22 @@ func (ss *Sim) OpenPats() {
 	ss.OpenPatAsset(ss.CloseSems, "close_sems.tsv", "CloseSems", "Close Semantic items")
 }
 
+// OpenPatsFile opens training patterns from given .tsv or .csv file in place
+// of the TrainPats, checking that they fit the Orthography, Semantics and
+// Phonology layers.
+func (ss *Sim) OpenPatsFile(filename gi.FileName) error {
+	err := patfile.Open(ss.TrainPats, string(filename), ss.Net, "Orthography", "Semantics", "Phonology")
+	if err != nil {
+		patfile.Report(ss.Win, err)
+		return err
+	}
+	ss.TrainEnv.Table = etable.NewIdxView(ss.TrainPats)
+	ss.TrainEnv.Init(0)
+	ss.TestEnv.Table = etable.NewIdxView(ss.TrainPats)
+	ss.TestEnv.Init(0)
+	return nil
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////
 // 		Logging
 
@@ -1503,6 +1537,11 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		}
 	})
 
+	tbar.AddAction(gi.ActOpts{Label: "Open Pats", Icon: "file-open", Tooltip: "Open your own training patterns from a .tsv or .csv file, in place of the TrainPats -- the columns must fit the Orthography, Semantics and Phonology layers."}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			giv.CallMethod(ss, "OpenPatsFile", vp)
+		})
+
 	tbar.AddSeparator("log")
 
 	tbar.AddAction(gi.ActOpts{Label: "Open Trained Wts", Icon: "update", Tooltip: "open weights trained for 250 epochs with default params", UpdateFunc: func(act *gi.Action) {
@@ -1540,6 +1579,8 @@ func (ss *Sim) ConfigGui() *gi.Window {
 			vp.SetNeedsFullRender()
 		})
 
+	bench.AddAction(tbar, win, &ss.IsRunning, ss.Benchmark)
+
 	tbar.AddAction(gi.ActOpts{Label: "README", Icon: "file-markdown", Tooltip: "Opens your browser on the README file that contains instructions for how to run this model."}, win.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			gi.OpenURL("https://github.com/CompCogNeuro/sims/blob/master/ch9/dyslex/README.md")
@@ -1620,6 +1661,16 @@ func (ss *Sim) ConfigGui() *gi.Window {
 // These props register Save methods so they can be used
 var SimProps = ki.Props{
 	"CallMethods": ki.PropSlice{
+		{"Benchmark", bench.MethodProps},
+		{"OpenPatsFile", ki.Props{
+			"desc": "open patterns from .tsv or .csv file, replacing the current ones",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".tsv,.csv",
+				}},
+			},
+		}},
 		{"SaveWeights", ki.Props{
 			"desc": "save network weights to file",
 			"icon": "file-save",
@@ -1640,6 +1691,66 @@ var SimProps = ki.Props{
 	},
 }
 
+// CmdArgs runs without the gui: either the commands in the script file
+// given by the -script arg, or else the benchmark, using the -bench,
+// -benchthreads and -benchfile args (-bench defaults to 100 trials)
+func (ss *Sim) CmdArgs() {
+	var scriptFile string
+	flag.StringVar(&scriptFile, "script", "", "if set, run the commands in given script file instead of the benchmark")
+	ss.Bench.AddFlags()
+	flag.Parse()
+	if scriptFile != "" {
+		ss.Init()
+		ss.RunScript(scriptFile)
+		return
+	}
+	ss.Benchmark()
+}
+
+// ConfigScript registers the toolbar actions and the SimProps CallMethods
+// as commands that can be used in a script file passed with -script
+func (ss *Sim) ConfigScript(sc *script.Interp) {
+	sc.AddAction("Init", "initialize everything including network weights, and start over", ss.Init)
+	sc.AddSteps("Train", "run training to the end, or for given number of steps", ss.Train, map[string]func(){
+		"trial": ss.TrainTrial,
+		"epoch": ss.TrainEpoch,
+		"run":   ss.TrainRun,
+	})
+	sc.AddAction("TestTrial", "run the next testing trial", func() { ss.TestTrial(false) })
+	sc.AddFunc("TestItem", "test the first testing pattern whose Name contains given string", func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("expected one pattern name, got: %v", args)
+		}
+		idxs := ss.TestEnv.Table.RowsByString("Name", args[0], etable.Contains, etable.IgnoreCase)
+		if len(idxs) == 0 {
+			return fmt.Errorf("no patterns found containing: %s", args[0])
+		}
+		ss.TestItem(idxs[0])
+		return nil
+	})
+	sc.AddAction("TestAll", "test all of the testing trials", ss.RunTestAll)
+	sc.AddAction("OpenTrainedWts", "open weights trained for 250 epochs with default params", ss.OpenTrainedWts)
+	sc.AddFunc("Lesion", "lesion the network using given type of lesion, and given proportion of neurons: Lesion <Type> <Proportion>", func(args []string) error {
+		_, err := script.CallMethod(reflect.ValueOf(ss.LesionNet), args)
+		return err
+	})
+	sc.AddAction("ResetRunLog", "reset the accumulated log of all Runs", func() { ss.RunLog.SetNumRows(0) })
+	sc.AddAction("NewSeed", "generate a new initial random seed to get different results", ss.NewRndSeed)
+	if err := sc.AddCallMethods(SimProps); err != nil {
+		log.Println(err)
+	}
+}
+
+// RunScript runs the commands in given script file, exiting on any error
+func (ss *Sim) RunScript(fnm string) {
+	sc := script.New(ss)
+	ss.ConfigScript(sc)
+	fmt.Printf("Running script: %s\n", fnm)
+	if err := sc.RunFile(fnm); err != nil {
+		log.Fatalln(err)
+	}
+}
+
 func mainrun() {
 	TheSim.New()
 	TheSim.Config()
@@ -18,6 +18,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/CompCogNeuro/sims/bench"
+	"github.com/CompCogNeuro/sims/patfile"
+	"github.com/CompCogNeuro/sims/progress"
+	"github.com/CompCogNeuro/sims/script"
 	"github.com/emer/emergent/emer"
 	"github.com/emer/emergent/env"
 	"github.com/emer/emergent/netview"
@@ -186,7 +190,8 @@ type Sim struct {
 	// Testing environment -- manages iterating over testing
 	TestEnv env.FixedTable `desc:"Testing environment -- manages iterating over testing"`
 	// leabra timing parameters and state
-	Time leabra.Time `desc:"leabra timing parameters and state"`
+	Time  leabra.Time `desc:"leabra timing parameters and state"`
+	Bench bench.Bench `view:"no-inline" desc:"benchmark parameters and results -- see Benchmark"`
 	// whether to update the network view while running
 	ViewOn bool `desc:"whether to update the network view while running"`
 	// at what time scale to update the display during training?  Anything longer than Epoch updates at Epoch in this model
@@ -270,7 +275,8 @@ type Sim struct {
 	// [view: -] for command-line run only, auto-save final weights after each run
 	SaveWts bool `view:"-" desc:"for command-line run only, auto-save final weights after each run"`
 	// [view: -] if true, runing in no GUI mode
-	NoGui bool `view:"-" desc:"if true, runing in no GUI mode"`
+	NoGui    bool              `view:"-" desc:"if true, runing in no GUI mode"`
+	Progress progress.Progress `view:"-" desc:"progress display for runs without the gui"`
 	// [view: -] if true, print message for all params that are set
 	LogSetParams bool `view:"-" desc:"if true, print message for all params that are set"`
 	// [view: -] true if sim is running
@@ -315,6 +321,9 @@ func (ss *Sim) New() {
 	ss.TestUpdt = leabra.Cycle
 	ss.TestInterval = -1
 	ss.LayStatNms = []string{"OrthoCode", "Hidden"}
+	ss.Bench.Defaults("ss")
+	ss.Progress.Defaults("ss")
+	ss.Progress.Stats = []string{"PctErr", "PctNameErr", "SSE", "CosDiff"}
 }
 
 func (ss *Sim) Defaults() {
@@ -583,6 +592,9 @@ func (ss *Sim) TrainTrial() {
 	ss.ApplyInputs(&ss.TrainEnv)
 	ss.AlphaCyc(true)                              // train
 	ss.TrialStats(true, ss.TrainEnv.TrialName.Cur) // accumulate
+	if ss.NoGui && ss.Progress.Due() {
+		ss.Progress.Update(ss.Counters(true), progress.Frac(ss.TrainEnv.Run.Cur, ss.MaxRuns, ss.TrainEnv.Epoch.Cur, ss.MaxEpcs, ss.TrainEnv.Trial.Cur, ss.TrainEnv.Trial.Max), ss.TrnEpcLog)
+	}
 }
 
 // RunEnd is called at the end of a run -- save weights, record final log, etc here
@@ -784,6 +796,11 @@ func (ss *Sim) Stopped() {
 	}
 }
 
+// Benchmark runs the Bench benchmark with TrainTrial (see bench.Bench.RunSim)
+func (ss *Sim) Benchmark() {
+	ss.Bench.RunSim(ss.Net, &ss.Time, ss.TrainTrial, ss.Init, ss.Stopped, &ss.NetView)
+}
+
 // SaveWeights saves the network weights -- when called with giv.CallMethod
 // it will auto-prompt for filename
 func (ss *Sim) SaveWeights(filename gi.FileName) {
@@ -960,6 +977,19 @@ func (ss *Sim) OpenPats() {
 	ss.OpenPatAsset(ss.PhonVowelPats, "phon_vowel.tsv", "PhonVowel", "Phonology patterns -- vowels")
 }
 
+// OpenPatsFile opens training patterns from given .tsv or .csv file in place
+// of the TrainPats, checking that they fit the Ortho and Phon layers.
+func (ss *Sim) OpenPatsFile(filename gi.FileName) error {
+	err := patfile.Open(ss.TrainPats, string(filename), ss.Net, "Ortho", "Phon")
+	if err != nil {
+		patfile.Report(ss.Win, err)
+		return err
+	}
+	ss.TrainEnv.Table = etable.NewIdxView(ss.TrainPats)
+	ss.TrainEnv.Init(0)
+	return nil
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////
 // 		Logging
 
@@ -1525,6 +1555,11 @@ func (ss *Sim) ConfigGui() *gi.Window {
 		}
 	})
 
+	tbar.AddAction(gi.ActOpts{Label: "Open Pats", Icon: "file-open", Tooltip: "Open your own training patterns from a .tsv or .csv file, in place of the TrainPats -- the columns must fit the Ortho and Phon layers."}, win.This(),
+		func(recv, send ki.Ki, sig int64, data interface{}) {
+			giv.CallMethod(ss, "OpenPatsFile", vp)
+		})
+
 	tbar.AddSeparator("log")
 
 	tbar.AddAction(gi.ActOpts{Label: "Open Trained Wts", Icon: "update", Tooltip: "open weights trained for 250 epochs with default params", UpdateFunc: func(act *gi.Action) {
@@ -1548,6 +1583,8 @@ func (ss *Sim) ConfigGui() *gi.Window {
 			vp.SetNeedsFullRender()
 		})
 
+	bench.AddAction(tbar, win, &ss.IsRunning, ss.Benchmark)
+
 	tbar.AddAction(gi.ActOpts{Label: "README", Icon: "file-markdown", Tooltip: "Opens your browser on the README file that contains instructions for how to run this model."}, win.This(),
 		func(recv, send ki.Ki, sig int64, data interface{}) {
 			gi.OpenURL("https://github.com/CompCogNeuro/sims/blob/master/ch9/ss/README.md")
@@ -1628,6 +1665,16 @@ func (ss *Sim) ConfigGui() *gi.Window {
 // These props register Save methods so they can be used
 var SimProps = ki.Props{
 	"CallMethods": ki.PropSlice{
+		{"Benchmark", bench.MethodProps},
+		{"OpenPatsFile", ki.Props{
+			"desc": "open patterns from .tsv or .csv file, replacing the current ones",
+			"icon": "file-open",
+			"Args": ki.PropSlice{
+				{"File Name", ki.Props{
+					"ext": ".tsv,.csv",
+				}},
+			},
+		}},
 		{"SaveWeights", ki.Props{
 			"desc": "save network weights to file",
 			"icon": "file-save",
@@ -1653,6 +1700,7 @@ func (ss *Sim) CmdArgs() {
 	var nogui bool
 	var saveEpcLog bool
 	var saveRunLog bool
+	var scriptFile string
 	flag.StringVar(&ss.ParamSet, "params", "", "ParamSet name to use -- must be valid name as listed in compiled-in params or loaded params")
 	flag.StringVar(&ss.Tag, "tag", "", "extra tag to add to file names saved from this run")
 	flag.IntVar(&ss.MaxRuns, "runs", 1, "number of runs to do (note that MaxEpcs is in paramset)")
@@ -1660,9 +1708,16 @@ func (ss *Sim) CmdArgs() {
 	flag.BoolVar(&ss.SaveWts, "wts", true, "if true, save final weights after each run")
 	flag.BoolVar(&saveEpcLog, "epclog", true, "if true, save train epoch log to file")
 	flag.BoolVar(&saveRunLog, "runlog", true, "if true, save run epoch log to file")
+	flag.StringVar(&scriptFile, "script", "", "if set, run the commands in given script file instead of the default training")
 	flag.BoolVar(&nogui, "nogui", true, "if not passing any other args and want to run nogui, use nogui")
+	ss.Bench.AddFlags()
+	ss.Progress.AddFlags()
 	flag.Parse()
 	ss.Init()
+	if bench.Flagged() {
+		ss.Benchmark()
+		return
+	}
 
 	if ss.ParamSet != "" {
 		fmt.Printf("Using ParamSet: %s\n", ss.ParamSet)
@@ -1695,6 +1750,52 @@ func (ss *Sim) CmdArgs() {
 	if ss.SaveWts {
 		fmt.Printf("Saving final weights per run\n")
 	}
-	fmt.Printf("Running %d Runs\n", ss.MaxRuns)
-	ss.Train()
+	ss.Progress.Start()
+	if scriptFile != "" {
+		ss.RunScript(scriptFile)
+	} else {
+		fmt.Printf("Running %d Runs\n", ss.MaxRuns)
+		ss.Train()
+	}
+	ss.Progress.Done()
+}
+
+// ConfigScript registers the toolbar actions and the SimProps CallMethods
+// as commands that can be used in a script file passed with -script
+func (ss *Sim) ConfigScript(sc *script.Interp) {
+	sc.AddAction("Init", "initialize everything including network weights, and start over", ss.Init)
+	sc.AddSteps("Train", "run training to the end, or for given number of steps", ss.Train, map[string]func(){
+		"trial": ss.TrainTrial,
+		"epoch": ss.TrainEpoch,
+		"run":   ss.TrainRun,
+	})
+	sc.AddAction("TestTrial", "run the next testing trial", func() { ss.TestTrial(false) })
+	sc.AddFunc("TestItem", "test the first testing pattern whose Name contains given string", func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("expected one pattern name, got: %v", args)
+		}
+		idxs := ss.TestEnv.Table.RowsByString("Name", args[0], etable.Contains, etable.IgnoreCase)
+		if len(idxs) == 0 {
+			return fmt.Errorf("no patterns found containing: %s", args[0])
+		}
+		ss.TestItem(idxs[0])
+		return nil
+	})
+	sc.AddAction("TestAll", "test all of the testing trials", ss.RunTestAll)
+	sc.AddAction("OpenTrainedWts", "open weights trained for 250 epochs with default params", ss.OpenTrainedWts)
+	sc.AddAction("ResetRunLog", "reset the accumulated log of all Runs", func() { ss.RunLog.SetNumRows(0) })
+	sc.AddAction("NewSeed", "generate a new initial random seed to get different results", ss.NewRndSeed)
+	if err := sc.AddCallMethods(SimProps); err != nil {
+		log.Println(err)
+	}
+}
+
+// RunScript runs the commands in given script file, exiting on any error
+func (ss *Sim) RunScript(fnm string) {
+	sc := script.New(ss)
+	ss.ConfigScript(sc)
+	fmt.Printf("Running script: %s\n", fnm)
+	if err := sc.RunFile(fnm); err != nil {
+		log.Fatalln(err)
+	}
 }
@@ -0,0 +1,582 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package csnet builds constraint-satisfaction networks, like those in the
+necker_cube and cats_dogs sims, from a simple declarative description of
+the units, groups, symmetric constraints and external inputs, so that
+other ambiguous figures and semantic networks can be explored without
+writing any code.
+
+Each group becomes a layer of the network, with its own inhibition, and
+each constraint becomes a symmetric weight between two units: positive
+constraints are excitatory weights, and negative ones are inhibitory
+weights, in separate projections of type emer.Inhib (class Neg).
+The external inputs are organized into named patterns, one per testing
+trial (see ConfigPats).
+
+Descriptions can be written in JSON (files ending in .json), with the same
+structure as the Net type, or in a line-oriented text format (all other
+files), where # starts a comment:
+
+	net NeckerCube                # name of the network
+	group Cube a b c d            # group (layer) named Cube, with units a..d
+	shape Cube 2 2                # optional 2D shape (rows, cols) of a group
+	gi Cube 1.4                   # optional inhibition (Layer.Inhib.Layer.Gi)
+	constraint Cube.a Cube.b 1    # symmetric constraint with weight 1
+	constraint a d -0.5           # units can be named without their group if unique
+	input Left Cube.a 1           # input value 1 to unit a in pattern Left
+	input All Cube.*              # * is all units in the group, value defaults to 1
+
+Weights must be between -1 and 1.  The same unit references, including
+Group.*, can be used in constraints, which then apply to all pairs.
+*/
+package csnet
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/emer/emergent/emer"
+	"github.com/emer/emergent/prjn"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+)
+
+// Group is a group of mutually-inhibiting units, which becomes a layer
+type Group struct {
+	Name  string   `desc:"name of the group, used as the layer name"`
+	Units []string `desc:"names of the units in the group, which must be unique within the group"`
+	Shape []int    `json:",omitempty" desc:"optional 2D shape (rows, cols) of the layer -- defaults to one row"`
+	Gi    float32  `json:",omitempty" desc:"optional inhibition for the layer (Layer.Inhib.Layer.Gi) -- 0 uses the params of the sim"`
+}
+
+// Constraint is a symmetric constraint between two units
+type Constraint struct {
+	A  string  `desc:"first unit, as Group.Unit, Unit if unique, or Group.* for all units in the group"`
+	B  string  `desc:"second unit, as Group.Unit, Unit if unique, or Group.* for all units in the group"`
+	Wt float32 `desc:"strength of the constraint, from -1 to 1: positive values are excitatory, negative ones inhibitory"`
+}
+
+// Input is an external input to a unit, in a named input pattern
+type Input struct {
+	Pat  string  `desc:"name of the input pattern, which is one testing trial"`
+	Unit string  `desc:"unit, as Group.Unit, Unit if unique, or Group.* for all units in the group"`
+	Val  float32 `desc:"value of the input"`
+}
+
+// Net is the description of a constraint-satisfaction network
+type Net struct {
+	Name        string        `desc:"name of the network"`
+	Groups      []*Group      `desc:"groups of units, each of which becomes a layer"`
+	Constraints []*Constraint `desc:"symmetric constraints between units"`
+	Inputs      []*Input      `desc:"external inputs, organized into named patterns"`
+
+	pw map[prjnKey]map[synKey]float32 // weights of the projections, from the constraints, as of ConfigNet
+}
+
+// unitRef is a resolved reference to a unit: group and unit indexes
+type unitRef struct {
+	gi, ui int
+}
+
+// Open opens the network description from given file, in JSON format
+// for files ending in .json and in the text format otherwise, and
+// validates it.
+func Open(fname string) (*Net, error) {
+	fp, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	cn := &Net{}
+	if strings.ToLower(filepath.Ext(fname)) == ".json" {
+		err = json.NewDecoder(fp).Decode(cn)
+	} else {
+		err = cn.ReadText(fp)
+	}
+	if err == nil {
+		err = cn.Validate()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("csnet: %s: %v", fname, err)
+	}
+	if cn.Name == "" {
+		cn.Name = strings.TrimSuffix(filepath.Base(fname), filepath.Ext(fname))
+	}
+	return cn, nil
+}
+
+// ReadText reads the description in the text format (see package docs),
+// adding to any existing contents
+func (cn *Net) ReadText(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	ln := 0
+	for sc.Scan() {
+		ln++
+		line := sc.Text()
+		if ci := strings.Index(line, "#"); ci >= 0 {
+			line = line[:ci]
+		}
+		fs := strings.Fields(line)
+		if len(fs) == 0 {
+			continue
+		}
+		if err := cn.readLine(fs); err != nil {
+			return fmt.Errorf("line %d: %v", ln, err)
+		}
+	}
+	return sc.Err()
+}
+
+// readLine reads one line of the text format, split into fields
+func (cn *Net) readLine(fs []string) error {
+	args := fs[1:]
+	switch fs[0] {
+	case "net":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: net <name>")
+		}
+		cn.Name = args[0]
+	case "group":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: group <name> <unit>...")
+		}
+		cn.Groups = append(cn.Groups, &Group{Name: args[0], Units: args[1:]})
+	case "shape":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: shape <group> <rows> <cols>")
+		}
+		gp := cn.GroupByName(args[0])
+		if gp == nil {
+			return fmt.Errorf("group not found: %s", args[0])
+		}
+		y, err1 := strconv.Atoi(args[1])
+		x, err2 := strconv.Atoi(args[2])
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("shape must be two integers: %s %s", args[1], args[2])
+		}
+		gp.Shape = []int{y, x}
+	case "gi":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: gi <group> <gi>")
+		}
+		gp := cn.GroupByName(args[0])
+		if gp == nil {
+			return fmt.Errorf("group not found: %s", args[0])
+		}
+		gi, err := strconv.ParseFloat(args[1], 32)
+		if err != nil {
+			return fmt.Errorf("gi is not a number: %s", args[1])
+		}
+		gp.Gi = float32(gi)
+	case "constraint":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: constraint <unit> <unit> <wt>")
+		}
+		wt, err := strconv.ParseFloat(args[2], 32)
+		if err != nil {
+			return fmt.Errorf("weight is not a number: %s", args[2])
+		}
+		cn.Constraints = append(cn.Constraints, &Constraint{A: args[0], B: args[1], Wt: float32(wt)})
+	case "input":
+		if len(args) != 2 && len(args) != 3 {
+			return fmt.Errorf("usage: input <pattern> <unit> [val]")
+		}
+		val := 1.0
+		if len(args) == 3 {
+			var err error
+			val, err = strconv.ParseFloat(args[2], 32)
+			if err != nil {
+				return fmt.Errorf("input value is not a number: %s", args[2])
+			}
+		}
+		cn.Inputs = append(cn.Inputs, &Input{Pat: args[0], Unit: args[1], Val: float32(val)})
+	default:
+		return fmt.Errorf("unknown keyword: %s (must be net, group, shape, gi, constraint or input)", fs[0])
+	}
+	return nil
+}
+
+// GroupByName returns the group of given name, or nil if not found
+func (cn *Net) GroupByName(name string) *Group {
+	for _, gp := range cn.Groups {
+		if gp.Name == name {
+			return gp
+		}
+	}
+	return nil
+}
+
+// GroupNames returns the names of all the groups, which are also the
+// names of the layers
+func (cn *Net) GroupNames() []string {
+	nms := make([]string, len(cn.Groups))
+	for i, gp := range cn.Groups {
+		nms[i] = gp.Name
+	}
+	return nms
+}
+
+// LayShape returns the 2D shape of the layer for the group
+func (gp *Group) LayShape() []int {
+	if len(gp.Shape) == 2 {
+		return gp.Shape
+	}
+	return []int{1, len(gp.Units)}
+}
+
+// UnitIdx returns the index of the unit of given name, or -1 if not found
+func (gp *Group) UnitIdx(unit string) int {
+	for i, u := range gp.Units {
+		if u == unit {
+			return i
+		}
+	}
+	return -1
+}
+
+// resolve returns the units referred to by given reference: Group.Unit,
+// Unit if it is unique across all groups, or Group.* for all units in
+// the group
+func (cn *Net) resolve(ref string) ([]unitRef, error) {
+	if di := strings.LastIndex(ref, "."); di >= 0 {
+		gnm, unm := ref[:di], ref[di+1:]
+		gi := -1
+		for i, gp := range cn.Groups {
+			if gp.Name == gnm {
+				gi = i
+				break
+			}
+		}
+		if gi < 0 {
+			return nil, fmt.Errorf("group not found: %s", ref)
+		}
+		gp := cn.Groups[gi]
+		if unm == "*" {
+			urs := make([]unitRef, len(gp.Units))
+			for ui := range gp.Units {
+				urs[ui] = unitRef{gi, ui}
+			}
+			return urs, nil
+		}
+		ui := gp.UnitIdx(unm)
+		if ui < 0 {
+			return nil, fmt.Errorf("unit not found: %s", ref)
+		}
+		return []unitRef{{gi, ui}}, nil
+	}
+	var urs []unitRef
+	for gi, gp := range cn.Groups {
+		if ui := gp.UnitIdx(ref); ui >= 0 {
+			urs = append(urs, unitRef{gi, ui})
+		}
+	}
+	switch len(urs) {
+	case 0:
+		return nil, fmt.Errorf("unit not found: %s", ref)
+	case 1:
+		return urs, nil
+	}
+	return nil, fmt.Errorf("unit name is not unique, use Group.Unit: %s", ref)
+}
+
+// Validate checks the description for errors, returning all of the
+// problems found, one per line
+func (cn *Net) Validate() error {
+	var errs []string
+	if len(cn.Groups) == 0 {
+		errs = append(errs, "no groups")
+	}
+	gnms := map[string]bool{}
+	for _, gp := range cn.Groups {
+		if gp.Name == "" || strings.Contains(gp.Name, ".") {
+			errs = append(errs, fmt.Sprintf("invalid group name: %q", gp.Name))
+		}
+		if gnms[gp.Name] {
+			errs = append(errs, fmt.Sprintf("duplicate group: %s", gp.Name))
+		}
+		gnms[gp.Name] = true
+		if len(gp.Units) == 0 {
+			errs = append(errs, fmt.Sprintf("group %s has no units", gp.Name))
+		}
+		unms := map[string]bool{}
+		for _, u := range gp.Units {
+			if u == "" || u == "*" || strings.Contains(u, ".") {
+				errs = append(errs, fmt.Sprintf("invalid unit name in group %s: %q", gp.Name, u))
+			}
+			if unms[u] {
+				errs = append(errs, fmt.Sprintf("duplicate unit in group %s: %s", gp.Name, u))
+			}
+			unms[u] = true
+		}
+		if gp.Shape != nil && (len(gp.Shape) != 2 || gp.Shape[0]*gp.Shape[1] != len(gp.Units)) {
+			errs = append(errs, fmt.Sprintf("shape of group %s: %v does not fit its %d units", gp.Name, gp.Shape, len(gp.Units)))
+		}
+	}
+	for _, cs := range cn.Constraints {
+		for _, ref := range []string{cs.A, cs.B} {
+			if _, err := cn.resolve(ref); err != nil {
+				errs = append(errs, "constraint: "+err.Error())
+			}
+		}
+		if cs.Wt < -1 || cs.Wt > 1 {
+			errs = append(errs, fmt.Sprintf("constraint %s %s: weight must be between -1 and 1: %g", cs.A, cs.B, cs.Wt))
+		}
+	}
+	for _, in := range cn.Inputs {
+		if _, err := cn.resolve(in.Unit); err != nil {
+			errs = append(errs, "input: "+err.Error())
+		}
+		if in.Pat == "" {
+			errs = append(errs, fmt.Sprintf("input %s: no pattern name", in.Unit))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// prjnKey identifies a projection: sending and receiving group, and
+// whether it is inhibitory (for negative constraints)
+type prjnKey struct {
+	send, recv int
+	neg        bool
+}
+
+// synKey identifies a synapse within a projection
+type synKey struct {
+	si, ri int
+}
+
+// wts returns the weights of all the projections implied by the
+// constraints, which are symmetric.  A later constraint between the
+// same two units replaces an earlier one.
+func (cn *Net) wts() map[prjnKey]map[synKey]float32 {
+	pw := map[prjnKey]map[synKey]float32{}
+	set := func(s, r unitRef, wt float32) {
+		for _, neg := range []bool{false, true} {
+			if pm, ok := pw[prjnKey{s.gi, r.gi, neg}]; ok {
+				delete(pm, synKey{s.ui, r.ui})
+			}
+		}
+		pk := prjnKey{s.gi, r.gi, wt < 0}
+		if wt < 0 {
+			wt = -wt
+		}
+		pm, ok := pw[pk]
+		if !ok {
+			pm = map[synKey]float32{}
+			pw[pk] = pm
+		}
+		pm[synKey{s.ui, r.ui}] = wt
+	}
+	for _, cs := range cn.Constraints {
+		as, _ := cn.resolve(cs.A)
+		bs, _ := cn.resolve(cs.B)
+		for _, a := range as {
+			for _, b := range bs {
+				if a == b {
+					continue
+				}
+				set(a, b, cs.Wt)
+				set(b, a, cs.Wt)
+			}
+		}
+	}
+	return pw
+}
+
+// weights returns the weights of the projections as of ConfigNet
+func (cn *Net) weights() map[prjnKey]map[synKey]float32 {
+	if cn.pw == nil {
+		cn.pw = cn.wts()
+	}
+	return cn.pw
+}
+
+// ConfigNet configures the network from the description: a layer for
+// each group, and a projection for each pair of groups with any positive
+// or negative constraints between them.  All layers are of type
+// emer.Input, so that any of them can receive external inputs.
+// The network must then be built, and SetWts called after InitWts.
+func (cn *Net) ConfigNet(net *leabra.Network) {
+	net.InitName(net, cn.Name)
+	lays := make([]emer.Layer, len(cn.Groups))
+	for gi, gp := range cn.Groups {
+		shp := gp.LayShape()
+		lays[gi] = net.AddLayer2D(gp.Name, shp[0], shp[1], emer.Input)
+	}
+	full := prjn.NewFull()
+	cn.pw = cn.wts()
+	pw := cn.pw
+	for _, neg := range []bool{false, true} {
+		for ri := range cn.Groups {
+			for si := range cn.Groups {
+				if _, ok := pw[prjnKey{si, ri, neg}]; !ok {
+					continue
+				}
+				typ := emer.Forward
+				switch {
+				case neg:
+					typ = emer.Inhib
+				case si == ri:
+					typ = emer.Lateral
+				case si > ri:
+					typ = emer.Back
+				}
+				pj := net.ConnectLayers(lays[si], lays[ri], full, typ)
+				if neg {
+					pj.SetClass("Neg")
+				}
+			}
+		}
+	}
+}
+
+// SetParams sets the inhibition of the layers for groups with Gi set.
+// It must be called after the params of the sim are applied.
+func (cn *Net) SetParams(net *leabra.Network) {
+	for _, gp := range cn.Groups {
+		if gp.Gi <= 0 {
+			continue
+		}
+		ly := net.LayerByName(gp.Name).(leabra.LeabraLayer).AsLeabra()
+		ly.Inhib.Layer.Gi = gp.Gi
+	}
+}
+
+// SetWts sets the weights of the network configured by ConfigNet from
+// the constraints, with all other weights 0.  It must be called after
+// InitWts, and returns an error if the network does not match.
+func (cn *Net) SetWts(net *leabra.Network) error {
+	pw := cn.weights()
+	for ri, gp := range cn.Groups {
+		ly, err := net.LayerByNameTry(gp.Name)
+		if err != nil {
+			return err
+		}
+		for _, p := range ly.(leabra.LeabraLayer).AsLeabra().RcvPrjns {
+			pj := p.(leabra.LeabraPrjn).AsLeabra()
+			si := cn.groupIdx(pj.Send.Name())
+			pm := pw[prjnKey{si, ri, pj.Typ == emer.Inhib}]
+			pj.SetWtsFunc(func(si, ri int, send, recv *etensor.Shape) float32 {
+				return pm[synKey{si, ri}]
+			})
+		}
+	}
+	return nil
+}
+
+// groupIdx returns the index of the group of given name, or -1
+func (cn *Net) groupIdx(name string) int {
+	for gi, gp := range cn.Groups {
+		if gp.Name == name {
+			return gi
+		}
+	}
+	return -1
+}
+
+// PatNames returns the names of the input patterns, in order of first use
+func (cn *Net) PatNames() []string {
+	var nms []string
+	has := map[string]bool{}
+	for _, in := range cn.Inputs {
+		if !has[in.Pat] {
+			has[in.Pat] = true
+			nms = append(nms, in.Pat)
+		}
+	}
+	return nms
+}
+
+// ConfigPats configures the table with the input patterns, one row per
+// pattern with its name in the Name column, and a column for each group,
+// in the format used by env.FixedTable.  If there are no inputs, there
+// is one pattern named None with no input.
+func (cn *Net) ConfigPats(dt *etable.Table) {
+	sch := etable.Schema{
+		{"Name", etensor.STRING, nil, nil},
+	}
+	for _, gp := range cn.Groups {
+		sch = append(sch, etable.Column{gp.Name, etensor.FLOAT32, gp.LayShape(), []string{"Y", "X"}})
+	}
+	pnms := cn.PatNames()
+	if len(pnms) == 0 {
+		pnms = []string{"None"}
+	}
+	dt.SetFromSchema(sch, len(pnms))
+	for i := 1; i < len(dt.Cols); i++ {
+		dt.Cols[i].SetMetaData("grid-fill", "0.9")
+	}
+	for row, pnm := range pnms {
+		dt.SetCellString("Name", row, pnm)
+	}
+	for _, in := range cn.Inputs {
+		row := 0
+		for i, pnm := range pnms {
+			if pnm == in.Pat {
+				row = i
+				break
+			}
+		}
+		urs, _ := cn.resolve(in.Unit)
+		for _, ur := range urs {
+			tsr := dt.CellTensor(cn.Groups[ur.gi].Name, row).(*etensor.Float32)
+			tsr.Values[ur.ui] = in.Val
+		}
+	}
+}
+
+// Labels returns labels for the units in each group, with the names of
+// the units separated by spaces, for netview.ConfigLabels
+func (cn *Net) Labels() []string {
+	labs := make([]string, len(cn.Groups))
+	for gi, gp := range cn.Groups {
+		labs[gi] = " " + strings.Join(gp.Units, " ")
+	}
+	return labs
+}
+
+// Harmony computes the harmony of the current activation state of the
+// network: the sum over all constraints of the weight times the
+// activations of the two units, plus the sum over all units of the
+// external input times the activation, divided by the number of units
+func (cn *Net) Harmony(net *leabra.Network) float32 {
+	nlays := make([]*leabra.Layer, len(cn.Groups))
+	nu := 0
+	harm := float32(0)
+	for gi, gp := range cn.Groups {
+		ly := net.LayerByName(gp.Name).(leabra.LeabraLayer).AsLeabra()
+		nlays[gi] = ly
+		for ni := range ly.Neurons {
+			nrn := &ly.Neurons[ni]
+			harm += nrn.Ext * nrn.Act
+			nu++
+		}
+	}
+	for pk, pm := range cn.weights() {
+		sl, rl := nlays[pk.send], nlays[pk.recv]
+		sgn := float32(0.5) // each constraint is in both directions
+		if pk.neg {
+			sgn = -0.5
+		}
+		for sk, wt := range pm {
+			harm += sgn * wt * sl.Neurons[sk.si].Act * rl.Neurons[sk.ri].Act
+		}
+	}
+	if nu > 0 {
+		harm /= float32(nu)
+	}
+	return harm
+}
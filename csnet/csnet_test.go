@@ -0,0 +1,294 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csnet
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/emer/emergent/emer"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+)
+
+const testText = `
+net Triangle                  # name of the network
+group Shape tri sq circ
+group Sides three four none
+gi Shape 1.8
+shape Sides 1 3
+constraint Shape.tri Sides.three 1
+constraint tri four -0.5      # unique unit names
+input Three Sides.three
+input Any Shape.* 0.5
+input Three Shape.sq 0.25
+`
+
+func testNet(t *testing.T) *Net {
+	cn := &Net{}
+	if err := cn.ReadText(strings.NewReader(testText)); err != nil {
+		t.Fatal(err)
+	}
+	if err := cn.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return cn
+}
+
+func TestReadText(t *testing.T) {
+	cn := testNet(t)
+	want := &Net{
+		Name: "Triangle",
+		Groups: []*Group{
+			{Name: "Shape", Units: []string{"tri", "sq", "circ"}, Gi: 1.8},
+			{Name: "Sides", Units: []string{"three", "four", "none"}, Shape: []int{1, 3}},
+		},
+		Constraints: []*Constraint{
+			{A: "Shape.tri", B: "Sides.three", Wt: 1},
+			{A: "tri", B: "four", Wt: -0.5},
+		},
+		Inputs: []*Input{
+			{Pat: "Three", Unit: "Sides.three", Val: 1},
+			{Pat: "Any", Unit: "Shape.*", Val: 0.5},
+			{Pat: "Three", Unit: "Shape.sq", Val: 0.25},
+		},
+	}
+	if !reflect.DeepEqual(cn, want) {
+		got, _ := json.Marshal(cn)
+		exp, _ := json.Marshal(want)
+		t.Errorf("got:\n%s\nwant:\n%s", got, exp)
+	}
+	if nms := cn.GroupNames(); !reflect.DeepEqual(nms, []string{"Shape", "Sides"}) {
+		t.Errorf("GroupNames: %v", nms)
+	}
+	if nms := cn.PatNames(); !reflect.DeepEqual(nms, []string{"Three", "Any"}) {
+		t.Errorf("PatNames: %v", nms)
+	}
+	if labs := cn.Labels(); !reflect.DeepEqual(labs, []string{" tri sq circ", " three four none"}) {
+		t.Errorf("Labels: %q", labs)
+	}
+}
+
+func TestReadTextErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"net", "line 1: usage: net"},
+		{"group G", "line 1: usage: group"},
+		{"group G a b\nshape H 1 2", "line 2: group not found: H"},
+		{"group G a b\nshape G 1 x", "line 2: shape must be two integers"},
+		{"group G a b\n\n# comment\ngi G high", "line 4: gi is not a number"},
+		{"constraint a b", "line 1: usage: constraint"},
+		{"constraint a b strong", "line 1: weight is not a number"},
+		{"input P", "line 1: usage: input"},
+		{"input P G.a on", "line 1: input value is not a number"},
+		{"unit a", "line 1: unknown keyword: unit"},
+	}
+	for _, tt := range tests {
+		cn := &Net{}
+		err := cn.ReadText(strings.NewReader(tt.text))
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("%q: err = %v, want %q", tt.text, err, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"net Empty", []string{"no groups"}},
+		{"group A.B x", []string{"invalid group name"}},
+		{"group G a\ngroup G b", []string{"duplicate group: G"}},
+		{"group G a a *", []string{"duplicate unit in group G: a", "invalid unit name in group G"}},
+		{"group G a b c\nshape G 2 2", []string{"shape of group G"}},
+		{"group G a b\ngroup H a\nconstraint a b 1", []string{"constraint: unit name is not unique"}},
+		{"group G a b\nconstraint G.a H.b 1\nconstraint a c 1", []string{"constraint: group not found: H.b", "constraint: unit not found: c"}},
+		{"group G a b\nconstraint a b 1.5", []string{"weight must be between -1 and 1"}},
+		{"group G a b\ninput P G.c", []string{"input: unit not found: G.c"}},
+	}
+	for _, tt := range tests {
+		cn := &Net{}
+		if err := cn.ReadText(strings.NewReader(tt.text)); err != nil {
+			t.Errorf("%q: %v", tt.text, err)
+			continue
+		}
+		err := cn.Validate()
+		if err == nil {
+			t.Errorf("%q: expected error", tt.text)
+			continue
+		}
+		lines := strings.Split(err.Error(), "\n")
+		if len(lines) != len(tt.want) {
+			t.Errorf("%q: got %d errors, want %d: %v", tt.text, len(lines), len(tt.want), err)
+			continue
+		}
+		for i, w := range tt.want {
+			if !strings.Contains(lines[i], w) {
+				t.Errorf("%q: error %d = %q, want %q", tt.text, i, lines[i], w)
+			}
+		}
+	}
+	cn := &Net{Groups: []*Group{{Name: "G", Units: []string{"a"}}}, Inputs: []*Input{{Unit: "a", Val: 1}}}
+	if err := cn.Validate(); err == nil || !strings.Contains(err.Error(), "no pattern name") {
+		t.Errorf("input without pattern: err = %v", err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	for _, fn := range []string{"../ch3/cats_dogs/cats_dogs.csn", "../ch3/necker_cube/necker_cube.csn"} {
+		cn, err := Open(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := json.MarshalIndent(cn, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		jfn := filepath.Join(t.TempDir(), "net.json")
+		if err := os.WriteFile(jfn, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+		jn, err := Open(jfn)
+		if err != nil {
+			t.Fatalf("%s: %v", fn, err)
+		}
+		if !reflect.DeepEqual(jn, cn) {
+			t.Errorf("%s: JSON round trip differs:\n%s", fn, b)
+		}
+	}
+
+	// the name defaults to the file name
+	fn := filepath.Join(t.TempDir(), "tri.csn")
+	if err := os.WriteFile(fn, []byte(strings.Replace(testText, "net Triangle", "", 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cn, err := Open(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cn.Name != "tri" {
+		t.Errorf("default name: %q", cn.Name)
+	}
+
+	if err := os.WriteFile(fn, []byte("group G a\nconstraint a b 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(fn); err == nil || !strings.Contains(err.Error(), "unit not found: b") {
+		t.Errorf("invalid net: err = %v", err)
+	}
+	if _, err := Open(filepath.Join(t.TempDir(), "none.csn")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestWts(t *testing.T) {
+	cn := &Net{}
+	text := `
+group G a b c
+group H x
+constraint G.* G.* 0.5
+constraint a b -1      # replaces the positive weight
+constraint c x 0.25
+`
+	if err := cn.ReadText(strings.NewReader(text)); err != nil {
+		t.Fatal(err)
+	}
+	pw := cn.wts()
+	want := map[prjnKey]map[synKey]float32{
+		{0, 0, false}: {{0, 2}: 0.5, {2, 0}: 0.5, {1, 2}: 0.5, {2, 1}: 0.5},
+		{0, 0, true}:  {{0, 1}: 1, {1, 0}: 1},
+		{0, 1, false}: {{2, 0}: 0.25},
+		{1, 0, false}: {{0, 2}: 0.25},
+	}
+	if !reflect.DeepEqual(pw, want) {
+		t.Errorf("wts:\n%v\nwant:\n%v", pw, want)
+	}
+}
+
+func TestConfigPats(t *testing.T) {
+	cn := testNet(t)
+	dt := &etable.Table{}
+	cn.ConfigPats(dt)
+	if dt.Rows != 2 {
+		t.Fatalf("%d rows, want 2", dt.Rows)
+	}
+	want := map[string][][]float32{
+		"Shape": {{0, 0.25, 0}, {0.5, 0.5, 0.5}},
+		"Sides": {{1, 0, 0}, {0, 0, 0}},
+	}
+	for row, pnm := range []string{"Three", "Any"} {
+		if nm := dt.CellString("Name", row); nm != pnm {
+			t.Errorf("row %d name: %s, want %s", row, nm, pnm)
+		}
+		for _, gnm := range cn.GroupNames() {
+			tsr := dt.CellTensor(gnm, row).(*etensor.Float32)
+			if !reflect.DeepEqual(tsr.Shapes(), []int{1, 3}) {
+				t.Errorf("%s shape: %v", gnm, tsr.Shapes())
+			}
+			if !reflect.DeepEqual(tsr.Values, want[gnm][row]) {
+				t.Errorf("%s row %d: %v, want %v", gnm, row, tsr.Values, want[gnm][row])
+			}
+		}
+	}
+
+	cn.Inputs = nil
+	cn.ConfigPats(dt)
+	if dt.Rows != 1 || dt.CellString("Name", 0) != "None" {
+		t.Errorf("no inputs: %d rows, name %s", dt.Rows, dt.CellString("Name", 0))
+	}
+}
+
+func TestConfigNet(t *testing.T) {
+	cn := testNet(t)
+	net := &leabra.Network{}
+	cn.ConfigNet(net)
+	net.Defaults()
+	cn.SetParams(net)
+	if err := net.Build(); err != nil {
+		t.Fatal(err)
+	}
+	net.InitWts()
+	if err := cn.SetWts(net); err != nil {
+		t.Fatal(err)
+	}
+	if net.Nm != "Triangle" || net.NLayers() != 2 {
+		t.Fatalf("network %s with %d layers", net.Nm, net.NLayers())
+	}
+	shape := net.LayerByName("Shape").(leabra.LeabraLayer).AsLeabra()
+	sides := net.LayerByName("Sides").(leabra.LeabraLayer).AsLeabra()
+	if shape.Inhib.Layer.Gi != 1.8 {
+		t.Errorf("Shape Gi: %g", shape.Inhib.Layer.Gi)
+	}
+	if len(sides.RcvPrjns) != 2 {
+		t.Fatalf("Sides has %d receiving prjns, want 2", len(sides.RcvPrjns))
+	}
+	for _, p := range sides.RcvPrjns {
+		pj := p.(leabra.LeabraPrjn).AsLeabra()
+		neg := pj.Typ == emer.Inhib
+		if neg != (pj.Class() == "Neg") {
+			t.Errorf("prjn %s: type %v, class %s", pj.Name(), pj.Typ, pj.Class())
+		}
+		// tri -> three is 1, tri -> four is -0.5
+		wts := [][]float32{{1, 0, 0}, {0, 0, 0}}
+		if neg {
+			wts = [][]float32{{0, 0.5, 0}, {0, 0, 0}}
+		}
+		for si := 0; si < 2; si++ {
+			for ri, w := range wts[si] {
+				if wt := pj.SynVal("Wt", si, ri); wt != w {
+					t.Errorf("prjn %s: wt %d -> %d = %g, want %g", pj.Name(), si, ri, wt, w)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,397 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package pairpats generates paired-associate pattern lists, such as the AB and
+AC lists used in the abac and hip sims, with controlled sparsity, overlap
+between items, shared-cue structure and the length of each list, for running
+capacity and interference studies.
+
+Each list is named by two letters, for the cue and associate items,
+e.g., AB and AC: lists that share a letter share the same items in that role,
+so AB and AC have the same A cues paired with different associates, while
+a list such as DE has all novel items (e.g., for lures).  Each list also has
+its own context, which can drift from one item to the next.
+
+The patterns are built from pools of units using the emergent patgen
+vocabulary functions, and the tables have a $Name column and a column
+for each layer, in the same format as the .tsv pattern files used by the
+sims, so they can be saved with SaveTables and opened with the
+Open Pats action in the sims (see patfile).
+*/
+package pairpats
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/emer/emergent/patgen"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/etable/metric"
+	"github.com/goki/gi/gi"
+)
+
+// Col specifies one column of the generated tables, i.e., the patterns
+// for one layer, as a list of pools each holding one part of the pattern
+type Col struct {
+	Name  string `desc:"name of the column, which must be the name of the layer that it is applied to"`
+	Shape []int  `desc:"shape of the layer: 2D (Y, X) for a single pool, or 4D (PoolsY, PoolsX, Y, X) for multiple pools"`
+	Pools string `desc:"what goes in each pool, in order, one char per pool: c = cue item, a = associate item, x = list context, - = empty"`
+	Test  bool   `desc:"if true, the associate pools are left empty in the testing tables, for cued recall"`
+}
+
+// NPools returns the number of pools in the column
+func (cl *Col) NPools() int {
+	if len(cl.Shape) == 4 {
+		return cl.Shape[0] * cl.Shape[1]
+	}
+	return 1
+}
+
+// PoolShape returns the Y, X shape of each pool
+func (cl *Col) PoolShape() (y, x int) {
+	n := len(cl.Shape)
+	return cl.Shape[n-2], cl.Shape[n-1]
+}
+
+// Pool returns the tensor for given pool within given cell tensor
+func (cl *Col) Pool(cell etensor.Tensor, pi int) etensor.Tensor {
+	if len(cl.Shape) == 4 {
+		return cell.SubSpace([]int{pi / cl.Shape[1], pi % cl.Shape[1]})
+	}
+	return cell
+}
+
+// Params are the parameters for generating paired-associate lists
+type Params struct {
+	Lists     []string `desc:"names of the lists, each two letters naming the cue and associate items, e.g., AB, AC -- lists that share a letter share the same items in that role, and distinct letters get novel items"`
+	ListSize  int      `min:"1" desc:"number of pairs in each list, unless given in ListSizes"`
+	ListSizes []int    `desc:"if set, the number of pairs in each list, in the same order as Lists (one size per list) -- items shared across lists (e.g., the A cues) are generated for the largest list that uses them, and smaller lists use the first ones"`
+	PctAct    float32  `min:"0" max:"1" desc:"proportion of units active in each pool (sparsity)"`
+	MinDiff   float32  `min:"0" max:"1" desc:"minimum proportion of the active units in a pool that must differ between any two items (or list contexts) -- i.e., 1 - MinDiff is the maximum pairwise overlap"`
+	Overlap   float32  `min:"0" max:"1" desc:"shifts the distribution of pairwise overlaps between items upward, setting its minimum: the proportion of the active units in each item pool that are shared by all the items in that role (e.g., all the A cues), with the rest chosen at random -- 0 = independent random items, with only chance overlap -- must be <= 1 - MinDiff"`
+	CtxtDrift float32  `min:"0" max:"1" desc:"proportion of the active context units that change from one item to the next within a list -- 0 = the same context for all items in a list"`
+	Cols      []Col    `desc:"the columns (layers) of the patterns"`
+}
+
+// HipDefaults sets the parameters for patterns like those of the hip sim,
+// with 3 pools each for the A and B items and 6 of drifting list context,
+// in 4D Input and ECout layers, where the testing Input has no B item.
+func (pp *Params) HipDefaults() {
+	pp.Lists = []string{"AB", "AC"}
+	pp.ListSize = 10
+	pp.PctAct = 0.25
+	pp.MinDiff = 0.5
+	pp.CtxtDrift = 0.3
+	pp.Cols = []Col{
+		{Name: "Input", Shape: []int{6, 2, 3, 4}, Pools: "cccaaaxxxxxx", Test: true},
+		{Name: "ECout", Shape: []int{6, 2, 3, 4}, Pools: "cccaaaxxxxxx"},
+	}
+}
+
+// ABACDefaults sets the parameters for patterns like those of the abac sim,
+// with separate 5x5 Input, Context and Output layers.
+func (pp *Params) ABACDefaults() {
+	pp.Lists = []string{"AB", "AC"}
+	pp.ListSize = 10
+	pp.PctAct = 0.4
+	pp.MinDiff = 0.5
+	pp.CtxtDrift = 0.2
+	pp.Cols = []Col{
+		{Name: "Input", Shape: []int{5, 5}, Pools: "c"},
+		{Name: "Context", Shape: []int{5, 5}, Pools: "x"},
+		{Name: "Output", Shape: []int{5, 5}, Pools: "a"},
+	}
+}
+
+// Validate returns an error if the parameters are not valid
+func (pp *Params) Validate() error {
+	if len(pp.Lists) == 0 {
+		return fmt.Errorf("pairpats: no Lists")
+	}
+	for _, ls := range pp.Lists {
+		if len(ls) != 2 {
+			return fmt.Errorf("pairpats: list name %q must be two letters, for the cue and associate items", ls)
+		}
+	}
+	if len(pp.ListSizes) > 0 {
+		if len(pp.ListSizes) != len(pp.Lists) {
+			return fmt.Errorf("pairpats: ListSizes must have one size for each of the %d Lists", len(pp.Lists))
+		}
+		for li, sz := range pp.ListSizes {
+			if sz <= 0 {
+				return fmt.Errorf("pairpats: ListSizes for list %s must be > 0", pp.Lists[li])
+			}
+		}
+	} else if pp.ListSize <= 0 {
+		return fmt.Errorf("pairpats: ListSize must be > 0")
+	}
+	if pp.PctAct <= 0 || pp.PctAct > 1 {
+		return fmt.Errorf("pairpats: PctAct must be > 0 and <= 1")
+	}
+	if pp.Overlap < 0 || pp.Overlap > 1-pp.MinDiff {
+		return fmt.Errorf("pairpats: Overlap must be >= 0 and <= 1 - MinDiff")
+	}
+	if len(pp.Cols) == 0 {
+		return fmt.Errorf("pairpats: no Cols")
+	}
+	for ci := range pp.Cols {
+		cl := &pp.Cols[ci]
+		if len(cl.Shape) != 2 && len(cl.Shape) != 4 {
+			return fmt.Errorf("pairpats: column %s: Shape must be 2D or 4D, not: %v", cl.Name, cl.Shape)
+		}
+		if len(cl.Pools) != cl.NPools() {
+			return fmt.Errorf("pairpats: column %s: Pools %q must have one char for each of the %d pools", cl.Name, cl.Pools, cl.NPools())
+		}
+		if strings.Trim(cl.Pools, "cax-") != "" {
+			return fmt.Errorf("pairpats: column %s: Pools %q must only have c, a, x or - chars", cl.Name, cl.Pools)
+		}
+	}
+	return nil
+}
+
+// Size returns the number of pairs in the list at given index in Lists
+func (pp *Params) Size(li int) int {
+	if len(pp.ListSizes) > 0 {
+		return pp.ListSizes[li]
+	}
+	return pp.ListSize
+}
+
+// itemSize returns the number of items to generate for the given item
+// letter: the size of the largest list that uses it
+func (pp *Params) itemSize(item byte) int {
+	n := 0
+	for li, ls := range pp.Lists {
+		if (ls[0] == item || ls[1] == item) && pp.Size(li) > n {
+			n = pp.Size(li)
+		}
+	}
+	return n
+}
+
+// vocabKey returns the vocabulary name for the given pool within a column:
+// the item letter (or ctxt) and the index of the pool among those of the
+// same part, so that the same part of the pattern is the same across columns.
+func (pp *Params) vocabKey(cl *Col, pi int, list string) string {
+	pt := cl.Pools[pi]
+	n := strings.Count(cl.Pools[:pi], string(pt))
+	switch pt {
+	case 'c':
+		return fmt.Sprintf("%c%d", list[0], n)
+	case 'a':
+		return fmt.Sprintf("%c%d", list[1], n)
+	case 'x':
+		return fmt.Sprintf("ctxt%d:%s", n, list)
+	}
+	return ""
+}
+
+// Vocab makes the vocabulary of pool patterns for all the items and list
+// contexts, with the required minimum differences between them.
+func (pp *Params) Vocab() (patgen.Vocab, error) {
+	mp := make(patgen.Vocab)
+	for ci := range pp.Cols {
+		cl := &pp.Cols[ci]
+		py, px := cl.PoolShape()
+		for pi := range cl.Pools {
+			for li, ls := range pp.Lists {
+				key := pp.vocabKey(cl, pi, ls)
+				if key == "" {
+					continue
+				}
+				if voc, has := mp[key]; has {
+					if voc.Dim(1) != py || voc.Dim(2) != px {
+						return nil, fmt.Errorf("pairpats: column %s: pool shape %v does not match the same pool of another column: %v", cl.Name, []int{py, px}, voc.Shapes()[1:])
+					}
+					continue
+				}
+				if cl.Pools[pi] != 'x' {
+					if err := pp.addItems(mp, key, pp.itemSize(key[0]), py, px); err != nil {
+						return nil, fmt.Errorf("pairpats: items %s: %v", key, err)
+					}
+					continue
+				}
+				// contexts are different for each list, and drift within it
+				base := strings.Split(key, ":")[0]
+				if _, has := mp[base]; !has {
+					if _, err := patgen.AddVocabPermutedBinary(mp, base, len(pp.Lists), py, px, pp.PctAct, pp.MinDiff); err != nil {
+						return nil, fmt.Errorf("pairpats: contexts %s: %v", base, err)
+					}
+				}
+				if _, err := patgen.AddVocabDrift(mp, key, pp.Size(li), pp.CtxtDrift, base, li); err != nil {
+					return nil, fmt.Errorf("pairpats: contexts %s: %v", key, err)
+				}
+			}
+		}
+	}
+	return mp, nil
+}
+
+// addItems adds the given number of item patterns to the vocabulary,
+// all sharing the Overlap proportion of their active units, and each
+// differing from all the others by at least MinDiff.
+func (pp *Params) addItems(mp patgen.Vocab, key string, rows, py, px int) error {
+	if pp.Overlap <= 0 {
+		_, err := patgen.AddVocabPermutedBinary(mp, key, rows, py, px, pp.PctAct, pp.MinDiff)
+		return err
+	}
+	nOn := patgen.NFmPct(pp.PctAct, py*px)
+	nCore := patgen.NFmPct(pp.Overlap, nOn)
+	minDist := float32(2 * patgen.NFmPct(pp.MinDiff, nOn)) // hamming distance counts each differing unit twice
+	core := etensor.NewFloat32([]int{py, px}, nil, nil)
+	patgen.PermutedBinary(core, nCore, 1, 0)
+	tsr := etensor.NewFloat32([]int{rows, py, px}, nil, []string{"row", "Y", "X"})
+	mp[key] = tsr
+	iters := 100
+	for rw := 0; rw < rows; rw++ {
+		trow := tsr.SubSpace([]int{rw})
+		itr := 0
+		for ; itr < iters; itr++ {
+			trow.CopyFrom(core)
+			patgen.FlipBits(trow, 0, nOn-nCore, 1, 0)
+			if mn, _ := patgen.RowVsPrevDist32(tsr, rw, metric.Hamming32); rw == 0 || mn >= minDist {
+				break
+			}
+		}
+		if itr == iters {
+			return fmt.Errorf("minimum difference of: %g was not met with Overlap: %g for item: %d -- reduce one of them", pp.MinDiff, pp.Overlap, rw)
+		}
+	}
+	return nil
+}
+
+// Generate generates a training table for each of the Lists, named Train
+// plus the list name, e.g., TrainAB.  If any of the Cols are marked as Test,
+// a testing table for each list is also returned, e.g., TestAB, which is
+// the same except without the associate items in those columns.
+// The rows are named with the lower-case list name and the item index,
+// e.g., ab_0.
+func (pp *Params) Generate() (train, test []*etable.Table, err error) {
+	if err := pp.Validate(); err != nil {
+		return nil, nil, err
+	}
+	mp, err := pp.Vocab()
+	if err != nil {
+		return nil, nil, err
+	}
+	hasTest := false
+	sc := etable.Schema{{"Name", etensor.STRING, nil, nil}}
+	for _, cl := range pp.Cols {
+		sc = append(sc, etable.Column{cl.Name, etensor.FLOAT32, cl.Shape, nil})
+		hasTest = hasTest || cl.Test
+	}
+	for li, ls := range pp.Lists {
+		dt := etable.NewTable("Train" + ls)
+		dt.SetMetaData("desc", ls+" paired associate training patterns")
+		dt.SetFromSchema(sc, pp.Size(li))
+		pp.setPats(dt, mp, ls, false)
+		train = append(train, dt)
+		if !hasTest {
+			continue
+		}
+		tt := etable.NewTable("Test" + ls)
+		tt.SetMetaData("desc", ls+" paired associate testing patterns")
+		tt.SetFromSchema(sc, pp.Size(li))
+		pp.setPats(tt, mp, ls, true)
+		test = append(test, tt)
+	}
+	return train, test, nil
+}
+
+// setPats sets the patterns for given list in the table
+func (pp *Params) setPats(dt *etable.Table, mp patgen.Vocab, list string, test bool) {
+	for row := 0; row < dt.Rows; row++ {
+		dt.SetCellString("Name", row, fmt.Sprintf("%s_%d", strings.ToLower(list), row))
+		for ci := range pp.Cols {
+			cl := &pp.Cols[ci]
+			cell := dt.CellTensor(cl.Name, row)
+			for pi := range cl.Pools {
+				key := pp.vocabKey(cl, pi, list)
+				if key == "" || (test && cl.Test && cl.Pools[pi] == 'a') {
+					continue
+				}
+				cl.Pool(cell, pi).CopyFrom(mp[key].SubSpace([]int{row}))
+			}
+		}
+	}
+	for _, cl := range dt.Cols[1:] {
+		cl.SetMetaData("grid-fill", "0.9")
+	}
+}
+
+// Overlaps returns the overlap between the patterns in each pair of rows
+// of the given column: the number of units active in both, normalized by
+// the geometric mean of the number active in each (i.e., the cosine).
+func Overlaps(dt *etable.Table, col string) []float64 {
+	var ovs []float64
+	for r1 := 0; r1 < dt.Rows; r1++ {
+		p1 := dt.CellTensor(col, r1)
+		for r2 := r1 + 1; r2 < dt.Rows; r2++ {
+			p2 := dt.CellTensor(col, r2)
+			n1, n2, nb := 0.0, 0.0, 0.0
+			for i := 0; i < p1.Len(); i++ {
+				a1 := p1.FloatVal1D(i) > 0
+				a2 := p2.FloatVal1D(i) > 0
+				if a1 {
+					n1++
+				}
+				if a2 {
+					n2++
+				}
+				if a1 && a2 {
+					nb++
+				}
+			}
+			if n1 > 0 && n2 > 0 {
+				ovs = append(ovs, nb/math.Sqrt(n1*n2))
+			} else {
+				ovs = append(ovs, 0)
+			}
+		}
+	}
+	return ovs
+}
+
+// OverlapStats returns the min, mean and max of the Overlaps between the
+// patterns of given column
+func OverlapStats(dt *etable.Table, col string) (min, mean, max float64) {
+	ovs := Overlaps(dt, col)
+	if len(ovs) == 0 {
+		return
+	}
+	min = ovs[0]
+	max = ovs[0]
+	for _, ov := range ovs {
+		mean += ov
+		min = math.Min(min, ov)
+		max = math.Max(max, ov)
+	}
+	mean /= float64(len(ovs))
+	return
+}
+
+// SaveTables saves the tables in the given directory, as .tsv files named
+// by the lower-case table name, e.g., trainab.tsv, with the headers needed
+// to open them in the sims.
+func SaveTables(dts []*etable.Table, dir string) error {
+	for _, dt := range dts {
+		fnm := filepath.Join(dir, strings.ToLower(dt.MetaData["name"])+".tsv")
+		if err := dt.SaveCSV(gi.FileName(fnm), etable.Tab, etable.Headers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetTable replaces the contents of table dt with those of nt, keeping the
+// metadata of dt (name, desc), so that existing views of dt show the new
+// patterns.
+func SetTable(dt, nt *etable.Table) {
+	nt.CopyMetaDataFrom(dt)
+	*dt = *nt
+}
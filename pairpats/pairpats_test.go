@@ -0,0 +1,201 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pairpats
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+)
+
+// nOn returns the number of active units in given pool of given row
+func nOn(dt *etable.Table, cl *Col, row, pi int) int {
+	pool := cl.Pool(dt.CellTensor(cl.Name, row), pi)
+	n := 0
+	for i := 0; i < pool.Len(); i++ {
+		if pool.FloatVal1D(i) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// samePool returns true if given pool is the same in the two rows
+func samePool(dt1, dt2 *etable.Table, cl *Col, row, pi int) bool {
+	p1 := cl.Pool(dt1.CellTensor(cl.Name, row), pi)
+	p2 := cl.Pool(dt2.CellTensor(cl.Name, row), pi)
+	return reflect.DeepEqual(p1.(*etensor.Float32).Values, p2.(*etensor.Float32).Values)
+}
+
+func TestGenerateShape(t *testing.T) {
+	rand.Seed(1)
+	pp := &Params{}
+	pp.HipDefaults()
+	pp.Lists = append(pp.Lists, "DE")
+	pp.ListSizes = []int{8, 10, 4}
+	train, test, err := pp.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(train) != 3 || len(test) != 3 {
+		t.Fatalf("got %d train and %d test tables, want 3 each", len(train), len(test))
+	}
+	for li, ls := range pp.Lists {
+		for _, dt := range []*etable.Table{train[li], test[li]} {
+			if dt.Rows != pp.ListSizes[li] {
+				t.Errorf("%s: %d rows, want %d", dt.MetaData["name"], dt.Rows, pp.ListSizes[li])
+			}
+			if dt.CellString("Name", 0) != fmt.Sprintf("%s_0", []string{"ab", "ac", "de"}[li]) {
+				t.Errorf("%s: first row name: %s", dt.MetaData["name"], dt.CellString("Name", 0))
+			}
+			for ci := range pp.Cols {
+				cl := &pp.Cols[ci]
+				if shp := dt.ColByName(cl.Name).Shapes()[1:]; !reflect.DeepEqual(shp, cl.Shape) {
+					t.Errorf("%s: column %s shape %v, want %v", dt.MetaData["name"], cl.Name, shp, cl.Shape)
+				}
+			}
+		}
+		if train[li].MetaData["name"] != "Train"+ls || test[li].MetaData["name"] != "Test"+ls {
+			t.Errorf("table names: %s %s", train[li].MetaData["name"], test[li].MetaData["name"])
+		}
+	}
+
+	in := &pp.Cols[0]
+	py, px := in.PoolShape()
+	want := int(float32(py*px)*pp.PctAct + 0.5)
+	for row := 0; row < train[0].Rows; row++ {
+		for pi, pt := range in.Pools {
+			n := nOn(train[0], in, row, pi)
+			if n != want {
+				t.Errorf("AB row %d pool %d (%c): %d active, want %d", row, pi, pt, n, want)
+			}
+			if tn := nOn(test[0], in, row, pi); pt == 'a' && tn != 0 {
+				t.Errorf("AB test row %d pool %d: associate not empty", row, pi)
+			} else if pt != 'a' && tn != n {
+				t.Errorf("AB test row %d pool %d: %d active, want %d", row, pi, tn, n)
+			}
+			// AB and AC share the cues, and differ in associates and contexts
+			same := samePool(train[0], train[1], in, row, pi)
+			if (pt == 'c') != same {
+				t.Errorf("AB vs AC row %d pool %d (%c): same = %v", row, pi, pt, same)
+			}
+			// Input and ECout have the same items
+			if !reflect.DeepEqual(in.Pool(train[0].CellTensor("Input", row), pi).(*etensor.Float32).Values,
+				in.Pool(train[0].CellTensor("ECout", row), pi).(*etensor.Float32).Values) {
+				t.Errorf("AB row %d pool %d: Input and ECout differ", row, pi)
+			}
+		}
+	}
+	// the extra AC rows still have the full cue items
+	for row := 8; row < 10; row++ {
+		if nOn(train[1], in, row, 0) != want {
+			t.Errorf("AC row %d: cue not set", row)
+		}
+	}
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	gen := func(seed int64) []*etable.Table {
+		rand.Seed(seed)
+		pp := &Params{}
+		pp.ABACDefaults()
+		pp.MinDiff = 0.3
+		pp.Overlap = 0.2
+		train, _, err := pp.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return train
+	}
+	vals := func(dts []*etable.Table) [][]float32 {
+		var vs [][]float32
+		for _, dt := range dts {
+			for _, cl := range dt.Cols[1:] {
+				vs = append(vs, cl.(*etensor.Float32).Values)
+			}
+		}
+		return vs
+	}
+	t1 := vals(gen(42))
+	t2 := vals(gen(42))
+	if !reflect.DeepEqual(t1, t2) {
+		t.Error("same seed generated different patterns")
+	}
+	if reflect.DeepEqual(t1, vals(gen(43))) {
+		t.Error("different seeds generated the same patterns")
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	rand.Seed(2)
+	stats := func(ovl float32) (min, mean, max float64) {
+		pp := &Params{}
+		pp.ABACDefaults()
+		pp.ListSize = 8
+		pp.MinDiff = 0.3
+		pp.Overlap = ovl
+		train, _, err := pp.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		min, mean, max = OverlapStats(train[0], "Input")
+		if max > float64(1-pp.MinDiff)+1.0e-6 {
+			t.Errorf("Overlap %g: max overlap %g exceeds 1 - MinDiff", ovl, max)
+		}
+		return
+	}
+	_, mean0, _ := stats(0)
+	mn, mean3, _ := stats(0.3)
+	if mean3 <= mean0 {
+		t.Errorf("mean overlap with Overlap .3: %g is not above independent items: %g", mean3, mean0)
+	}
+	if mn < 0.3-1.0e-6 {
+		t.Errorf("min overlap with Overlap .3: %g is below .3", mn)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(pp *Params)
+	}{
+		{"no lists", func(pp *Params) { pp.Lists = nil }},
+		{"list name", func(pp *Params) { pp.Lists = []string{"ABC"} }},
+		{"list size", func(pp *Params) { pp.ListSize = 0 }},
+		{"list sizes len", func(pp *Params) { pp.ListSizes = []int{10} }},
+		{"list sizes val", func(pp *Params) { pp.ListSizes = []int{10, 0} }},
+		{"pct act", func(pp *Params) { pp.PctAct = 0 }},
+		{"overlap", func(pp *Params) { pp.Overlap = 0.6 }},
+		{"no cols", func(pp *Params) { pp.Cols = nil }},
+		{"shape", func(pp *Params) { pp.Cols[0].Shape = []int{25} }},
+		{"pools", func(pp *Params) { pp.Cols[0].Pools = "cc" }},
+		{"pool chars", func(pp *Params) { pp.Cols[0].Pools = "z" }},
+	}
+	pp := &Params{}
+	pp.ABACDefaults()
+	if err := pp.Validate(); err != nil {
+		t.Fatalf("defaults: %v", err)
+	}
+	pp.ListSize = 0
+	pp.ListSizes = []int{4, 6}
+	if err := pp.Validate(); err != nil {
+		t.Errorf("ListSizes without ListSize: %v", err)
+	}
+	for _, tt := range tests {
+		pp := &Params{}
+		pp.ABACDefaults()
+		tt.set(pp)
+		if err := pp.Validate(); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+		if _, _, err := pp.Generate(); err == nil {
+			t.Errorf("%s: expected Generate error", tt.name)
+		}
+	}
+}
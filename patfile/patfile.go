@@ -0,0 +1,168 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package patfile opens user-supplied pattern files for the sims that use
+an env.FixedTable, validating the columns against the layers of the
+network that the patterns are applied to.
+
+Pattern files use the same format as the .tsv files embedded in the sims:
+a header row with a $Name column for the pattern names, and a tensor column
+for each layer, e.g., %Input[2:0,0]<2:5,5> for a 5x5 Input layer.
+Files ending in .csv are comma-delimited, and all others are tab-delimited.
+*/
+package patfile
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/emer/emergent/emer"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/goki/gi/gi"
+)
+
+// Open opens patterns from given file, and validates them against the given
+// layers of the network (see Validate).  If valid, the contents of dt are
+// replaced with the new patterns, keeping the existing table metadata
+// (name, desc) -- otherwise dt is not changed and an error is returned
+// describing all of the problems found.
+func Open(dt *etable.Table, fname string, net emer.Network, lays ...string) error {
+	if _, err := os.Stat(fname); err != nil {
+		return err
+	}
+	delim := etable.Tab
+	if strings.ToLower(filepath.Ext(fname)) == ".csv" {
+		delim = etable.Comma
+	}
+	nt := &etable.Table{}
+	if err := nt.OpenCSV(gi.FileName(fname), delim); err != nil {
+		return fmt.Errorf("patfile: %s: %v", fname, err)
+	}
+	if err := Validate(nt, net, lays...); err != nil {
+		return fmt.Errorf("patfile: %s: patterns do not match network:\n%v", fname, err)
+	}
+	for _, cl := range nt.Cols {
+		if cl.DataType() != etensor.STRING {
+			cl.SetMetaData("grid-fill", "0.9")
+		}
+	}
+	nt.CopyMetaDataFrom(dt)
+	*dt = *nt
+	return nil
+}
+
+// Validate checks that the table has patterns, that any Name column
+// (used for the trial names) is a string column, and that there is
+// a column for each of the given layers, with a cell shape that fits
+// the layer in the way that leabra.Layer.ApplyExt applies it:
+// a 2D or 4D cell must have the same shape as the layer (or the 2D shape
+// of a 4D layer), and otherwise it must have the same number of units.
+// Returns all of the problems found, one per line.
+func Validate(dt *etable.Table, net emer.Network, lays ...string) error {
+	var errs []string
+	if dt.Rows == 0 {
+		errs = append(errs, "no patterns (rows) in table")
+	}
+	if nc, err := dt.ColByNameTry("Name"); err == nil && nc.DataType() != etensor.STRING && !hasLay(lays, "Name") {
+		errs = append(errs, "Name column is not a string column: use $Name as its header")
+	}
+	for _, lnm := range lays {
+		ly, err := net.LayerByNameTry(lnm)
+		if err != nil {
+			errs = append(errs, strings.TrimSpace(err.Error()))
+			continue
+		}
+		cl, err := dt.ColByNameTry(lnm)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("no column for layer %s, which has shape %v", lnm, ly.Shape().Shp))
+			continue
+		}
+		if err := CheckShape(cl, ly.Shape()); err != nil {
+			errs = append(errs, fmt.Sprintf("column %s: %v", lnm, err))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(errs, "\n"))
+}
+
+// ValidateTables validates each of the given tables (see Validate),
+// returning all of the problems found in all of them, one per line,
+// prefixed with the name of the table (from its name metadata).
+func ValidateTables(dts []*etable.Table, net emer.Network, lays ...string) error {
+	var errs []string
+	for _, dt := range dts {
+		err := Validate(dt, net, lays...)
+		if err == nil {
+			continue
+		}
+		for _, e := range strings.Split(err.Error(), "\n") {
+			errs = append(errs, dt.MetaData["name"]+": "+e)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(errs, "\n"))
+}
+
+func hasLay(lays []string, lnm string) bool {
+	for _, l := range lays {
+		if l == lnm {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckShape returns an error if the cells of given table column do not
+// fit a layer of the given shape -- see Validate for the rules.
+func CheckShape(cl etensor.Tensor, shp *etensor.Shape) error {
+	if cl.DataType() == etensor.STRING {
+		return fmt.Errorf("is a string column, not numeric patterns")
+	}
+	cs := cl.Shapes()[1:]
+	lnd := shp.NumDims()
+	switch {
+	case len(cs) == 0:
+		return fmt.Errorf("is a scalar column: use a tensor header of the layer shape, e.g., %%Input[2:0,0]<2:5,5> for a 5x5 layer")
+	case len(cs) == 2 && lnd == 4:
+		ny, nx, _, _ := etensor.Prjn2DShape(shp, false)
+		if cs[0] != ny || cs[1] != nx {
+			return fmt.Errorf("has cell shape %v but 4D layer has 2D shape %v", cs, []int{ny, nx})
+		}
+	case len(cs) == lnd && (lnd == 2 || lnd == 4):
+		for i := range cs {
+			if cs[i] != shp.Dim(i) {
+				return fmt.Errorf("has cell shape %v but layer has shape %v", cs, shp.Shp)
+			}
+		}
+	default:
+		n := 1
+		for _, d := range cs {
+			n *= d
+		}
+		if n != shp.Len() {
+			return fmt.Errorf("has %d values per pattern (shape %v) but layer has %d units (shape %v)", n, cs, shp.Len(), shp.Shp)
+		}
+	}
+	return nil
+}
+
+// Report reports an error from Open, in a dialog if there is a window
+// (i.e., running in the gui), and always in the log.
+func Report(win *gi.Window, err error) {
+	log.Println(err)
+	if win == nil {
+		return
+	}
+	gi.PromptDialog(win.WinViewport2D(), gi.DlgOpts{Title: "Invalid Patterns", Prompt: strings.ReplaceAll(err.Error(), "\n", "<br>")}, gi.AddOk, gi.NoCancel, nil, nil)
+}
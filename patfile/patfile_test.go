@@ -0,0 +1,239 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package patfile
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emer/emergent/emer"
+	"github.com/emer/etable/etable"
+	"github.com/emer/etable/etensor"
+	"github.com/emer/leabra/leabra"
+	"github.com/goki/gi/gi"
+)
+
+func testNet() *leabra.Network {
+	net := &leabra.Network{}
+	net.InitName(net, "Test")
+	net.AddLayer2D("Input", 5, 5, emer.Input)
+	net.AddLayer4D("Hidden", 2, 3, 2, 2, emer.Hidden)
+	net.AddLayer2D("Output", 2, 5, emer.Target)
+	return net
+}
+
+func testPats(rows int) *etable.Table {
+	dt := &etable.Table{}
+	dt.SetMetaData("name", "TestPats")
+	dt.SetMetaData("desc", "patterns for testing")
+	dt.SetFromSchema(etable.Schema{
+		{Name: "Name", Type: etensor.STRING},
+		{Name: "Input", Type: etensor.FLOAT32, CellShape: []int{5, 5}, DimNames: []string{"Y", "X"}},
+		{Name: "Output", Type: etensor.FLOAT32, CellShape: []int{2, 5}, DimNames: []string{"Y", "X"}},
+	}, rows)
+	in := dt.ColByName("Input").(*etensor.Float32)
+	out := dt.ColByName("Output").(*etensor.Float32)
+	for row := 0; row < rows; row++ {
+		dt.SetCellString("Name", row, fmt.Sprintf("pat%d", row))
+		for i := row; i < 25; i += rows + 1 {
+			in.Values[row*25+i] = 1
+		}
+		out.Values[row*10+row%10] = 1
+	}
+	return dt
+}
+
+// swapCols swaps the names of the two columns, so each has the other's shape
+func swapCols(dt *etable.Table, a, b string) *etable.Table {
+	ai, bi := dt.ColIdx(a), dt.ColIdx(b)
+	dt.ColNames[ai], dt.ColNames[bi] = dt.ColNames[bi], dt.ColNames[ai]
+	dt.UpdateColNameMap()
+	return dt
+}
+
+func TestOpen(t *testing.T) {
+	net := testNet()
+	src := testPats(4)
+	for _, ext := range []string{".tsv", ".csv"} {
+		fname := filepath.Join(t.TempDir(), "pats"+ext)
+		delim := etable.Tab
+		if ext == ".csv" {
+			delim = etable.Comma
+		}
+		if err := src.SaveCSV(gi.FileName(fname), delim, etable.Headers); err != nil {
+			t.Fatal(err)
+		}
+		dt := &etable.Table{}
+		dt.SetMetaData("name", "Loaded")
+		dt.SetMetaData("desc", "loaded patterns")
+		if err := Open(dt, fname, net, "Input", "Output"); err != nil {
+			t.Fatalf("%s: %v", ext, err)
+		}
+		if dt.Rows != src.Rows || len(dt.Cols) != len(src.Cols) {
+			t.Fatalf("%s: got %d rows %d cols, want %d rows %d cols", ext, dt.Rows, len(dt.Cols), src.Rows, len(src.Cols))
+		}
+		if dt.MetaData["name"] != "Loaded" || dt.MetaData["desc"] != "loaded patterns" {
+			t.Errorf("%s: table metadata not kept: %v", ext, dt.MetaData)
+		}
+		for ci, scl := range src.Cols {
+			nm := src.ColNames[ci]
+			cl, err := dt.ColByNameTry(nm)
+			if err != nil {
+				t.Errorf("%s: %v", ext, err)
+				continue
+			}
+			if cl.DataType() != scl.DataType() {
+				t.Errorf("%s: column %s type %v, want %v", ext, nm, cl.DataType(), scl.DataType())
+			}
+			if fmt.Sprint(cl.Shapes()) != fmt.Sprint(scl.Shapes()) {
+				t.Errorf("%s: column %s shape %v, want %v", ext, nm, cl.Shapes(), scl.Shapes())
+			}
+			for i := 0; i < scl.Len(); i++ {
+				if cl.StringVal1D(i) != scl.StringVal1D(i) {
+					t.Errorf("%s: column %s value %d = %s, want %s", ext, nm, i, cl.StringVal1D(i), scl.StringVal1D(i))
+					break
+				}
+			}
+			if nm != "Name" {
+				if gf, _ := cl.MetaData("grid-fill"); gf != "0.9" {
+					t.Errorf("%s: column %s grid-fill = %q", ext, nm, gf)
+				}
+			}
+		}
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	net := testNet()
+	fname := filepath.Join(t.TempDir(), "pats.tsv")
+	if err := testPats(2).SaveCSV(gi.FileName(fname), etable.Tab, etable.Headers); err != nil {
+		t.Fatal(err)
+	}
+	dt := testPats(3)
+	if err := Open(dt, fname, net, "Input", "Hidden"); err == nil {
+		t.Fatal("expected error for missing Hidden column")
+	}
+	if dt.Rows != 3 {
+		t.Errorf("table changed by invalid Open: %d rows", dt.Rows)
+	}
+	if err := Open(dt, filepath.Join(t.TempDir(), "none.tsv"), net, "Input"); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	net := testNet()
+	if err := Validate(testPats(2), net, "Input", "Output"); err != nil {
+		t.Errorf("valid patterns: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		dt   *etable.Table
+		lays []string
+		want []string
+	}{
+		{"no rows", testPats(0), []string{"Input"}, []string{"no patterns"}},
+		{"missing column", testPats(2), []string{"Input", "Hidden"}, []string{"no column for layer Hidden"}},
+		{"missing layer", testPats(2), []string{"Input", "Nope"}, []string{"Nope not found"}},
+		{"wrong shape", swapCols(testPats(2), "Input", "Output"), []string{"Output", "Input"}, []string{
+			"column Output: has cell shape [5 5] but layer has shape [2 5]",
+			"column Input: has cell shape [2 5] but layer has shape [5 5]",
+		}},
+		{"numeric name", etable.New(etable.Schema{
+			{Name: "Name", Type: etensor.INT64},
+			{Name: "Input", Type: etensor.FLOAT32, CellShape: []int{5, 5}},
+		}, 2), []string{"Input"}, []string{"Name column is not a string column"}},
+		{"scalar column", etable.New(etable.Schema{
+			{Name: "Name", Type: etensor.STRING},
+			{Name: "Input", Type: etensor.FLOAT32},
+		}, 2), []string{"Input"}, []string{"column Input: is a scalar column"}},
+		{"several", etable.New(etable.Schema{
+			{Name: "Input", Type: etensor.STRING},
+			{Name: "Output", Type: etensor.FLOAT32, CellShape: []int{5, 2}},
+		}, 1), []string{"Input", "Output", "Hidden"}, []string{
+			"column Input: is a string column",
+			"column Output: has cell shape [5 2] but layer has shape [2 5]",
+			"no column for layer Hidden",
+		}},
+	}
+	for _, tt := range tests {
+		err := Validate(tt.dt, net, tt.lays...)
+		if err == nil {
+			t.Errorf("%s: expected error", tt.name)
+			continue
+		}
+		lines := strings.Split(err.Error(), "\n")
+		if len(lines) != len(tt.want) {
+			t.Errorf("%s: got %d errors, want %d: %v", tt.name, len(lines), len(tt.want), err)
+			continue
+		}
+		for i, w := range tt.want {
+			if !strings.Contains(lines[i], w) {
+				t.Errorf("%s: error %d = %q, want %q", tt.name, i, lines[i], w)
+			}
+		}
+	}
+}
+
+func TestCheckShape(t *testing.T) {
+	shp4 := etensor.NewShape([]int{2, 3, 2, 2}, nil, nil)
+	shp2 := etensor.NewShape([]int{2, 5}, nil, nil)
+	tests := []struct {
+		name string
+		cell []int
+		shp  *etensor.Shape
+		ok   bool
+	}{
+		{"2D same", []int{2, 5}, shp2, true},
+		{"2D transposed", []int{5, 2}, shp2, false},
+		{"1D same size", []int{10}, shp2, true},
+		{"1D wrong size", []int{9}, shp2, false},
+		{"4D same", []int{2, 3, 2, 2}, shp4, true},
+		{"4D different", []int{3, 2, 2, 2}, shp4, false},
+		{"4D as 2D", []int{4, 6}, shp4, true},
+		{"4D as wrong 2D", []int{6, 4}, shp4, false},
+		{"3D same size", []int{6, 2, 2}, shp4, true},
+	}
+	for _, tt := range tests {
+		cl := etensor.NewFloat32(append([]int{1}, tt.cell...), nil, nil)
+		err := CheckShape(cl, tt.shp)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: cell %v layer %v: err = %v", tt.name, tt.cell, tt.shp.Shp, err)
+		}
+	}
+}
+
+func TestValidateTables(t *testing.T) {
+	net := testNet()
+	good := testPats(2)
+	if err := ValidateTables([]*etable.Table{good, testPats(3)}, net, "Input", "Output"); err != nil {
+		t.Errorf("valid tables: %v", err)
+	}
+	empty := testPats(0)
+	empty.SetMetaData("name", "Empty")
+	bad := swapCols(testPats(2), "Input", "Output")
+	bad.SetMetaData("name", "Swapped")
+	err := ValidateTables([]*etable.Table{good, empty, bad}, net, "Input", "Output")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	want := []string{
+		"Empty: no patterns",
+		"Swapped: column Input: has cell shape [2 5]",
+		"Swapped: column Output: has cell shape [5 5]",
+	}
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("got %d errors, want %d: %v", len(lines), len(want), err)
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(lines[i], w) {
+			t.Errorf("error %d = %q, want %q", i, lines[i], w)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package progress reports the progress of long runs without the gui:
+a one-line display in the terminal with the current counters (from the
+sim's Counters method), the percent done and estimated time remaining,
+and the latest epoch-level stats (e.g., PctErr, SSE, CosDiff), which is
+updated in place every Interval.  When the output is not a terminal (e.g.,
+redirected to a file) a full line is printed every LogInterval instead.
+
+Optionally, the same information is written to stderr as a stream of
+JSON objects, one per line, for use by other tools that run the sims:
+
+	{"sim":"hip","event":"progress","elapsed":12.5,"frac":0.25,"eta":37.5,
+	 "counters":{"Run":0,"Epoch":5,"Trial":3,...},"stats":{"PctErr":0.2,...}}
+
+with event "start" at the start and "done" at the end.
+*/
+package progress
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emer/etable/etable"
+)
+
+// Progress has the parameters and state for reporting progress
+type Progress struct {
+	Sim         string        `desc:"name of the sim, recorded in the JSON stream"`
+	On          bool          `desc:"show the progress display in the terminal"`
+	JSON        bool          `desc:"write progress as JSON lines to stderr"`
+	Interval    time.Duration `desc:"minimum time between updates of the terminal display, and JSON lines"`
+	LogInterval time.Duration `desc:"minimum time between lines when the output is not a terminal"`
+	Stats       []string      `desc:"names of the columns in the epoch log to report, if present"`
+
+	Out    io.Writer `view:"-" desc:"where the display goes -- os.Stdout by default"`
+	Err    io.Writer `view:"-" desc:"where the JSON lines go -- os.Stderr by default"`
+	tty    bool
+	start  time.Time
+	last   time.Time
+	lastLn time.Time
+	lnLen  int
+}
+
+// Defaults sets default parameters for given sim name
+func (pr *Progress) Defaults(sim string) {
+	pr.Sim = sim
+	pr.On = true
+	pr.Interval = time.Second
+	pr.LogInterval = 30 * time.Second
+	pr.Stats = []string{"PctErr", "SSE", "CosDiff"}
+}
+
+// AddFlags adds the command-line flags: -progress turns the terminal
+// display on or off, and -progressjson turns on the JSON lines on stderr
+func (pr *Progress) AddFlags() {
+	flag.BoolVar(&pr.On, "progress", pr.On, "if true, show a progress display with counters, time remaining and latest stats")
+	flag.BoolVar(&pr.JSON, "progressjson", pr.JSON, "if true, write progress to stderr as JSON lines, for use by other tools")
+}
+
+// Start starts the timer for a new run of the sim
+func (pr *Progress) Start() {
+	if pr.Out == nil {
+		pr.Out = os.Stdout
+	}
+	if pr.Err == nil {
+		pr.Err = os.Stderr
+	}
+	pr.tty = false
+	if f, ok := pr.Out.(*os.File); ok {
+		if fi, err := f.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+			pr.tty = true
+		}
+	}
+	pr.start = time.Now()
+	pr.last = time.Time{}
+	pr.lastLn = pr.start
+	pr.lnLen = 0
+	pr.writeJSON("start", 0, "", nil)
+}
+
+// Due returns true if it is time for another Update -- use this to avoid
+// the cost of computing the counters etc when not needed
+func (pr *Progress) Due() bool {
+	if !pr.On && !pr.JSON {
+		return false
+	}
+	return time.Since(pr.last) >= pr.Interval
+}
+
+// Update updates the progress display with given counters string (as
+// returned by the sim's Counters method), fraction of the run done (0-1),
+// and the latest stats from the last row of given epoch log table (can be nil).
+func (pr *Progress) Update(ctrs string, frac float64, dt *etable.Table) {
+	now := time.Now()
+	pr.last = now
+	stats := pr.LastStats(dt)
+	if pr.JSON {
+		pr.writeJSON("progress", frac, ctrs, stats)
+	}
+	if !pr.On {
+		return
+	}
+	if !pr.tty {
+		if now.Sub(pr.lastLn) < pr.LogInterval {
+			return
+		}
+		pr.lastLn = now
+		fmt.Fprintln(pr.Out, pr.Line(ctrs, frac, stats))
+		return
+	}
+	ln := pr.Line(ctrs, frac, stats)
+	pad := pr.lnLen - len(ln)
+	pr.lnLen = len(ln)
+	if pad < 0 {
+		pad = 0
+	}
+	fmt.Fprintf(pr.Out, "\r%s%s", ln, strings.Repeat(" ", pad))
+}
+
+// Done finishes the progress display
+func (pr *Progress) Done() {
+	if pr.On && pr.tty && pr.lnLen > 0 {
+		fmt.Fprintln(pr.Out)
+	}
+	pr.lnLen = 0
+	if pr.JSON {
+		pr.writeJSON("done", 1, "", nil)
+	}
+}
+
+// Line returns the one-line text display of the progress
+func (pr *Progress) Line(ctrs string, frac float64, stats map[string]float64) string {
+	var b strings.Builder
+	b.WriteString(strings.Join(strings.Fields(ctrs), " "))
+	el := time.Since(pr.start)
+	fmt.Fprintf(&b, "  |  %.1f%%  %s", 100*frac, Dur(el))
+	if frac > 0 && frac < 1 {
+		fmt.Fprintf(&b, "  ETA %s", Dur(ETA(el, frac)))
+	}
+	if len(stats) > 0 {
+		b.WriteString("  |")
+		for _, nm := range pr.Stats {
+			if v, ok := stats[nm]; ok {
+				fmt.Fprintf(&b, "  %s %.4g", nm, v)
+			}
+		}
+	}
+	return b.String()
+}
+
+// LastStats returns the Stats values from the last row of given table,
+// for those that are present and are not NaN or Inf
+func (pr *Progress) LastStats(dt *etable.Table) map[string]float64 {
+	if dt == nil || dt.Rows == 0 {
+		return nil
+	}
+	stats := make(map[string]float64)
+	for _, nm := range pr.Stats {
+		if _, err := dt.ColByNameTry(nm); err != nil {
+			continue
+		}
+		v := dt.CellFloat(nm, dt.Rows-1)
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		stats[nm] = v
+	}
+	return stats
+}
+
+// Frac returns the fraction of the total run done, given the current
+// run, epoch and trial counters and their maximums (each starting at 0).
+// A trial maximum of 0 is ignored.
+func Frac(run, nRuns, epc, nEpcs, trl, nTrls int) float64 {
+	if nRuns <= 0 || nEpcs <= 0 {
+		return 0
+	}
+	done := float64(run*nEpcs + epc)
+	if nTrls > 0 {
+		done += float64(trl) / float64(nTrls)
+	}
+	frac := done / float64(nRuns*nEpcs)
+	if frac > 1 {
+		frac = 1
+	}
+	return frac
+}
+
+// ETA returns the estimated time remaining, given the elapsed time and
+// fraction done
+func ETA(elapsed time.Duration, frac float64) time.Duration {
+	if frac <= 0 {
+		return 0
+	}
+	return time.Duration(float64(elapsed) * (1 - frac) / frac)
+}
+
+// Dur returns a compact string for a duration, rounded to seconds
+func Dur(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
+
+// Counters parses a counters string, as returned by the sim's Counters
+// method, in the form "Run:\t1\tEpoch:\t2\t...", into a map from counter
+// name to value, with numbers as int and others as string
+func Counters(ctrs string) map[string]interface{} {
+	cm := make(map[string]interface{})
+	fs := strings.Fields(ctrs)
+	for i := 0; i < len(fs); i++ {
+		if !strings.HasSuffix(fs[i], ":") {
+			continue
+		}
+		nm := strings.TrimSuffix(fs[i], ":")
+		if i+1 >= len(fs) || strings.HasSuffix(fs[i+1], ":") {
+			cm[nm] = ""
+			continue
+		}
+		i++
+		if n, err := strconv.Atoi(fs[i]); err == nil {
+			cm[nm] = n
+		} else {
+			cm[nm] = fs[i]
+		}
+	}
+	return cm
+}
+
+// jsonRec is one line of the JSON stream
+type jsonRec struct {
+	Sim      string                 `json:"sim"`
+	Event    string                 `json:"event"`
+	Time     string                 `json:"time"`
+	Elapsed  float64                `json:"elapsed"`
+	Frac     float64                `json:"frac"`
+	ETA      float64                `json:"eta"`
+	Counters map[string]interface{} `json:"counters,omitempty"`
+	Stats    map[string]float64     `json:"stats,omitempty"`
+}
+
+// writeJSON writes one JSON line for given event to Err
+func (pr *Progress) writeJSON(event string, frac float64, ctrs string, stats map[string]float64) {
+	if !pr.JSON || pr.Err == nil {
+		return
+	}
+	el := time.Since(pr.start)
+	rec := jsonRec{Sim: pr.Sim, Event: event, Time: time.Now().Format(time.RFC3339), Elapsed: el.Seconds(), Frac: frac, ETA: ETA(el, frac).Seconds(), Stats: stats}
+	if ctrs != "" {
+		rec.Counters = Counters(ctrs)
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	pr.Err.Write(append(b, '\n'))
+}
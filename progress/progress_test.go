@@ -0,0 +1,109 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package progress
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFrac(t *testing.T) {
+	tests := []struct {
+		name                               string
+		run, nRuns, epc, nEpcs, trl, nTrls int
+		frac                               float64
+	}{
+		{"start", 0, 2, 0, 10, 0, 4, 0},
+		{"first epoch", 0, 2, 0, 10, 2, 4, 0.025},
+		{"first run", 0, 2, 5, 10, 0, 4, 0.25},
+		{"second run", 1, 2, 5, 10, 2, 4, 0.775},
+		{"last trial", 1, 2, 9, 10, 3, 4, 0.9875},
+		{"end", 2, 2, 0, 10, 0, 4, 1},
+		{"past end", 3, 2, 4, 10, 0, 4, 1},
+		{"no trials", 1, 4, 3, 6, 5, 0, 9.0 / 24},
+		{"negative trials", 1, 4, 3, 6, 5, -1, 9.0 / 24},
+		{"zero runs", 1, 0, 3, 10, 0, 4, 0},
+		{"zero epochs", 1, 2, 3, 0, 0, 4, 0},
+		{"negative runs", 1, -2, 3, 10, 0, 4, 0},
+		{"negative epochs", 1, 2, 3, -10, 0, 4, 0},
+	}
+	for _, tt := range tests {
+		if frac := Frac(tt.run, tt.nRuns, tt.epc, tt.nEpcs, tt.trl, tt.nTrls); frac != tt.frac {
+			t.Errorf("%s: Frac = %g, want %g", tt.name, frac, tt.frac)
+		}
+	}
+}
+
+func TestETA(t *testing.T) {
+	tests := []struct {
+		elapsed time.Duration
+		frac    float64
+		eta     time.Duration
+		str     string
+	}{
+		{10 * time.Second, 0, 0, "0s"},
+		{10 * time.Second, -0.5, 0, "0s"},
+		{10 * time.Second, 0.5, 10 * time.Second, "10s"},
+		{30 * time.Second, 0.25, 90 * time.Second, "1m30s"},
+		{time.Hour, 0.8, 15 * time.Minute, "15m0s"},
+		{time.Minute, 0.01, 99 * time.Minute, "1h39m0s"},
+		{3 * time.Second, 0.4, 4500 * time.Millisecond, "5s"},
+		{time.Second, 0.75, 333333333 * time.Nanosecond, "0s"},
+		{time.Minute, 1, 0, "0s"},
+	}
+	for _, tt := range tests {
+		eta := ETA(tt.elapsed, tt.frac)
+		if d := eta - tt.eta; d < -time.Microsecond || d > time.Microsecond {
+			t.Errorf("ETA(%v, %g) = %v, want %v", tt.elapsed, tt.frac, eta, tt.eta)
+		}
+		if s := Dur(eta); s != tt.str {
+			t.Errorf("Dur(%v) = %q, want %q", eta, s, tt.str)
+		}
+	}
+}
+
+func TestCounters(t *testing.T) {
+	tests := []struct {
+		ctrs string
+		cm   map[string]interface{}
+	}{
+		{"", map[string]interface{}{}},
+		{"Run:\t1\tEpoch:\t12\tTrial:\t3\tCycle:\t70\tName:\tA_B\t\t\t",
+			map[string]interface{}{"Run": 1, "Epoch": 12, "Trial": 3, "Cycle": 70, "Name": "A_B"}},
+		{"Run:\t0\tEnv:\tAB\tTrial:\t-1\tName:\t",
+			map[string]interface{}{"Run": 0, "Env": "AB", "Trial": -1, "Name": ""}},
+		{"Run: 2 Name: Epoch: 4",
+			map[string]interface{}{"Run": 2, "Name": "", "Epoch": 4}},
+		{"stray Run: 5 words",
+			map[string]interface{}{"Run": 5}},
+	}
+	for _, tt := range tests {
+		if cm := Counters(tt.ctrs); !reflect.DeepEqual(cm, tt.cm) {
+			t.Errorf("Counters(%q) = %v, want %v", tt.ctrs, cm, tt.cm)
+		}
+	}
+}
+
+func TestLine(t *testing.T) {
+	pr := &Progress{}
+	pr.Defaults("test")
+	pr.start = time.Now().Add(-30 * time.Second)
+	tests := []struct {
+		frac  float64
+		stats map[string]float64
+		line  string
+	}{
+		{0, nil, "Run: 0 Epoch: 5  |  0.0%  30s"},
+		{0.25, nil, "Run: 0 Epoch: 5  |  25.0%  30s  ETA 1m30s"},
+		{0.5, map[string]float64{"SSE": 1.5, "PctErr": 0.25, "Other": 3}, "Run: 0 Epoch: 5  |  50.0%  30s  ETA 30s  |  PctErr 0.25  SSE 1.5"},
+		{1, map[string]float64{"CosDiff": 0.987654}, "Run: 0 Epoch: 5  |  100.0%  30s  |  CosDiff 0.9877"},
+	}
+	for _, tt := range tests {
+		if ln := pr.Line("Run:\t0\tEpoch:\t5\t", tt.frac, tt.stats); ln != tt.line {
+			t.Errorf("Line(%g) = %q, want %q", tt.frac, ln, tt.line)
+		}
+	}
+}
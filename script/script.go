@@ -0,0 +1,399 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package script provides a simple command-file interpreter for running
+a simulation protocol without writing any Go code, e.g.:
+
+	Init
+	Set MaxEpcs 50
+	SetEnv true; Train 10 epochs
+	TestAll
+	SaveWeights trained.wts
+
+Each line (or semicolon-separated segment of a line) is a command name
+followed by space-separated arguments (use double quotes for args with
+spaces).  Comments start with # or //.  Commands are the Sim methods
+listed in the SimProps CallMethods, plus the toolbar actions that each
+sim registers via AddFunc, AddMethod and AddSteps.  The builtin Set and
+Print commands access Sim fields by name (dotted paths into sub-structs
+are supported), and Help lists all available commands.
+*/
+package script
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goki/ki/ki"
+	"github.com/goki/ki/kit"
+)
+
+// Cmd is one command available to the script
+type Cmd struct {
+	Name string                    `desc:"name of the command, as used in the script"`
+	Desc string                    `desc:"description of the command, for Help"`
+	Func func(args []string) error `desc:"function that executes the command with given args"`
+}
+
+// Interp is the script interpreter, which maps command lines onto
+// methods and fields of the Sim.
+type Interp struct {
+	Sim  interface{}     `desc:"pointer to the Sim that commands operate on"`
+	Cmds map[string]*Cmd `desc:"all the available commands, by lower-case name"`
+	Out  io.Writer       `desc:"where Print and Help output goes -- os.Stdout by default"`
+}
+
+// New returns a new interpreter for given Sim (must be a pointer),
+// with the builtin Set, Print and Help commands
+func New(sim interface{}) *Interp {
+	in := &Interp{Sim: sim, Cmds: make(map[string]*Cmd), Out: os.Stdout}
+	in.AddFunc("Set", "set a Sim field to given value: Set <Field> <Value>", in.setCmd)
+	in.AddFunc("Print", "print the value of Sim field(s): Print <Field>...", in.printCmd)
+	in.AddFunc("Help", "list all available commands", in.helpCmd)
+	return in
+}
+
+// AddFunc adds a command that calls given function with the args
+func (in *Interp) AddFunc(name, desc string, fun func(args []string) error) {
+	in.Cmds[strings.ToLower(name)] = &Cmd{Name: name, Desc: desc, Func: fun}
+}
+
+// AddAction adds a command that takes no args and calls given function,
+// as is typical for toolbar actions
+func (in *Interp) AddAction(name, desc string, fun func()) {
+	in.AddFunc(name, desc, func(args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("takes no args, got: %v", args)
+		}
+		fun()
+		return nil
+	})
+}
+
+// AddSteps adds a command that runs given step functions a given number of
+// times, according to args of the form: N <unit>, e.g., "Train 10 epochs".
+// Units are the keys of the steps map, in singular form (plural is also accepted).
+// If no args are given, def is called instead, e.g., ss.Train to run to the end.
+func (in *Interp) AddSteps(name, desc string, def func(), steps map[string]func()) {
+	var units []string
+	for u := range steps {
+		units = append(units, u)
+	}
+	sort.Strings(units)
+	desc += " -- optional args: N " + strings.Join(units, "|")
+	in.AddFunc(name, desc, func(args []string) error {
+		if len(args) == 0 {
+			def()
+			return nil
+		}
+		if len(args) != 2 {
+			return fmt.Errorf("expected args: N %s, got: %v", strings.Join(units, "|"), args)
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid count: %s", args[0])
+		}
+		unit := strings.ToLower(args[1])
+		fun, ok := steps[unit]
+		if !ok {
+			fun, ok = steps[strings.TrimSuffix(unit, "s")]
+		}
+		if !ok {
+			return fmt.Errorf("invalid unit: %s -- must be one of: %s", args[1], strings.Join(units, "|"))
+		}
+		for i := 0; i < n; i++ {
+			fun()
+		}
+		return nil
+	})
+}
+
+// AddMethod adds a command that calls the Sim method of the given name,
+// converting the string args to the method's parameter types.
+func (in *Interp) AddMethod(name, desc string) error {
+	mth := reflect.ValueOf(in.Sim).MethodByName(name)
+	if !mth.IsValid() {
+		return fmt.Errorf("script.AddMethod: method %s not found on type %T", name, in.Sim)
+	}
+	in.AddFunc(name, desc, func(args []string) error {
+		rv, err := CallMethod(mth, args)
+		if err != nil {
+			return err
+		}
+		for _, r := range rv {
+			fmt.Fprintf(in.Out, "%s:\t%v\n", name, r.Interface())
+		}
+		return nil
+	})
+	return nil
+}
+
+// AddCallMethods adds all the methods listed in the CallMethods of
+// given props, which are typically the SimProps registered for the Sim type.
+// Returns an error for the last method that was not found, if any.
+func (in *Interp) AddCallMethods(props ki.Props) error {
+	cm, ok := props["CallMethods"].(ki.PropSlice)
+	if !ok {
+		return nil
+	}
+	var rerr error
+	for _, mp := range cm {
+		desc := ""
+		if pp, ok := mp.Value.(ki.Props); ok {
+			desc, _ = pp["desc"].(string)
+		}
+		if err := in.AddMethod(mp.Name, desc); err != nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
+
+// CallMethod calls given method value with string args converted to the
+// types of the method parameters.  Any error returned by the method is
+// returned as the error, and the other return values are returned.
+func CallMethod(mth reflect.Value, args []string) ([]reflect.Value, error) {
+	mt := mth.Type()
+	if len(args) != mt.NumIn() {
+		return nil, fmt.Errorf("expected %d args, got %d: %v", mt.NumIn(), len(args), args)
+	}
+	vals := make([]reflect.Value, len(args))
+	for i, a := range args {
+		v := reflect.New(mt.In(i))
+		if err := SetFromString(v, a); err != nil {
+			return nil, fmt.Errorf("arg %d: %v", i+1, err)
+		}
+		vals[i] = v.Elem()
+	}
+	var rv []reflect.Value
+	errType := reflect.TypeOf((*error)(nil)).Elem()
+	for _, r := range mth.Call(vals) {
+		if r.Type() == errType {
+			if !r.IsNil() {
+				return nil, r.Interface().(error)
+			}
+			continue
+		}
+		rv = append(rv, r)
+	}
+	return rv, nil
+}
+
+// SetFromString sets the value pointed to by ptr from given string,
+// handling enums registered with kit.Enums by their names.
+func SetFromString(ptr reflect.Value, str string) error {
+	typ := ptr.Elem().Type()
+	if kit.Enums.TypeRegistered(typ) {
+		return kit.Enums.SetAnyEnumValueFromString(ptr, str)
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		ptr.Elem().SetString(str)
+		return nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return fmt.Errorf("invalid bool: %s", str)
+		}
+		ptr.Elem().SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(str, 0, 64)
+		if err != nil {
+			return fmt.Errorf("invalid int: %s", str)
+		}
+		ptr.Elem().SetInt(i)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number: %s", str)
+		}
+		ptr.Elem().SetFloat(f)
+		return nil
+	}
+	if !kit.SetRobust(ptr.Interface(), str) {
+		return fmt.Errorf("cannot set value of type %v from: %s", typ, str)
+	}
+	return nil
+}
+
+// FieldByPath returns the Sim field at given path of field names,
+// separated by dots, e.g., TrainEnv.Epoch.Max
+func (in *Interp) FieldByPath(path string) (reflect.Value, error) {
+	v := reflect.ValueOf(in.Sim)
+	for _, fn := range strings.Split(path, ".") {
+		v = kit.NonPtrValue(v)
+		if v.Kind() != reflect.Struct {
+			return v, fmt.Errorf("field path %s: %s is not within a struct", path, fn)
+		}
+		v = v.FieldByName(fn)
+		if !v.IsValid() {
+			return v, fmt.Errorf("field path %s: field %s not found", path, fn)
+		}
+	}
+	return v, nil
+}
+
+func (in *Interp) setCmd(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("expected args: <Field> <Value>, got: %v", args)
+	}
+	fv, err := in.FieldByPath(args[0])
+	if err != nil {
+		return err
+	}
+	if !fv.CanSet() {
+		return fmt.Errorf("field %s cannot be set", args[0])
+	}
+	return SetFromString(fv.Addr(), args[1])
+}
+
+func (in *Interp) printCmd(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expected at least one field name")
+	}
+	for _, fn := range args {
+		fv, err := in.FieldByPath(fn)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(in.Out, "%s:\t%v\n", fn, fv.Interface())
+	}
+	return nil
+}
+
+func (in *Interp) helpCmd(args []string) error {
+	var nms []string
+	for nm := range in.Cmds {
+		nms = append(nms, nm)
+	}
+	sort.Strings(nms)
+	for _, nm := range nms {
+		cm := in.Cmds[nm]
+		fmt.Fprintf(in.Out, "%s\t%s\n", cm.Name, cm.Desc)
+	}
+	return nil
+}
+
+// Exec executes one command line, which has already been split into fields
+func (in *Interp) Exec(fields []string) error {
+	cm, ok := in.Cmds[strings.ToLower(fields[0])]
+	if !ok {
+		return fmt.Errorf("unknown command: %s", fields[0])
+	}
+	if err := cm.Func(fields[1:]); err != nil {
+		return fmt.Errorf("%s: %v", cm.Name, err)
+	}
+	return nil
+}
+
+// Run runs the script read from given reader, stopping at the first error,
+// which is reported with the given file name and line number.
+func (in *Interp) Run(r io.Reader, fname string) error {
+	scan := bufio.NewScanner(r)
+	ln := 0
+	for scan.Scan() {
+		ln++
+		cmds, err := SplitCmds(StripComment(scan.Text()))
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", fname, ln, err)
+		}
+		for _, fields := range cmds {
+			if err := in.Exec(fields); err != nil {
+				return fmt.Errorf("%s:%d: %v", fname, ln, err)
+			}
+		}
+	}
+	return scan.Err()
+}
+
+// RunFile runs the script in given file
+func (in *Interp) RunFile(fname string) error {
+	f, err := os.Open(fname)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return in.Run(f, fname)
+}
+
+// StripComment removes any # or // comment from the end of given line,
+// ignoring any comment chars within double quotes
+func StripComment(line string) string {
+	inq := false
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '"':
+			inq = !inq
+		case inq:
+		case line[i] == '#':
+			return line[:i]
+		case line[i] == '/' && i+1 < len(line) && line[i+1] == '/':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// SplitCmds splits given line into the commands separated by semicolons,
+// each split into fields as in SplitFields, ignoring semicolons within
+// double quotes.  Empty commands are skipped.
+func SplitCmds(line string) ([][]string, error) {
+	var cmds [][]string
+	st := 0
+	inq := false
+	for i := 0; i <= len(line); i++ {
+		if i < len(line) {
+			if line[i] == '"' {
+				inq = !inq
+			}
+			if inq || line[i] != ';' {
+				continue
+			}
+		}
+		fields, err := SplitFields(line[st:i])
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) > 0 {
+			cmds = append(cmds, fields)
+		}
+		st = i + 1
+	}
+	return cmds, nil
+}
+
+// SplitFields splits given command line into space-separated fields,
+// where double-quoted strings are kept as one field (with quotes removed)
+func SplitFields(line string) ([]string, error) {
+	var fields []string
+	line = strings.TrimSpace(line)
+	for len(line) > 0 {
+		if line[0] == '"' {
+			end := strings.IndexByte(line[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote: %s", line)
+			}
+			fields = append(fields, line[1:end+1])
+			line = strings.TrimSpace(line[end+2:])
+			continue
+		}
+		end := strings.IndexAny(line, " \t")
+		if end < 0 {
+			fields = append(fields, line)
+			break
+		}
+		fields = append(fields, line[:end])
+		line = strings.TrimSpace(line[end:])
+	}
+	return fields, nil
+}
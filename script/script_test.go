@@ -0,0 +1,239 @@
+// Copyright (c) 2019, The Emergent Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package script
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goki/ki/ki"
+)
+
+type testParams struct {
+	Lrate float32
+	On    bool
+}
+
+type testSim struct {
+	MaxEpcs int
+	Tag     string
+	Params  testParams
+	Trials  int
+	Epochs  int
+	Inits   int
+	Saved   string
+	Lesions []string
+}
+
+func (ts *testSim) Init() { ts.Inits++ }
+
+func (ts *testSim) SaveWeights(fname string) { ts.Saved = fname }
+
+func (ts *testSim) Lesion(lay string, prop float64) error {
+	if prop < 0 || prop > 1 {
+		return fmt.Errorf("proportion out of range: %g", prop)
+	}
+	ts.Lesions = append(ts.Lesions, fmt.Sprintf("%s %g", lay, prop))
+	return nil
+}
+
+func (ts *testSim) NLesions() int { return len(ts.Lesions) }
+
+var testProps = ki.Props{
+	"CallMethods": ki.PropSlice{
+		{Name: "SaveWeights", Value: ki.Props{
+			"desc": "save network weights to file",
+		}},
+		{Name: "Lesion", Value: ki.Props{
+			"desc": "lesion given layer",
+		}},
+		{Name: "NLesions", Value: ki.Props{}},
+	},
+}
+
+func newTestInterp(t *testing.T) (*testSim, *Interp, *bytes.Buffer) {
+	ts := &testSim{}
+	in := New(ts)
+	out := &bytes.Buffer{}
+	in.Out = out
+	in.AddAction("Init", "init", ts.Init)
+	in.AddSteps("Train", "train", func() { ts.Epochs = -1 }, map[string]func(){
+		"trial": func() { ts.Trials++ },
+		"epoch": func() { ts.Epochs++ },
+	})
+	if err := in.AddCallMethods(testProps); err != nil {
+		t.Fatal(err)
+	}
+	return ts, in, out
+}
+
+func TestSplitFields(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", nil},
+		{"   ", nil},
+		{"Init", []string{"Init"}},
+		{"  Train  10\tepochs ", []string{"Train", "10", "epochs"}},
+		{`Set Tag "a b  c"`, []string{"Set", "Tag", "a b  c"}},
+		{`Set Tag ""`, []string{"Set", "Tag", ""}},
+		{`"a b" c`, []string{"a b", "c"}},
+	}
+	for _, tt := range tests {
+		got, err := SplitFields(tt.line)
+		if err != nil {
+			t.Errorf("SplitFields(%q): unexpected error: %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitFields(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+	if _, err := SplitFields(`Set Tag "abc`); err == nil {
+		t.Errorf("SplitFields: expected error for unterminated quote")
+	}
+}
+
+func TestSplitCmds(t *testing.T) {
+	tests := []struct {
+		line string
+		want [][]string
+	}{
+		{"", nil},
+		{";;", nil},
+		{"Init", [][]string{{"Init"}}},
+		{"Init; Train 10 epochs", [][]string{{"Init"}, {"Train", "10", "epochs"}}},
+		{`Set Tag "a;b"; Init`, [][]string{{"Set", "Tag", "a;b"}, {"Init"}}},
+		{`Set Tag ";"`, [][]string{{"Set", "Tag", ";"}}},
+		{"Init ; ; TestAll;", [][]string{{"Init"}, {"TestAll"}}},
+	}
+	for _, tt := range tests {
+		got, err := SplitCmds(tt.line)
+		if err != nil {
+			t.Errorf("SplitCmds(%q): unexpected error: %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitCmds(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+	if _, err := SplitCmds(`Init; Set Tag "a;b`); err == nil {
+		t.Errorf("SplitCmds: expected error for unterminated quote")
+	}
+}
+
+func TestStripComment(t *testing.T) {
+	tests := []struct {
+		line, want string
+	}{
+		{"Init", "Init"},
+		{"Init # start over", "Init "},
+		{"Init // start over", "Init "},
+		{"# all comment", ""},
+		{`Set Tag "a # b" # tag`, `Set Tag "a # b" `},
+		{`Set Tag "a // b"`, `Set Tag "a // b"`},
+		{"Print a/b", "Print a/b"},
+	}
+	for _, tt := range tests {
+		if got := StripComment(tt.line); got != tt.want {
+			t.Errorf("StripComment(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestSetPrint(t *testing.T) {
+	ts, in, out := newTestInterp(t)
+	scr := `Set MaxEpcs 50
+Set Tag "run one; two"
+Set Params.Lrate 0.02; Set Params.On true
+Print MaxEpcs Tag Params.Lrate Params.On
+`
+	if err := in.Run(strings.NewReader(scr), "test.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if ts.MaxEpcs != 50 || ts.Tag != "run one; two" || ts.Params.Lrate != 0.02 || !ts.Params.On {
+		t.Errorf("fields not set: %+v", *ts)
+	}
+	want := "MaxEpcs:\t50\nTag:\trun one; two\nParams.Lrate:\t0.02\nParams.On:\ttrue\n"
+	if got := out.String(); got != want {
+		t.Errorf("Print output = %q, want %q", got, want)
+	}
+
+	for _, cmd := range []string{"Set NoField 1", "Set MaxEpcs abc", "Set Params.Lrate.X 1", "Set MaxEpcs", "Print"} {
+		if err := in.Run(strings.NewReader(cmd), "test.txt"); err == nil {
+			t.Errorf("%q: expected error", cmd)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	ts, in, out := newTestInterp(t)
+	scr := `# a protocol
+init
+Train 3 epochs; Train 2 trial
+SaveWeights "my weights.wts"   // quoted file name
+Lesion Hidden 0.5
+NLesions
+`
+	if err := in.Run(strings.NewReader(scr), "test.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if ts.Inits != 1 || ts.Epochs != 3 || ts.Trials != 2 {
+		t.Errorf("wrong counts: inits %d epochs %d trials %d", ts.Inits, ts.Epochs, ts.Trials)
+	}
+	if ts.Saved != "my weights.wts" {
+		t.Errorf("SaveWeights arg = %q", ts.Saved)
+	}
+	if !reflect.DeepEqual(ts.Lesions, []string{"Hidden 0.5"}) {
+		t.Errorf("Lesions = %q", ts.Lesions)
+	}
+	if got := out.String(); got != "NLesions:\t1\n" {
+		t.Errorf("NLesions output = %q", got)
+	}
+	if err := in.Run(strings.NewReader("Train"), "test.txt"); err != nil || ts.Epochs != -1 {
+		t.Errorf("Train with no args should call the default: %v", err)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		scr  string
+		want string
+	}{
+		{"Init\n\nFoo", "test.txt:3: unknown command: Foo"},
+		{"Init\nInit 1", "test.txt:2: Init: takes no args"},
+		{"Train 3 weeks", "test.txt:1: Train: invalid unit: weeks"},
+		{"Train x epochs", "test.txt:1: Train: invalid count: x"},
+		{"Init; Lesion Hidden 2", "test.txt:1: Lesion: proportion out of range: 2"},
+		{"Lesion Hidden", "test.txt:1: Lesion: expected 2 args"},
+		{`SaveWeights "x.wts`, "test.txt:1: unterminated quote"},
+	}
+	for _, tt := range tests {
+		_, in, _ := newTestInterp(t)
+		err := in.Run(strings.NewReader(tt.scr), "test.txt")
+		if err == nil {
+			t.Errorf("%q: expected error", tt.scr)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("%q: error = %q, want prefix %q", tt.scr, err.Error(), tt.want)
+		}
+	}
+}
+
+func TestAddMethodNotFound(t *testing.T) {
+	in := New(&testSim{})
+	in.Out = &bytes.Buffer{}
+	if err := in.AddMethod("NoSuchMethod", ""); err == nil {
+		t.Errorf("expected error for missing method")
+	}
+	if err := in.Exec([]string{"Help"}); err != nil {
+		t.Errorf("Help: %v", err)
+	}
+}